@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -20,10 +25,15 @@ import (
 	"github.com/roackb2/lucid/internal/pkg/agents/worker"
 	"github.com/roackb2/lucid/internal/pkg/control_plane"
 	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+	"github.com/roackb2/lucid/internal/pkg/ws"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// DefaultShutdownTimeout is used when server.shutdown_timeout is not configured
+const DefaultShutdownTimeout = 30 * time.Second
+
 // @title Lucid API
 // @version 1.0
 // @description This is the API for the Lucid project.
@@ -64,17 +74,63 @@ func main() {
 		panic(err)
 	}
 
-	tracker := control_plane.NewMemoryAgentTracker()
+	// The tracker and the scheduler share the node ID, so leases and claims name the same node
+	nodeID := config.Config.ControlPlane.NodeID
+	if nodeID == "" {
+		nodeID = control_plane.DefaultNodeID()
+	}
+
+	var tracker control_plane.AgentTracker
+	switch config.Config.ControlPlane.Tracker {
+	case "postgres":
+		trackerConfig := control_plane.PostgresAgentTrackerConfig{
+			NodeID:        nodeID,
+			LeaseDuration: config.Config.ControlPlane.LeaseDuration,
+		}
+		postgresTracker := control_plane.NewPostgresAgentTracker(trackerConfig)
+		go func() {
+			err := postgresTracker.Start(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Error running agent tracker", "error", err)
+			}
+		}()
+		tracker = postgresTracker
+	default:
+		tracker = control_plane.NewMemoryAgentTracker()
+	}
 	client := openai.NewClient(option.WithAPIKey(config.Config.OpenAI.APIKey))
 	provider := providers.NewOpenAIChatProvider(client)
-	pubSub := pubsub.NewKafkaPubSub()
+	pubSub := pubsub.NewPubSub()
 
 	controllerConfig := control_plane.AgentControllerConfig{
 		AgentLifeTime: 3 * time.Second,
 	}
 	controller := control_plane.NewAgentController(controllerConfig, storage, tracker)
-	scheduler := control_plane.NewScheduler(ctx, nil)
-	agentFactory := &agent.RealAgentFactory{}
+	schedulerConfig := control_plane.SchedulerConfig{
+		NodeID:               nodeID,
+		ScanInterval:         config.Config.Scheduler.ScanInterval,
+		BatchSize:            config.Config.Scheduler.BatchSize,
+		AwakeDuration:        config.Config.Scheduler.AwakeDuration,
+		DefaultWakePolicy:    config.Config.Scheduler.DefaultWakePolicy,
+		SleepDuration:        config.Config.Scheduler.SleepDuration,
+		MaxBackoffDuration:   config.Config.Scheduler.MaxBackoffDuration,
+		MaxEventWaitDuration: config.Config.Scheduler.MaxEventWaitDuration,
+	}
+	scheduler := control_plane.NewScheduler(ctx, schedulerConfig, nil)
+	runQueueConfig := control_plane.RunQueueConfig{
+		MaxRunning:         config.Config.ControlPlane.RunQueue.MaxRunning,
+		MaxRunningPerRole:  config.Config.ControlPlane.RunQueue.MaxRunningPerRole,
+		MaxRunningPerOwner: config.Config.ControlPlane.RunQueue.MaxRunningPerOwner,
+		MaxPending:         config.Config.ControlPlane.RunQueue.MaxPending,
+	}
+	runQueue := control_plane.NewRunQueue(runQueueConfig)
+	agentFactory := &agent.RealAgentFactory{
+		ApprovalPolicy: worker.ToolApprovalPolicy{
+			Tools:  config.Config.ToolApproval.Tools,
+			Roles:  config.Config.ToolApproval.Roles,
+			Owners: config.Config.ToolApproval.Owners,
+		},
+	}
 	controlPlaneCallbacks := control_plane.ControlPlaneCallbacks{
 		control_plane.ControlPlaneEventAgentFinalResponse: func(agentID string, response string) {
 			slog.Info("Agent final response", "agent_id", agentID, "response", response)
@@ -94,7 +150,20 @@ func main() {
 			slog.Info("Agent terminating", "agent_id", agentID, "status", status)
 		},
 	}
-	controlPlane := control_plane.NewControlPlane(agentFactory, storage, provider, controller, scheduler, pubSub, controlPlaneCallbacks, workerCallbacks)
+	controlPlane := control_plane.NewControlPlane(agentFactory, storage, provider, controller, scheduler, runQueue, pubSub, controlPlaneCallbacks, workerCallbacks)
+	taskSchedulerConfig := control_plane.TaskSchedulerConfig{
+		ScanInterval:   config.Config.TaskScheduler.ScanInterval,
+		BatchSize:      config.Config.TaskScheduler.BatchSize,
+		MissedRunGrace: config.Config.TaskScheduler.MissedRunGrace,
+		ResultTimeout:  config.Config.TaskScheduler.ResultTimeout,
+	}
+	taskScheduler := control_plane.NewTaskScheduler(taskSchedulerConfig, controlPlane)
+	outboxRelayConfig := control_plane.OutboxRelayConfig{
+		PollInterval: config.Config.Outbox.PollInterval,
+		BatchSize:    config.Config.Outbox.BatchSize,
+		Retention:    config.Config.Outbox.Retention,
+	}
+	outboxRelay := control_plane.NewOutboxRelay(outboxRelayConfig, storage, pubSub)
 
 	if withControlPlane {
 		go func() {
@@ -105,6 +174,20 @@ func main() {
 			}
 			slog.Info("Control plane started")
 		}()
+		// Scheduled runs are fired on nodes that run agents
+		go func() {
+			err := taskScheduler.Start(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Error running task scheduler", "error", err)
+			}
+		}()
+		// Agents write their responses and approval requests to the outbox with their state
+		go func() {
+			err := outboxRelay.Start(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Error running outbox relay", "error", err)
+			}
+		}()
 	}
 
 	// Initialize HTTP server
@@ -112,6 +195,10 @@ func main() {
 	server.Use(corsMiddleware())
 	docs.SwaggerInfo.BasePath = "/api/v1"
 	agentRouterController := controllers.NewAgentRouterController(ctx, controlPlane)
+	scheduleRouterController := controllers.NewScheduleRouterController(ctx, taskScheduler)
+	approvalRouterController := controllers.NewApprovalRouterController(ctx, controlPlane)
+	directoryRouterController := controllers.NewDirectoryRouterController(ctx, controlPlane)
+	threadRouterController := controllers.NewThreadRouterController(ctx, controlPlane)
 	v1 := server.Group("/api/v1")
 	{
 		users := v1.Group("/users")
@@ -122,6 +209,35 @@ func main() {
 		agents := v1.Group("/agents/")
 		{
 			agents.POST("/create", agentRouterController.StartAgent)
+			agents.POST("/:id/command", agentRouterController.SendAgentCommand)
+			agents.POST("/:id/answer", agentRouterController.AnswerAgent)
+			agents.GET("/queue", agentRouterController.GetRunQueueStats)
+		}
+
+		schedules := v1.Group("/schedules")
+		{
+			schedules.POST("", scheduleRouterController.CreateSchedule)
+			schedules.GET("", scheduleRouterController.ListSchedules)
+			schedules.POST("/:id/pause", scheduleRouterController.PauseSchedule)
+			schedules.POST("/:id/resume", scheduleRouterController.ResumeSchedule)
+		}
+
+		approvals := v1.Group("/approvals")
+		{
+			approvals.GET("", approvalRouterController.ListToolApprovals)
+			approvals.POST("/:id/decision", approvalRouterController.DecideToolApproval)
+		}
+
+		directory := v1.Group("/directory")
+		{
+			directory.GET("", directoryRouterController.SearchAgentProfiles)
+			directory.GET("/:id", directoryRouterController.GetAgentProfile)
+		}
+
+		threads := v1.Group("/threads")
+		{
+			threads.GET("", threadRouterController.ListAgentThreads)
+			threads.GET("/:id", threadRouterController.GetAgentThread)
 		}
 	}
 	server.GET("/healthz", controllers.Healthz)
@@ -132,13 +248,14 @@ func main() {
 	server.StaticFile("/favicon.ico", "./client/dist/favicon.ico")
 	server.Static("/assets", "./client/dist/assets")
 
-	stopChan := make(chan struct{})
-
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%s", config.Config.Server.Port),
+		Handler: server,
+	}
 	go func() {
-		port := config.Config.Server.Port
-		slog.Info("Server is running on port", "port", port)
-		err := server.Run(fmt.Sprintf(":%s", port))
-		if err != nil {
+		slog.Info("Server is running on port", "port", config.Config.Server.Port)
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("Error running server", "error", err)
 			panic(err)
 		}
@@ -147,31 +264,68 @@ func main() {
 	// Initialize websocket server
 	wsServer := gin.Default()
 	wsServer.Use(corsMiddleware())
-	websocketController := controllers.NewWebsocketController(ctx, pubSub)
+	// Every node serves websocket clients, so every node records and replays the events sent to them
+	eventLogConfig := ws.EventLogConfig{
+		Retention:    config.Config.Websocket.EventRetention,
+		PollInterval: config.Config.Websocket.EventPollInterval,
+	}
+	eventLog := ws.NewEventLog(eventLogConfig, storage, pubSub)
+	go func() {
+		err := eventLog.Start(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("Error running event log", "error", err)
+		}
+	}()
+	websocketController := controllers.NewWebsocketController(ctx, eventLog, controlPlane)
 	wsGroup := wsServer.Group("/")
 	{
 		wsGroup.GET("/", websocketController.SocketHandler)
 	}
 
+	wsHTTPServer := &http.Server{
+		Addr:    fmt.Sprintf(":%s", config.Config.Websocket.Port),
+		Handler: wsServer,
+	}
 	go func() {
-		port := config.Config.Websocket.Port
-		slog.Info("Websocket server is running on port", "port", port)
-		err := wsServer.Run(fmt.Sprintf(":%s", port))
-		if err != nil {
+		slog.Info("Websocket server is running on port", "port", config.Config.Websocket.Port)
+		err := wsHTTPServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("Error running websocket server", "error", err)
 			panic(err)
 		}
 	}()
 
 	// Wait for stop signal
-	select {
-	case <-stopChan:
-		slog.Info("Stopping server")
-		return
-	case <-ctx.Done():
-		slog.Info("Stopping server")
-		return
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signalCh
+	slog.Info("Stopping server", "signal", sig.String())
+
+	shutdownTimeout := utils.GetOrDefault(config.Config.Server.ShutdownTimeout, DefaultShutdownTimeout)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+
+	// Reject new tasks and let the running agents persist their state before anything they use is closed
+	if err := controlPlane.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error draining control plane", "error", err)
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "error", err)
+	}
+	websocketController.Shutdown()
+	if err := wsHTTPServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down websocket server", "error", err)
+	}
+
+	// Stops the tracker, the task scheduler and whatever still runs after the deadline
+	cancel()
+	if err := pubSub.Close(); err != nil {
+		slog.Error("Error closing pubsub", "error", err)
+	}
+	if err := storage.Close(); err != nil {
+		slog.Error("Error closing storage", "error", err)
 	}
+	slog.Info("Server stopped")
 }
 
 func corsMiddleware() gin.HandlerFunc {
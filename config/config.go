@@ -2,6 +2,7 @@ package config
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,9 +16,15 @@ type Configuration struct {
 	} `mapstructure:"openai"`
 	Server struct {
 		Port string `mapstructure:"port"`
+		// ShutdownTimeout bounds how long the server waits for agents to persist their state on shutdown
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
 	} `mapstructure:"server"`
 	Websocket struct {
 		Port string `mapstructure:"port"`
+		// EventRetention is how long the events sent to clients are kept for clients resuming with a cursor
+		EventRetention time.Duration `mapstructure:"event_retention"`
+		// EventPollInterval is how often the events recorded by the other nodes are read to send them to clients
+		EventPollInterval time.Duration `mapstructure:"event_poll_interval"`
 	} `mapstructure:"websocket"`
 	Database struct {
 		Host     string `mapstructure:"host"`
@@ -33,6 +40,58 @@ type Configuration struct {
 	Kafka struct {
 		Address string `mapstructure:"address"`
 	} `mapstructure:"kafka"`
+	PubSub struct {
+		// Driver is kafka, postgres or memory, memory only delivers messages within the process
+		Driver string `mapstructure:"driver"`
+		// Memory also configures the subscriber buffers of the postgres driver
+		Memory struct {
+			BufferSize int `mapstructure:"buffer_size"`
+			// OverflowPolicy is block, drop_newest or drop_oldest
+			OverflowPolicy string `mapstructure:"overflow_policy"`
+		} `mapstructure:"memory"`
+		Postgres struct {
+			Channel   string        `mapstructure:"channel"`
+			Retention time.Duration `mapstructure:"retention"`
+		} `mapstructure:"postgres"`
+	} `mapstructure:"pubsub"`
+	ControlPlane struct {
+		Tracker       string        `mapstructure:"tracker"`
+		NodeID        string        `mapstructure:"node_id"`
+		LeaseDuration time.Duration `mapstructure:"lease_duration"`
+		RunQueue      struct {
+			MaxRunning         int            `mapstructure:"max_running"`
+			MaxRunningPerRole  map[string]int `mapstructure:"max_running_per_role"`
+			MaxRunningPerOwner int            `mapstructure:"max_running_per_owner"`
+			MaxPending         int            `mapstructure:"max_pending"`
+		} `mapstructure:"run_queue"`
+	} `mapstructure:"control_plane"`
+	Scheduler struct {
+		ScanInterval         time.Duration `mapstructure:"scan_interval"`
+		BatchSize            int           `mapstructure:"batch_size"`
+		AwakeDuration        time.Duration `mapstructure:"awake_duration"`
+		DefaultWakePolicy    string        `mapstructure:"default_wake_policy"`
+		SleepDuration        time.Duration `mapstructure:"sleep_duration"`
+		MaxBackoffDuration   time.Duration `mapstructure:"max_backoff_duration"`
+		MaxEventWaitDuration time.Duration `mapstructure:"max_event_wait_duration"`
+	} `mapstructure:"scheduler"`
+	TaskScheduler struct {
+		ScanInterval   time.Duration `mapstructure:"scan_interval"`
+		BatchSize      int           `mapstructure:"batch_size"`
+		MissedRunGrace time.Duration `mapstructure:"missed_run_grace"`
+		ResultTimeout  time.Duration `mapstructure:"result_timeout"`
+	} `mapstructure:"task_scheduler"`
+	Outbox struct {
+		PollInterval time.Duration `mapstructure:"poll_interval"`
+		BatchSize    int           `mapstructure:"batch_size"`
+		Retention    time.Duration `mapstructure:"retention"`
+	} `mapstructure:"outbox"`
+	// ToolApproval maps tool names to auto, require_approval or deny.
+	// Rules for an owner win over rules for a role, which win over the default rules.
+	ToolApproval struct {
+		Tools  map[string]string            `mapstructure:"tools"`
+		Roles  map[string]map[string]string `mapstructure:"roles"`
+		Owners map[string]map[string]string `mapstructure:"owners"`
+	} `mapstructure:"tool_approval"`
 }
 
 func LoadConfig(name string) error {
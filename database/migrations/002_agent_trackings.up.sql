@@ -0,0 +1,11 @@
+CREATE TABLE agent_trackings (
+    id SERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    node_id VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    lease_expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_trackings_agent_id_idx ON agent_trackings (agent_id);
+CREATE INDEX agent_trackings_node_id_idx ON agent_trackings (node_id);
@@ -0,0 +1,2 @@
+ALTER TABLE agent_states DROP COLUMN claimed_until;
+ALTER TABLE agent_states DROP COLUMN claimed_by;
@@ -0,0 +1,2 @@
+ALTER TABLE agent_states ADD COLUMN claimed_by VARCHAR(255);
+ALTER TABLE agent_states ADD COLUMN claimed_until TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS agent_states_wake_at_idx;
+ALTER TABLE agent_states DROP COLUMN idle_rounds;
+ALTER TABLE agent_states DROP COLUMN wake_scheduled_at;
+ALTER TABLE agent_states DROP COLUMN wake_at;
+ALTER TABLE agent_states DROP COLUMN wake_policy;
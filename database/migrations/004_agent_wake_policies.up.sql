@@ -0,0 +1,5 @@
+ALTER TABLE agent_states ADD COLUMN wake_policy VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE agent_states ADD COLUMN wake_at TIMESTAMP;
+ALTER TABLE agent_states ADD COLUMN wake_scheduled_at TIMESTAMP;
+ALTER TABLE agent_states ADD COLUMN idle_rounds INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX agent_states_wake_at_idx ON agent_states (wake_at);
@@ -0,0 +1,20 @@
+CREATE TABLE agent_schedules (
+    id SERIAL PRIMARY KEY,
+    schedule_id VARCHAR(255) NOT NULL,
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(255) NOT NULL,
+    task_template TEXT NOT NULL,
+    cron_expression VARCHAR(255) NOT NULL DEFAULT '',
+    run_interval INTERVAL,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    missed_run_policy VARCHAR(32) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    agent_id VARCHAR(255),
+    last_result TEXT,
+    next_run_at TIMESTAMP NOT NULL,
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_schedules_schedule_id_idx ON agent_schedules (schedule_id);
+CREATE INDEX agent_schedules_next_run_at_idx ON agent_schedules (next_run_at);
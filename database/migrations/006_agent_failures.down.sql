@@ -0,0 +1,2 @@
+ALTER TABLE agent_states DROP COLUMN failed_at;
+ALTER TABLE agent_states DROP COLUMN last_error;
@@ -0,0 +1,2 @@
+ALTER TABLE agent_states ADD COLUMN last_error TEXT;
+ALTER TABLE agent_states ADD COLUMN failed_at TIMESTAMP;
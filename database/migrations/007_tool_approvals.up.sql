@@ -0,0 +1,17 @@
+CREATE TABLE tool_approvals (
+    id SERIAL PRIMARY KEY,
+    approval_id VARCHAR(255) NOT NULL,
+    agent_id VARCHAR(255) NOT NULL,
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(255) NOT NULL,
+    tool_call_id VARCHAR(255) NOT NULL,
+    tool_name VARCHAR(255) NOT NULL,
+    arguments TEXT NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    edited_arguments TEXT,
+    reason TEXT,
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX tool_approvals_approval_id_idx ON tool_approvals (approval_id);
+CREATE INDEX tool_approvals_agent_id_idx ON tool_approvals (agent_id);
@@ -0,0 +1 @@
+DROP TABLE agent_messages;
@@ -0,0 +1,11 @@
+CREATE TABLE agent_messages (
+    id SERIAL PRIMARY KEY,
+    message_id VARCHAR(255) NOT NULL,
+    from_agent_id VARCHAR(255) NOT NULL,
+    to_agent_id VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_messages_message_id_idx ON agent_messages (message_id);
+CREATE INDEX agent_messages_to_agent_id_idx ON agent_messages (to_agent_id);
@@ -0,0 +1,13 @@
+CREATE TABLE agent_interests (
+    id SERIAL PRIMARY KEY,
+    interest_id VARCHAR(255) NOT NULL,
+    agent_id VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    keywords TEXT[] NOT NULL DEFAULT '{}',
+    embedding REAL[],
+    min_similarity REAL NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_interests_interest_id_idx ON agent_interests (interest_id);
+CREATE INDEX agent_interests_agent_id_idx ON agent_interests (agent_id);
@@ -0,0 +1,13 @@
+CREATE TABLE agent_profiles (
+    id SERIAL PRIMARY KEY,
+    agent_id VARCHAR(255) NOT NULL,
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    role VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    embedding REAL[],
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_profiles_agent_id_idx ON agent_profiles (agent_id);
+CREATE INDEX agent_profiles_tags_idx ON agent_profiles USING gin (tags);
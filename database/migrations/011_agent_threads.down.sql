@@ -0,0 +1,5 @@
+DROP INDEX agent_messages_thread_id_idx;
+ALTER TABLE agent_messages DROP COLUMN kind;
+ALTER TABLE agent_messages DROP COLUMN thread_id;
+
+DROP TABLE agent_threads;
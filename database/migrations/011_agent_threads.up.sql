@@ -0,0 +1,18 @@
+CREATE TABLE agent_threads (
+    id SERIAL PRIMARY KEY,
+    thread_id VARCHAR(255) NOT NULL,
+    initiator_agent_id VARCHAR(255) NOT NULL,
+    responder_agent_id VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    status VARCHAR(64) NOT NULL,
+    last_sender_agent_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX agent_threads_thread_id_idx ON agent_threads (thread_id);
+CREATE INDEX agent_threads_initiator_agent_id_idx ON agent_threads (initiator_agent_id);
+CREATE INDEX agent_threads_responder_agent_id_idx ON agent_threads (responder_agent_id);
+
+ALTER TABLE agent_messages ADD COLUMN thread_id VARCHAR(255);
+ALTER TABLE agent_messages ADD COLUMN kind VARCHAR(64) NOT NULL DEFAULT 'text';
+CREATE INDEX agent_messages_thread_id_idx ON agent_messages (thread_id);
@@ -0,0 +1,9 @@
+CREATE TABLE pubsub_events (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX pubsub_events_topic_id_idx ON pubsub_events (topic, id);
+CREATE INDEX pubsub_events_created_at_idx ON pubsub_events (created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX event_outbox_next_attempt_at_idx ON event_outbox (next_attempt_at) WHERE delivered_at IS NULL;
+CREATE INDEX event_outbox_message_key_idx ON event_outbox (message_key, id) WHERE delivered_at IS NULL;
+CREATE INDEX event_outbox_delivered_at_idx ON event_outbox (delivered_at);
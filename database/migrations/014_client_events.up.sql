@@ -0,0 +1,13 @@
+CREATE TABLE client_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(255) NOT NULL,
+    agent_id VARCHAR(255) NOT NULL,
+    owner VARCHAR(255) NOT NULL DEFAULT '',
+    event VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX client_events_event_id_idx ON client_events (event_id);
+CREATE INDEX client_events_agent_id_idx ON client_events (agent_id, id);
+CREATE INDEX client_events_owner_idx ON client_events (owner, id);
+CREATE INDEX client_events_created_at_idx ON client_events (created_at);
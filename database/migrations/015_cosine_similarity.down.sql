@@ -0,0 +1 @@
+DROP FUNCTION cosine_similarity(REAL[], REAL[]);
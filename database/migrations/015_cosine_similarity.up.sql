@@ -0,0 +1,10 @@
+-- Cosine similarity of two embeddings, 0 if their dimensions differ or one of them is zero
+CREATE FUNCTION cosine_similarity(a REAL[], b REAL[]) RETURNS REAL AS $$
+    SELECT CASE
+        WHEN cardinality(a) <> cardinality(b) THEN 0
+        ELSE COALESCE((
+            SELECT SUM(x::float8 * y) / NULLIF(SQRT(SUM(x::float8 * x)) * SQRT(SUM(y::float8 * y)), 0)
+            FROM UNNEST(a, b) AS v(x, y)
+        ), 0)
+    END::REAL
+$$ LANGUAGE SQL IMMUTABLE PARALLEL SAFE;
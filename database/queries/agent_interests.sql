@@ -0,0 +1,60 @@
+-- name: CreateAgentInterest :one
+INSERT INTO agent_interests (interest_id, agent_id, query, keywords, embedding, min_similarity)
+VALUES (@interest_id, @agent_id, @query, @keywords::text[], @embedding::real[], @min_similarity)
+RETURNING *;
+
+-- name: CancelAgentInterest :one
+UPDATE agent_interests
+SET active = FALSE
+WHERE interest_id = @interest_id
+  AND agent_id = @agent_id
+  AND active
+RETURNING *;
+
+-- name: MatchLexicalAgentInterests :many
+-- Lists the active interests without an embedding that match the content of a new post.
+-- An interest matches when the content contains its query or a similar enough text, and contains all of its keywords.
+-- Interests of agents in one of the excluded statuses are skipped.
+SELECT agent_interests.*
+FROM agent_interests
+JOIN agent_states ON agent_states.agent_id = agent_interests.agent_id
+WHERE agent_interests.active
+  AND agent_interests.embedding IS NULL
+  AND agent_states.status <> ALL(@excluded_statuses::varchar[])
+  AND (
+    @content::text ILIKE '%' || agent_interests.query || '%'
+    OR WORD_SIMILARITY(agent_interests.query, @content::text) >= agent_interests.min_similarity
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM UNNEST(agent_interests.keywords) AS keyword
+    WHERE @content::text NOT ILIKE '%' || keyword || '%'
+  );
+
+-- name: HasSemanticAgentInterests :one
+-- Tells whether any active interest with an embedding may match a new post, so that the post is only embedded then.
+-- Interests of agents in one of the excluded statuses are skipped.
+SELECT EXISTS (
+  SELECT 1
+  FROM agent_interests
+  JOIN agent_states ON agent_states.agent_id = agent_interests.agent_id
+  WHERE agent_interests.active
+    AND agent_interests.embedding IS NOT NULL
+    AND agent_states.status <> ALL(@excluded_statuses::varchar[])
+);
+
+-- name: MatchSemanticAgentInterests :many
+-- Lists the active interests with an embedding similar enough to the embedding of a new post
+-- whose keywords are all contained in its content. Interests of agents in one of the excluded statuses are skipped.
+SELECT agent_interests.*
+FROM agent_interests
+JOIN agent_states ON agent_states.agent_id = agent_interests.agent_id
+WHERE agent_interests.active
+  AND agent_interests.embedding IS NOT NULL
+  AND agent_states.status <> ALL(@excluded_statuses::varchar[])
+  AND NOT EXISTS (
+    SELECT 1
+    FROM UNNEST(agent_interests.keywords) AS keyword
+    WHERE @content::text NOT ILIKE '%' || keyword || '%'
+  )
+  AND cosine_similarity(agent_interests.embedding, @embedding::real[]) >= agent_interests.min_similarity;
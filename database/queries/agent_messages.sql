@@ -0,0 +1,23 @@
+-- name: CreateAgentMessage :one
+INSERT INTO agent_messages (message_id, from_agent_id, to_agent_id, content, thread_id, kind)
+VALUES (@message_id, @from_agent_id, @to_agent_id, @content, @thread_id, @kind)
+RETURNING *;
+
+-- name: ListUnreadAgentMessages :many
+SELECT *
+FROM agent_messages
+WHERE to_agent_id = @to_agent_id
+  AND read_at IS NULL
+ORDER BY created_at ASC, id ASC;
+
+-- name: MarkAgentMessagesRead :exec
+UPDATE agent_messages
+SET read_at = now()
+WHERE to_agent_id = @to_agent_id
+  AND message_id = ANY(@message_ids::varchar[]);
+
+-- name: ListAgentThreadMessages :many
+SELECT *
+FROM agent_messages
+WHERE thread_id = @thread_id
+ORDER BY created_at ASC, id ASC;
@@ -0,0 +1,54 @@
+-- name: UpsertAgentProfile :one
+INSERT INTO agent_profiles (agent_id, owner, role, description, tags, embedding)
+VALUES (@agent_id, @owner, @role, @description, @tags::text[], @embedding::real[])
+ON CONFLICT (agent_id) DO UPDATE
+SET owner = EXCLUDED.owner,
+    role = EXCLUDED.role,
+    description = EXCLUDED.description,
+    tags = EXCLUDED.tags,
+    embedding = EXCLUDED.embedding,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetAgentProfile :one
+SELECT *
+FROM agent_profiles
+WHERE agent_id = @agent_id;
+
+-- name: SearchAgentProfiles :many
+-- Lists the profiles of the given role and owner that have all the given tags and match the query by text,
+-- best matches first. Empty role, owner or query match all profiles.
+-- Profiles of the excluded agent or of agents in one of the excluded statuses are skipped.
+SELECT agent_profiles.*
+FROM agent_profiles
+JOIN agent_states ON agent_states.agent_id = agent_profiles.agent_id
+WHERE agent_profiles.agent_id <> @excluded_agent_id
+  AND agent_states.status <> ALL(@excluded_statuses::varchar[])
+  AND (@role::text = '' OR agent_profiles.role = @role::text)
+  AND (@owner::text = '' OR agent_profiles.owner = @owner::text)
+  AND agent_profiles.tags @> @tags::text[]
+  AND (
+    @query::text = ''
+    OR agent_profiles.description ILIKE '%' || @query::text || '%'
+    OR WORD_SIMILARITY(@query::text, agent_profiles.description) >= 0.3
+    OR @query::text = ANY(agent_profiles.tags)
+  )
+ORDER BY WORD_SIMILARITY(@query::text, agent_profiles.description) DESC, agent_profiles.updated_at DESC
+LIMIT @max_results;
+
+-- name: SearchSemanticAgentProfiles :many
+-- Lists the profiles with an embedding similar enough to the query embedding, most similar first.
+-- Filters work as in SearchAgentProfiles, profiles of the already found agents are skipped.
+SELECT agent_profiles.*
+FROM agent_profiles
+JOIN agent_states ON agent_states.agent_id = agent_profiles.agent_id
+WHERE agent_profiles.embedding IS NOT NULL
+  AND agent_profiles.agent_id <> @excluded_agent_id
+  AND agent_profiles.agent_id <> ALL(@found_agent_ids::varchar[])
+  AND agent_states.status <> ALL(@excluded_statuses::varchar[])
+  AND (@role::text = '' OR agent_profiles.role = @role::text)
+  AND (@owner::text = '' OR agent_profiles.owner = @owner::text)
+  AND agent_profiles.tags @> @tags::text[]
+  AND cosine_similarity(agent_profiles.embedding, @query_embedding::real[]) >= @min_similarity::real
+ORDER BY cosine_similarity(agent_profiles.embedding, @query_embedding::real[]) DESC
+LIMIT @max_results;
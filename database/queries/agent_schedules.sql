@@ -0,0 +1,52 @@
+-- name: CreateAgentSchedule :one
+INSERT INTO agent_schedules (
+  schedule_id, owner, role, task_template, cron_expression, run_interval, time_zone, missed_run_policy, status, next_run_at
+)
+VALUES (
+  @schedule_id, @owner, @role, @task_template, @cron_expression, @run_interval, @time_zone, @missed_run_policy, @status, @next_run_at
+)
+RETURNING *;
+
+-- name: GetAgentSchedule :one
+SELECT *
+FROM agent_schedules
+WHERE schedule_id = @schedule_id;
+
+-- name: ListAgentSchedules :many
+-- Lists the schedules of an owner, or of all owners when owner is empty.
+SELECT *
+FROM agent_schedules
+WHERE @owner::text = '' OR owner = @owner::text
+ORDER BY created_at ASC;
+
+-- name: UpdateAgentScheduleStatus :one
+UPDATE agent_schedules
+SET status = @status, next_run_at = @next_run_at, updated_at = now()
+WHERE schedule_id = @schedule_id
+RETURNING *;
+
+-- name: SearchDueAgentSchedules :many
+-- Locks the active schedules due to run, skipping those locked by another node.
+-- Must run in the same transaction as UpdateAgentScheduleRun.
+SELECT *
+FROM agent_schedules
+WHERE status = @status
+  AND next_run_at <= @now
+ORDER BY next_run_at ASC
+LIMIT @max_schedules
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateAgentScheduleRun :exec
+UPDATE agent_schedules
+SET next_run_at = @next_run_at, last_run_at = @last_run_at, updated_at = now()
+WHERE schedule_id = @schedule_id;
+
+-- name: UpdateAgentScheduleAgent :exec
+UPDATE agent_schedules
+SET agent_id = @agent_id, updated_at = now()
+WHERE schedule_id = @schedule_id;
+
+-- name: UpdateAgentScheduleResult :exec
+UPDATE agent_schedules
+SET last_result = @last_result, updated_at = now()
+WHERE schedule_id = @schedule_id;
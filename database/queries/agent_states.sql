@@ -32,3 +32,103 @@ WHERE asleep_at + @duration::interval < now()
   AND status = ANY(@statuses::varchar[])
 ORDER BY asleep_at ASC
 LIMIT @max_agents;
+
+-- name: UpdateAgentStatus :exec
+UPDATE agent_states
+SET status = @status
+WHERE agent_id = @agent_id;
+
+-- name: SearchClaimableAsleepAgents :many
+-- Locks the asleep agents that are due to wake and are not claimed by any node.
+-- An agent is due when its wake_at has passed, when it has no wake_at and has been asleep longer than the given duration,
+-- when it uses the event policy and a post matching one of its interests was created after it fell asleep,
+-- or when another agent sent it a message after it fell asleep.
+-- Agents of the round_robin policy are interleaved by owner, so no owner takes the whole batch.
+-- The status and claim are checked again on the locked rows, as a row claimed by another node meanwhile is rechecked
+-- on its latest version, and the limit applies after skipping locked rows, so a node waiting on another still gets due agents.
+-- Must run in the same transaction as ClaimAgents.
+SELECT agent_states.*
+FROM agent_states
+JOIN (
+  SELECT candidates.id AS due_id,
+    ROW_NUMBER() OVER (
+      PARTITION BY CASE
+        WHEN candidates.wake_policy = 'round_robin' THEN COALESCE(candidates.state->'metadata'->>'owner', '')
+        ELSE candidates.agent_id
+      END
+      ORDER BY candidates.wake_at ASC NULLS FIRST, candidates.asleep_at ASC
+    ) AS owner_rank
+  FROM agent_states AS candidates
+  WHERE candidates.status = ANY(@statuses::varchar[])
+    AND (candidates.claimed_until IS NULL OR candidates.claimed_until < now())
+    AND (
+      candidates.wake_at <= now()
+      OR (candidates.wake_at IS NULL AND candidates.wake_policy <> 'event' AND candidates.asleep_at + @duration::interval < now())
+      OR (candidates.wake_policy = 'event' AND EXISTS (
+        SELECT 1
+        FROM posts
+        WHERE posts.created_at > candidates.asleep_at
+          AND posts.content ILIKE ANY(
+            SELECT '%' || interest || '%'
+            FROM jsonb_array_elements_text(COALESCE(candidates.state->'metadata'->'interests', '[]'::jsonb)) AS interest
+          )
+      ))
+      OR EXISTS (
+        SELECT 1
+        FROM agent_messages
+        WHERE agent_messages.to_agent_id = candidates.agent_id
+          AND agent_messages.read_at IS NULL
+          AND agent_messages.created_at > candidates.asleep_at
+      )
+    )
+) AS due ON due.due_id = agent_states.id
+WHERE agent_states.status = ANY(@statuses::varchar[])
+  AND (agent_states.claimed_until IS NULL OR agent_states.claimed_until < now())
+ORDER BY due.owner_rank ASC, agent_states.wake_at ASC NULLS FIRST
+LIMIT @max_agents
+FOR UPDATE OF agent_states SKIP LOCKED;
+
+-- name: SearchClaimableOrphanedAgents :many
+-- Locks the agents whose tracking lease has expired, or that were never tracked with a lease
+-- and have been awake longer than the given duration, and are not claimed by any node.
+-- Must run in the same transaction as ClaimAgents.
+SELECT agent_states.*
+FROM agent_states
+LEFT JOIN agent_trackings ON agent_trackings.agent_id = agent_states.agent_id
+WHERE agent_states.status = ANY(@statuses::varchar[])
+  AND (
+    agent_trackings.lease_expires_at < now()
+    OR (agent_trackings.agent_id IS NULL AND agent_states.awakened_at + @duration::interval < now())
+  )
+  AND (agent_states.claimed_until IS NULL OR agent_states.claimed_until < now())
+ORDER BY agent_states.awakened_at ASC
+LIMIT @max_agents
+FOR UPDATE OF agent_states SKIP LOCKED;
+
+-- name: ClaimAgents :many
+-- Claims the agents that are still in one of the statuses and not claimed by any node, and returns their IDs.
+-- An agent left out was claimed or changed by another node, it must not be resumed.
+UPDATE agent_states
+SET claimed_by = @node_id, claimed_until = now() + @claim_duration::interval
+WHERE agent_id = ANY(@agent_ids::varchar[])
+  AND status = ANY(@statuses::varchar[])
+  AND (claimed_until IS NULL OR claimed_until < now())
+RETURNING agent_id;
+
+-- name: ReleaseAgentClaim :exec
+-- Releases the node's claim, a claim another node took after this one expired is kept.
+UPDATE agent_states
+SET claimed_by = NULL, claimed_until = NULL
+WHERE agent_id = @agent_id
+  AND claimed_by = @node_id;
+
+-- name: UpdateAgentWake :exec
+UPDATE agent_states
+SET wake_policy = @wake_policy, wake_at = @wake_at, wake_scheduled_at = now(), idle_rounds = @idle_rounds
+WHERE agent_id = @agent_id;
+
+-- name: UpdateAgentError :exec
+-- Records why the agent failed, a NULL error clears it when the agent is retried.
+UPDATE agent_states
+SET last_error = @last_error, failed_at = @failed_at
+WHERE agent_id = @agent_id;
@@ -0,0 +1,27 @@
+-- name: CreateAgentThread :one
+INSERT INTO agent_threads (thread_id, initiator_agent_id, responder_agent_id, subject, status, last_sender_agent_id)
+VALUES (@thread_id, @initiator_agent_id, @responder_agent_id, @subject, @status, @last_sender_agent_id)
+RETURNING *;
+
+-- name: GetAgentThread :one
+SELECT *
+FROM agent_threads
+WHERE thread_id = @thread_id;
+
+-- name: ListAgentThreads :many
+SELECT *
+FROM agent_threads
+WHERE initiator_agent_id = @agent_id
+   OR responder_agent_id = @agent_id
+ORDER BY updated_at DESC;
+
+-- name: AdvanceAgentThread :one
+-- Moves the thread to its next status, only if no other message advanced it since the sender read it.
+UPDATE agent_threads
+SET status = @status,
+    last_sender_agent_id = @last_sender_agent_id,
+    updated_at = now()
+WHERE thread_id = @thread_id
+  AND status = @expected_status
+  AND last_sender_agent_id = @expected_last_sender_agent_id
+RETURNING *;
@@ -0,0 +1,31 @@
+-- name: UpsertAgentTracking :exec
+INSERT INTO agent_trackings (agent_id, node_id, status, lease_expires_at)
+VALUES (@agent_id, @node_id, @status, now() + @lease_duration::interval)
+ON CONFLICT (agent_id) DO UPDATE
+SET node_id = EXCLUDED.node_id,
+    status = EXCLUDED.status,
+    lease_expires_at = EXCLUDED.lease_expires_at,
+    updated_at = now();
+
+-- name: GetAgentTracking :one
+SELECT *
+FROM agent_trackings
+WHERE agent_id = @agent_id;
+
+-- name: UpdateAgentTrackingStatus :exec
+UPDATE agent_trackings
+SET status = @status, updated_at = now()
+WHERE agent_id = @agent_id
+  AND node_id = @node_id;
+
+-- name: DeleteAgentTracking :exec
+DELETE FROM agent_trackings
+WHERE agent_id = @agent_id
+  AND node_id = @node_id;
+
+-- name: RenewAgentLeases :many
+UPDATE agent_trackings
+SET lease_expires_at = now() + @lease_duration::interval, updated_at = now()
+WHERE node_id = @node_id
+  AND agent_id = ANY(@agent_ids::varchar[])
+RETURNING agent_id;
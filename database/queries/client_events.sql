@@ -0,0 +1,39 @@
+-- name: LockClientEvents :exec
+-- Holds the lock until the transaction ends, so events are committed in the order of their IDs.
+SELECT pg_advisory_xact_lock(hashtext('client_events'));
+
+-- name: GetClientEventID :one
+SELECT id
+FROM client_events
+WHERE event_id = @event_id;
+
+-- name: RecordClientEvent :one
+-- Records the event once, every node recording it gets the ID of the first record as its cursor.
+INSERT INTO client_events (event_id, agent_id, owner, event, data)
+VALUES (@event_id, @agent_id, @owner, @event, @data)
+ON CONFLICT (event_id) DO UPDATE SET event_id = EXCLUDED.event_id
+RETURNING id;
+
+-- name: ListClientEventsAfter :many
+-- Lists the events after the cursor of the agents by ID, or of the owner's agents when the owner is not empty.
+SELECT *
+FROM client_events
+WHERE id > @after_id
+  AND (agent_id = ANY(@agent_ids::text[]) OR (@owner::text <> '' AND owner = @owner::text))
+ORDER BY id
+LIMIT @max_events;
+
+-- name: ListAllClientEventsAfter :many
+SELECT *
+FROM client_events
+WHERE id > @after_id
+ORDER BY id
+LIMIT @max_events;
+
+-- name: GetLatestClientEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS id
+FROM client_events;
+
+-- name: DeleteExpiredClientEvents :exec
+DELETE FROM client_events
+WHERE created_at < now() - @retention::interval;
@@ -0,0 +1,43 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO event_outbox (topic, message_key, message, headers)
+VALUES (@topic, @message_key, @message, @headers);
+
+-- name: ClaimOutboxEvents :many
+-- Claims the due undelivered events, oldest first, other relays skip them until the claim expires.
+-- An event waits while an earlier event with the same key is undelivered, so a key is published in order.
+UPDATE event_outbox
+SET next_attempt_at = now() + @claim_duration::interval
+WHERE id IN (
+    SELECT id
+    FROM event_outbox
+    WHERE delivered_at IS NULL
+      AND next_attempt_at <= now()
+      AND NOT EXISTS (
+          SELECT 1
+          FROM event_outbox e2
+          WHERE e2.message_key = event_outbox.message_key
+            AND e2.message_key <> ''
+            AND e2.delivered_at IS NULL
+            AND e2.id < event_outbox.id
+      )
+    ORDER BY id
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxEventDelivered :exec
+UPDATE event_outbox
+SET delivered_at = now()
+WHERE id = @id;
+
+-- name: RetryOutboxEvent :exec
+UPDATE event_outbox
+SET attempts = attempts + 1,
+    last_error = @last_error,
+    next_attempt_at = now() + @backoff::interval
+WHERE id = @id;
+
+-- name: DeleteDeliveredOutboxEvents :exec
+DELETE FROM event_outbox
+WHERE delivered_at < now() - @retention::interval;
@@ -12,3 +12,8 @@ OR content ILIKE ANY(
   SELECT '%' || word || '%'
   FROM UNNEST(STRING_TO_ARRAY(@keyword, ' ')) AS word
 );
+
+-- name: CountPostsSince :one
+SELECT COUNT(*)
+FROM posts
+WHERE created_at > @since;
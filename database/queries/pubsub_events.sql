@@ -0,0 +1,29 @@
+-- name: CreatePubSubEvent :one
+INSERT INTO pubsub_events (topic, message, headers)
+VALUES (@topic, @message, @headers)
+RETURNING *;
+
+-- name: GetPubSubEvent :one
+SELECT *
+FROM pubsub_events
+WHERE id = @id;
+
+-- name: ListPubSubEventsAfter :many
+-- Lists the events of the given topics published after an event, oldest first, to catch up after a reconnect.
+SELECT *
+FROM pubsub_events
+WHERE id > @after_id
+  AND topic = ANY(@topics::text[])
+ORDER BY id
+LIMIT @max_events;
+
+-- name: GetLatestPubSubEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS id
+FROM pubsub_events;
+
+-- name: NotifyPubSubEvent :exec
+SELECT pg_notify(@channel::text, @payload::text);
+
+-- name: DeleteExpiredPubSubEvents :exec
+DELETE FROM pubsub_events
+WHERE created_at < now() - @retention::interval;
@@ -0,0 +1,35 @@
+-- name: CreateToolApproval :one
+INSERT INTO tool_approvals (
+  approval_id, agent_id, owner, role, tool_call_id, tool_name, arguments, status
+)
+VALUES (
+  @approval_id, @agent_id, @owner, @role, @tool_call_id, @tool_name, @arguments, @status
+)
+RETURNING *;
+
+-- name: GetToolApproval :one
+SELECT *
+FROM tool_approvals
+WHERE approval_id = @approval_id;
+
+-- name: ListToolApprovals :many
+-- Lists the approvals of an owner and status, an empty owner or status matches all.
+SELECT *
+FROM tool_approvals
+WHERE (@owner::text = '' OR owner = @owner::text)
+  AND (@status::text = '' OR status = @status::text)
+ORDER BY created_at ASC;
+
+-- name: DecideToolApproval :one
+-- Records the decision of a pending approval, no row is returned if it was already decided.
+UPDATE tool_approvals
+SET status = @status, edited_arguments = @edited_arguments, reason = @reason, decided_at = @decided_at
+WHERE approval_id = @approval_id
+  AND status = 'pending'
+RETURNING *;
+
+-- name: ReopenToolApproval :exec
+-- Clears the decision of an approval whose agent could not be resumed, so the user can decide again.
+UPDATE tool_approvals
+SET status = 'pending', edited_arguments = NULL, reason = NULL, decided_at = NULL
+WHERE approval_id = @approval_id;
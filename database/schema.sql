@@ -30,10 +30,182 @@ CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
 COMMENT ON EXTENSION pg_trgm IS 'text similarity measurement and index searching based on trigrams';
 
 
+--
+-- Name: cosine_similarity(real[], real[]); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.cosine_similarity(a real[], b real[]) RETURNS real
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$
+    SELECT CASE
+        WHEN cardinality(a) <> cardinality(b) THEN 0
+        ELSE COALESCE((
+            SELECT SUM(x::float8 * y) / NULLIF(SQRT(SUM(x::float8 * x)) * SQRT(SUM(y::float8 * y)), 0)
+            FROM UNNEST(a, b) AS v(x, y)
+        ), 0)
+    END::REAL
+$$;
+
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: agent_interests; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_interests (
+    id integer NOT NULL,
+    interest_id character varying(255) NOT NULL,
+    agent_id character varying(255) NOT NULL,
+    query text NOT NULL,
+    keywords text[] DEFAULT '{}'::text[] NOT NULL,
+    embedding real[],
+    min_similarity real NOT NULL,
+    active boolean DEFAULT true NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: agent_interests_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_interests_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_interests_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_interests_id_seq OWNED BY public.agent_interests.id;
+
+
+--
+-- Name: agent_messages; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_messages (
+    id integer NOT NULL,
+    message_id character varying(255) NOT NULL,
+    from_agent_id character varying(255) NOT NULL,
+    to_agent_id character varying(255) NOT NULL,
+    content text NOT NULL,
+    read_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    thread_id character varying(255),
+    kind character varying(64) DEFAULT 'text'::character varying NOT NULL
+);
+
+
+--
+-- Name: agent_messages_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_messages_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_messages_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_messages_id_seq OWNED BY public.agent_messages.id;
+
+
+--
+-- Name: agent_profiles; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_profiles (
+    id integer NOT NULL,
+    agent_id character varying(255) NOT NULL,
+    owner character varying(255) DEFAULT ''::character varying NOT NULL,
+    role character varying(255) NOT NULL,
+    description text DEFAULT ''::text NOT NULL,
+    tags text[] DEFAULT '{}'::text[] NOT NULL,
+    embedding real[],
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: agent_profiles_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_profiles_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_profiles_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_profiles_id_seq OWNED BY public.agent_profiles.id;
+
+
+--
+-- Name: agent_schedules; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_schedules (
+    id integer NOT NULL,
+    schedule_id character varying(255) NOT NULL,
+    owner character varying(255) DEFAULT ''::character varying NOT NULL,
+    role character varying(255) NOT NULL,
+    task_template text NOT NULL,
+    cron_expression character varying(255) DEFAULT ''::character varying NOT NULL,
+    run_interval interval,
+    time_zone character varying(64) DEFAULT 'UTC'::character varying NOT NULL,
+    missed_run_policy character varying(32) NOT NULL,
+    status character varying(32) NOT NULL,
+    agent_id character varying(255),
+    last_result text,
+    next_run_at timestamp without time zone NOT NULL,
+    last_run_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: agent_schedules_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_schedules_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_schedules_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_schedules_id_seq OWNED BY public.agent_schedules.id;
+
+
 --
 -- Name: agent_states; Type: TABLE; Schema: public; Owner: -
 --
@@ -47,7 +219,15 @@ CREATE TABLE public.agent_states (
     created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
     awakened_at timestamp without time zone,
-    asleep_at timestamp without time zone
+    asleep_at timestamp without time zone,
+    claimed_by character varying(255),
+    claimed_until timestamp without time zone,
+    wake_policy character varying(64) DEFAULT ''::character varying NOT NULL,
+    wake_at timestamp without time zone,
+    wake_scheduled_at timestamp without time zone,
+    idle_rounds integer DEFAULT 0 NOT NULL,
+    last_error text,
+    failed_at timestamp without time zone
 );
 
 
@@ -71,6 +251,149 @@ CREATE SEQUENCE public.agent_states_id_seq
 ALTER SEQUENCE public.agent_states_id_seq OWNED BY public.agent_states.id;
 
 
+--
+-- Name: agent_threads; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_threads (
+    id integer NOT NULL,
+    thread_id character varying(255) NOT NULL,
+    initiator_agent_id character varying(255) NOT NULL,
+    responder_agent_id character varying(255) NOT NULL,
+    subject text NOT NULL,
+    status character varying(64) NOT NULL,
+    last_sender_agent_id character varying(255) NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: agent_threads_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_threads_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_threads_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_threads_id_seq OWNED BY public.agent_threads.id;
+
+
+--
+-- Name: agent_trackings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.agent_trackings (
+    id integer NOT NULL,
+    agent_id character varying(255) NOT NULL,
+    node_id character varying(255) NOT NULL,
+    status character varying(255) NOT NULL,
+    lease_expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: agent_trackings_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.agent_trackings_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: agent_trackings_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.agent_trackings_id_seq OWNED BY public.agent_trackings.id;
+
+
+--
+-- Name: client_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.client_events (
+    id bigint NOT NULL,
+    event_id character varying(255) NOT NULL,
+    agent_id character varying(255) NOT NULL,
+    owner character varying(255) DEFAULT ''::character varying NOT NULL,
+    event character varying(64) NOT NULL,
+    data jsonb NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: client_events_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.client_events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: client_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.client_events_id_seq OWNED BY public.client_events.id;
+
+
+--
+-- Name: event_outbox; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.event_outbox (
+    id bigint NOT NULL,
+    topic character varying(255) NOT NULL,
+    message_key character varying(255) DEFAULT ''::character varying NOT NULL,
+    message text NOT NULL,
+    headers jsonb DEFAULT '{}'::jsonb NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    next_attempt_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    delivered_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: event_outbox_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.event_outbox_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: event_outbox_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.event_outbox_id_seq OWNED BY public.event_outbox.id;
+
+
 --
 -- Name: posts; Type: TABLE; Schema: public; Owner: -
 --
@@ -104,6 +427,38 @@ CREATE SEQUENCE public.posts_id_seq
 ALTER SEQUENCE public.posts_id_seq OWNED BY public.posts.id;
 
 
+--
+-- Name: pubsub_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.pubsub_events (
+    id bigint NOT NULL,
+    topic character varying(255) NOT NULL,
+    message text NOT NULL,
+    headers jsonb DEFAULT '{}'::jsonb NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: pubsub_events_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.pubsub_events_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: pubsub_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.pubsub_events_id_seq OWNED BY public.pubsub_events.id;
+
+
 --
 -- Name: schema_migrations; Type: TABLE; Schema: public; Owner: -
 --
@@ -114,6 +469,47 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: tool_approvals; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.tool_approvals (
+    id integer NOT NULL,
+    approval_id character varying(255) NOT NULL,
+    agent_id character varying(255) NOT NULL,
+    owner character varying(255) DEFAULT ''::character varying NOT NULL,
+    role character varying(255) NOT NULL,
+    tool_call_id character varying(255) NOT NULL,
+    tool_name character varying(255) NOT NULL,
+    arguments text NOT NULL,
+    status character varying(32) NOT NULL,
+    edited_arguments text,
+    reason text,
+    decided_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+
+--
+-- Name: tool_approvals_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.tool_approvals_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: tool_approvals_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.tool_approvals_id_seq OWNED BY public.tool_approvals.id;
+
+
 --
 -- Name: users; Type: TABLE; Schema: public; Owner: -
 --
@@ -148,6 +544,34 @@ CREATE SEQUENCE public.users_id_seq
 ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
 
 
+--
+-- Name: agent_interests id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_interests ALTER COLUMN id SET DEFAULT nextval('public.agent_interests_id_seq'::regclass);
+
+
+--
+-- Name: agent_messages id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_messages ALTER COLUMN id SET DEFAULT nextval('public.agent_messages_id_seq'::regclass);
+
+
+--
+-- Name: agent_profiles id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_profiles ALTER COLUMN id SET DEFAULT nextval('public.agent_profiles_id_seq'::regclass);
+
+
+--
+-- Name: agent_schedules id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_schedules ALTER COLUMN id SET DEFAULT nextval('public.agent_schedules_id_seq'::regclass);
+
+
 --
 -- Name: agent_states id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -155,6 +579,34 @@ ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
 ALTER TABLE ONLY public.agent_states ALTER COLUMN id SET DEFAULT nextval('public.agent_states_id_seq'::regclass);
 
 
+--
+-- Name: agent_threads id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_threads ALTER COLUMN id SET DEFAULT nextval('public.agent_threads_id_seq'::regclass);
+
+
+--
+-- Name: agent_trackings id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_trackings ALTER COLUMN id SET DEFAULT nextval('public.agent_trackings_id_seq'::regclass);
+
+
+--
+-- Name: client_events id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.client_events ALTER COLUMN id SET DEFAULT nextval('public.client_events_id_seq'::regclass);
+
+
+--
+-- Name: event_outbox id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.event_outbox ALTER COLUMN id SET DEFAULT nextval('public.event_outbox_id_seq'::regclass);
+
+
 --
 -- Name: posts id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -162,6 +614,20 @@ ALTER TABLE ONLY public.agent_states ALTER COLUMN id SET DEFAULT nextval('public
 ALTER TABLE ONLY public.posts ALTER COLUMN id SET DEFAULT nextval('public.posts_id_seq'::regclass);
 
 
+--
+-- Name: pubsub_events id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.pubsub_events ALTER COLUMN id SET DEFAULT nextval('public.pubsub_events_id_seq'::regclass);
+
+
+--
+-- Name: tool_approvals id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tool_approvals ALTER COLUMN id SET DEFAULT nextval('public.tool_approvals_id_seq'::regclass);
+
+
 --
 -- Name: users id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -169,6 +635,38 @@ ALTER TABLE ONLY public.posts ALTER COLUMN id SET DEFAULT nextval('public.posts_
 ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
 
 
+--
+-- Name: agent_interests agent_interests_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_interests
+    ADD CONSTRAINT agent_interests_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: agent_messages agent_messages_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_messages
+    ADD CONSTRAINT agent_messages_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: agent_profiles agent_profiles_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_profiles
+    ADD CONSTRAINT agent_profiles_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: agent_schedules agent_schedules_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_schedules
+    ADD CONSTRAINT agent_schedules_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: agent_states agent_states_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -177,6 +675,38 @@ ALTER TABLE ONLY public.agent_states
     ADD CONSTRAINT agent_states_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: agent_threads agent_threads_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_threads
+    ADD CONSTRAINT agent_threads_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: agent_trackings agent_trackings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.agent_trackings
+    ADD CONSTRAINT agent_trackings_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: client_events client_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.client_events
+    ADD CONSTRAINT client_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: event_outbox event_outbox_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.event_outbox
+    ADD CONSTRAINT event_outbox_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: posts posts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -185,6 +715,14 @@ ALTER TABLE ONLY public.posts
     ADD CONSTRAINT posts_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: pubsub_events pubsub_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.pubsub_events
+    ADD CONSTRAINT pubsub_events_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -193,6 +731,14 @@ ALTER TABLE ONLY public.schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
 
 
+--
+-- Name: tool_approvals tool_approvals_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tool_approvals
+    ADD CONSTRAINT tool_approvals_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -201,6 +747,69 @@ ALTER TABLE ONLY public.users
     ADD CONSTRAINT users_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: agent_interests_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_interests_agent_id_idx ON public.agent_interests USING btree (agent_id);
+
+
+--
+-- Name: agent_interests_interest_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_interests_interest_id_idx ON public.agent_interests USING btree (interest_id);
+
+
+--
+-- Name: agent_messages_message_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_messages_message_id_idx ON public.agent_messages USING btree (message_id);
+
+
+--
+-- Name: agent_messages_thread_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_messages_thread_id_idx ON public.agent_messages USING btree (thread_id);
+
+
+--
+-- Name: agent_messages_to_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_messages_to_agent_id_idx ON public.agent_messages USING btree (to_agent_id);
+
+
+--
+-- Name: agent_profiles_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_profiles_agent_id_idx ON public.agent_profiles USING btree (agent_id);
+
+
+--
+-- Name: agent_profiles_tags_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_profiles_tags_idx ON public.agent_profiles USING gin (tags);
+
+
+--
+-- Name: agent_schedules_next_run_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_schedules_next_run_at_idx ON public.agent_schedules USING btree (next_run_at);
+
+
+--
+-- Name: agent_schedules_schedule_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_schedules_schedule_id_idx ON public.agent_schedules USING btree (schedule_id);
+
+
 --
 -- Name: agent_states_agent_id_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -208,6 +817,125 @@ ALTER TABLE ONLY public.users
 CREATE UNIQUE INDEX agent_states_agent_id_idx ON public.agent_states USING btree (agent_id);
 
 
+--
+-- Name: agent_states_wake_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_states_wake_at_idx ON public.agent_states USING btree (wake_at);
+
+
+--
+-- Name: agent_threads_initiator_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_threads_initiator_agent_id_idx ON public.agent_threads USING btree (initiator_agent_id);
+
+
+--
+-- Name: agent_threads_responder_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_threads_responder_agent_id_idx ON public.agent_threads USING btree (responder_agent_id);
+
+
+--
+-- Name: agent_threads_thread_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_threads_thread_id_idx ON public.agent_threads USING btree (thread_id);
+
+
+--
+-- Name: agent_trackings_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX agent_trackings_agent_id_idx ON public.agent_trackings USING btree (agent_id);
+
+
+--
+-- Name: agent_trackings_node_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX agent_trackings_node_id_idx ON public.agent_trackings USING btree (node_id);
+
+
+--
+-- Name: client_events_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX client_events_agent_id_idx ON public.client_events USING btree (agent_id, id);
+
+
+--
+-- Name: client_events_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX client_events_created_at_idx ON public.client_events USING btree (created_at);
+
+
+--
+-- Name: client_events_event_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX client_events_event_id_idx ON public.client_events USING btree (event_id);
+
+
+--
+-- Name: client_events_owner_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX client_events_owner_idx ON public.client_events USING btree (owner, id);
+
+
+--
+-- Name: event_outbox_delivered_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX event_outbox_delivered_at_idx ON public.event_outbox USING btree (delivered_at);
+
+
+--
+-- Name: event_outbox_message_key_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX event_outbox_message_key_idx ON public.event_outbox USING btree (message_key, id) WHERE (delivered_at IS NULL);
+
+
+--
+-- Name: event_outbox_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX event_outbox_next_attempt_at_idx ON public.event_outbox USING btree (next_attempt_at) WHERE (delivered_at IS NULL);
+
+
+--
+-- Name: pubsub_events_created_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX pubsub_events_created_at_idx ON public.pubsub_events USING btree (created_at);
+
+
+--
+-- Name: pubsub_events_topic_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX pubsub_events_topic_id_idx ON public.pubsub_events USING btree (topic, id);
+
+
+--
+-- Name: tool_approvals_agent_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX tool_approvals_agent_id_idx ON public.tool_approvals USING btree (agent_id);
+
+
+--
+-- Name: tool_approvals_approval_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX tool_approvals_approval_id_idx ON public.tool_approvals USING btree (approval_id);
+
+
 --
 -- Name: posts posts_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -41,12 +41,15 @@ func main() {
 		AgentLifeTime: 3 * time.Second,
 	}
 	controller := control_plane.NewAgentController(controllerConfig, storage, tracker)
-	scheduler := control_plane.NewScheduler(ctx, nil)
-	pubSub := pubsub.NewKafkaPubSub()
+	scheduler := control_plane.NewScheduler(ctx, control_plane.SchedulerConfig{}, nil)
+	pubSub := pubsub.NewPubSub()
 	defer pubSub.Close()
+	// Agents write their responses to the outbox, the relay publishes them
+	relay := control_plane.NewOutboxRelay(control_plane.OutboxRelayConfig{}, storage, pubSub)
+	go relay.Start(ctx)
 
 	go func() {
-		err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string) error {
+		_, err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string, headers pubsub.Headers) error {
 			slog.Info("Received PubSub response", "message", message)
 			return nil
 		})
@@ -75,7 +78,8 @@ func main() {
 			slog.Info("Agent terminating", "agent_id", agentID, "status", status)
 		},
 	}
-	controlPlane := control_plane.NewControlPlane(agentFactory, storage, provider, controller, scheduler, pubSub, callbacks, workerCallbacks)
+	runQueue := control_plane.NewRunQueue(control_plane.RunQueueConfig{})
+	controlPlane := control_plane.NewControlPlane(agentFactory, storage, provider, controller, scheduler, runQueue, pubSub, callbacks, workerCallbacks)
 
 	doneCh := make(chan struct{})
 
@@ -94,11 +98,12 @@ func main() {
 	}
 
 	for _, task := range tasks {
-		err := controlPlane.KickoffTask(ctx, task, "consumer")
+		agentID, _, err := controlPlane.KickoffTask(ctx, task, "consumer", worker.TaskMetadata{})
 		if err != nil {
 			slog.Error("Error kicking off task", "error", err)
 			panic(err)
 		}
+		slog.Info("Kicked off task", "agent_id", agentID)
 	}
 
 	time.Sleep(5 * time.Second)
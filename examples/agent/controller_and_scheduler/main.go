@@ -38,11 +38,14 @@ func main() {
 	tracker := control_plane.NewMemoryAgentTracker()
 	client := openai.NewClient(option.WithAPIKey(config.Config.OpenAI.APIKey))
 	provider := providers.NewOpenAIChatProvider(client)
-	pubSub := pubsub.NewKafkaPubSub()
+	pubSub := pubsub.NewPubSub()
 	defer pubSub.Close()
+	// Agents write their responses to the outbox, the relay publishes them
+	relay := control_plane.NewOutboxRelay(control_plane.OutboxRelayConfig{}, storage, pubSub)
+	go relay.Start(ctx)
 
 	go func() {
-		err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string) error {
+		_, err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string, headers pubsub.Headers) error {
 			slog.Info("Received PubSub response", "message", message)
 			return nil
 		})
@@ -101,7 +104,7 @@ func main() {
 		slog.Info("Registered agent", "agent_id", agentID)
 	}
 
-	scheduler := control_plane.NewScheduler(ctx, onAgentFound)
+	scheduler := control_plane.NewScheduler(ctx, control_plane.SchedulerConfig{}, onAgentFound)
 	go func() {
 		defer wg.Done()
 		err := scheduler.Start(ctx)
@@ -15,6 +15,7 @@ import (
 	"github.com/roackb2/lucid/internal/pkg/agents/providers"
 	"github.com/roackb2/lucid/internal/pkg/agents/storage"
 	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
 	"github.com/roackb2/lucid/internal/pkg/pubsub"
 	"github.com/roackb2/lucid/internal/pkg/utils"
 )
@@ -42,11 +43,14 @@ func main() {
 
 	client := openai.NewClient(option.WithAPIKey(config.Config.OpenAI.APIKey))
 	provider := providers.NewOpenAIChatProvider(client)
-	pubSub := pubsub.NewKafkaPubSub()
+	pubSub := pubsub.NewPubSub()
 	defer pubSub.Close()
+	// Agents write their responses to the outbox, the relay publishes them
+	relay := control_plane.NewOutboxRelay(control_plane.OutboxRelayConfig{}, storage, pubSub)
+	go relay.Start(ctx)
 
 	go func() {
-		err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string) error {
+		_, err := pubSub.Subscribe(worker.GetAgentResponseGeneralTopic(), func(message string, headers pubsub.Headers) error {
 			slog.Info("Received PubSub response", "message", message)
 			return nil
 		})
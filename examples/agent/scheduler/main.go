@@ -34,7 +34,7 @@ func main() {
 		slog.Info("Scheduler: Agent found", "agentID", agent.AgentID)
 	}
 
-	scheduler := control_plane.NewScheduler(ctx, onAgentFound)
+	scheduler := control_plane.NewScheduler(ctx, control_plane.SchedulerConfig{}, onAgentFound)
 	go func() {
 		err := scheduler.Start(ctx)
 		if err != nil {
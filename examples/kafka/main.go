@@ -19,14 +19,16 @@ func main() {
 
 	topic := fmt.Sprintf("%s_response", uuid.New().String())
 
-	messageCallback := func(message string) error {
+	messageCallback := func(message string, headers pubsub.Headers) error {
 		slog.Info("KafkaPubSub: received message", "message", message)
 		return nil
 	}
-	err := kafkaPubSub.Subscribe(topic, messageCallback)
+	subscription, err := kafkaPubSub.Subscribe(topic, messageCallback)
 	if err != nil {
 		slog.Error("KafkaPubSub: failed to subscribe", "error", err)
+		return
 	}
+	defer subscription.Unsubscribe()
 
 	publishTimeout := 10 * time.Second
 
@@ -2,18 +2,43 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
 	"github.com/roackb2/lucid/internal/pkg/control_plane"
 )
 
 type StartAgentRequest struct {
-	Role string `json:"role" binding:"required"`
-	Task string `json:"task" binding:"required"`
+	Role         string            `json:"role" binding:"required"`
+	Task         string            `json:"task" binding:"required"`
+	Owner        string            `json:"owner"`
+	Labels       map[string]string `json:"labels"`
+	Budget       int               `json:"budget"`
+	CustomPrompt string            `json:"custom_prompt"`
+	Priority     int               `json:"priority"`
+	WakePolicy   string            `json:"wake_policy" enums:"fixed,backoff,event,round_robin"`
+	Interests    []string          `json:"interests"`
+	// Description tells what the agent offers or seeks, it is published in the agent directory with Tags
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
 }
 
+type StartAgentResponse struct {
+	AgentID  string `json:"agent_id"`
+	Status   string `json:"status"`
+	Response string `json:"response,omitempty"`
+}
+
+const (
+	// StartAgentWaitTimeout bounds how long StartAgent long-polls for the agent's result
+	StartAgentWaitTimeout = 60 * time.Second
+)
+
 type AgentRouterController struct {
 	ctx          context.Context
 	controlPlane control_plane.ControlPlane
@@ -28,14 +53,19 @@ func NewAgentRouterController(ctx context.Context, controlPlane control_plane.Co
 
 // StartAgent godoc
 // @Summary Start a new agent
-// @Description Starts a new agent with role and task
+// @Description Starts a new agent with role and task and returns its ID.
+// @Description With wait=true the request long-polls until the agent reports or the wait times out.
 // @Tags agents
 // @Accept json
 // @Produce json
 // @Param agent body StartAgentRequest true "Agent details"
-// @Success 201 {object} map[string]string "Agent created successfully"
+// @Param wait query bool false "Wait for the agent's result"
+// @Success 200 {object} StartAgentResponse "Agent finished the task"
+// @Success 202 {object} StartAgentResponse "Agent started, asleep, awaiting approval, or awaiting input with its question as the response"
 // @Failure 400 {object} map[string]string "Bad request"
+// @Failure 429 {object} map[string]string "Too many queued agents"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Server is shutting down"
 // @Router /api/v1/agents/create [post]
 func (ac *AgentRouterController) StartAgent(c *gin.Context) {
 	var agent StartAgentRequest
@@ -43,14 +73,208 @@ func (ac *AgentRouterController) StartAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	wait := c.Query("wait") == "true"
 
-	err := ac.controlPlane.KickoffTask(ac.ctx, agent.Task, agent.Role)
+	metadata := worker.TaskMetadata{
+		Owner:        agent.Owner,
+		Labels:       agent.Labels,
+		Budget:       agent.Budget,
+		CustomPrompt: agent.CustomPrompt,
+		Priority:     agent.Priority,
+		WakePolicy:   agent.WakePolicy,
+		Interests:    agent.Interests,
+		Description:  agent.Description,
+		Tags:         agent.Tags,
+	}
+	agentID, handle, err := ac.controlPlane.KickoffTask(ac.ctx, agent.Task, agent.Role, metadata)
+	if errors.Is(err, control_plane.ErrRunQueueFull) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, control_plane.ErrRunQueueClosed) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	slog.Info("Starting agent", "role", agent.Role, "task", agent.Task)
+	slog.Info("Starting agent", "agent_id", agentID, "role", agent.Role, "task", agent.Task)
+
+	if !wait {
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusRunning})
+		return
+	}
+
+	writeTaskResult(c, agentID, handle)
+}
+
+// writeTaskResult long-polls the task handle and writes the agent's result,
+// or that the agent is still running if the wait times out.
+func writeTaskResult(c *gin.Context, agentID string, handle *control_plane.TaskHandle) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), StartAgentWaitTimeout)
+	defer cancel()
+	resp, err := handle.Wait(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusRunning})
+	case errors.Is(err, control_plane.ErrAgentAsleep):
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusAsleep})
+	case errors.Is(err, control_plane.ErrAgentAwaitingInput):
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusAwaitingInput, Response: resp.Message})
+	case errors.Is(err, control_plane.ErrAgentAwaitingApproval):
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusAwaitingApproval})
+	case errors.Is(err, control_plane.ErrRunQueueClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"agent_id": agentID, "error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"agent_id": agentID, "error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, StartAgentResponse{AgentID: agentID, Status: worker.StatusTerminated, Response: resp.Message})
+	}
+}
+
+type SendAgentCommandRequest struct {
+	Command string `json:"command" binding:"required" enums:"pause,resume,sleep,terminate,retry"`
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Agent created successfully"})
+// SendAgentCommand godoc
+// @Summary Send a command to an agent
+// @Description Sends a pause, resume, sleep or terminate command to a single agent.
+// @Description Failed agents are skipped by the scheduler until they receive the retry command.
+// @Description Agents awaiting input or approval can only be terminated until the user responds.
+// @Tags agents
+// @Accept json
+// @Produce json
+// @Param id path string true "Agent ID"
+// @Param command body SendAgentCommandRequest true "Command details"
+// @Success 200 {object} map[string]string "Command sent successfully"
+// @Failure 400 {object} map[string]string "Bad request or unknown command"
+// @Failure 404 {object} map[string]string "Agent not found"
+// @Failure 409 {object} map[string]string "Command does not apply to the agent's status"
+// @Failure 429 {object} map[string]string "Too many queued agents"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Server is shutting down"
+// @Router /api/v1/agents/{id}/command [post]
+func (ac *AgentRouterController) SendAgentCommand(c *gin.Context) {
+	agentID := c.Param("id")
+	var req SendAgentCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ac.controlPlane.SendAgentCommand(ac.ctx, agentID, req.Command)
+	switch {
+	case errors.Is(err, control_plane.ErrInvalidAgentCommand):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, storage.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrAgentTerminated),
+		errors.Is(err, control_plane.ErrAgentNotFailed),
+		errors.Is(err, control_plane.ErrAgentCommandNotAllowed),
+		errors.Is(err, control_plane.ErrAgentFailed),
+		errors.Is(err, control_plane.ErrAgentBusy),
+		errors.Is(err, control_plane.ErrAgentAwaitingInput),
+		errors.Is(err, control_plane.ErrAgentAwaitingApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueFull):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Sent agent command", "agent_id", agentID, "command", req.Command)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Command sent successfully"})
+}
+
+type AnswerAgentRequest struct {
+	Answer string `json:"answer" binding:"required"`
+}
+
+// AnswerAgent godoc
+// @Summary Answer an agent's question
+// @Description Resumes an agent awaiting input, the answer is given to the agent as the next user message.
+// @Description With wait=true the request long-polls until the agent reports or the wait times out.
+// @Tags agents
+// @Accept json
+// @Produce json
+// @Param id path string true "Agent ID"
+// @Param answer body AnswerAgentRequest true "Answer details"
+// @Param wait query bool false "Wait for the agent's result"
+// @Success 200 {object} StartAgentResponse "Agent finished the task"
+// @Success 202 {object} StartAgentResponse "Agent resumed, asleep, awaiting approval, or awaiting input with its next question as the response"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 409 {object} map[string]string "Agent is not awaiting input"
+// @Failure 429 {object} map[string]string "Too many queued agents"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Server is shutting down"
+// @Router /api/v1/agents/{id}/answer [post]
+func (ac *AgentRouterController) AnswerAgent(c *gin.Context) {
+	agentID := c.Param("id")
+	var req AnswerAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wait := c.Query("wait") == "true"
+
+	handle, err := ac.controlPlane.AnswerAgent(ac.ctx, agentID, req.Answer)
+	switch {
+	case errors.Is(err, control_plane.ErrAgentNotAwaitingInput):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueFull):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Answered agent", "agent_id", agentID)
+
+	if !wait {
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: agentID, Status: worker.StatusRunning})
+		return
+	}
+	writeTaskResult(c, agentID, handle)
+}
+
+type RunQueueStatsResponse struct {
+	Pending        int            `json:"pending"`
+	Running        int            `json:"running"`
+	MaxRunning     int            `json:"max_running"`
+	RunningByRole  map[string]int `json:"running_by_role"`
+	RunningByOwner map[string]int `json:"running_by_owner"`
+}
+
+// GetRunQueueStats godoc
+// @Summary Get the run queue stats
+// @Description Returns the number of running and pending agents on the node serving the request
+// @Tags agents
+// @Produce json
+// @Success 200 {object} RunQueueStatsResponse "Run queue stats"
+// @Router /api/v1/agents/queue [get]
+func (ac *AgentRouterController) GetRunQueueStats(c *gin.Context) {
+	stats := ac.controlPlane.GetRunQueueStats()
+	c.JSON(http.StatusOK, RunQueueStatsResponse{
+		Pending:        stats.Pending,
+		Running:        stats.Running,
+		MaxRunning:     stats.MaxRunning,
+		RunningByRole:  stats.RunningByRole,
+		RunningByOwner: stats.RunningByOwner,
+	})
 }
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+)
+
+type ToolApprovalResponse struct {
+	ApprovalID      string     `json:"approval_id"`
+	AgentID         string     `json:"agent_id"`
+	Owner           string     `json:"owner"`
+	Role            string     `json:"role"`
+	ToolName        string     `json:"tool_name"`
+	Arguments       string     `json:"arguments"`
+	Status          string     `json:"status" enums:"pending,approved,rejected"`
+	EditedArguments string     `json:"edited_arguments,omitempty"`
+	Reason          string     `json:"reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty"`
+}
+
+type DecideToolApprovalRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject" enums:"approve,reject"`
+	// EditedArguments is a JSON object replacing the arguments of an approved call
+	EditedArguments string `json:"edited_arguments" example:"{\"content\": \"edited post\"}"`
+	Reason          string `json:"reason"`
+}
+
+type ApprovalRouterController struct {
+	ctx          context.Context
+	controlPlane control_plane.ControlPlane
+}
+
+func NewApprovalRouterController(ctx context.Context, controlPlane control_plane.ControlPlane) *ApprovalRouterController {
+	return &ApprovalRouterController{
+		ctx:          ctx,
+		controlPlane: controlPlane,
+	}
+}
+
+// ListToolApprovals godoc
+// @Summary List tool call approvals
+// @Tags approvals
+// @Produce json
+// @Param owner query string false "Only list the approvals of this owner's agents"
+// @Param status query string false "Only list the approvals in this status" Enums(pending, approved, rejected)
+// @Success 200 {array} ToolApprovalResponse "Approvals"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/approvals [get]
+func (ac *ApprovalRouterController) ListToolApprovals(c *gin.Context) {
+	approvals, err := ac.controlPlane.ListToolApprovals(ac.ctx, c.Query("owner"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]ToolApprovalResponse, 0, len(approvals))
+	for _, approval := range approvals {
+		resp = append(resp, toToolApprovalResponse(approval))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DecideToolApproval godoc
+// @Summary Decide on a tool call
+// @Description Approves, possibly with edited arguments, or rejects a tool call parked by an agent, and resumes the agent.
+// @Description The decision is recorded in the agent's chat history. With wait=true the request long-polls until the agent reports or the wait times out.
+// @Tags approvals
+// @Accept json
+// @Produce json
+// @Param id path string true "Approval ID"
+// @Param decision body DecideToolApprovalRequest true "Decision details"
+// @Param wait query bool false "Wait for the agent's result"
+// @Success 200 {object} StartAgentResponse "Agent finished the task"
+// @Success 202 {object} StartAgentResponse "Agent resumed, asleep, or awaiting the user again"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Approval not found"
+// @Failure 409 {object} map[string]string "Approval already decided or agent no longer waiting"
+// @Failure 429 {object} map[string]string "Too many queued agents"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Server is shutting down"
+// @Router /api/v1/approvals/{id}/decision [post]
+func (ac *ApprovalRouterController) DecideToolApproval(c *gin.Context) {
+	approvalID := c.Param("id")
+	var req DecideToolApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	wait := c.Query("wait") == "true"
+
+	decision := control_plane.ToolApprovalDecision{
+		Approved:        req.Decision == "approve",
+		EditedArguments: req.EditedArguments,
+		Reason:          req.Reason,
+	}
+	handle, err := ac.controlPlane.DecideToolApproval(ac.ctx, approvalID, decision)
+	switch {
+	case errors.Is(err, control_plane.ErrInvalidApprovalDecision):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, storage.ErrToolApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, storage.ErrToolApprovalNotPending), errors.Is(err, control_plane.ErrAgentNotAwaitingApproval):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueFull):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, control_plane.ErrRunQueueClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Decided tool approval", "approval_id", approvalID, "decision", req.Decision)
+
+	if !wait {
+		c.JSON(http.StatusAccepted, StartAgentResponse{AgentID: handle.AgentID(), Status: worker.StatusRunning})
+		return
+	}
+	writeTaskResult(c, handle.AgentID(), handle)
+}
+
+func toToolApprovalResponse(approval storage.ToolApproval) ToolApprovalResponse {
+	return ToolApprovalResponse{
+		ApprovalID:      approval.ApprovalID,
+		AgentID:         approval.AgentID,
+		Owner:           approval.Owner,
+		Role:            approval.Role,
+		ToolName:        approval.ToolName,
+		Arguments:       approval.Arguments,
+		Status:          approval.Status,
+		EditedArguments: approval.EditedArguments,
+		Reason:          approval.Reason,
+		CreatedAt:       approval.CreatedAt,
+		DecidedAt:       approval.DecidedAt,
+	}
+}
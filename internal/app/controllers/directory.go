@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+)
+
+type AgentProfileResponse struct {
+	AgentID     string    `json:"agent_id"`
+	Owner       string    `json:"owner"`
+	Role        string    `json:"role"`
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type DirectoryRouterController struct {
+	ctx          context.Context
+	controlPlane control_plane.ControlPlane
+}
+
+func NewDirectoryRouterController(ctx context.Context, controlPlane control_plane.ControlPlane) *DirectoryRouterController {
+	return &DirectoryRouterController{
+		ctx:          ctx,
+		controlPlane: controlPlane,
+	}
+}
+
+// SearchAgentProfiles godoc
+// @Summary Browse the agent directory
+// @Description Lists the profiles agents published, best matches of the query first.
+// @Description Terminated and failed agents are left out unless include_inactive=true.
+// @Tags directory
+// @Produce json
+// @Param query query string false "What the agents offer or seek, matched by text and by meaning"
+// @Param role query string false "Only list agents of this role"
+// @Param owner query string false "Only list the agents of this owner"
+// @Param tag query []string false "Only list agents having all these tags" collectionFormat(multi)
+// @Param include_inactive query bool false "Also list terminated and failed agents"
+// @Param limit query int false "Maximum number of profiles, 20 by default and at most 100"
+// @Success 200 {array} AgentProfileResponse "Profiles"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/directory [get]
+func (dc *DirectoryRouterController) SearchAgentProfiles(c *gin.Context) {
+	query := storage.AgentProfileQuery{
+		Query: c.Query("query"),
+		Role:  c.Query("role"),
+		Owner: c.Query("owner"),
+		Tags:  c.QueryArray("tag"),
+	}
+	if c.Query("include_inactive") != "true" {
+		query.ExcludedStatuses = []string{worker.StatusTerminated, worker.StatusFailed}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		query.Limit = n
+	}
+	profiles, err := dc.controlPlane.SearchAgentProfiles(dc.ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]AgentProfileResponse, 0, len(profiles))
+	for _, profile := range profiles {
+		resp = append(resp, toAgentProfileResponse(profile))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAgentProfile godoc
+// @Summary Get an agent's profile
+// @Tags directory
+// @Produce json
+// @Param id path string true "Agent ID"
+// @Success 200 {object} AgentProfileResponse "Profile"
+// @Failure 404 {object} map[string]string "Agent has no profile"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/directory/{id} [get]
+func (dc *DirectoryRouterController) GetAgentProfile(c *gin.Context) {
+	profile, err := dc.controlPlane.GetAgentProfile(dc.ctx, c.Param("id"))
+	if errors.Is(err, storage.ErrAgentProfileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toAgentProfileResponse(*profile))
+}
+
+func toAgentProfileResponse(profile storage.AgentProfile) AgentProfileResponse {
+	tags := profile.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return AgentProfileResponse{
+		AgentID:     profile.AgentID,
+		Owner:       profile.Owner,
+		Role:        profile.Role,
+		Description: profile.Description,
+		Tags:        tags,
+		UpdatedAt:   profile.UpdatedAt,
+	}
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+)
+
+type CreateScheduleRequest struct {
+	Role         string `json:"role" binding:"required"`
+	TaskTemplate string `json:"task_template" binding:"required"`
+	Owner        string `json:"owner"`
+	// Either cron_expression or interval is required
+	CronExpression string `json:"cron_expression" example:"0 9 * * mon-fri"`
+	// Interval is a Go duration such as 30m or 24h
+	Interval        string `json:"interval" example:"24h"`
+	TimeZone        string `json:"time_zone" example:"Asia/Taipei"`
+	MissedRunPolicy string `json:"missed_run_policy" enums:"run_once,skip"`
+}
+
+type ScheduleResponse struct {
+	ScheduleID      string     `json:"schedule_id"`
+	Owner           string     `json:"owner"`
+	Role            string     `json:"role"`
+	TaskTemplate    string     `json:"task_template"`
+	CronExpression  string     `json:"cron_expression,omitempty"`
+	Interval        string     `json:"interval,omitempty"`
+	TimeZone        string     `json:"time_zone"`
+	MissedRunPolicy string     `json:"missed_run_policy"`
+	Status          string     `json:"status"`
+	AgentID         string     `json:"agent_id,omitempty"`
+	LastResult      string     `json:"last_result,omitempty"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type ScheduleRouterController struct {
+	ctx           context.Context
+	taskScheduler control_plane.TaskScheduler
+}
+
+func NewScheduleRouterController(ctx context.Context, taskScheduler control_plane.TaskScheduler) *ScheduleRouterController {
+	return &ScheduleRouterController{
+		ctx:           ctx,
+		taskScheduler: taskScheduler,
+	}
+}
+
+// CreateSchedule godoc
+// @Summary Create a schedule
+// @Description Creates a recurring task. Each run renders the task template and starts an agent, or resumes the agent of the previous run with its result.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param schedule body CreateScheduleRequest true "Schedule details"
+// @Success 201 {object} ScheduleResponse "Schedule created"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/schedules [post]
+func (sc *ScheduleRouterController) CreateSchedule(c *gin.Context) {
+	var req CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var interval time.Duration
+	if req.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(req.Interval)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	definition := control_plane.ScheduleDefinition{
+		Owner:           req.Owner,
+		Role:            req.Role,
+		TaskTemplate:    req.TaskTemplate,
+		CronExpression:  req.CronExpression,
+		Interval:        interval,
+		TimeZone:        req.TimeZone,
+		MissedRunPolicy: req.MissedRunPolicy,
+	}
+	schedule, err := sc.taskScheduler.CreateSchedule(sc.ctx, definition)
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toScheduleResponse(schedule))
+}
+
+// ListSchedules godoc
+// @Summary List schedules
+// @Tags schedules
+// @Produce json
+// @Param owner query string false "Only list the schedules of this owner"
+// @Success 200 {array} ScheduleResponse "Schedules"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/schedules [get]
+func (sc *ScheduleRouterController) ListSchedules(c *gin.Context) {
+	schedules, err := sc.taskScheduler.ListSchedules(sc.ctx, c.Query("owner"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]ScheduleResponse, 0, len(schedules))
+	for i := range schedules {
+		resp = append(resp, toScheduleResponse(&schedules[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// PauseSchedule godoc
+// @Summary Pause a schedule
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} ScheduleResponse "Schedule paused"
+// @Failure 404 {object} map[string]string "Schedule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/schedules/{id}/pause [post]
+func (sc *ScheduleRouterController) PauseSchedule(c *gin.Context) {
+	schedule, err := sc.taskScheduler.PauseSchedule(sc.ctx, c.Param("id"))
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toScheduleResponse(schedule))
+}
+
+// ResumeSchedule godoc
+// @Summary Resume a schedule
+// @Description Reactivates a paused schedule from its next run, runs missed while paused are skipped
+// @Tags schedules
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} ScheduleResponse "Schedule resumed"
+// @Failure 404 {object} map[string]string "Schedule not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/schedules/{id}/resume [post]
+func (sc *ScheduleRouterController) ResumeSchedule(c *gin.Context) {
+	schedule, err := sc.taskScheduler.ResumeSchedule(sc.ctx, c.Param("id"))
+	if err != nil {
+		writeScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toScheduleResponse(schedule))
+}
+
+func writeScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, control_plane.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, control_plane.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func toScheduleResponse(schedule *control_plane.AgentSchedule) ScheduleResponse {
+	resp := ScheduleResponse{
+		ScheduleID:      schedule.ScheduleID,
+		Owner:           schedule.Owner,
+		Role:            schedule.Role,
+		TaskTemplate:    schedule.TaskTemplate,
+		CronExpression:  schedule.CronExpression,
+		TimeZone:        schedule.TimeZone,
+		MissedRunPolicy: schedule.MissedRunPolicy,
+		Status:          schedule.Status,
+		AgentID:         schedule.AgentID,
+		LastResult:      schedule.LastResult,
+		NextRunAt:       schedule.NextRunAt,
+		LastRunAt:       schedule.LastRunAt,
+		CreatedAt:       schedule.CreatedAt,
+	}
+	if schedule.Interval > 0 {
+		resp.Interval = schedule.Interval.String()
+	}
+	return resp
+}
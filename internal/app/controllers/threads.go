@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+)
+
+type AgentThreadResponse struct {
+	ThreadID          string    `json:"thread_id"`
+	InitiatorAgentID  string    `json:"initiator_agent_id"`
+	ResponderAgentID  string    `json:"responder_agent_id"`
+	Subject           string    `json:"subject"`
+	Status            string    `json:"status"`
+	LastSenderAgentID string    `json:"last_sender_agent_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type AgentThreadMessageResponse struct {
+	MessageID   string     `json:"message_id"`
+	FromAgentID string     `json:"from_agent_id"`
+	ToAgentID   string     `json:"to_agent_id"`
+	Kind        string     `json:"kind"`
+	Content     string     `json:"content"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+type AgentThreadHistoryResponse struct {
+	AgentThreadResponse
+	Messages []AgentThreadMessageResponse `json:"messages"`
+}
+
+type ThreadRouterController struct {
+	ctx          context.Context
+	controlPlane control_plane.ControlPlane
+}
+
+func NewThreadRouterController(ctx context.Context, controlPlane control_plane.ControlPlane) *ThreadRouterController {
+	return &ThreadRouterController{
+		ctx:          ctx,
+		controlPlane: controlPlane,
+	}
+}
+
+// ListAgentThreads godoc
+// @Summary List an agent's threads
+// @Description Lists the threads the agent opened or was asked in, most recently active first.
+// @Tags threads
+// @Produce json
+// @Param agent_id query string true "Agent ID"
+// @Success 200 {array} AgentThreadResponse "Threads"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/threads [get]
+func (tc *ThreadRouterController) ListAgentThreads(c *gin.Context) {
+	agentID := c.Query("agent_id")
+	if agentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id is required"})
+		return
+	}
+	threads, err := tc.controlPlane.ListAgentThreads(tc.ctx, agentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := make([]AgentThreadResponse, 0, len(threads))
+	for _, thread := range threads {
+		resp = append(resp, toAgentThreadResponse(thread))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAgentThread godoc
+// @Summary Get a thread with its full history
+// @Tags threads
+// @Produce json
+// @Param id path string true "Thread ID"
+// @Success 200 {object} AgentThreadHistoryResponse "Thread"
+// @Failure 404 {object} map[string]string "Thread not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/threads/{id} [get]
+func (tc *ThreadRouterController) GetAgentThread(c *gin.Context) {
+	history, err := tc.controlPlane.GetAgentThread(tc.ctx, c.Param("id"))
+	if errors.Is(err, storage.ErrAgentThreadNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := AgentThreadHistoryResponse{
+		AgentThreadResponse: toAgentThreadResponse(history.Thread),
+		Messages:            make([]AgentThreadMessageResponse, 0, len(history.Messages)),
+	}
+	for _, message := range history.Messages {
+		resp.Messages = append(resp.Messages, AgentThreadMessageResponse{
+			MessageID:   message.MessageID,
+			FromAgentID: message.FromAgentID,
+			ToAgentID:   message.ToAgentID,
+			Kind:        message.Kind,
+			Content:     message.Content,
+			CreatedAt:   message.CreatedAt,
+			ReadAt:      message.ReadAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func toAgentThreadResponse(thread storage.AgentThread) AgentThreadResponse {
+	return AgentThreadResponse{
+		ThreadID:          thread.ThreadID,
+		InitiatorAgentID:  thread.InitiatorAgentID,
+		ResponderAgentID:  thread.ResponderAgentID,
+		Subject:           thread.Subject,
+		Status:            thread.Status,
+		LastSenderAgentID: thread.LastSenderAgentID,
+		CreatedAt:         thread.CreatedAt,
+		UpdatedAt:         thread.UpdatedAt,
+	}
+}
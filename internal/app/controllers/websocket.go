@@ -4,10 +4,15 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
-	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
 	"github.com/roackb2/lucid/internal/pkg/ws"
 )
 
@@ -16,20 +21,66 @@ import (
 // this controller is to abstract from the actual implementation of the gorilla/websocket package
 // and to provide a clean interface for the websocket connections.
 type WebsocketController struct {
-	ctx      context.Context
-	upgrader websocket.Upgrader
-	pubsub   pubsub.PubSub
+	ctx          context.Context
+	upgrader     websocket.Upgrader
+	eventLog     ws.EventLog
+	controlPlane control_plane.ControlPlane
+
+	// Upgraded connections are hijacked from the HTTP server, so they are tracked here to be closed on shutdown
+	mu    sync.Mutex
+	conns map[*websocket.Conn]struct{}
 }
 
-func NewWebsocketController(ctx context.Context, pubsub pubsub.PubSub) *WebsocketController {
+func NewWebsocketController(ctx context.Context, eventLog ws.EventLog, controlPlane control_plane.ControlPlane) *WebsocketController {
 	return &WebsocketController{ctx: ctx, upgrader: websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
-	}, pubsub: pubsub}
+	}, eventLog: eventLog, controlPlane: controlPlane, conns: make(map[*websocket.Conn]struct{})}
 }
 
+// Shutdown sends a close frame to every open connection and closes it.
+func (ac *WebsocketController) Shutdown() {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	slog.Info("Closing websocket connections", "num_connections", len(ac.conns))
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for conn := range ac.conns {
+		if err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+			slog.Warn("Failed to send websocket close frame", "error", err)
+		}
+		conn.Close()
+		delete(ac.conns, conn)
+	}
+}
+
+// SocketHandler upgrades the request to a websocket connection.
+// The owner query parameter identifies the user, whose agents the connection is subscribed to and who owns the tasks it starts.
+// The progress_level and progress_types (comma separated) query parameters set the initial progress filter,
+// which the client can change later with a set_progress_filter message.
+// The cursor query parameter is the cursor of the last event the client received,
+// the events it missed are sent before the live ones.
 func (ac *WebsocketController) SocketHandler(c *gin.Context) {
+	filter := ws.ProgressFilter{Level: worker.ProgressLevel(c.Query("progress_level"))}
+	if types := c.Query("progress_types"); types != "" {
+		for _, progressType := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, worker.ProgressType(strings.TrimSpace(progressType)))
+		}
+	}
+	if filter.Level != "" && !filter.Level.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown progress_level: " + string(filter.Level)})
+		return
+	}
+	var resumeCursor *int64
+	if cursorParam, ok := c.GetQuery("cursor"); ok {
+		cursor, err := strconv.ParseInt(cursorParam, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor: " + cursorParam})
+			return
+		}
+		resumeCursor = &cursor
+	}
+
 	slog.Info("Websocket connection established")
 	conn, err := ac.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -37,7 +88,20 @@ func (ac *WebsocketController) SocketHandler(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
+	ac.mu.Lock()
+	ac.conns[conn] = struct{}{}
+	ac.mu.Unlock()
+	defer func() {
+		ac.mu.Lock()
+		delete(ac.conns, conn)
+		ac.mu.Unlock()
+	}()
 
-	handler := ws.NewWsHandler(conn, ac.pubsub)
+	handler := ws.NewWsHandler(conn, ac.eventLog, ac.controlPlane, c.Query("owner"))
+	// The level was validated before upgrading
+	_ = handler.SetProgressFilter(filter)
+	if resumeCursor != nil {
+		handler.SetResumeCursor(*resumeCursor)
+	}
 	handler.HandleConnection(ac.ctx)
 }
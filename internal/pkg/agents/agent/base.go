@@ -35,6 +35,12 @@ func (b *BaseAgent) GetID() string {
 	return b.id
 }
 
+// SetID makes the agent adopt the ID of a persisted agent, call it before ResumeTask
+// so the agent is registered and addressed under its original ID.
+func (b *BaseAgent) SetID(agentID string) {
+	b.id = agentID
+}
+
 func (b *BaseAgent) GetStatus() string {
 	return b.worker.GetStatus()
 }
@@ -43,6 +49,19 @@ func (b *BaseAgent) GetRole() string {
 	return b.role
 }
 
+func (b *BaseAgent) SetMetadata(metadata worker.TaskMetadata) {
+	b.worker.SetMetadata(metadata)
+}
+
+// SetApprovalPolicy sets the policy deciding which of the agent's tool calls need the user's approval
+func (b *BaseAgent) SetApprovalPolicy(policy worker.ToolApprovalPolicy) {
+	b.worker.SetApprovalPolicy(policy)
+}
+
+func (b *BaseAgent) GetMetadata() worker.TaskMetadata {
+	return b.worker.GetMetadata()
+}
+
 func (b *BaseAgent) StartTask(ctx context.Context, callbacks worker.WorkerCallbacks) (*AgentResponse, error) {
 	slog.Info("Agent: Starting task", "role", b.role, "task", b.task)
 	response, err := b.worker.Chat(ctx, b.task, callbacks)
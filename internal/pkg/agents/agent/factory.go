@@ -3,15 +3,23 @@ package agent
 import (
 	"github.com/roackb2/lucid/internal/pkg/agents/providers"
 	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
 	"github.com/roackb2/lucid/internal/pkg/pubsub"
 )
 
-type RealAgentFactory struct{}
+type RealAgentFactory struct {
+	// ApprovalPolicy is applied to every agent the factory creates
+	ApprovalPolicy worker.ToolApprovalPolicy
+}
 
 func (f *RealAgentFactory) NewPublisherAgent(storage storage.Storage, task string, chatProvider providers.ChatProvider, pubSub pubsub.PubSub) Agent {
-	return NewPublisher(task, storage, chatProvider, pubSub)
+	publisher := NewPublisher(task, storage, chatProvider, pubSub)
+	publisher.SetApprovalPolicy(f.ApprovalPolicy)
+	return publisher
 }
 
 func (f *RealAgentFactory) NewConsumerAgent(storage storage.Storage, task string, chatProvider providers.ChatProvider, pubSub pubsub.PubSub) Agent {
-	return NewConsumer(task, storage, chatProvider, pubSub)
+	consumer := NewConsumer(task, storage, chatProvider, pubSub)
+	consumer.SetApprovalPolicy(f.ApprovalPolicy)
+	return consumer
 }
@@ -14,8 +14,11 @@ type AgentResponse struct {
 
 type Agent interface {
 	GetID() string
+	SetID(agentID string)
 	GetStatus() string
 	GetRole() string
+	SetMetadata(metadata worker.TaskMetadata)
+	GetMetadata() worker.TaskMetadata
 	StartTask(ctx context.Context, callbacks worker.WorkerCallbacks) (*AgentResponse, error)
 	ResumeTask(ctx context.Context, agentID string, newPrompt *string, callbacks worker.WorkerCallbacks) (*AgentResponse, error)
 	PersistState() error
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorKind classifies the errors of a ChatProvider, so the Worker can decide whether to retry.
+type ErrorKind string
+
+const (
+	// ErrorKindRateLimit means the provider throttled the request, it should be retried after a delay.
+	ErrorKindRateLimit ErrorKind = "rate_limit"
+	// ErrorKindTimeout means the request did not complete in time.
+	ErrorKindTimeout ErrorKind = "timeout"
+	// ErrorKindServer means the provider failed to handle a valid request.
+	ErrorKindServer ErrorKind = "server"
+	// ErrorKindInvalidRequest means the request was rejected, retrying it unchanged fails again.
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+	// ErrorKindAuth means the credentials were rejected, retrying fails until they are fixed.
+	ErrorKindAuth ErrorKind = "auth"
+	// ErrorKindUnknown is any other error, such as a network failure.
+	ErrorKindUnknown ErrorKind = "unknown"
+)
+
+// ProviderError is a classified ChatProvider error.
+type ProviderError struct {
+	Kind ErrorKind
+	// RetryAfter is the delay requested by the provider, zero if it did not ask for one.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the same request may succeed later.
+func (e *ProviderError) Retryable() bool {
+	return e.Kind != ErrorKindInvalidRequest && e.Kind != ErrorKindAuth
+}
+
+// ClassifyError returns err as a ProviderError.
+// Errors not classified by the provider are timeouts if they are deadline or network timeouts, unknown otherwise.
+func ClassifyError(err error) *ProviderError {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &ProviderError{Kind: ErrorKindTimeout, Err: err}
+	}
+	return &ProviderError{Kind: ErrorKindUnknown, Err: err}
+}
+
+// NewHTTPError classifies an error response of an HTTP based provider by its status code and Retry-After header.
+func NewHTTPError(statusCode int, header http.Header, err error) *ProviderError {
+	providerErr := &ProviderError{Err: err, RetryAfter: parseRetryAfter(header)}
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		providerErr.Kind = ErrorKindRateLimit
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		providerErr.Kind = ErrorKindAuth
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		providerErr.Kind = ErrorKindTimeout
+	case statusCode >= 500:
+		providerErr.Kind = ErrorKindServer
+	case statusCode >= 400:
+		providerErr.Kind = ErrorKindInvalidRequest
+	default:
+		providerErr.Kind = ErrorKindUnknown
+	}
+	return providerErr
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
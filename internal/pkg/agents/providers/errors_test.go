@@ -0,0 +1,44 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPError(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		kind       providers.ErrorKind
+		retryable  bool
+	}{
+		{statusCode: http.StatusTooManyRequests, kind: providers.ErrorKindRateLimit, retryable: true},
+		{statusCode: http.StatusUnauthorized, kind: providers.ErrorKindAuth, retryable: false},
+		{statusCode: http.StatusBadRequest, kind: providers.ErrorKindInvalidRequest, retryable: false},
+		{statusCode: http.StatusGatewayTimeout, kind: providers.ErrorKindTimeout, retryable: true},
+		{statusCode: http.StatusInternalServerError, kind: providers.ErrorKindServer, retryable: true},
+	}
+	for _, test := range tests {
+		err := providers.NewHTTPError(test.statusCode, http.Header{}, errors.New("api error"))
+		assert.Equal(t, test.kind, err.Kind, "status: %d", test.statusCode)
+		assert.Equal(t, test.retryable, err.Retryable(), "status: %d", test.statusCode)
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	err := providers.NewHTTPError(http.StatusTooManyRequests, header, errors.New("api error"))
+	assert.Equal(t, 7*time.Second, err.RetryAfter)
+}
+
+func TestClassifyError(t *testing.T) {
+	rateLimited := providers.NewHTTPError(http.StatusTooManyRequests, http.Header{}, errors.New("api error"))
+	assert.Same(t, rateLimited, providers.ClassifyError(fmt.Errorf("wrapped: %w", rateLimited)))
+	assert.Equal(t, providers.ErrorKindTimeout, providers.ClassifyError(context.DeadlineExceeded).Kind)
+	assert.Equal(t, providers.ErrorKindUnknown, providers.ClassifyError(errors.New("connection reset")).Kind)
+}
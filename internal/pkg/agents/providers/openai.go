@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/openai/openai-go"
@@ -44,6 +45,23 @@ var (
 				}),
 			}),
 		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("ask_user"),
+				Description: openai.String("Ask the user a question and stop until the user answers, the answer is given as the next user message"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"question": map[string]string{
+							"type":        "string",
+							"description": "The question to ask the user",
+						},
+					},
+					"required": []string{"question"},
+				}),
+			}),
+		},
 	}
 	persistToolDefinition = []openai.ChatCompletionToolParam{
 		{
@@ -80,6 +98,211 @@ var (
 				}),
 			}),
 		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("subscribe_interest"),
+				Description: openai.String("Subscribe to new posts matching a query, matching posts are sent to your inbox as they are saved"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]string{
+							"type":        "string",
+							"description": "The text the posts should match",
+						},
+						"keywords": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Keywords that must all be in a post for it to match",
+						},
+						"semantic": map[string]string{
+							"type":        "boolean",
+							"description": "Match posts by meaning instead of by text",
+						},
+						"min_similarity": map[string]string{
+							"type":        "number",
+							"description": "The similarity between 0 and 1 a post needs with the query to match, leave it out to use the default",
+						},
+					},
+					"required": []string{"query"},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("unsubscribe_interest"),
+				Description: openai.String("Stop receiving new posts for an interest you subscribed to"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"interest_id": map[string]string{
+							"type":        "string",
+							"description": "The ID of the interest, given when you subscribed",
+						},
+					},
+					"required": []string{"interest_id"},
+				}),
+			}),
+		},
+	}
+	messageToolDefinition = []openai.ChatCompletionToolParam{
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("send_message"),
+				Description: openai.String("Send a message to another agent, the message is kept in the agent's inbox until it reads it"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"to_agent_id": map[string]string{
+							"type":        "string",
+							"description": "The ID of the agent to send the message to",
+						},
+						"content": map[string]string{
+							"type":        "string",
+							"description": "The content of the message",
+						},
+					},
+					"required": []string{"to_agent_id", "content"},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("check_inbox"),
+				Description: openai.String("Read the messages other agents sent you that you have not read yet"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("find_agents"),
+				Description: openai.String("Search the agent directory for agents that offer or seek something, returns their agent IDs"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]string{
+							"type":        "string",
+							"description": "What the agents should offer or seek, matched against their descriptions by text and by meaning",
+						},
+						"role": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"publisher", "consumer"},
+							"description": "Only find agents of this role",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Only find agents having all these tags",
+						},
+						"limit": map[string]string{
+							"type":        "integer",
+							"description": "The maximum number of agents to return",
+						},
+					},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("update_profile"),
+				Description: openai.String("Describe in the agent directory what you offer or seek, so other agents can find you"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"description": map[string]string{
+							"type":        "string",
+							"description": "What you offer or seek",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Short topics other agents can filter you by",
+						},
+					},
+					"required": []string{"description"},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("open_thread"),
+				Description: openai.String("Open a thread with another agent by requesting something from it, the agent answers with an offer or a rejection"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"to_agent_id": map[string]string{
+							"type":        "string",
+							"description": "The ID of the agent to open the thread with",
+						},
+						"subject": map[string]string{
+							"type":        "string",
+							"description": "A short subject of the thread",
+						},
+						"content": map[string]string{
+							"type":        "string",
+							"description": "What you request from the agent",
+						},
+					},
+					"required": []string{"to_agent_id", "subject", "content"},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("reply_thread"),
+				Description: openai.String("Reply in a thread when it is your turn: offer (answer a request or counter an offer), accept or reject an offer, deliver what an accepted offer promised"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"thread_id": map[string]string{
+							"type":        "string",
+							"description": "The ID of the thread",
+						},
+						"kind": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"offer", "accept", "reject", "deliver"},
+							"description": "The kind of the reply",
+						},
+						"content": map[string]string{
+							"type":        "string",
+							"description": "The content of the reply",
+						},
+					},
+					"required": []string{"thread_id", "kind", "content"},
+				}),
+			}),
+		},
+		{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String("close_thread"),
+				Description: openai.String("Close a thread, either agent may close it at any time"),
+				Parameters: openai.F(openai.FunctionParameters{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"thread_id": map[string]string{
+							"type":        "string",
+							"description": "The ID of the thread",
+						},
+						"content": map[string]string{
+							"type":        "string",
+							"description": "An optional closing message",
+						},
+					},
+					"required": []string{"thread_id"},
+				}),
+			}),
+		},
 	}
 )
 
@@ -96,17 +319,23 @@ func NewOpenAIChatProvider(client *openai.Client) *OpenAIChatProvider {
 }
 
 func (p *OpenAIChatProvider) Chat(messages []ChatMessage) (ChatResponse, error) {
-	respMessage, err := p.chatCompletion(context.Background(), messages)
+	chatCompletion, err := p.chatCompletion(context.Background(), messages)
 	if err != nil {
 		return ChatResponse{}, err
 	}
 
-	resp := p.convertToChatResponse(respMessage)
+	resp := p.convertToChatResponse(&chatCompletion.Choices[0].Message)
+	resp.Usage = TokenUsage{
+		PromptTokens:     int(chatCompletion.Usage.PromptTokens),
+		CompletionTokens: int(chatCompletion.Usage.CompletionTokens),
+		TotalTokens:      int(chatCompletion.Usage.TotalTokens),
+	}
 	return resp, nil
 }
 
 func (p *OpenAIChatProvider) assembleChatParams(messages []ChatMessage) openai.ChatCompletionNewParams {
 	tools := append(persistToolDefinition, flowToolDefinition...)
+	tools = append(tools, messageToolDefinition...)
 	convertedMessages := p.convertFromChatMessages(messages)
 	return openai.ChatCompletionNewParams{
 		Messages: openai.F(convertedMessages),
@@ -115,19 +344,26 @@ func (p *OpenAIChatProvider) assembleChatParams(messages []ChatMessage) openai.C
 	}
 }
 
-func (p *OpenAIChatProvider) chatCompletion(ctx context.Context, messages []ChatMessage) (*openai.ChatCompletionMessage, error) {
+func (p *OpenAIChatProvider) chatCompletion(ctx context.Context, messages []ChatMessage) (*openai.ChatCompletion, error) {
 	chatParams := p.assembleChatParams(messages)
 	p.debugStruct("OpenAI chat params messages", chatParams.Messages)
 
 	chatCompletion, err := p.Client.Chat.Completions.New(ctx, chatParams)
 	if err != nil {
 		slog.Error("OpenAI chat error", "error", err)
-		return nil, err
+		return nil, p.classifyError(err)
 	}
-	respMessage := chatCompletion.Choices[0].Message
 
 	p.debugStruct("OpenAI chat completion", chatCompletion)
-	return &respMessage, nil
+	return chatCompletion, nil
+}
+
+func (p *OpenAIChatProvider) classifyError(err error) *ProviderError {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		return NewHTTPError(apiErr.StatusCode, apiErr.Response.Header, err)
+	}
+	return ClassifyError(err)
 }
 
 func (p *OpenAIChatProvider) convertFromChatMessages(messages []ChatMessage) []openai.ChatCompletionMessageParamUnion {
@@ -12,10 +12,18 @@ type ChatMessage struct {
 	ToolCall *ToolCall `json:"tool_call"`
 }
 
+// TokenUsage is the number of tokens an LLM call consumed, as reported by the provider
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
 type ChatResponse struct {
 	Content   *string    `json:"content"`
 	Role      string     `json:"role"`
 	ToolCalls []ToolCall `json:"tool_calls"`
+	Usage     TokenUsage `json:"usage"`
 }
 
 // ChatProvider is responsible for interacting with the LLM.
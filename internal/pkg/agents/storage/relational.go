@@ -2,10 +2,16 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/roackb2/lucid/internal/pkg/agents/embedding"
 	"github.com/roackb2/lucid/internal/pkg/dbaccess"
 	"github.com/roackb2/lucid/internal/pkg/utils"
 )
@@ -58,31 +64,47 @@ func (m *RelationalStorage) SearchPosts(query string) ([]string, error) {
 }
 
 func (m *RelationalStorage) SaveAgentState(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error {
-	slog.Info("RelationalStorage: Saving agent state", "agentID", agentID, "status", status, "role", role, "awakenedAt", awakenedAt, "asleepAt", asleepAt)
-	_, err := dbaccess.Querier.GetAgentState(context.Background(), agentID)
-	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			slog.Info("RelationalStorage: No existing agent state found, creating new state", "agentID", agentID)
-			err = m.createAgentState(agentID, state, status, role, awakenedAt, asleepAt)
-			if err != nil {
-				slog.Error("RelationalStorage: Failed to create agent state", "error", err)
+	return m.SaveAgentStateWithEvents(agentID, state, status, role, awakenedAt, asleepAt, nil)
+}
+
+func (m *RelationalStorage) SaveAgentStateWithEvents(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time, events []OutboxEvent) error {
+	slog.Info("RelationalStorage: Saving agent state", "agentID", agentID, "status", status, "role", role, "awakenedAt", awakenedAt, "asleepAt", asleepAt, "events", len(events))
+	err := dbaccess.WithTx(context.Background(), func(q *dbaccess.Queries) error {
+		_, err := q.GetAgentState(context.Background(), agentID)
+		if err != nil {
+			if strings.Contains(err.Error(), "no rows in result set") {
+				slog.Info("RelationalStorage: No existing agent state found, creating new state", "agentID", agentID)
+				err = m.createAgentState(q, agentID, state, status, role, awakenedAt, asleepAt)
+				if err != nil {
+					slog.Error("RelationalStorage: Failed to create agent state", "error", err)
+					return err
+				}
+			} else {
+				slog.Error("RelationalStorage: Failed to get existing agent state", "error", err)
 				return err
 			}
-		} else {
-			slog.Error("RelationalStorage: Failed to get existing agent state", "error", err)
+		}
+		err = m.updateAgentState(q, agentID, state, status, role, awakenedAt, asleepAt)
+		if err != nil {
+			slog.Error("RelationalStorage: Failed to update agent state", "error", err)
 			return err
 		}
-	}
-	err = m.updateAgentState(agentID, state, status, role, awakenedAt, asleepAt)
+		for _, event := range events {
+			if err := m.createOutboxEvent(q, event); err != nil {
+				slog.Error("RelationalStorage: Failed to add event to outbox", "topic", event.Topic, "error", err)
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		slog.Error("RelationalStorage: Failed to update agent state", "error", err)
 		return err
 	}
 	slog.Info("RelationalStorage: Saved agent state", "agentID", agentID)
 	return nil
 }
 
-func (m *RelationalStorage) createAgentState(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error {
+func (m *RelationalStorage) createAgentState(q *dbaccess.Queries, agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error {
 	slog.Info("RelationalStorage: Creating agent state", "agentID", agentID, "status", status, "awakenedAt", awakenedAt, "asleepAt", asleepAt)
 	params := dbaccess.CreateAgentStateParams{
 		AgentID:    agentID,
@@ -92,7 +114,7 @@ func (m *RelationalStorage) createAgentState(agentID string, state []byte, statu
 		AwakenedAt: utils.ConvertToPgTimestamp(awakenedAt),
 		AsleepAt:   utils.ConvertToPgTimestamp(asleepAt),
 	}
-	err := dbaccess.Querier.CreateAgentState(context.Background(), params)
+	err := q.CreateAgentState(context.Background(), params)
 	if err != nil {
 		slog.Error("RelationalStorage: Failed to save agent state", "error", err)
 		return err
@@ -101,7 +123,7 @@ func (m *RelationalStorage) createAgentState(agentID string, state []byte, statu
 	return nil
 }
 
-func (m *RelationalStorage) updateAgentState(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error {
+func (m *RelationalStorage) updateAgentState(q *dbaccess.Queries, agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error {
 	slog.Info("RelationalStorage: Updating agent state", "agentID", agentID, "status", status, "awakenedAt", awakenedAt, "asleepAt", asleepAt)
 
 	params := dbaccess.UpdateAgentStateParams{
@@ -112,7 +134,7 @@ func (m *RelationalStorage) updateAgentState(agentID string, state []byte, statu
 		AwakenedAt: utils.ConvertToPgTimestamp(awakenedAt),
 		AsleepAt:   utils.ConvertToPgTimestamp(asleepAt),
 	}
-	err := dbaccess.Querier.UpdateAgentState(context.Background(), params)
+	err := q.UpdateAgentState(context.Background(), params)
 	if err != nil {
 		slog.Error("RelationalStorage: Failed to update agent state", "error", err)
 		return err
@@ -121,6 +143,177 @@ func (m *RelationalStorage) updateAgentState(agentID string, state []byte, statu
 	return nil
 }
 
+func (m *RelationalStorage) createOutboxEvent(q *dbaccess.Queries, event OutboxEvent) error {
+	headers := event.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	return q.CreateOutboxEvent(context.Background(), dbaccess.CreateOutboxEventParams{
+		Topic:      event.Topic,
+		MessageKey: event.Key,
+		Message:    event.Message,
+		Headers:    encodedHeaders,
+	})
+}
+
+func (m *RelationalStorage) ClaimOutboxEvents(limit int, claimDuration time.Duration) ([]OutboxEvent, error) {
+	rows, err := dbaccess.Querier.ClaimOutboxEvents(context.Background(), dbaccess.ClaimOutboxEventsParams{
+		ClaimDuration: utils.ConvertToPgInterval(claimDuration),
+		BatchSize:     int32(limit),
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to claim outbox events", "error", err)
+		return nil, err
+	}
+	events := make([]OutboxEvent, 0, len(rows))
+	for _, row := range rows {
+		event := OutboxEvent{
+			ID:       row.ID,
+			Topic:    row.Topic,
+			Key:      row.MessageKey,
+			Message:  row.Message,
+			Attempts: int(row.Attempts),
+		}
+		if err := json.Unmarshal(row.Headers, &event.Headers); err != nil {
+			slog.Error("RelationalStorage: Failed to decode outbox event headers", "id", row.ID, "error", err)
+		}
+		events = append(events, event)
+	}
+	// The claimed rows are returned in no particular order
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+func (m *RelationalStorage) MarkOutboxEventDelivered(id int64) error {
+	err := dbaccess.Querier.MarkOutboxEventDelivered(context.Background(), id)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to mark outbox event delivered", "id", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) RetryOutboxEvent(id int64, lastError string, backoff time.Duration) error {
+	err := dbaccess.Querier.RetryOutboxEvent(context.Background(), dbaccess.RetryOutboxEventParams{
+		LastError: lastError,
+		Backoff:   utils.ConvertToPgInterval(backoff),
+		ID:        id,
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to schedule outbox event retry", "id", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) DeleteDeliveredOutboxEvents(retention time.Duration) error {
+	err := dbaccess.Querier.DeleteDeliveredOutboxEvents(context.Background(), utils.ConvertToPgInterval(retention))
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to delete delivered outbox events", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) RecordClientEvent(event ClientEvent) (int64, error) {
+	// Most events are recorded by every node, only the first one needs the lock
+	id, err := dbaccess.Querier.GetClientEventID(context.Background(), event.EventID)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("RelationalStorage: Failed to get client event", "event_id", event.EventID, "error", err)
+		return 0, err
+	}
+	err = dbaccess.WithTx(context.Background(), func(q *dbaccess.Queries) error {
+		if err := q.LockClientEvents(context.Background()); err != nil {
+			return err
+		}
+		id, err = q.RecordClientEvent(context.Background(), dbaccess.RecordClientEventParams{
+			EventID: event.EventID,
+			AgentID: event.AgentID,
+			Owner:   event.Owner,
+			Event:   event.Event,
+			Data:    event.Data,
+		})
+		return err
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to record client event", "event_id", event.EventID, "error", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (m *RelationalStorage) ListClientEventsAfter(afterID int64, agentIDs []string, owner string, limit int) ([]ClientEvent, error) {
+	if agentIDs == nil {
+		// A nil slice is sent as NULL, which matches no agent
+		agentIDs = []string{}
+	}
+	rows, err := dbaccess.Querier.ListClientEventsAfter(context.Background(), dbaccess.ListClientEventsAfterParams{
+		AfterID:   afterID,
+		AgentIds:  agentIDs,
+		Owner:     owner,
+		MaxEvents: int32(limit),
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list client events", "after_id", afterID, "error", err)
+		return nil, err
+	}
+	return toClientEvents(rows), nil
+}
+
+func (m *RelationalStorage) ListAllClientEventsAfter(afterID int64, limit int) ([]ClientEvent, error) {
+	rows, err := dbaccess.Querier.ListAllClientEventsAfter(context.Background(), dbaccess.ListAllClientEventsAfterParams{
+		AfterID:   afterID,
+		MaxEvents: int32(limit),
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list client events", "after_id", afterID, "error", err)
+		return nil, err
+	}
+	return toClientEvents(rows), nil
+}
+
+func (m *RelationalStorage) GetLatestClientEventID() (int64, error) {
+	id, err := dbaccess.Querier.GetLatestClientEventID(context.Background())
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to get latest client event ID", "error", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+func toClientEvents(rows []dbaccess.ClientEvent) []ClientEvent {
+	events := make([]ClientEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, ClientEvent{
+			ID:      row.ID,
+			EventID: row.EventID,
+			AgentID: row.AgentID,
+			Owner:   row.Owner,
+			Event:   row.Event,
+			Data:    row.Data,
+		})
+	}
+	return events
+}
+
+func (m *RelationalStorage) DeleteExpiredClientEvents(retention time.Duration) error {
+	err := dbaccess.Querier.DeleteExpiredClientEvents(context.Background(), utils.ConvertToPgInterval(retention))
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to delete expired client events", "error", err)
+		return err
+	}
+	return nil
+}
+
 func (m *RelationalStorage) GetAgentState(agentID string) ([]byte, error) {
 	slog.Info("RelationalStorage: Getting agent state", "agentID", agentID)
 	state, err := dbaccess.Querier.GetAgentState(context.Background(), agentID)
@@ -131,3 +324,590 @@ func (m *RelationalStorage) GetAgentState(agentID string) ([]byte, error) {
 	slog.Info("RelationalStorage: Got agent state", "agentID", agentID)
 	return state.State, nil
 }
+
+func (m *RelationalStorage) GetAgentInfo(agentID string) (*AgentInfo, error) {
+	slog.Info("RelationalStorage: Getting agent info", "agentID", agentID)
+	state, err := dbaccess.Querier.GetAgentState(context.Background(), agentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAgentNotFound
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to get agent info", "error", err)
+		return nil, err
+	}
+	return &AgentInfo{
+		AgentID:   state.AgentID,
+		Status:    state.Status,
+		Role:      state.Role,
+		LastError: state.LastError.String,
+	}, nil
+}
+
+func (m *RelationalStorage) UpdateAgentStatus(agentID string, status string) error {
+	slog.Info("RelationalStorage: Updating agent status", "agentID", agentID, "status", status)
+	params := dbaccess.UpdateAgentStatusParams{
+		AgentID: agentID,
+		Status:  status,
+	}
+	err := dbaccess.Querier.UpdateAgentStatus(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to update agent status", "error", err)
+		return err
+	}
+	slog.Info("RelationalStorage: Updated agent status", "agentID", agentID, "status", status)
+	return nil
+}
+
+func (m *RelationalStorage) ClaimAgent(agentID string, nodeID string, status string, claimDuration time.Duration) (bool, error) {
+	slog.Info("RelationalStorage: Claiming agent", "agentID", agentID, "nodeID", nodeID)
+	claimedIDs, err := dbaccess.Querier.ClaimAgents(context.Background(), dbaccess.ClaimAgentsParams{
+		NodeID:        pgtype.Text{String: nodeID, Valid: true},
+		ClaimDuration: utils.ConvertToPgInterval(claimDuration),
+		AgentIds:      []string{agentID},
+		Statuses:      []string{status},
+	})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to claim agent", "error", err)
+		return false, err
+	}
+	return len(claimedIDs) == 1, nil
+}
+
+func (m *RelationalStorage) ReleaseAgentClaim(agentID string, nodeID string) error {
+	slog.Info("RelationalStorage: Releasing agent claim", "agentID", agentID, "nodeID", nodeID)
+	params := dbaccess.ReleaseAgentClaimParams{
+		AgentID: agentID,
+		NodeID:  pgtype.Text{String: nodeID, Valid: true},
+	}
+	err := dbaccess.Querier.ReleaseAgentClaim(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to release agent claim", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) SetAgentError(agentID string, message string) error {
+	slog.Info("RelationalStorage: Setting agent error", "agentID", agentID, "error", message)
+	params := dbaccess.UpdateAgentErrorParams{
+		AgentID: agentID,
+	}
+	if message != "" {
+		now := time.Now()
+		params.LastError = pgtype.Text{String: message, Valid: true}
+		params.FailedAt = utils.ConvertToPgTimestamp(&now)
+	}
+	err := dbaccess.Querier.UpdateAgentError(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to set agent error", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) CreateToolApproval(approval ToolApproval) error {
+	slog.Info("RelationalStorage: Creating tool approval", "approvalID", approval.ApprovalID, "agentID", approval.AgentID, "tool", approval.ToolName)
+	params := dbaccess.CreateToolApprovalParams{
+		ApprovalID: approval.ApprovalID,
+		AgentID:    approval.AgentID,
+		Owner:      approval.Owner,
+		Role:       approval.Role,
+		ToolCallID: approval.ToolCallID,
+		ToolName:   approval.ToolName,
+		Arguments:  approval.Arguments,
+		Status:     approval.Status,
+	}
+	_, err := dbaccess.Querier.CreateToolApproval(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to create tool approval", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) GetToolApproval(approvalID string) (*ToolApproval, error) {
+	slog.Info("RelationalStorage: Getting tool approval", "approvalID", approvalID)
+	row, err := dbaccess.Querier.GetToolApproval(context.Background(), approvalID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrToolApprovalNotFound
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to get tool approval", "error", err)
+		return nil, err
+	}
+	return toToolApproval(row), nil
+}
+
+func (m *RelationalStorage) ListToolApprovals(owner string, status string) ([]ToolApproval, error) {
+	slog.Info("RelationalStorage: Listing tool approvals", "owner", owner, "status", status)
+	params := dbaccess.ListToolApprovalsParams{
+		Owner:  owner,
+		Status: status,
+	}
+	rows, err := dbaccess.Querier.ListToolApprovals(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list tool approvals", "error", err)
+		return nil, err
+	}
+	approvals := make([]ToolApproval, len(rows))
+	for i, row := range rows {
+		approvals[i] = *toToolApproval(row)
+	}
+	return approvals, nil
+}
+
+func (m *RelationalStorage) DecideToolApproval(approvalID string, status string, editedArguments string, reason string) (*ToolApproval, error) {
+	slog.Info("RelationalStorage: Deciding tool approval", "approvalID", approvalID, "status", status)
+	now := time.Now()
+	params := dbaccess.DecideToolApprovalParams{
+		ApprovalID:      approvalID,
+		Status:          status,
+		EditedArguments: pgtype.Text{String: editedArguments, Valid: editedArguments != ""},
+		Reason:          pgtype.Text{String: reason, Valid: reason != ""},
+		DecidedAt:       utils.ConvertToPgTimestamp(&now),
+	}
+	row, err := dbaccess.Querier.DecideToolApproval(context.Background(), params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrToolApprovalNotPending
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to decide tool approval", "error", err)
+		return nil, err
+	}
+	return toToolApproval(row), nil
+}
+
+func (m *RelationalStorage) ReopenToolApproval(approvalID string) error {
+	slog.Info("RelationalStorage: Reopening tool approval", "approvalID", approvalID)
+	err := dbaccess.Querier.ReopenToolApproval(context.Background(), approvalID)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to reopen tool approval", "error", err)
+		return err
+	}
+	return nil
+}
+
+func toToolApproval(row dbaccess.ToolApproval) *ToolApproval {
+	approval := &ToolApproval{
+		ApprovalID:      row.ApprovalID,
+		AgentID:         row.AgentID,
+		Owner:           row.Owner,
+		Role:            row.Role,
+		ToolCallID:      row.ToolCallID,
+		ToolName:        row.ToolName,
+		Arguments:       row.Arguments,
+		Status:          row.Status,
+		EditedArguments: row.EditedArguments.String,
+		Reason:          row.Reason.String,
+		CreatedAt:       row.CreatedAt.Time,
+	}
+	if row.DecidedAt.Valid {
+		approval.DecidedAt = &row.DecidedAt.Time
+	}
+	return approval
+}
+
+func (m *RelationalStorage) SaveAgentMessage(message AgentMessage) error {
+	slog.Info("RelationalStorage: Saving agent message", "messageID", message.MessageID, "from", message.FromAgentID, "to", message.ToAgentID)
+	_, err := dbaccess.Querier.CreateAgentMessage(context.Background(), agentMessageParams(message))
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to save agent message", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) ListUnreadAgentMessages(agentID string) ([]AgentMessage, error) {
+	slog.Info("RelationalStorage: Listing unread agent messages", "agentID", agentID)
+	rows, err := dbaccess.Querier.ListUnreadAgentMessages(context.Background(), agentID)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list unread agent messages", "error", err)
+		return nil, err
+	}
+	messages := make([]AgentMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = convertAgentMessage(row)
+	}
+	return messages, nil
+}
+
+func (m *RelationalStorage) MarkAgentMessagesRead(agentID string, messageIDs []string) error {
+	slog.Info("RelationalStorage: Marking agent messages read", "agentID", agentID, "messages", len(messageIDs))
+	params := dbaccess.MarkAgentMessagesReadParams{
+		ToAgentID:  agentID,
+		MessageIds: messageIDs,
+	}
+	err := dbaccess.Querier.MarkAgentMessagesRead(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to mark agent messages read", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) CreateAgentInterest(interest AgentInterest) error {
+	slog.Info("RelationalStorage: Creating agent interest", "interestID", interest.InterestID, "agentID", interest.AgentID, "query", interest.Query)
+	params := dbaccess.CreateAgentInterestParams{
+		InterestID:    interest.InterestID,
+		AgentID:       interest.AgentID,
+		Query:         interest.Query,
+		Keywords:      interest.Keywords,
+		MinSimilarity: interest.MinSimilarity,
+	}
+	if params.Keywords == nil {
+		params.Keywords = []string{}
+	}
+	if interest.Semantic {
+		queryEmbedding, err := embedText(interest.Query)
+		if err != nil {
+			slog.Error("RelationalStorage: Failed to embed interest query", "error", err)
+			return err
+		}
+		params.Embedding = queryEmbedding
+		if params.MinSimilarity == 0 {
+			params.MinSimilarity = DefaultSemanticInterestSimilarity
+		}
+	} else if params.MinSimilarity == 0 {
+		params.MinSimilarity = DefaultLexicalInterestSimilarity
+	}
+	_, err := dbaccess.Querier.CreateAgentInterest(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to create agent interest", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) CancelAgentInterest(agentID string, interestID string) error {
+	slog.Info("RelationalStorage: Canceling agent interest", "interestID", interestID, "agentID", agentID)
+	params := dbaccess.CancelAgentInterestParams{
+		InterestID: interestID,
+		AgentID:    agentID,
+	}
+	_, err := dbaccess.Querier.CancelAgentInterest(context.Background(), params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAgentInterestNotFound
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to cancel agent interest", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) MatchAgentInterests(content string, excludedStatuses []string) ([]AgentInterest, error) {
+	slog.Info("RelationalStorage: Matching agent interests")
+	lexicalParams := dbaccess.MatchLexicalAgentInterestsParams{
+		ExcludedStatuses: excludedStatuses,
+		Content:          content,
+	}
+	lexicalRows, err := dbaccess.Querier.MatchLexicalAgentInterests(context.Background(), lexicalParams)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to match lexical agent interests", "error", err)
+		return nil, err
+	}
+	interests := make([]AgentInterest, 0, len(lexicalRows))
+	for _, row := range lexicalRows {
+		interests = append(interests, convertAgentInterest(row))
+	}
+
+	hasSemantic, err := dbaccess.Querier.HasSemanticAgentInterests(context.Background(), excludedStatuses)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to check semantic agent interests", "error", err)
+		return nil, err
+	}
+	if !hasSemantic {
+		return interests, nil
+	}
+	// The post is only embedded when some semantic interest may match it
+	contentEmbedding, err := embedText(content)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to embed post content", "error", err)
+		return nil, err
+	}
+	semanticParams := dbaccess.MatchSemanticAgentInterestsParams{
+		ExcludedStatuses: excludedStatuses,
+		Content:          content,
+		Embedding:        contentEmbedding,
+	}
+	semanticRows, err := dbaccess.Querier.MatchSemanticAgentInterests(context.Background(), semanticParams)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to match semantic agent interests", "error", err)
+		return nil, err
+	}
+	for _, row := range semanticRows {
+		interests = append(interests, convertAgentInterest(row))
+	}
+	slog.Info("RelationalStorage: Matched agent interests", "lexical", len(lexicalRows), "semantic", len(semanticRows))
+	return interests, nil
+}
+
+func convertAgentInterest(row dbaccess.AgentInterest) AgentInterest {
+	return AgentInterest{
+		InterestID:    row.InterestID,
+		AgentID:       row.AgentID,
+		Query:         row.Query,
+		Keywords:      row.Keywords,
+		Semantic:      row.Embedding != nil,
+		MinSimilarity: row.MinSimilarity,
+		CreatedAt:     row.CreatedAt.Time,
+	}
+}
+
+func embedText(text string) ([]float32, error) {
+	embeddings, err := embedding.Embed(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, errors.New("no embedding returned")
+	}
+	return embedding.ConvertToFloat32(embeddings)[0], nil
+}
+
+func (m *RelationalStorage) SaveAgentProfile(profile AgentProfile) error {
+	slog.Info("RelationalStorage: Saving agent profile", "agentID", profile.AgentID, "role", profile.Role)
+	params := dbaccess.UpsertAgentProfileParams{
+		AgentID:     profile.AgentID,
+		Owner:       profile.Owner,
+		Role:        profile.Role,
+		Description: profile.Description,
+		Tags:        profile.Tags,
+	}
+	if params.Tags == nil {
+		params.Tags = []string{}
+	}
+	if profile.Description != "" {
+		// Without an embedding the profile is still found by its text
+		descriptionEmbedding, err := embedText(profile.Description)
+		if err != nil {
+			slog.Warn("RelationalStorage: Failed to embed agent profile", "agentID", profile.AgentID, "error", err)
+		}
+		params.Embedding = descriptionEmbedding
+	}
+	_, err := dbaccess.Querier.UpsertAgentProfile(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to save agent profile", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (m *RelationalStorage) GetAgentProfile(agentID string) (*AgentProfile, error) {
+	slog.Info("RelationalStorage: Getting agent profile", "agentID", agentID)
+	row, err := dbaccess.Querier.GetAgentProfile(context.Background(), agentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAgentProfileNotFound
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to get agent profile", "error", err)
+		return nil, err
+	}
+	profile := convertAgentProfile(row)
+	return &profile, nil
+}
+
+func (m *RelationalStorage) SearchAgentProfiles(query AgentProfileQuery) ([]AgentProfile, error) {
+	slog.Info("RelationalStorage: Searching agent profiles", "query", query.Query, "role", query.Role, "owner", query.Owner, "tags", query.Tags)
+	tags := query.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	excludedStatuses := query.ExcludedStatuses
+	if excludedStatuses == nil {
+		excludedStatuses = []string{}
+	}
+	params := dbaccess.SearchAgentProfilesParams{
+		ExcludedAgentID:  query.ExcludedAgentID,
+		ExcludedStatuses: excludedStatuses,
+		Role:             query.Role,
+		Owner:            query.Owner,
+		Tags:             tags,
+		Query:            query.Query,
+		MaxResults:       int32(query.Limit),
+	}
+	rows, err := dbaccess.Querier.SearchAgentProfiles(context.Background(), params)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to search agent profiles", "error", err)
+		return nil, err
+	}
+	profiles := make([]AgentProfile, 0, len(rows))
+	foundAgentIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		profiles = append(profiles, convertAgentProfile(row))
+		foundAgentIDs = append(foundAgentIDs, row.AgentID)
+	}
+	if query.Query == "" || len(profiles) >= query.Limit {
+		return profiles, nil
+	}
+
+	queryEmbedding, err := embedText(query.Query)
+	if err != nil {
+		// The text matches are still useful
+		slog.Warn("RelationalStorage: Failed to embed agent profile query", "error", err)
+		return profiles, nil
+	}
+	// Fill the remaining results with the profiles closest in meaning to the query
+	semanticParams := dbaccess.SearchSemanticAgentProfilesParams{
+		ExcludedAgentID:  query.ExcludedAgentID,
+		FoundAgentIds:    foundAgentIDs,
+		ExcludedStatuses: excludedStatuses,
+		Role:             query.Role,
+		Owner:            query.Owner,
+		Tags:             tags,
+		QueryEmbedding:   queryEmbedding,
+		MinSimilarity:    SemanticProfileSimilarity,
+		MaxResults:       int32(query.Limit - len(profiles)),
+	}
+	semanticRows, err := dbaccess.Querier.SearchSemanticAgentProfiles(context.Background(), semanticParams)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to search semantic agent profiles", "error", err)
+		return nil, err
+	}
+	for _, row := range semanticRows {
+		profiles = append(profiles, convertAgentProfile(row))
+	}
+	return profiles, nil
+}
+
+func convertAgentProfile(row dbaccess.AgentProfile) AgentProfile {
+	return AgentProfile{
+		AgentID:     row.AgentID,
+		Owner:       row.Owner,
+		Role:        row.Role,
+		Description: row.Description,
+		Tags:        row.Tags,
+		UpdatedAt:   row.UpdatedAt.Time,
+	}
+}
+
+func (m *RelationalStorage) CreateAgentThread(thread AgentThread, message AgentMessage) error {
+	slog.Info("RelationalStorage: Creating agent thread", "threadID", thread.ThreadID, "initiator", thread.InitiatorAgentID, "responder", thread.ResponderAgentID)
+	return dbaccess.WithTx(context.Background(), func(q *dbaccess.Queries) error {
+		params := dbaccess.CreateAgentThreadParams{
+			ThreadID:          thread.ThreadID,
+			InitiatorAgentID:  thread.InitiatorAgentID,
+			ResponderAgentID:  thread.ResponderAgentID,
+			Subject:           thread.Subject,
+			Status:            thread.Status,
+			LastSenderAgentID: thread.LastSenderAgentID,
+		}
+		if _, err := q.CreateAgentThread(context.Background(), params); err != nil {
+			slog.Error("RelationalStorage: Failed to create agent thread", "error", err)
+			return err
+		}
+		if _, err := q.CreateAgentMessage(context.Background(), agentMessageParams(message)); err != nil {
+			slog.Error("RelationalStorage: Failed to save agent thread message", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (m *RelationalStorage) GetAgentThread(threadID string) (*AgentThread, error) {
+	slog.Info("RelationalStorage: Getting agent thread", "threadID", threadID)
+	row, err := dbaccess.Querier.GetAgentThread(context.Background(), threadID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAgentThreadNotFound
+	}
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to get agent thread", "error", err)
+		return nil, err
+	}
+	thread := convertAgentThread(row)
+	return &thread, nil
+}
+
+func (m *RelationalStorage) ListAgentThreads(agentID string) ([]AgentThread, error) {
+	slog.Info("RelationalStorage: Listing agent threads", "agentID", agentID)
+	rows, err := dbaccess.Querier.ListAgentThreads(context.Background(), agentID)
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list agent threads", "error", err)
+		return nil, err
+	}
+	threads := make([]AgentThread, len(rows))
+	for i, row := range rows {
+		threads[i] = convertAgentThread(row)
+	}
+	return threads, nil
+}
+
+func (m *RelationalStorage) AdvanceAgentThread(advance AgentThreadAdvance) error {
+	slog.Info("RelationalStorage: Advancing agent thread", "threadID", advance.ThreadID, "from", advance.ExpectedStatus, "to", advance.Status)
+	return dbaccess.WithTx(context.Background(), func(q *dbaccess.Queries) error {
+		params := dbaccess.AdvanceAgentThreadParams{
+			Status:                    advance.Status,
+			LastSenderAgentID:         advance.Message.FromAgentID,
+			ThreadID:                  advance.ThreadID,
+			ExpectedStatus:            advance.ExpectedStatus,
+			ExpectedLastSenderAgentID: advance.ExpectedLastSenderAgentID,
+		}
+		_, err := q.AdvanceAgentThread(context.Background(), params)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrAgentThreadConflict
+		}
+		if err != nil {
+			slog.Error("RelationalStorage: Failed to advance agent thread", "error", err)
+			return err
+		}
+		if _, err := q.CreateAgentMessage(context.Background(), agentMessageParams(advance.Message)); err != nil {
+			slog.Error("RelationalStorage: Failed to save agent thread message", "error", err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (m *RelationalStorage) ListAgentThreadMessages(threadID string) ([]AgentMessage, error) {
+	slog.Info("RelationalStorage: Listing agent thread messages", "threadID", threadID)
+	rows, err := dbaccess.Querier.ListAgentThreadMessages(context.Background(), pgtype.Text{String: threadID, Valid: true})
+	if err != nil {
+		slog.Error("RelationalStorage: Failed to list agent thread messages", "error", err)
+		return nil, err
+	}
+	messages := make([]AgentMessage, len(rows))
+	for i, row := range rows {
+		messages[i] = convertAgentMessage(row)
+	}
+	return messages, nil
+}
+
+func agentMessageParams(message AgentMessage) dbaccess.CreateAgentMessageParams {
+	return dbaccess.CreateAgentMessageParams{
+		MessageID:   message.MessageID,
+		FromAgentID: message.FromAgentID,
+		ToAgentID:   message.ToAgentID,
+		Content:     message.Content,
+		ThreadID:    pgtype.Text{String: message.ThreadID, Valid: message.ThreadID != ""},
+		Kind:        message.Kind,
+	}
+}
+
+func convertAgentMessage(row dbaccess.AgentMessage) AgentMessage {
+	message := AgentMessage{
+		MessageID:   row.MessageID,
+		FromAgentID: row.FromAgentID,
+		ToAgentID:   row.ToAgentID,
+		Content:     row.Content,
+		ThreadID:    row.ThreadID.String,
+		Kind:        row.Kind,
+		CreatedAt:   row.CreatedAt.Time,
+	}
+	if row.ReadAt.Valid {
+		message.ReadAt = &row.ReadAt.Time
+	}
+	return message
+}
+
+func convertAgentThread(row dbaccess.AgentThread) AgentThread {
+	return AgentThread{
+		ThreadID:          row.ThreadID,
+		InitiatorAgentID:  row.InitiatorAgentID,
+		ResponderAgentID:  row.ResponderAgentID,
+		Subject:           row.Subject,
+		Status:            row.Status,
+		LastSenderAgentID: row.LastSenderAgentID,
+		CreatedAt:         row.CreatedAt.Time,
+		UpdatedAt:         row.UpdatedAt.Time,
+	}
+}
@@ -1,13 +1,237 @@
 package storage
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrAgentNotFound is returned when no state is stored for the agent.
+var ErrAgentNotFound = errors.New("agent not found")
+
+// AgentInfo is the metadata stored alongside an agent's serialized state.
+type AgentInfo struct {
+	AgentID string
+	Status  string
+	Role    string
+	// LastError is why the agent failed, empty unless its status is failed.
+	LastError string
+}
+
+// Statuses of a ToolApproval.
+const (
+	ToolApprovalPending  = "pending"
+	ToolApprovalApproved = "approved"
+	ToolApprovalRejected = "rejected"
+)
+
+// ErrToolApprovalNotFound is returned when no approval has the requested ID.
+var ErrToolApprovalNotFound = errors.New("tool approval not found")
+
+// ErrToolApprovalNotPending is returned when deciding an approval that was already decided.
+var ErrToolApprovalNotPending = errors.New("tool approval is not pending")
+
+// ToolApproval is a tool call an agent made that waits for, or was given, the user's decision.
+type ToolApproval struct {
+	ApprovalID string
+	AgentID    string
+	Owner      string
+	Role       string
+	ToolCallID string
+	ToolName   string
+	Arguments  string
+	Status     string
+	// EditedArguments replace Arguments if the user approved the call with edits.
+	EditedArguments string
+	// Reason is the user's explanation of the decision, if any.
+	Reason    string
+	CreatedAt time.Time
+	DecidedAt *time.Time
+}
+
+// AgentMessage is a message an agent sent to another agent's inbox.
+type AgentMessage struct {
+	MessageID   string
+	FromAgentID string
+	ToAgentID   string
+	Content     string
+	// ThreadID is empty unless the message is part of a thread.
+	ThreadID string
+	Kind     string
+	// ReadAt is nil until the recipient read the message.
+	ReadAt    *time.Time
+	CreatedAt time.Time
+}
+
+// ErrAgentThreadNotFound is returned when no thread has the requested ID.
+var ErrAgentThreadNotFound = errors.New("agent thread not found")
+
+// ErrAgentThreadConflict is returned when advancing a thread that another message advanced first.
+var ErrAgentThreadConflict = errors.New("agent thread was advanced by another message")
+
+// AgentThread is a structured conversation between the agent that opened it and the agent it was opened with.
+type AgentThread struct {
+	ThreadID         string
+	InitiatorAgentID string
+	ResponderAgentID string
+	Subject          string
+	Status           string
+	// LastSenderAgentID sent the message that moved the thread to its status.
+	LastSenderAgentID string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// AgentThreadAdvance moves a thread to a new status with a message,
+// only if the thread is still in the status and last sent by the agent the sender saw.
+type AgentThreadAdvance struct {
+	ThreadID                  string
+	ExpectedStatus            string
+	ExpectedLastSenderAgentID string
+	Status                    string
+	Message                   AgentMessage
+}
+
+// Default similarity an interest needs with a post to match it.
+const (
+	DefaultLexicalInterestSimilarity  = 0.3
+	DefaultSemanticInterestSimilarity = 0.5
+)
+
+// ErrAgentInterestNotFound is returned when the agent has no active interest with the requested ID.
+var ErrAgentInterestNotFound = errors.New("agent interest not found")
+
+// AgentInterest is a standing interest of an agent in new posts.
+type AgentInterest struct {
+	InterestID string
+	AgentID    string
+	Query      string
+	// Keywords must all be in a post for the interest to match it.
+	Keywords []string
+	// Semantic interests match posts by the similarity of their embeddings, other interests by their text.
+	Semantic      bool
+	MinSimilarity float32
+	CreatedAt     time.Time
+}
+
+// SemanticProfileSimilarity is the similarity a profile's description needs with a query to match it by meaning.
+const SemanticProfileSimilarity = 0.4
+
+// ErrAgentProfileNotFound is returned when the agent has not published a profile.
+var ErrAgentProfileNotFound = errors.New("agent profile not found")
+
+// AgentProfile describes an agent in the agent directory, so other agents and users can find it.
+type AgentProfile struct {
+	AgentID string
+	Owner   string
+	Role    string
+	// Description tells what the agent offers or seeks.
+	Description string
+	Tags        []string
+	UpdatedAt   time.Time
+}
+
+// AgentProfileQuery searches the agent directory, empty fields match all profiles.
+type AgentProfileQuery struct {
+	// Query is matched against the profiles' descriptions and tags, by text and by meaning.
+	Query string
+	Role  string
+	Owner string
+	// Tags must all be in a profile for it to match.
+	Tags []string
+	// ExcludedAgentID skips the profile of the searching agent.
+	ExcludedAgentID string
+	// ExcludedStatuses skips the profiles of agents in these statuses.
+	ExcludedStatuses []string
+	Limit            int
+}
+
+// OutboxEvent is a message written to the outbox with the agent state, published once the state is saved.
+type OutboxEvent struct {
+	ID      int64
+	Topic   string
+	Key     string
+	Message string
+	Headers map[string]string
+	// Attempts counts the failed attempts to publish the event.
+	Attempts int
+}
+
+// ClientEvent is an agent event recorded for clients to replay, its ID is the cursor clients resume after.
+type ClientEvent struct {
+	ID      int64
+	EventID string
+	AgentID string
+	Owner   string
+	// Event is the type of the event as sent to clients, Data its payload as JSON
+	Event string
+	Data  []byte
+}
+
 type Storage interface {
 	SavePost(content string) error
 	SearchPosts(query string) ([]string, error)
 	SaveAgentState(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time) error
+	// SaveAgentStateWithEvents saves the agent state and adds the events to the outbox in one transaction.
+	SaveAgentStateWithEvents(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time, events []OutboxEvent) error
+	// ClaimOutboxEvents claims the undelivered events due for publishing, oldest first,
+	// other callers do not get them until the claim duration elapsed.
+	// An event is not claimed while an earlier event with the same key is undelivered.
+	ClaimOutboxEvents(limit int, claimDuration time.Duration) ([]OutboxEvent, error)
+	MarkOutboxEventDelivered(id int64) error
+	// RetryOutboxEvent records a failed attempt to publish the event, which is due again after the backoff.
+	RetryOutboxEvent(id int64, lastError string, backoff time.Duration) error
+	// DeleteDeliveredOutboxEvents deletes the events delivered longer ago than the retention.
+	DeleteDeliveredOutboxEvents(retention time.Duration) error
+	// RecordClientEvent records the event once per event ID and returns its ID, the same for every caller.
+	// Events are committed in the order of their IDs, so an event listed after an ID is never followed by a smaller one.
+	RecordClientEvent(event ClientEvent) (int64, error)
+	// ListClientEventsAfter lists the events after the ID, oldest first,
+	// of the agents by ID or of the owner's agents when the owner is not empty.
+	ListClientEventsAfter(afterID int64, agentIDs []string, owner string, limit int) ([]ClientEvent, error)
+	// ListAllClientEventsAfter lists the events of every agent after the ID, oldest first.
+	ListAllClientEventsAfter(afterID int64, limit int) ([]ClientEvent, error)
+	// GetLatestClientEventID returns the ID of the latest event, 0 when there is none.
+	GetLatestClientEventID() (int64, error)
+	// DeleteExpiredClientEvents deletes the events recorded longer ago than the retention.
+	DeleteExpiredClientEvents(retention time.Duration) error
 	GetAgentState(agentID string) ([]byte, error)
+	GetAgentInfo(agentID string) (*AgentInfo, error)
+	UpdateAgentStatus(agentID string, status string) error
+	// ClaimAgent claims the agent for the node if it is still in the status and no node holds a claim on it,
+	// like the scheduler claims the agents it resumes. It returns whether the agent was claimed.
+	ClaimAgent(agentID string, nodeID string, status string, claimDuration time.Duration) (bool, error)
+	// ReleaseAgentClaim releases the node's claim on the agent, a claim held by another node is kept.
+	ReleaseAgentClaim(agentID string, nodeID string) error
+	// SetAgentError records why the agent failed, an empty message clears the error.
+	SetAgentError(agentID string, message string) error
+	CreateToolApproval(approval ToolApproval) error
+	GetToolApproval(approvalID string) (*ToolApproval, error)
+	// ListToolApprovals lists the approvals of an owner and status, an empty owner or status matches all.
+	ListToolApprovals(owner string, status string) ([]ToolApproval, error)
+	// DecideToolApproval records the decision of a pending approval, it returns ErrToolApprovalNotPending if it was already decided.
+	DecideToolApproval(approvalID string, status string, editedArguments string, reason string) (*ToolApproval, error)
+	// ReopenToolApproval clears the decision of an approval, so the user can decide again.
+	ReopenToolApproval(approvalID string) error
+	SaveAgentMessage(message AgentMessage) error
+	// ListUnreadAgentMessages lists the messages in the agent's inbox that it has not read, oldest first.
+	ListUnreadAgentMessages(agentID string) ([]AgentMessage, error)
+	MarkAgentMessagesRead(agentID string, messageIDs []string) error
+	// CreateAgentThread saves the thread with the message that opened it.
+	CreateAgentThread(thread AgentThread, message AgentMessage) error
+	GetAgentThread(threadID string) (*AgentThread, error)
+	// ListAgentThreads lists the threads the agent takes part in, most recently updated first.
+	ListAgentThreads(agentID string) ([]AgentThread, error)
+	// AdvanceAgentThread returns ErrAgentThreadConflict if the thread changed since the sender read it.
+	AdvanceAgentThread(advance AgentThreadAdvance) error
+	ListAgentThreadMessages(threadID string) ([]AgentMessage, error)
+	CreateAgentInterest(interest AgentInterest) error
+	CancelAgentInterest(agentID string, interestID string) error
+	// MatchAgentInterests lists the active interests matching the content of a new post,
+	// interests of agents in one of the excluded statuses are skipped.
+	MatchAgentInterests(content string, excludedStatuses []string) ([]AgentInterest, error)
+	SaveAgentProfile(profile AgentProfile) error
+	GetAgentProfile(agentID string) (*AgentProfile, error)
+	// SearchAgentProfiles lists the profiles matching the query, best matches first.
+	SearchAgentProfiles(query AgentProfileQuery) ([]AgentProfile, error)
 	Close() error
 }
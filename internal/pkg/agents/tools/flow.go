@@ -2,6 +2,7 @@ package tools
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -47,3 +48,32 @@ func (t *FlowTool) waitImpl(arguments string) string {
 func (t *FlowTool) Wait(toolCall providers.ToolCall) string {
 	return t.waitImpl(toolCall.Args)
 }
+
+func (t *FlowTool) askUserImpl(arguments string) (string, error) {
+	var args map[string]interface{}
+	err := json.Unmarshal([]byte(arguments), &args)
+	if err != nil {
+		slog.Error("Flow tool: AskUser", "error", err)
+		return "", err
+	}
+	question, ok := args["question"].(string)
+	if !ok || question == "" {
+		return "", errors.New("question is required")
+	}
+	slog.Info("Flow tool: AskUser", "question", question)
+	return question, nil
+}
+
+// AskUser returns the question to ask the user, or the error for the model when the call has no question.
+func (t *FlowTool) AskUser(toolCall providers.ToolCall) string {
+	question, err := t.askUserImpl(toolCall.Args)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return question
+}
+
+// Question returns the question of an ask_user call, failing when the model did not give one.
+func (t *FlowTool) Question(toolCall providers.ToolCall) (string, error) {
+	return t.askUserImpl(toolCall.Args)
+}
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+// Approval modes of a tool.
+const (
+	// ApprovalAuto runs the tool call right away.
+	ApprovalAuto = "auto"
+	// ApprovalRequired parks the tool call until the user approves or rejects it.
+	ApprovalRequired = "require_approval"
+	// ApprovalDeny refuses the tool call without running it.
+	ApprovalDeny = "deny"
+)
+
+// ErrApprovalPending is returned when resuming a Worker whose parked tool call has not been decided yet.
+var ErrApprovalPending = errors.New("tool call approval is still pending")
+
+// flowTools control the task itself and always run, whatever the approval policy says.
+var flowTools = map[string]bool{
+	"report":   true,
+	"wait":     true,
+	"ask_user": true,
+}
+
+// ToolApprovalPolicy maps tool names to approval modes.
+// The task owner's rule for a tool wins over the Worker role's rule, which wins over the default rule,
+// and tools without any rule run automatically.
+type ToolApprovalPolicy struct {
+	// Tools holds the default rules.
+	Tools map[string]string
+	// Roles holds the rules of each Worker role.
+	Roles map[string]map[string]string
+	// Owners holds the rules of each task owner.
+	Owners map[string]map[string]string
+}
+
+// Mode returns the approval mode of a tool called by a Worker of the role running a task of the owner.
+// Unknown modes require approval, so a typo in the configuration does not let the tool run unchecked.
+// Owners and roles are matched in lowercase, as the configuration lowercases its keys.
+func (p ToolApprovalPolicy) Mode(owner string, role string, toolName string) string {
+	if flowTools[toolName] {
+		return ApprovalAuto
+	}
+	mode := ApprovalAuto
+	if m, ok := p.Tools[toolName]; ok {
+		mode = m
+	}
+	if m, ok := p.Roles[strings.ToLower(role)][toolName]; ok {
+		mode = m
+	}
+	if m, ok := p.Owners[strings.ToLower(owner)][toolName]; ok {
+		mode = m
+	}
+	switch mode {
+	case ApprovalAuto, ApprovalRequired, ApprovalDeny:
+		return mode
+	default:
+		slog.Warn("Worker: Unknown approval mode, requiring approval", "tool", toolName, "mode", mode)
+		return ApprovalRequired
+	}
+}
+
+// PendingApproval is a tool call parked until the user decides on it.
+type PendingApproval struct {
+	ApprovalID string             `json:"approval_id"`
+	ToolCall   providers.ToolCall `json:"tool_call"`
+}
+
+// requestApproval records the tool call for the user to decide on and parks it in the Worker's state.
+func (w *WorkerImpl) requestApproval(toolCall providers.ToolCall) error {
+	approval := storage.ToolApproval{
+		ApprovalID: uuid.New().String(),
+		AgentID:    *w.ID,
+		Owner:      w.Metadata.Owner,
+		Role:       w.Role,
+		ToolCallID: toolCall.ID,
+		ToolName:   toolCall.FunctionName,
+		Arguments:  toolCall.Args,
+		Status:     storage.ToolApprovalPending,
+	}
+	if err := w.storage.CreateToolApproval(approval); err != nil {
+		return err
+	}
+	w.PendingApproval = &PendingApproval{
+		ApprovalID: approval.ApprovalID,
+		ToolCall:   toolCall,
+	}
+	// The request is published once persisted with the awaiting approval state
+	if err := w.queueApprovalRequest(context.Background(), approval); err != nil {
+		slog.Error("Worker: Failed to queue approval request", "error", err)
+	}
+	return nil
+}
+
+// awaitApproval moves the Worker to the awaiting approval state,
+// which persists the state so the Worker can be resumed once the user decides.
+func (w *WorkerImpl) awaitApproval() {
+	slog.Info("Worker: Awaiting tool call approval", "agentID", *w.ID, "role", w.Role, "approvalID", w.PendingApproval.ApprovalID)
+	if err := w.stateMachine.Event(context.Background(), eventAwaitApproval); err != nil {
+		slog.Error("Error processing event", "error", err)
+	}
+}
+
+// resolvePendingApproval applies the user's decision on the parked tool call.
+// An approved call runs with the user's edits to its arguments, if any, a rejected call does not run.
+// The returned tool message records the decision in the chat history.
+func (w *WorkerImpl) resolvePendingApproval() (providers.ChatMessage, error) {
+	pending := w.PendingApproval
+	approval, err := w.storage.GetToolApproval(pending.ApprovalID)
+	if err != nil {
+		slog.Error("Worker: Failed to get tool approval", "approvalID", pending.ApprovalID, "error", err)
+		return providers.ChatMessage{}, err
+	}
+
+	toolCall := pending.ToolCall
+	var content string
+	switch approval.Status {
+	case storage.ToolApprovalApproved:
+		content = "The user approved the tool call."
+		if approval.EditedArguments != "" {
+			toolCall.Args = approval.EditedArguments
+			content = fmt.Sprintf("The user approved the tool call with the arguments edited to %s.", approval.EditedArguments)
+		}
+		result := w.executeToolCall(toolCall)
+		content = fmt.Sprintf("%s\nResult: %s", content, result)
+	case storage.ToolApprovalRejected:
+		content = "The user rejected the tool call, the tool was not called."
+		if approval.Reason != "" {
+			content = fmt.Sprintf("%s\nReason: %s", content, approval.Reason)
+		}
+	default:
+		return providers.ChatMessage{}, ErrApprovalPending
+	}
+	slog.Info("Worker: Resolved tool call approval", "agentID", *w.ID, "approvalID", pending.ApprovalID, "status", approval.Status)
+
+	w.PendingApproval = nil
+	return providers.ChatMessage{
+		Content:  &content,
+		Role:     "tool",
+		ToolCall: &pending.ToolCall,
+	}, nil
+}
+
+// queueApprovalRequest notifies the user that a tool call waits for a decision
+func (w *WorkerImpl) queueApprovalRequest(ctx context.Context, approval storage.ToolApproval) error {
+	payload := WorkerApprovalNotification{
+		AgentID:    approval.AgentID,
+		Owner:      approval.Owner,
+		ApprovalID: approval.ApprovalID,
+		ToolName:   approval.ToolName,
+		Arguments:  approval.Arguments,
+	}
+	return w.queueEvent(ctx, AgentApprovalEvent, payload, GetAgentApprovalTopic())
+}
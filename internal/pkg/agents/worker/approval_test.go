@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolApprovalPolicyMode(t *testing.T) {
+	policy := ToolApprovalPolicy{
+		Tools: map[string]string{"save_content": ApprovalAuto, "search_content": "typo"},
+		Roles: map[string]map[string]string{
+			RolePublisher: {"save_content": ApprovalRequired},
+		},
+		Owners: map[string]map[string]string{
+			"trusted": {"save_content": ApprovalAuto},
+			"blocked": {"save_content": ApprovalDeny, "report": ApprovalDeny},
+		},
+	}
+
+	tests := []struct {
+		owner    string
+		role     string
+		toolName string
+		expected string
+	}{
+		{owner: "", role: RoleConsumer, toolName: "save_content", expected: ApprovalAuto},
+		{owner: "", role: RolePublisher, toolName: "save_content", expected: ApprovalRequired},
+		{owner: "trusted", role: RolePublisher, toolName: "save_content", expected: ApprovalAuto},
+		{owner: "blocked", role: RoleConsumer, toolName: "save_content", expected: ApprovalDeny},
+		// The configuration lowercases owner IDs and roles
+		{owner: "Blocked", role: RoleConsumer, toolName: "save_content", expected: ApprovalDeny},
+		{owner: "", role: "Publisher", toolName: "save_content", expected: ApprovalRequired},
+		// Tools without rules run, unknown modes require approval and flow tools always run
+		{owner: "", role: RoleConsumer, toolName: "unlisted", expected: ApprovalAuto},
+		{owner: "", role: RoleConsumer, toolName: "search_content", expected: ApprovalRequired},
+		{owner: "blocked", role: RoleConsumer, toolName: "report", expected: ApprovalAuto},
+	}
+	for _, test := range tests {
+		mode := policy.Mode(test.owner, test.role, test.toolName)
+		assert.Equal(t, test.expected, mode, "owner: %s, role: %s, tool: %s", test.owner, test.role, test.toolName)
+	}
+
+	assert.Equal(t, ApprovalAuto, ToolApprovalPolicy{}.Mode("", RolePublisher, "save_content"))
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how the Worker retries failed LLM calls.
+type RetryPolicy struct {
+	// MaxConsecutiveFailures is the number of failed calls in a row after which the Worker fails.
+	MaxConsecutiveFailures int
+	// BaseDelay is the delay after the first failure, it doubles with each further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential delay, a longer Retry-After from the provider is still honored.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by Workers unless SetRetryPolicy is called.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxConsecutiveFailures: 5,
+	BaseDelay:              1 * time.Second,
+	MaxDelay:               1 * time.Minute,
+}
+
+// Delay returns how long to wait after the given number of consecutive failures.
+// The exponential delay is jittered between half and all of its value, so agents failing together do not retry together,
+// and it is never shorter than the retryAfter requested by the provider.
+func (p RetryPolicy) Delay(failures int, retryAfter time.Duration) time.Duration {
+	delay := p.MaxDelay
+	if failures < 1 {
+		failures = 1
+	}
+	// Stop doubling once the cap is reached, the shift would overflow for large failure counts
+	if shift := failures - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		delay = p.BaseDelay << shift
+	}
+	delay = delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+	if retryAfter > delay {
+		return retryAfter
+	}
+	return delay
+}
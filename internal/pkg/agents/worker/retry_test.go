@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxConsecutiveFailures: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		max      time.Duration
+	}{
+		{failures: 1, max: time.Second},
+		{failures: 2, max: 2 * time.Second},
+		{failures: 3, max: 4 * time.Second},
+		{failures: 5, max: 10 * time.Second},
+		{failures: 100, max: 10 * time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			delay := policy.Delay(test.failures, 0)
+			assert.GreaterOrEqual(t, delay, test.max/2, "failures: %d", test.failures)
+			assert.LessOrEqual(t, delay, test.max, "failures: %d", test.failures)
+		}
+	}
+
+	// Retry-After is honored even beyond the max delay
+	assert.Equal(t, time.Minute, policy.Delay(1, time.Minute))
+}
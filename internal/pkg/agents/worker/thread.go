@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/looplab/fsm"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+// MessageKind is the kind of a message sent between agents.
+type MessageKind string
+
+// Message kinds. Messages sent with send_message are text messages,
+// the other kinds are the steps of the turn-taking protocol of threads.
+const (
+	// MessageKindText is a free-form message outside of any thread
+	MessageKindText MessageKind = "text"
+	// MessageKindRequest opens a thread by asking the other agent for something
+	MessageKindRequest MessageKind = "request"
+	// MessageKindOffer proposes what to exchange, or counters the previous offer
+	MessageKindOffer MessageKind = "offer"
+	// MessageKindAccept agrees to the other agent's offer
+	MessageKindAccept MessageKind = "accept"
+	// MessageKindReject turns down the request or the offer, a new offer may follow
+	MessageKindReject MessageKind = "reject"
+	// MessageKindDeliver hands over what the accepted offer promised
+	MessageKindDeliver MessageKind = "deliver"
+	// MessageKindClose ends the thread, either agent may close it at any time
+	MessageKindClose MessageKind = "close"
+)
+
+// Statuses of a thread, each named after the message that moves the thread to it.
+const (
+	ThreadStatusRequested = "requested"
+	ThreadStatusOffered   = "offered"
+	ThreadStatusAccepted  = "accepted"
+	ThreadStatusRejected  = "rejected"
+	ThreadStatusDelivered = "delivered"
+	ThreadStatusClosed    = "closed"
+)
+
+// ErrInvalidThreadMessage is returned when a message is not allowed in the current status of a thread,
+// or is not sent in turn.
+var ErrInvalidThreadMessage = errors.New("message not allowed in the thread")
+
+// threadEvents are the messages allowed in each status of a thread.
+var threadEvents = fsm.Events{
+	{Name: string(MessageKindOffer), Src: []string{ThreadStatusRequested, ThreadStatusOffered, ThreadStatusRejected}, Dst: ThreadStatusOffered},
+	{Name: string(MessageKindAccept), Src: []string{ThreadStatusOffered}, Dst: ThreadStatusAccepted},
+	{Name: string(MessageKindReject), Src: []string{ThreadStatusRequested, ThreadStatusOffered}, Dst: ThreadStatusRejected},
+	{Name: string(MessageKindDeliver), Src: []string{ThreadStatusAccepted}, Dst: ThreadStatusDelivered},
+	{
+		Name: string(MessageKindClose),
+		Src: []string{
+			ThreadStatusRequested,
+			ThreadStatusOffered,
+			ThreadStatusAccepted,
+			ThreadStatusRejected,
+			ThreadStatusDelivered,
+		},
+		Dst: ThreadStatusClosed,
+	},
+}
+
+// NextThreadStatus validates a message the agent sends to the thread and returns the status it moves the thread to.
+// Agents take turns, only the agent that did not send the last message may reply, but either agent may close the thread.
+func NextThreadStatus(thread storage.AgentThread, senderID string, kind MessageKind) (string, error) {
+	if senderID != thread.InitiatorAgentID && senderID != thread.ResponderAgentID {
+		return "", fmt.Errorf("%w: agent %s does not take part in the thread", ErrInvalidThreadMessage, senderID)
+	}
+	if kind != MessageKindClose && senderID == thread.LastSenderAgentID {
+		return "", fmt.Errorf("%w: wait for the other agent to reply", ErrInvalidThreadMessage)
+	}
+	machine := fsm.NewFSM(thread.Status, threadEvents, fsm.Callbacks{})
+	if !machine.Can(string(kind)) {
+		return "", fmt.Errorf("%w: cannot send %s in a %s thread, allowed: %v", ErrInvalidThreadMessage, kind, thread.Status, machine.AvailableTransitions())
+	}
+	err := machine.Event(context.Background(), string(kind))
+	var noTransition fsm.NoTransitionError
+	if err != nil && !errors.As(err, &noTransition) {
+		return "", err
+	}
+	return machine.Current(), nil
+}
+
+// threadRecipient returns the agent the sender talks to in the thread.
+func threadRecipient(thread storage.AgentThread, senderID string) string {
+	if senderID == thread.InitiatorAgentID {
+		return thread.ResponderAgentID
+	}
+	return thread.InitiatorAgentID
+}
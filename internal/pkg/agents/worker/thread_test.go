@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextThreadStatus(t *testing.T) {
+	thread := func(status string, lastSender string) storage.AgentThread {
+		return storage.AgentThread{
+			ThreadID:          "thread-id",
+			InitiatorAgentID:  "initiator",
+			ResponderAgentID:  "responder",
+			Status:            status,
+			LastSenderAgentID: lastSender,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		thread   storage.AgentThread
+		sender   string
+		kind     MessageKind
+		expected string
+		valid    bool
+	}{
+		{name: "offer on request", thread: thread(ThreadStatusRequested, "initiator"), sender: "responder", kind: MessageKindOffer, expected: ThreadStatusOffered, valid: true},
+		{name: "reject request", thread: thread(ThreadStatusRequested, "initiator"), sender: "responder", kind: MessageKindReject, expected: ThreadStatusRejected, valid: true},
+		{name: "counter offer", thread: thread(ThreadStatusOffered, "responder"), sender: "initiator", kind: MessageKindOffer, expected: ThreadStatusOffered, valid: true},
+		{name: "accept offer", thread: thread(ThreadStatusOffered, "responder"), sender: "initiator", kind: MessageKindAccept, expected: ThreadStatusAccepted, valid: true},
+		{name: "offer after rejection", thread: thread(ThreadStatusRejected, "initiator"), sender: "responder", kind: MessageKindOffer, expected: ThreadStatusOffered, valid: true},
+		{name: "deliver accepted offer", thread: thread(ThreadStatusAccepted, "initiator"), sender: "responder", kind: MessageKindDeliver, expected: ThreadStatusDelivered, valid: true},
+		{name: "close out of turn", thread: thread(ThreadStatusDelivered, "responder"), sender: "responder", kind: MessageKindClose, expected: ThreadStatusClosed, valid: true},
+		{name: "accept without offer", thread: thread(ThreadStatusRequested, "initiator"), sender: "responder", kind: MessageKindAccept},
+		{name: "deliver before accept", thread: thread(ThreadStatusOffered, "responder"), sender: "initiator", kind: MessageKindDeliver},
+		{name: "reply out of turn", thread: thread(ThreadStatusOffered, "responder"), sender: "responder", kind: MessageKindOffer},
+		{name: "reply to closed thread", thread: thread(ThreadStatusClosed, "initiator"), sender: "responder", kind: MessageKindOffer},
+		{name: "close closed thread", thread: thread(ThreadStatusClosed, "initiator"), sender: "responder", kind: MessageKindClose},
+		{name: "not a participant", thread: thread(ThreadStatusRequested, "initiator"), sender: "stranger", kind: MessageKindOffer},
+		{name: "text in thread", thread: thread(ThreadStatusRequested, "initiator"), sender: "responder", kind: MessageKindText},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := NextThreadStatus(test.thread, test.sender, test.kind)
+			if !test.valid {
+				assert.ErrorIs(t, err, ErrInvalidThreadMessage)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, status)
+		})
+	}
+}
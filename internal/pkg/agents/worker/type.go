@@ -38,6 +38,8 @@ const (
 	CmdSleep = "sleep"
 	// CmdTerminate instructs the Worker to persist its state and terminate.
 	CmdTerminate = "terminate"
+	// CmdRetry instructs the control plane to resume a failed Worker, it is not handled by the Worker itself.
+	CmdRetry = "retry"
 )
 
 // Status values representing the Worker's current state.
@@ -50,8 +52,36 @@ const (
 	StatusAsleep = "asleep"
 	// StatusTerminated indicates the Worker has final response and terminated.
 	StatusTerminated = "terminated"
+	// StatusFailed indicates the Worker gave up after LLM errors, it only runs again when retried.
+	StatusFailed = "failed"
+	// StatusAwaitingInput indicates the Worker asked the user a question, it only runs again when answered.
+	StatusAwaitingInput = "awaiting_input"
+	// StatusAwaitingApproval indicates the Worker parked a tool call, it only runs again when the user decides on it.
+	StatusAwaitingApproval = "awaiting_approval"
 )
 
+// TaskMetadata carries optional information about the task a Worker runs.
+// It is serialized with the Worker's state so it survives sleep and resume.
+type TaskMetadata struct {
+	// Owner identifies the user who started the task.
+	Owner string `json:"owner,omitempty"`
+	// Labels are arbitrary key/value pairs attached to the task.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Budget is the maximum number of LLM calls the Worker may make, 0 means unlimited.
+	Budget int `json:"budget,omitempty"`
+	// CustomPrompt is appended to the system prompt when the task starts.
+	CustomPrompt string `json:"custom_prompt,omitempty"`
+	// Priority orders the task among the runs waiting for a slot on the node, higher runs first.
+	Priority int `json:"priority,omitempty"`
+	// WakePolicy names the policy the scheduler uses to wake the agent, empty uses the scheduler's default.
+	WakePolicy string `json:"wake_policy,omitempty"`
+	// Interests are keywords of the posts that wake an agent using the event policy.
+	Interests []string `json:"interests,omitempty"`
+	// Description tells what the agent offers or seeks, it is published in the agent directory with Tags.
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
 // WorkerEventKey represents keys for Worker event callbacks.
 type WorkerEventKey string
 
@@ -65,6 +95,12 @@ const (
 	OnSleep WorkerEventKey = "onSleep"
 	// OnTerminate is the event key for the terminate callback.
 	OnTerminate WorkerEventKey = "onTerminate"
+	// OnFail is the event key for the fail callback.
+	OnFail WorkerEventKey = "onFail"
+	// OnAwaitInput is the event key for the callback when the Worker asks the user a question.
+	OnAwaitInput WorkerEventKey = "onAwaitInput"
+	// OnAwaitApproval is the event key for the callback when the Worker parks a tool call for approval.
+	OnAwaitApproval WorkerEventKey = "onAwaitApproval"
 )
 
 // WorkerCallbacks maps WorkerEventKeys to their corresponding CommandCallbacks.
@@ -96,7 +132,7 @@ type Worker interface {
 	// - callbacks: A map of WorkerEventKey to CommandCallback for handling events.
 	//
 	// Returns:
-	// - A string containing the Worker's response.
+	// - A string containing the Worker's response, or the question asked to the user if the Worker awaits input.
 	// - An error if the chat session could not be started or completed.
 	//
 	// Note:
@@ -109,10 +145,11 @@ type Worker interface {
 	// - ctx: The context used for cancellation and timeouts.
 	// - newPrompt: An optional new prompt to add to the chat history.
 	//   If newPrompt is nil, the chat resumes without adding a new prompt.
+	//   If the Worker parked a tool call, the user's decision on it is applied first.
 	// - callbacks: A map of WorkerEventKey to CommandCallback for handling events.
 	//
 	// Returns:
-	// - A string containing the Worker's response.
+	// - A string containing the Worker's response, or the question asked to the user if the Worker awaits input.
 	// - An error if the chat session could not be resumed.
 	//
 	// Note:
@@ -138,6 +175,30 @@ type Worker interface {
 	// - The current status of the Worker.
 	GetStatus() string
 
+	// SetMetadata sets the metadata of the task the Worker runs.
+	//
+	// Parameters:
+	// - metadata: The task metadata.
+	//
+	// Note:
+	// Call SetMetadata before Chat, ResumeChat restores the metadata from the persisted state.
+	SetMetadata(metadata TaskMetadata)
+
+	// GetMetadata returns the metadata of the task the Worker runs.
+	//
+	// Returns:
+	// - The task metadata.
+	GetMetadata() TaskMetadata
+
+	// SetApprovalPolicy sets the policy deciding which tool calls need the user's approval.
+	//
+	// Parameters:
+	// - policy: The tool approval policy.
+	//
+	// Note:
+	// Without a policy every tool call runs automatically.
+	SetApprovalPolicy(policy ToolApprovalPolicy)
+
 	// GetRole returns the role of the Worker.
 	//
 	// Returns:
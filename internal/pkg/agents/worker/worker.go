@@ -2,9 +2,11 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/looplab/fsm"
@@ -19,6 +21,24 @@ const (
 	TickerInterval      = 500 * time.Millisecond
 	WorkerControlChSize = 10
 	PublishTimeout      = 5 * time.Second
+	// MessageDeduplicatorCapacity is the number of message events a Worker remembers to drop duplicates
+	MessageDeduplicatorCapacity = 1000
+)
+
+// ErrBudgetExhausted is returned when the Worker has used up the LLM call budget of its task.
+var ErrBudgetExhausted = errors.New("task budget exhausted")
+
+// ErrTaskFailed is returned when the Worker gave up after LLM errors and moved to the failed state.
+var ErrTaskFailed = errors.New("task failed")
+
+// Events raised by the Worker itself and not sent as commands
+const (
+	// eventFail moves the Worker to the failed state
+	eventFail = "fail"
+	// eventAwaitInput moves the Worker to the awaiting input state after it called the ask_user tool
+	eventAwaitInput = "await_input"
+	// eventAwaitApproval moves the Worker to the awaiting approval state after it parked a tool call
+	eventAwaitApproval = "await_approval"
 )
 
 type WorkerImpl struct {
@@ -31,10 +51,31 @@ type WorkerImpl struct {
 	persistTools *tools.PersistTool     `json:"-"`
 	flowTools    *tools.FlowTool        `json:"-"`
 	pubSub       pubsub.PubSub          `json:"-"`
+	retryPolicy  RetryPolicy            `json:"-"`
+	approval     ToolApprovalPolicy     `json:"-"`
+	// Consecutive failed LLM calls in the current run, and when the next call may be made
+	consecutiveFailures int       `json:"-"`
+	retryAt             time.Time `json:"-"`
+	// Set when a message was published to the Worker's message topic and the inbox has not been read since
+	hasNewMessages atomic.Bool `json:"-"`
+	// Subscription to the Worker's message topic while it runs
+	messageSubscription pubsub.Subscription `json:"-"`
+	// Drops message events delivered twice
+	messageDeduplicator *pubsub.Deduplicator `json:"-"`
+	// Events written to the outbox with the next state the Worker persists
+	pendingEvents []storage.OutboxEvent `json:"-"`
 
 	ID       *string                 `json:"id"`
 	Role     string                  `json:"role"`
 	Messages []providers.ChatMessage `json:"messages"`
+	Metadata TaskMetadata            `json:"metadata"`
+	LLMCalls int                     `json:"llm_calls"`
+	// LastError is the error that failed the Worker, it is cleared when the Worker runs again.
+	LastError string `json:"last_error,omitempty"`
+	// PendingQuestion is the question the Worker asked the user, it is cleared when the Worker runs again.
+	PendingQuestion string `json:"pending_question,omitempty"`
+	// PendingApproval is the tool call waiting for the user's decision.
+	PendingApproval *PendingApproval `json:"pending_approval,omitempty"`
 }
 
 func NewWorker(id *string, role string, storage storage.Storage, chatProvider providers.ChatProvider, pubSub pubsub.PubSub) *WorkerImpl {
@@ -50,6 +91,9 @@ func NewWorker(id *string, role string, storage storage.Storage, chatProvider pr
 		persistTools: persistTool,
 		flowTools:    flowTool,
 		pubSub:       pubSub,
+		retryPolicy:  DefaultRetryPolicy,
+
+		messageDeduplicator: pubsub.NewDeduplicator(MessageDeduplicatorCapacity),
 
 		ID:   id,
 		Role: role,
@@ -61,6 +105,15 @@ func (w *WorkerImpl) SetControlCh(ch chan string) {
 	w.controlCh = ch
 }
 
+// SetRetryPolicy overrides DefaultRetryPolicy for this Worker
+func (w *WorkerImpl) SetRetryPolicy(policy RetryPolicy) {
+	w.retryPolicy = policy
+}
+
+func (w *WorkerImpl) SetApprovalPolicy(policy ToolApprovalPolicy) {
+	w.approval = policy
+}
+
 func (w *WorkerImpl) atomicGetMessages() []providers.ChatMessage {
 	w.messageMux.RLock()
 	defer w.messageMux.RUnlock()
@@ -92,12 +145,28 @@ func (w *WorkerImpl) GetRole() string {
 	return w.Role
 }
 
+func (w *WorkerImpl) SetMetadata(metadata TaskMetadata) {
+	w.Metadata = metadata
+}
+
+func (w *WorkerImpl) GetMetadata() TaskMetadata {
+	return w.Metadata
+}
+
+func (w *WorkerImpl) budgetExhausted() bool {
+	return w.Metadata.Budget > 0 && w.LLMCalls >= w.Metadata.Budget
+}
+
 func (w *WorkerImpl) Close() {
 	slog.Info("Worker: Closing control channel", "agentID", *w.ID, "role", w.Role)
 }
 
 func (w *WorkerImpl) initChat(messages []providers.ChatMessage, callbacks WorkerCallbacks) {
 	w.callbacks = callbacks
+	w.consecutiveFailures = 0
+	w.retryAt = time.Time{}
+	w.LastError = ""
+	w.PendingQuestion = ""
 	w.initAgentStateMachine()
 	w.atomicAppendMessages(messages)
 	if err := w.startMessageListener(); err != nil {
@@ -110,9 +179,13 @@ func (w *WorkerImpl) Chat(
 	prompt string,
 	callbacks WorkerCallbacks,
 ) (string, error) {
+	systemPrompt := SystemPrompt
+	if w.Metadata.CustomPrompt != "" {
+		systemPrompt = fmt.Sprintf("%s\n## Additional Instructions\n\n%s\n", SystemPrompt, w.Metadata.CustomPrompt)
+	}
 	messages := []providers.ChatMessage{
 		{
-			Content: &SystemPrompt,
+			Content: &systemPrompt,
 			Role:    "system",
 		},
 		{
@@ -125,6 +198,9 @@ func (w *WorkerImpl) Chat(
 	if err := w.PersistState(); err != nil {
 		slog.Error("Worker: Failed to persist state", "error", err)
 	}
+	if err := w.publishProfile(); err != nil {
+		slog.Error("Worker: Failed to publish profile", "error", err)
+	}
 	return w.getAgentResponseWithFlowControl(ctx)
 }
 
@@ -133,11 +209,23 @@ func (w *WorkerImpl) ResumeChat(
 	newPrompt *string,
 	callbacks WorkerCallbacks,
 ) (string, error) {
-	messages := []providers.ChatMessage{
-		{
+	var messages []providers.ChatMessage
+	if w.PendingApproval != nil {
+		toolMessage, err := w.resolvePendingApproval()
+		if err != nil {
+			return "", err
+		}
+		messages = append(messages, toolMessage)
+	}
+	// Messages sent by other agents while the Worker was not running
+	if inbox := w.inboxMessage(); inbox != nil {
+		messages = append(messages, *inbox)
+	}
+	if newPrompt != nil || len(messages) == 0 {
+		messages = append(messages, providers.ChatMessage{
 			Content: newPrompt,
 			Role:    "user",
-		},
+		})
 	}
 	w.initChat(messages, callbacks)
 	// Save initial state after resume
@@ -152,6 +240,7 @@ func (w *WorkerImpl) getAgentResponseWithFlowControl(ctx context.Context) (strin
 		slog.Error("Worker: Control channel not initialized", "agentID", *w.ID, "role", w.Role)
 		return "", fmt.Errorf("control channel not initialized")
 	}
+	defer w.stopMessageListener()
 
 	ticker := time.NewTicker(TickerInterval)
 	defer ticker.Stop()
@@ -176,12 +265,36 @@ func (w *WorkerImpl) getAgentResponseWithFlowControl(ctx context.Context) (strin
 			slog.Info("Worker: current state", "agentID", *w.ID, "role", w.Role, "state", status)
 			switch status {
 			case StatusRunning:
-				if response := w.getAgentResponse(); response != "" {
-					if err := w.publishFinalResponse(ctx, response); err != nil {
-						slog.Error("Worker: Failed to publish final response", "error", err)
+				if w.budgetExhausted() {
+					slog.Warn("Worker: Task budget exhausted", "agentID", *w.ID, "role", w.Role, "budget", w.Metadata.Budget)
+					w.terminate("task budget exhausted")
+					w.cleanUp()
+					return "", ErrBudgetExhausted
+				}
+				if time.Now().Before(w.retryAt) {
+					// Backing off after a failed LLM call
+					continue
+				}
+				response, err := w.getAgentResponse()
+				if err != nil {
+					if w.handleChatError(err) {
+						return "", fmt.Errorf("%w: %s", ErrTaskFailed, w.LastError)
+					}
+					continue
+				}
+				switch w.GetStatus() {
+				case StatusAwaitingInput:
+					return w.PendingQuestion, nil
+				case StatusAwaitingApproval:
+					return "", nil
+				}
+				if response != "" {
+					// The response is published once persisted with the terminated state
+					if err := w.queueFinalResponse(ctx, response); err != nil {
+						slog.Error("Worker: Failed to queue final response", "error", err)
 					}
 					// We got the final response, persist state and terminate the agent
-					w.stateMachine.SetState(StatusTerminated)
+					w.terminate("final response reported")
 					w.cleanUp()
 					return response, nil
 				}
@@ -189,22 +302,28 @@ func (w *WorkerImpl) getAgentResponseWithFlowControl(ctx context.Context) (strin
 				// Do nothing; the ticker handles pacing
 			case StatusAsleep:
 				return "", nil
+			case StatusAwaitingInput:
+				return w.PendingQuestion, nil
+			case StatusAwaitingApproval:
+				return "", nil
 			case StatusTerminated:
 				return "", nil
+			case StatusFailed:
+				return "", fmt.Errorf("%w: %s", ErrTaskFailed, w.LastError)
 			}
 		}
 	}
 }
 
-// SendCommand is idempotent, it will have no effect if the Worker is asleep or terminated.
+// SendCommand is idempotent, it will have no effect if the Worker is asleep, terminated, failed or awaiting the user.
 func (w *WorkerImpl) SendCommand(ctx context.Context, command string) error {
 	if w.controlCh == nil {
 		slog.Error("Worker: Control channel not initialized", "agentID", *w.ID, "role", w.Role)
 		return fmt.Errorf("control channel not initialized")
 	}
 	status := w.GetStatus()
-	if status == StatusAsleep || status == StatusTerminated {
-		slog.Warn("Worker: Agent is asleep or terminated, ignore send command", "agentID", *w.ID, "role", w.Role, "command", command)
+	if status == StatusAsleep || status == StatusTerminated || status == StatusFailed || status == StatusAwaitingInput || status == StatusAwaitingApproval {
+		slog.Warn("Worker: Agent is not running, ignore send command", "agentID", *w.ID, "role", w.Role, "command", command)
 		return nil
 	}
 	select {
@@ -226,6 +345,9 @@ func (w *WorkerImpl) initAgentStateMachine() {
 			{Name: CmdResume, Src: []string{StatusPaused}, Dst: StatusRunning},
 			{Name: CmdSleep, Src: []string{StatusRunning, StatusPaused, StatusAsleep}, Dst: StatusAsleep},
 			{Name: CmdTerminate, Src: []string{StatusRunning, StatusPaused}, Dst: StatusTerminated},
+			{Name: eventFail, Src: []string{StatusRunning}, Dst: StatusFailed},
+			{Name: eventAwaitInput, Src: []string{StatusRunning}, Dst: StatusAwaitingInput},
+			{Name: eventAwaitApproval, Src: []string{StatusRunning}, Dst: StatusAwaitingApproval},
 		},
 		fsm.Callbacks{
 			"before_event": func(_ context.Context, e *fsm.Event) {
@@ -233,6 +355,7 @@ func (w *WorkerImpl) initAgentStateMachine() {
 			},
 			"enter_state": func(_ context.Context, e *fsm.Event) {
 				slog.Info("Transitioned to state", "from", e.Src, "to", e.Dst)
+				w.publishStateChange(e.Src, e.Dst, w.stateChangeReason(e.Event))
 			},
 			"after_pause": func(_ context.Context, e *fsm.Event) {
 				if callback, ok := w.callbacks[OnPause]; ok {
@@ -256,10 +379,56 @@ func (w *WorkerImpl) initAgentStateMachine() {
 				}
 				w.cleanUp()
 			},
+			"after_fail": func(_ context.Context, e *fsm.Event) {
+				if callback, ok := w.callbacks[OnFail]; ok {
+					callback(*w.ID, w.stateMachine.Current())
+				}
+				w.cleanUp()
+				if err := w.storage.SetAgentError(*w.ID, w.LastError); err != nil {
+					slog.Error("Worker: Failed to record error", "error", err)
+				}
+			},
+			"after_await_input": func(_ context.Context, e *fsm.Event) {
+				if callback, ok := w.callbacks[OnAwaitInput]; ok {
+					callback(*w.ID, w.stateMachine.Current())
+				}
+				w.cleanUp()
+			},
+			"after_await_approval": func(_ context.Context, e *fsm.Event) {
+				if callback, ok := w.callbacks[OnAwaitApproval]; ok {
+					callback(*w.ID, w.stateMachine.Current())
+				}
+				w.cleanUp()
+			},
 		},
 	)
 }
 
+// terminate moves the Worker to the terminated state without running the terminate callbacks,
+// the caller persists the state.
+func (w *WorkerImpl) terminate(reason string) {
+	from := w.GetStatus()
+	w.stateMachine.SetState(StatusTerminated)
+	w.publishStateChange(from, StatusTerminated, reason)
+}
+
+// stateChangeReason describes why the event moved the Worker to another state.
+func (w *WorkerImpl) stateChangeReason(event string) string {
+	switch event {
+	case eventFail:
+		return w.LastError
+	case eventAwaitInput:
+		return "asked the user a question"
+	case eventAwaitApproval:
+		if w.PendingApproval != nil {
+			return fmt.Sprintf("the %s tool call needs the user's approval", w.PendingApproval.ToolCall.FunctionName)
+		}
+		return "a tool call needs the user's approval"
+	default:
+		return fmt.Sprintf("received %s command", event)
+	}
+}
+
 func (w *WorkerImpl) cleanUp() {
 	if err := w.PersistState(); err != nil {
 		slog.Error("Worker: Failed to persist state", "error", err)
@@ -267,14 +436,55 @@ func (w *WorkerImpl) cleanUp() {
 	slog.Info("Worker: Cleaned up", "agentID", *w.ID, "role", w.Role)
 }
 
-func (w *WorkerImpl) getAgentResponse() string {
+// handleChatError backs off before the next LLM call, or fails the Worker if the error cannot be fixed by retrying
+// or too many calls failed in a row. It returns true if the Worker failed.
+func (w *WorkerImpl) handleChatError(err error) bool {
+	providerErr := providers.ClassifyError(err)
+	w.consecutiveFailures++
+	if !providerErr.Retryable() || w.consecutiveFailures >= w.retryPolicy.MaxConsecutiveFailures {
+		slog.Error("Worker: Giving up on LLM errors", "agentID", *w.ID, "role", w.Role, "kind", providerErr.Kind, "failures", w.consecutiveFailures, "error", err)
+		w.publishError(fmt.Sprintf("LLM call failed (%s), giving up after %d failures", providerErr.Kind, w.consecutiveFailures), providerErr)
+		w.LastError = providerErr.Error()
+		if err := w.stateMachine.Event(context.Background(), eventFail); err != nil {
+			slog.Error("Error processing event", "error", err)
+		}
+		return true
+	}
+	delay := w.retryPolicy.Delay(w.consecutiveFailures, providerErr.RetryAfter)
+	w.retryAt = time.Now().Add(delay)
+	slog.Warn("Worker: LLM call failed, backing off", "agentID", *w.ID, "role", w.Role, "kind", providerErr.Kind, "failures", w.consecutiveFailures, "delay", delay)
+	w.publishError(fmt.Sprintf("LLM call failed (%s), retrying in %s", providerErr.Kind, delay), providerErr)
+	return false
+}
+
+// awaitInput moves the Worker to the awaiting input state, whose progress carries the pending question,
+// which persists the state so the Worker can be resumed with the user's answer.
+func (w *WorkerImpl) awaitInput() {
+	slog.Info("Worker: Awaiting user input", "agentID", *w.ID, "role", w.Role, "question", w.PendingQuestion)
+	if err := w.stateMachine.Event(context.Background(), eventAwaitInput); err != nil {
+		slog.Error("Error processing event", "error", err)
+	}
+}
+
+func (w *WorkerImpl) getAgentResponse() (string, error) {
+	if w.hasNewMessages.Swap(false) {
+		if inbox := w.inboxMessage(); inbox != nil {
+			w.atomicAppendMessage(*inbox)
+		}
+	}
+
 	// Ask the LLM
 	messages := w.atomicGetMessages()
+	w.LLMCalls++
+	w.publishLLMCallStarted()
+	startedAt := time.Now()
 	agentResponse, err := w.chatProvider.Chat(messages)
 	if err != nil {
 		slog.Error("Agent chat error", "role", w.Role, "error", err)
-		return ""
+		return "", err
 	}
+	w.publishLLMCallFinished(time.Since(startedAt), agentResponse)
+	w.consecutiveFailures = 0
 	msg := providers.ChatMessage{
 		Content: agentResponse.Content,
 		Role:    "assistant",
@@ -287,11 +497,17 @@ func (w *WorkerImpl) getAgentResponse() string {
 	// Handle tool calls
 	finalResponse := w.handleToolCalls(agentResponse.ToolCalls)
 	slog.Info("Agent final response", "role", w.Role, "response", finalResponse)
+	if w.PendingQuestion != "" {
+		w.awaitInput()
+	}
+	if w.PendingApproval != nil {
+		w.awaitApproval()
+	}
 
 	messages = w.atomicGetMessages()
 	w.debugStruct("Agent chat messages", messages)
 
-	return finalResponse
+	return finalResponse, nil
 }
 
 func (w *WorkerImpl) handleToolCalls(
@@ -301,10 +517,21 @@ func (w *WorkerImpl) handleToolCalls(
 		funcName := toolCall.FunctionName
 		slog.Info("Agent tool call", "role", w.Role, "tool_call", funcName)
 
-		// Publish progress
-		progress := fmt.Sprintf("Calling tool: %s", funcName)
-		if err := w.publishProgress(context.Background(), progress); err != nil {
-			slog.Error("Worker: Failed to publish progress", "error", err)
+		w.publishToolCall(toolCall)
+
+		switch w.approval.Mode(w.Metadata.Owner, w.Role, funcName) {
+		case ApprovalDeny:
+			slog.Warn("Agent tool call denied by policy", "role", w.Role, "tool_call", funcName)
+			w.appendToolResult(toolCall, fmt.Sprintf("The %s tool is not allowed for this task, the tool was not called.", funcName))
+			continue
+		case ApprovalRequired:
+			if err := w.requestApproval(toolCall); err != nil {
+				slog.Error("Worker: Failed to request tool call approval", "error", err)
+				w.appendToolResult(toolCall, fmt.Sprintf("Error: the %s tool needs the user's approval, but the approval could not be requested: %v", funcName, err))
+				continue
+			}
+			// Stop here, the task continues once the user decides on the call
+			return finalResponse
 		}
 
 		toolCallResult := w.handleSingleToolCall(toolCall)
@@ -314,6 +541,13 @@ func (w *WorkerImpl) handleToolCalls(
 			finalResponse = toolCallResult
 			break
 		}
+		if funcName == "ask_user" {
+			// A call without a question is answered with the error, so the model can ask again
+			if question, err := w.flowTools.Question(toolCall); err == nil {
+				w.PendingQuestion = question
+				break
+			}
+		}
 	}
 
 	return finalResponse
@@ -322,23 +556,43 @@ func (w *WorkerImpl) handleToolCalls(
 func (w *WorkerImpl) handleSingleToolCall(
 	toolCall providers.ToolCall,
 ) (toolCallResult string) {
+	toolCallResult = w.executeToolCall(toolCall)
+	w.appendToolResult(toolCall, toolCallResult)
+	return toolCallResult
+}
+
+func (w *WorkerImpl) executeToolCall(toolCall providers.ToolCall) string {
 	funcName := toolCall.FunctionName
 	slog.Info("Agent tool call", "role", w.Role, "tool_call", funcName)
 
 	toolCallFuncMap := map[string]func(toolCall providers.ToolCall) string{
-		"save_content":   w.persistTools.SaveContent,
-		"search_content": w.persistTools.SearchContent,
-		"wait":           w.flowTools.Wait,
-		"report":         w.flowTools.Report,
+		"save_content":         w.saveContent,
+		"search_content":       w.persistTools.SearchContent,
+		"wait":                 w.flowTools.Wait,
+		"report":               w.flowTools.Report,
+		"ask_user":             w.flowTools.AskUser,
+		"send_message":         w.SendMessage,
+		"check_inbox":          w.CheckInbox,
+		"subscribe_interest":   w.SubscribeInterest,
+		"unsubscribe_interest": w.UnsubscribeInterest,
+		"update_profile":       w.UpdateProfile,
+		"find_agents":          w.FindAgents,
+		"open_thread":          w.OpenThread,
+		"reply_thread":         w.ReplyThread,
+		"close_thread":         w.CloseThread,
 	}
-	toolCallResult = toolCallFuncMap[funcName](toolCall)
+	return toolCallFuncMap[funcName](toolCall)
+}
+
+// appendToolResult adds the result of the tool call to the conversation and publishes it as progress,
+// including the results of denied calls and of calls the user decided on.
+func (w *WorkerImpl) appendToolResult(toolCall providers.ToolCall, result string) {
+	w.publishToolResult(toolCall, result)
 	w.atomicAppendMessage(providers.ChatMessage{
-		Content:  &toolCallResult,
+		Content:  &result,
 		Role:     "tool",
 		ToolCall: &toolCall,
 	})
-
-	return toolCallResult
 }
 
 func (w *WorkerImpl) debugStruct(title string, v any) {
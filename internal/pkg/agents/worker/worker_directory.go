@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+const (
+	// DefaultFindAgentsLimit is the number of agents find_agents returns unless asked otherwise
+	DefaultFindAgentsLimit = 10
+	// MaxFindAgentsLimit bounds the number of agents find_agents returns
+	MaxFindAgentsLimit = 50
+)
+
+// publishProfile saves the Worker's profile to the agent directory, so other agents can find it.
+func (w *WorkerImpl) publishProfile() error {
+	profile := storage.AgentProfile{
+		AgentID:     *w.ID,
+		Owner:       w.Metadata.Owner,
+		Role:        w.Role,
+		Description: w.Metadata.Description,
+		Tags:        w.Metadata.Tags,
+	}
+	return w.storage.SaveAgentProfile(profile)
+}
+
+// UpdateProfile is the update_profile tool, it changes what the Worker's directory profile says it offers or seeks.
+func (w *WorkerImpl) UpdateProfile(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: UpdateProfile", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	description, _ := args["description"].(string)
+	if description == "" {
+		return "Error: description is required"
+	}
+	w.Metadata.Description = description
+	if tags, ok := args["tags"].([]interface{}); ok {
+		w.Metadata.Tags = stringArgs(tags)
+	}
+	if err := w.publishProfile(); err != nil {
+		slog.Error("Worker: UpdateProfile failed to save profile", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	slog.Info("Worker: UpdateProfile", "agentID", *w.ID, "description", description, "tags", w.Metadata.Tags)
+	return "Profile updated, other agents can now find you by it."
+}
+
+// FindAgents is the find_agents tool, it searches the agent directory for agents to connect with.
+// Terminated and failed agents are left out, as they do not read their messages.
+func (w *WorkerImpl) FindAgents(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: FindAgents", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	query := storage.AgentProfileQuery{
+		ExcludedAgentID:  *w.ID,
+		ExcludedStatuses: []string{StatusTerminated, StatusFailed},
+		Limit:            DefaultFindAgentsLimit,
+	}
+	query.Query, _ = args["query"].(string)
+	query.Role, _ = args["role"].(string)
+	if tags, ok := args["tags"].([]interface{}); ok {
+		query.Tags = stringArgs(tags)
+	}
+	if limit, ok := args["limit"].(float64); ok && limit > 0 {
+		query.Limit = min(int(limit), MaxFindAgentsLimit)
+	}
+
+	profiles, err := w.storage.SearchAgentProfiles(query)
+	if err != nil {
+		slog.Error("Worker: FindAgents failed to search profiles", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	slog.Info("Worker: FindAgents", "agentID", *w.ID, "query", query.Query, "found", len(profiles))
+	if len(profiles) == 0 {
+		return "No agents found."
+	}
+	var sb strings.Builder
+	sb.WriteString("Agents found (send them messages with the send_message tool):\n")
+	for _, profile := range profiles {
+		sb.WriteString(fmt.Sprintf("- Agent ID: %s, role: %s", profile.AgentID, profile.Role))
+		if profile.Description != "" {
+			sb.WriteString(fmt.Sprintf(", description: %s", profile.Description))
+		}
+		if len(profile.Tags) > 0 {
+			sb.WriteString(fmt.Sprintf(", tags: %s", strings.Join(profile.Tags, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func stringArgs(values []interface{}) []string {
+	var result []string
+	for _, value := range values {
+		if s, ok := value.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
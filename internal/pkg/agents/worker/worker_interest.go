@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+// interestInactiveStatuses are the statuses of agents whose interests are not notified,
+// they only run again when the user resumes or retries their task.
+var interestInactiveStatuses = []string{StatusTerminated, StatusFailed}
+
+// SubscribeInterest is the subscribe_interest tool, it registers a standing interest of the Worker in new posts.
+// Posts saved afterwards that match the interest are sent to the Worker's inbox.
+func (w *WorkerImpl) SubscribeInterest(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: SubscribeInterest", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	query, _ := args["query"].(string)
+	if query == "" {
+		return "Error: query is required"
+	}
+	interest := storage.AgentInterest{
+		InterestID: uuid.New().String(),
+		AgentID:    *w.ID,
+		Query:      query,
+	}
+	if keywords, ok := args["keywords"].([]interface{}); ok {
+		interest.Keywords = stringArgs(keywords)
+	}
+	if semantic, ok := args["semantic"].(bool); ok {
+		interest.Semantic = semantic
+	}
+	if minSimilarity, ok := args["min_similarity"].(float64); ok {
+		interest.MinSimilarity = float32(minSimilarity)
+	}
+
+	if err := w.storage.CreateAgentInterest(interest); err != nil {
+		slog.Error("Worker: SubscribeInterest failed to create interest", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	slog.Info("Worker: SubscribeInterest", "agentID", *w.ID, "interestID", interest.InterestID, "query", query)
+	return fmt.Sprintf("Subscribed to new posts matching %q (interest ID: %s). Matching posts will be sent to your inbox.", query, interest.InterestID)
+}
+
+// UnsubscribeInterest is the unsubscribe_interest tool, it cancels an interest of the Worker.
+func (w *WorkerImpl) UnsubscribeInterest(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: UnsubscribeInterest", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	interestID, _ := args["interest_id"].(string)
+	err := w.storage.CancelAgentInterest(*w.ID, interestID)
+	if errors.Is(err, storage.ErrAgentInterestNotFound) {
+		return fmt.Sprintf("Error: you have no active interest with ID %s", interestID)
+	}
+	if err != nil {
+		slog.Error("Worker: UnsubscribeInterest failed to cancel interest", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	slog.Info("Worker: UnsubscribeInterest", "agentID", *w.ID, "interestID", interestID)
+	return fmt.Sprintf("Unsubscribed from interest %s.", interestID)
+}
+
+// saveContent is the save_content tool, the saved post is matched against the interests of the other agents.
+func (w *WorkerImpl) saveContent(toolCall providers.ToolCall) string {
+	result := w.persistTools.SaveContent(toolCall)
+	if strings.HasPrefix(result, "Error") {
+		return result
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		return result
+	}
+	content, _ := args["content"].(string)
+	w.notifyInterests(content)
+	return result
+}
+
+// notifyInterests sends a new post to the inboxes of the agents interested in it,
+// running agents read it before their next LLM call and asleep agents are woken by the scheduler.
+// An agent gets one notification per post, however many of its interests match.
+func (w *WorkerImpl) notifyInterests(content string) {
+	interests, err := w.storage.MatchAgentInterests(content, interestInactiveStatuses)
+	if err != nil {
+		slog.Error("Worker: Failed to match interests", "error", err)
+		return
+	}
+	notified := map[string]bool{}
+	for _, interest := range interests {
+		if interest.AgentID == *w.ID || notified[interest.AgentID] {
+			continue
+		}
+		notified[interest.AgentID] = true
+		notification := fmt.Sprintf("A new post matches your interest %q (interest ID: %s):\n%s", interest.Query, interest.InterestID, content)
+		if _, err := w.deliverMessage(interest.AgentID, notification); err != nil {
+			slog.Error("Worker: Failed to notify interest", "agentID", interest.AgentID, "interestID", interest.InterestID, "error", err)
+		}
+	}
+	slog.Info("Worker: Notified interests", "agentID", *w.ID, "matched", len(interests), "notified", len(notified))
+}
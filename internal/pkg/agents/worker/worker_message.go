@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+)
+
+// SendMessage is the send_message tool, it delivers a message to another agent's inbox.
+// The message is persisted first, so an agent that is not running reads it when it is resumed,
+// and then published to the recipient's message topic so a running agent reads it before its next LLM call.
+func (w *WorkerImpl) SendMessage(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: SendMessage", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	toAgentID, _ := args["to_agent_id"].(string)
+	content, _ := args["content"].(string)
+	if toAgentID == "" || content == "" {
+		return "Error: to_agent_id and content are required"
+	}
+	if toAgentID == *w.ID {
+		return "Error: cannot send a message to yourself"
+	}
+	recipient, err := w.storage.GetAgentInfo(toAgentID)
+	if err != nil {
+		slog.Error("Worker: SendMessage failed to get recipient", "to", toAgentID, "error", err)
+		return fmt.Sprintf("Error: agent %s not found", toAgentID)
+	}
+
+	message, err := w.deliverMessage(toAgentID, content)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	slog.Info("Worker: SendMessage", "from", message.FromAgentID, "to", toAgentID, "messageID", message.MessageID)
+
+	switch recipient.Status {
+	case StatusTerminated, StatusFailed:
+		return fmt.Sprintf("Message sent to agent %s, but the agent is %s and reads it only if its task is resumed.", toAgentID, recipient.Status)
+	default:
+		return fmt.Sprintf("Message sent to agent %s.", toAgentID)
+	}
+}
+
+// deliverMessage saves the message to the recipient's inbox and publishes it to the recipient's message topic.
+func (w *WorkerImpl) deliverMessage(toAgentID string, content string) (WorkerMessage, error) {
+	message := WorkerMessage{
+		MessageID:   uuid.New().String(),
+		FromAgentID: *w.ID,
+		ToAgentID:   toAgentID,
+		Kind:        MessageKindText,
+		Content:     content,
+		CreatedAt:   time.Now(),
+	}
+	err := w.storage.SaveAgentMessage(message.toAgentMessage())
+	if err != nil {
+		slog.Error("Worker: Failed to save message", "error", err)
+		return WorkerMessage{}, err
+	}
+	if err := w.publishMessage(context.Background(), message); err != nil {
+		// The message is in the recipient's inbox, it is read when the recipient is resumed
+		slog.Error("Worker: Failed to publish message", "error", err)
+	}
+	return message, nil
+}
+
+// CheckInbox is the check_inbox tool, it returns the unread messages sent to the Worker and marks them read.
+func (w *WorkerImpl) CheckInbox(toolCall providers.ToolCall) string {
+	w.hasNewMessages.Store(false)
+	messages, err := w.readInbox()
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	if len(messages) == 0 {
+		return "No new messages."
+	}
+	return formatInbox(messages)
+}
+
+// readInbox lists the unread messages sent to the Worker and marks them read.
+func (w *WorkerImpl) readInbox() ([]storage.AgentMessage, error) {
+	messages, err := w.storage.ListUnreadAgentMessages(*w.ID)
+	if err != nil {
+		slog.Error("Worker: Failed to list unread messages", "agentID", *w.ID, "error", err)
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.MessageID
+	}
+	if err := w.storage.MarkAgentMessagesRead(*w.ID, messageIDs); err != nil {
+		slog.Error("Worker: Failed to mark messages read", "agentID", *w.ID, "error", err)
+		return nil, err
+	}
+	slog.Info("Worker: Read inbox", "agentID", *w.ID, "messages", len(messages))
+	return messages, nil
+}
+
+// inboxMessage reads the inbox into a user message for the conversation, it returns nil if the inbox is empty.
+func (w *WorkerImpl) inboxMessage() *providers.ChatMessage {
+	messages, err := w.readInbox()
+	if err != nil || len(messages) == 0 {
+		return nil
+	}
+	content := fmt.Sprintf("## New Messages\n\nOther agents sent you messages, reply with the send_message tool if needed.\n\n%s", formatInbox(messages))
+	return &providers.ChatMessage{
+		Content: &content,
+		Role:    "user",
+	}
+}
+
+func formatInbox(messages []storage.AgentMessage) string {
+	var sb strings.Builder
+	for _, message := range messages {
+		if message.ThreadID != "" {
+			sb.WriteString(fmt.Sprintf("From agent %s at %s in thread %s (%s):\n%s\n\n", message.FromAgentID, message.CreatedAt.Format(time.RFC3339), message.ThreadID, message.Kind, message.Content))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("From agent %s at %s:\n%s\n\n", message.FromAgentID, message.CreatedAt.Format(time.RFC3339), message.Content))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func (m WorkerMessage) toAgentMessage() storage.AgentMessage {
+	return storage.AgentMessage{
+		MessageID:   m.MessageID,
+		FromAgentID: m.FromAgentID,
+		ToAgentID:   m.ToAgentID,
+		ThreadID:    m.ThreadID,
+		Kind:        string(m.Kind),
+		Content:     m.Content,
+	}
+}
+
+func (w *WorkerImpl) publishMessage(ctx context.Context, message WorkerMessage) error {
+	return w.publishEvent(ctx, GetAgentMessageTopic(message.ToAgentID), AgentMessageEvent, message)
+}
+
+// startMessageListener listens to the Worker's message topic while it runs,
+// new messages are read from the inbox before the next LLM call.
+func (w *WorkerImpl) startMessageListener() error {
+	callback := func(message string, headers pubsub.Headers) error {
+		_, agentMessage, err := pubsub.DecodeEvent[WorkerMessage](message, AgentMessageEvent)
+		if err != nil {
+			slog.Error("Worker: Failed to decode message event", "error", err)
+			return err
+		}
+		slog.Info("Worker: Received message", "agentID", *w.ID, "from", agentMessage.FromAgentID, "messageID", agentMessage.MessageID)
+		w.hasNewMessages.Store(true)
+		return nil
+	}
+	w.stopMessageListener()
+	subscription, err := w.pubSub.Subscribe(GetAgentMessageTopic(*w.ID), pubsub.Idempotent(w.messageDeduplicator, callback))
+	if err != nil {
+		slog.Error("Worker: Failed to subscribe to agent messages", "error", err)
+		return err
+	}
+	w.messageSubscription = subscription
+	return nil
+}
+
+func (w *WorkerImpl) stopMessageListener() {
+	if w.messageSubscription == nil {
+		return
+	}
+	w.messageSubscription.Unsubscribe()
+	w.messageSubscription = nil
+}
@@ -2,27 +2,63 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+)
+
+// Schemas of the events the Worker publishes, their payloads are the notification structs below.
+var (
+	AgentResponseEvent = pubsub.EventSchema{Type: "agent.response", Version: 1}
+	AgentProgressEvent = pubsub.EventSchema{Type: "agent.progress", Version: 1}
+	AgentApprovalEvent = pubsub.EventSchema{Type: "agent.approval", Version: 1}
+	AgentMessageEvent  = pubsub.EventSchema{Type: "agent.message", Version: 1}
 )
 
+// WorkerResponseNotification carries the agent's final response.
+// Like the other notifications, it carries the owner of the task so it can be routed to the owner's clients.
 type WorkerResponseNotification struct {
 	AgentID  string `json:"agent_id"`
+	Owner    string `json:"owner,omitempty"`
 	Response string `json:"response"`
 }
 
+// WorkerProgressNotification reports a phase of the agent's task.
+// Progress is a human readable summary, the detail matching Type is set for LLM calls, tool calls and state changes.
 type WorkerProgressNotification struct {
-	AgentID  string `json:"agent_id"`
-	Progress string `json:"progress"`
+	AgentID     string               `json:"agent_id"`
+	Owner       string               `json:"owner,omitempty"`
+	Progress    string               `json:"progress"`
+	Type        ProgressType         `json:"type,omitempty"`
+	Level       ProgressLevel        `json:"level,omitempty"`
+	Timestamp   time.Time            `json:"timestamp"`
+	LLMCall     *LLMCallProgress     `json:"llm_call,omitempty"`
+	ToolCall    *ToolCallProgress    `json:"tool_call,omitempty"`
+	StateChange *StateChangeProgress `json:"state_change,omitempty"`
+	Error       string               `json:"error,omitempty"`
 }
 
+// WorkerApprovalNotification asks the user to decide on a tool call
+type WorkerApprovalNotification struct {
+	AgentID    string `json:"agent_id"`
+	Owner      string `json:"owner,omitempty"`
+	ApprovalID string `json:"approval_id"`
+	ToolName   string `json:"tool_name"`
+	Arguments  string `json:"arguments"`
+}
+
+// WorkerMessage is a message sent from one agent to another, published to the recipient's message topic
 type WorkerMessage struct {
+	MessageID   string      `json:"message_id"`
 	FromAgentID string      `json:"from_agent_id"`
 	ToAgentID   string      `json:"to_agent_id"`
-	MessageType string      `json:"message_type"`
-	Payload     interface{} `json:"payload"`
+	ThreadID    string      `json:"thread_id,omitempty"`
+	Kind        MessageKind `json:"kind"`
+	Content     string      `json:"content"`
+	CreatedAt   time.Time   `json:"created_at"`
 }
 
 func GetAgentResponseTopic(agentID string) string {
@@ -39,90 +75,58 @@ func GetAgentProgressTopic() string {
 	return "agent_progress"
 }
 
-// GetAgentMessageTopic returns the topic for agent messages between agents
-func GetAgentMessageTopic() string {
-	return "agent_message"
+// GetAgentApprovalTopic returns the topic for tool calls waiting for the user's approval
+func GetAgentApprovalTopic() string {
+	return "agent_approval"
 }
 
-// publishFinalResponse publishes the final response to the agent and the general topic
-func (w *WorkerImpl) publishFinalResponse(ctx context.Context, response string) error {
-	slog.Info("Worker: Publishing final response", "agentID", *w.ID, "response", response)
+// GetAgentMessageTopic returns the topic for messages sent to the agent by other agents
+func GetAgentMessageTopic(agentID string) string {
+	return fmt.Sprintf("%s_message", agentID)
+}
+
+// queueFinalResponse queues the final response for the agent and the general topic,
+// both topics carry the same event so consumers of both handle it once.
+func (w *WorkerImpl) queueFinalResponse(ctx context.Context, response string) error {
+	slog.Info("Worker: Queueing final response", "agentID", *w.ID, "response", response)
 	payload := WorkerResponseNotification{
 		AgentID:  *w.ID,
+		Owner:    w.Metadata.Owner,
 		Response: response,
 	}
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		slog.Error("Worker: Failed to marshal payload", "error", err)
-		return err
-	}
-	topic := GetAgentResponseTopic(*w.ID)
-	err = w.pubSub.Publish(ctx, topic, string(payloadBytes), PublishTimeout)
-	if err != nil {
-		slog.Error("Worker: Failed to publish response", "error", err)
-		return err
-	}
-	generalTopic := GetAgentResponseGeneralTopic()
-	err = w.pubSub.Publish(ctx, generalTopic, string(payloadBytes), PublishTimeout)
-	if err != nil {
-		slog.Error("Worker: Failed to publish response", "error", err)
-		return err
-	}
-	return nil
+	return w.queueEvent(ctx, AgentResponseEvent, payload, GetAgentResponseTopic(*w.ID), GetAgentResponseGeneralTopic())
 }
 
-func (w *WorkerImpl) publishProgress(ctx context.Context, progress string) error {
-	slog.Info("Worker: Publishing progress", "agentID", *w.ID, "progress", progress)
-	payload := WorkerProgressNotification{
-		AgentID:  *w.ID,
-		Progress: progress,
-	}
-	payloadBytes, err := json.Marshal(payload)
+// publishEvent publishes the payload in an event envelope sourced from the Worker
+func (w *WorkerImpl) publishEvent(ctx context.Context, topic string, schema pubsub.EventSchema, payload any) error {
+	_, err := pubsub.PublishEvent(ctx, w.pubSub, topic, schema, *w.ID, payload, PublishTimeout)
 	if err != nil {
-		slog.Error("Worker: Failed to marshal payload", "error", err)
-		return err
+		slog.Error("Worker: Failed to publish event", "topic", topic, "type", schema.Type, "error", err)
 	}
-	return w.pubSub.Publish(ctx, GetAgentProgressTopic(), string(payloadBytes), PublishTimeout)
+	return err
 }
 
-func (w *WorkerImpl) sendMessage(toAgentID string, messageType string, payload interface{}) error {
-	message := WorkerMessage{
-		FromAgentID: *w.ID,
-		ToAgentID:   toAgentID,
-		MessageType: messageType,
-		Payload:     payload,
-	}
-	messageBytes, err := json.Marshal(message)
+// queueEvent queues the payload in an event envelope sourced from the Worker, for each of the topics.
+// Queued events are written to the outbox in the same transaction as the next state the Worker persists,
+// so they are published if and only if that state is saved.
+func (w *WorkerImpl) queueEvent(ctx context.Context, schema pubsub.EventSchema, payload any, topics ...string) error {
+	envelope, err := pubsub.NewEnvelope(ctx, schema, *w.ID, payload)
 	if err != nil {
+		slog.Error("Worker: Failed to create event", "type", schema.Type, "error", err)
 		return err
 	}
-	return w.pubSub.Publish(context.Background(), GetAgentMessageTopic(), string(messageBytes), 5*time.Second)
-}
-
-func (w *WorkerImpl) startMessageListener() error {
-	callback := func(message string) error {
-		var agentMessage WorkerMessage
-		if err := json.Unmarshal([]byte(message), &agentMessage); err != nil {
-			return err
-		}
-		if agentMessage.ToAgentID != *w.ID {
-			// Not intended for this agent
-			return nil
-		}
-		// Process the message based on messageType
-		switch agentMessage.MessageType {
-		case "call":
-			// Handle incoming call
-		case "answer":
-			// Handle answer
-			// Other message types...
-		}
-		return nil
-	}
-	err := w.pubSub.Subscribe(GetAgentMessageTopic(), callback)
+	message, err := envelope.Encode()
 	if err != nil {
-		slog.Error("Worker: Failed to subscribe to agent-messages", "error", err)
+		slog.Error("Worker: Failed to encode event", "type", schema.Type, "error", err)
 		return err
 	}
+	for _, topic := range topics {
+		w.pendingEvents = append(w.pendingEvents, storage.OutboxEvent{
+			Topic:   topic,
+			Key:     envelope.Source,
+			Message: message,
+			Headers: envelope.Headers(),
+		})
+	}
 	return nil
 }
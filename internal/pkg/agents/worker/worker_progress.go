@@ -0,0 +1,199 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+)
+
+// ProgressType is the phase of the task a progress notification reports.
+type ProgressType string
+
+const (
+	// ProgressTypeLLMCallStarted is published before the Worker asks the LLM
+	ProgressTypeLLMCallStarted ProgressType = "llm_call_started"
+	// ProgressTypeLLMCallFinished is published once the LLM answered, with the latency and the tokens used
+	ProgressTypeLLMCallFinished ProgressType = "llm_call_finished"
+	// ProgressTypeToolCall is published before a tool is called, with its arguments
+	ProgressTypeToolCall ProgressType = "tool_call"
+	// ProgressTypeToolResult is published with the result of a tool call, truncated to ToolResultProgressLimit
+	ProgressTypeToolResult ProgressType = "tool_result"
+	// ProgressTypeStateChanged is published when the Worker moves to another state
+	ProgressTypeStateChanged ProgressType = "state_changed"
+	// ProgressTypeError is published when an LLM call fails, whether it is retried or not
+	ProgressTypeError ProgressType = "error"
+	// ProgressTypeAgentWoken is published by the control plane when the scheduler wakes the agent
+	ProgressTypeAgentWoken ProgressType = "agent_woken"
+	// ProgressTypeAgentAsleep is published by the control plane once the agent is asleep and its wake is scheduled
+	ProgressTypeAgentAsleep ProgressType = "agent_asleep"
+)
+
+// ProgressLevel is the verbosity of a progress notification, from the least to the most verbose.
+type ProgressLevel string
+
+const (
+	// ProgressLevelError only reports what went wrong
+	ProgressLevelError ProgressLevel = "error"
+	// ProgressLevelInfo reports the steps of the task, it is the default verbosity
+	ProgressLevelInfo ProgressLevel = "info"
+	// ProgressLevelDebug also reports the LLM calls being started and the tool results
+	ProgressLevelDebug ProgressLevel = "debug"
+)
+
+// ToolResultProgressLimit is the number of characters of a tool result included in its progress notification
+const ToolResultProgressLimit = 500
+
+var progressLevelRanks = map[ProgressLevel]int{
+	ProgressLevelError: 0,
+	ProgressLevelInfo:  1,
+	ProgressLevelDebug: 2,
+}
+
+// Valid returns whether the level is one of the known levels.
+func (l ProgressLevel) Valid() bool {
+	_, ok := progressLevelRanks[l]
+	return ok
+}
+
+// Includes returns whether a notification of the given level is reported at this verbosity.
+func (l ProgressLevel) Includes(level ProgressLevel) bool {
+	return progressLevelRanks[level] <= progressLevelRanks[l]
+}
+
+// LLMCallProgress describes an LLM call, the latency and usage are only set once it finished.
+type LLMCallProgress struct {
+	// Call is the number of the call within the task
+	Call      int                   `json:"call"`
+	LatencyMs int64                 `json:"latency_ms,omitempty"`
+	Usage     *providers.TokenUsage `json:"usage,omitempty"`
+	ToolCalls int                   `json:"tool_calls,omitempty"`
+}
+
+// ToolCallProgress describes a tool call, the result is only set once the tool returned.
+type ToolCallProgress struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+	// Truncated is set when the result was cut to ToolResultProgressLimit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// StateChangeProgress describes a transition of the Worker's state machine.
+type StateChangeProgress struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PublishProgress publishes the progress notification of an agent in an event envelope sourced from the agent,
+// so its notifications keep their order.
+// Progress is informational, it is published right away rather than through the outbox.
+func PublishProgress(ctx context.Context, ps pubsub.PubSub, notification WorkerProgressNotification) error {
+	if notification.Level == "" {
+		notification.Level = ProgressLevelInfo
+	}
+	if notification.Timestamp.IsZero() {
+		notification.Timestamp = time.Now()
+	}
+	slog.Info("Worker: Publishing progress", "agentID", notification.AgentID, "type", notification.Type, "progress", notification.Progress)
+	_, err := pubsub.PublishEvent(ctx, ps, GetAgentProgressTopic(), AgentProgressEvent, notification.AgentID, notification, PublishTimeout)
+	if err != nil {
+		slog.Error("Worker: Failed to publish progress", "agentID", notification.AgentID, "type", notification.Type, "error", err)
+	}
+	return err
+}
+
+func (w *WorkerImpl) publishProgress(notification WorkerProgressNotification) {
+	notification.AgentID = *w.ID
+	notification.Owner = w.Metadata.Owner
+	// Failures are logged by PublishProgress, the task goes on without its progress
+	_ = PublishProgress(context.Background(), w.pubSub, notification)
+}
+
+func (w *WorkerImpl) publishLLMCallStarted() {
+	w.publishProgress(WorkerProgressNotification{
+		Type:     ProgressTypeLLMCallStarted,
+		Level:    ProgressLevelDebug,
+		Progress: fmt.Sprintf("Calling the LLM (call %d)", w.LLMCalls),
+		LLMCall:  &LLMCallProgress{Call: w.LLMCalls},
+	})
+}
+
+func (w *WorkerImpl) publishLLMCallFinished(latency time.Duration, response providers.ChatResponse) {
+	usage := response.Usage
+	w.publishProgress(WorkerProgressNotification{
+		Type:     ProgressTypeLLMCallFinished,
+		Level:    ProgressLevelInfo,
+		Progress: fmt.Sprintf("LLM call %d finished in %s using %d tokens", w.LLMCalls, latency.Round(time.Millisecond), usage.TotalTokens),
+		LLMCall: &LLMCallProgress{
+			Call:      w.LLMCalls,
+			LatencyMs: latency.Milliseconds(),
+			Usage:     &usage,
+			ToolCalls: len(response.ToolCalls),
+		},
+	})
+}
+
+func (w *WorkerImpl) publishToolCall(toolCall providers.ToolCall) {
+	w.publishProgress(WorkerProgressNotification{
+		Type:     ProgressTypeToolCall,
+		Level:    ProgressLevelInfo,
+		Progress: fmt.Sprintf("Calling tool: %s", toolCall.FunctionName),
+		ToolCall: &ToolCallProgress{
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.FunctionName,
+			Arguments:  toolCall.Args,
+		},
+	})
+}
+
+func (w *WorkerImpl) publishToolResult(toolCall providers.ToolCall, result string) {
+	truncatedResult, truncated := truncateProgress(result, ToolResultProgressLimit)
+	w.publishProgress(WorkerProgressNotification{
+		Type:     ProgressTypeToolResult,
+		Level:    ProgressLevelDebug,
+		Progress: fmt.Sprintf("Tool %s returned", toolCall.FunctionName),
+		ToolCall: &ToolCallProgress{
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.FunctionName,
+			Result:     truncatedResult,
+			Truncated:  truncated,
+		},
+	})
+}
+
+func (w *WorkerImpl) publishStateChange(from string, to string, reason string) {
+	progress := fmt.Sprintf("State changed from %s to %s", from, to)
+	if to == StatusAwaitingInput {
+		progress = fmt.Sprintf("Waiting for the user to answer: %s", w.PendingQuestion)
+	}
+	w.publishProgress(WorkerProgressNotification{
+		Type:        ProgressTypeStateChanged,
+		Level:       ProgressLevelInfo,
+		Progress:    progress,
+		StateChange: &StateChangeProgress{From: from, To: to, Reason: reason},
+	})
+}
+
+func (w *WorkerImpl) publishError(progress string, err error) {
+	w.publishProgress(WorkerProgressNotification{
+		Type:     ProgressTypeError,
+		Level:    ProgressLevelError,
+		Progress: progress,
+		Error:    err.Error(),
+	})
+}
+
+// truncateProgress cuts the text to the limit in characters, reporting whether it was cut.
+func truncateProgress(text string, limit int) (string, bool) {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text, false
+	}
+	return string(runes[:limit]), true
+}
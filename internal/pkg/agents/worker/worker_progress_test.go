@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressLevelIncludes(t *testing.T) {
+	assert.True(t, ProgressLevelError.Includes(ProgressLevelError))
+	assert.False(t, ProgressLevelError.Includes(ProgressLevelInfo))
+	assert.True(t, ProgressLevelInfo.Includes(ProgressLevelError))
+	assert.True(t, ProgressLevelInfo.Includes(ProgressLevelInfo))
+	assert.False(t, ProgressLevelInfo.Includes(ProgressLevelDebug))
+	assert.True(t, ProgressLevelDebug.Includes(ProgressLevelDebug))
+
+	assert.True(t, ProgressLevelDebug.Valid())
+	assert.False(t, ProgressLevel("verbose").Valid())
+}
+
+func TestTruncateProgress(t *testing.T) {
+	text, truncated := truncateProgress("short", 10)
+	assert.Equal(t, "short", text)
+	assert.False(t, truncated)
+
+	// Multi-byte characters are not split
+	text, truncated = truncateProgress(strings.Repeat("好", 12), 10)
+	assert.Equal(t, strings.Repeat("好", 10), text)
+	assert.True(t, truncated)
+}
@@ -14,11 +14,17 @@ func (w *WorkerImpl) PersistState() error {
 		return err
 	}
 	awakenedAt, asleepAt := w.getStateTimestamps()
-	err = w.storage.SaveAgentState(*w.ID, state, w.GetStatus(), w.Role, awakenedAt, asleepAt)
+	if len(w.pendingEvents) == 0 {
+		err = w.storage.SaveAgentState(*w.ID, state, w.GetStatus(), w.Role, awakenedAt, asleepAt)
+	} else {
+		// Queued events stay queued for the next attempt if the state is not saved
+		err = w.storage.SaveAgentStateWithEvents(*w.ID, state, w.GetStatus(), w.Role, awakenedAt, asleepAt, w.pendingEvents)
+	}
 	if err != nil {
 		slog.Error("Worker: Failed to save state", "error", err)
 		return err
 	}
+	w.pendingEvents = nil
 	return nil
 }
 
@@ -3,9 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
 	mock_providers "github.com/roackb2/lucid/test/_mocks/providers"
 	mock_pubsub "github.com/roackb2/lucid/test/_mocks/pubsub"
 	mock_storage "github.com/roackb2/lucid/test/_mocks/storage"
@@ -20,6 +24,7 @@ type WorkerTestSuite struct {
 	mockStorage               *mock_storage.MockStorage
 	mockProvider              *mock_providers.MockChatProvider
 	mockPubSub                *mock_pubsub.MockPubSub
+	mockSubscription          *mock_pubsub.MockSubscription
 	worker                    *WorkerImpl
 	id                        string
 	role                      string
@@ -32,9 +37,12 @@ func (s *WorkerTestSuite) SetupTest() {
 	s.mockStorage = mock_storage.NewMockStorage(s.ctrl)
 	s.mockProvider = mock_providers.NewMockChatProvider(s.ctrl)
 	s.mockPubSub = mock_pubsub.NewMockPubSub(s.ctrl)
+	s.mockSubscription = mock_pubsub.NewMockSubscription(s.ctrl)
 	s.id = "test-id"
 	s.role = "test-role"
 	s.worker = NewWorker(&s.id, s.role, s.mockStorage, s.mockProvider, s.mockPubSub)
+	// Every new chat publishes the agent's profile to the directory
+	s.mockStorage.EXPECT().SaveAgentProfile(gomock.Any()).Return(nil).AnyTimes()
 
 	s.mockReportResponseContent = "Test response"
 	mockToolCallArgs := map[string]string{
@@ -61,6 +69,50 @@ func TestWorkerSuite(t *testing.T) {
 	suite.Run(t, new(WorkerTestSuite))
 }
 
+// expectFinalResponse expects the final response to be written to the outbox with the terminated state,
+// for the agent's response topic and the general one.
+func (s *WorkerTestSuite) expectFinalResponse() {
+	s.mockStorage.EXPECT().
+		SaveAgentStateWithEvents(s.id, gomock.Any(), StatusTerminated, s.role, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time, events []storage.OutboxEvent) error {
+			if !assert.Len(s.T(), events, 2) {
+				return nil
+			}
+			assert.Equal(s.T(), GetAgentResponseTopic(s.id), events[0].Topic)
+			assert.Equal(s.T(), GetAgentResponseGeneralTopic(), events[1].Topic)
+			assert.Equal(s.T(), events[0].Message, events[1].Message)
+			assert.Equal(s.T(), s.id, events[0].Key)
+			_, payload, err := pubsub.DecodeEvent[WorkerResponseNotification](events[0].Message, AgentResponseEvent)
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), s.mockReportResponseContent, payload.Response)
+			return nil
+		})
+}
+
+// expectBackgroundPubSub stubs the progress events a chat publishes and the subscription to the agent's message topic.
+// Tests asserting on these calls set their own expectations first, gomock matches them before the stubs.
+func (s *WorkerTestSuite) expectBackgroundPubSub() {
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentProgressTopic(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	s.mockPubSub.EXPECT().
+		Subscribe(GetAgentMessageTopic(s.id), gomock.Any()).
+		Return(s.mockSubscription, nil).
+		AnyTimes()
+	s.mockSubscription.EXPECT().
+		Unsubscribe().
+		AnyTimes()
+}
+
+// expectStateSaves stubs the state saves of a chat without events.
+func (s *WorkerTestSuite) expectStateSaves() {
+	s.mockStorage.EXPECT().
+		SaveAgentState(s.id, gomock.Any(), gomock.Any(), s.role, gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+}
+
 func (s *WorkerTestSuite) TestNewWorker() {
 	assert.NotNil(s.T(), s.worker)
 	assert.Equal(s.T(), &s.id, s.worker.ID)
@@ -77,24 +129,9 @@ func (s *WorkerTestSuite) TestChat() {
 		Chat(gomock.Any()).
 		Return(s.mockReportResponse, nil)
 
-	s.mockStorage.EXPECT().
-		SaveAgentState(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-		Return(nil)
-
-	s.mockStorage.EXPECT().
-		SaveAgentState(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-		Return(nil).
-		AnyTimes()
-
-	s.mockPubSub.EXPECT().
-		Publish(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-		Return(nil).
-		AnyTimes()
-
-	s.mockPubSub.EXPECT().
-		Subscribe(gomock.Any(), gomock.Any()).
-		Return(nil).
-		AnyTimes()
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
 
 	doneCh := make(chan struct{}, 1)
 	defer close(doneCh)
@@ -133,3 +170,526 @@ func (s *WorkerTestSuite) TestPersistAndRestoreState() {
 	err = s.worker.RestoreState(s.id)
 	assert.NoError(s.T(), err)
 }
+
+func (s *WorkerTestSuite) TestPersistStateKeepsEventsUntilSaved() {
+	s.Require().NoError(s.worker.queueFinalResponse(context.Background(), "done"))
+	s.mockStorage.EXPECT().
+		SaveAgentStateWithEvents(s.id, gomock.Any(), gomock.Any(), s.role, gomock.Any(), gomock.Any(), gomock.Len(2)).
+		Return(errors.New("connection lost"))
+	s.Error(s.worker.PersistState())
+
+	s.mockStorage.EXPECT().
+		SaveAgentStateWithEvents(s.id, gomock.Any(), gomock.Any(), s.role, gomock.Any(), gomock.Any(), gomock.Len(2)).
+		Return(nil)
+	s.NoError(s.worker.PersistState())
+
+	// Once saved, the events are not written again
+	s.mockStorage.EXPECT().
+		SaveAgentState(s.id, gomock.Any(), gomock.Any(), s.role, gomock.Any(), gomock.Any()).
+		Return(nil)
+	s.NoError(s.worker.PersistState())
+}
+
+func (s *WorkerTestSuite) TestChatPublishesProgress() {
+	s.mockReportResponse.Usage = providers.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil)
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	var notifications []WorkerProgressNotification
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentProgressTopic(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, message string, _ time.Duration, opts ...pubsub.PublishOption) error {
+			_, notification, err := pubsub.DecodeEvent[WorkerProgressNotification](message, AgentProgressEvent)
+			assert.NoError(s.T(), err)
+			notifications = append(notifications, notification)
+			return nil
+		}).
+		AnyTimes()
+	s.expectBackgroundPubSub()
+
+	_, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	s.Require().NoError(err)
+
+	types := make([]ProgressType, len(notifications))
+	for i, notification := range notifications {
+		types[i] = notification.Type
+		s.Equal(s.id, notification.AgentID)
+		s.False(notification.Timestamp.IsZero())
+	}
+	s.Equal([]ProgressType{
+		ProgressTypeLLMCallStarted,
+		ProgressTypeLLMCallFinished,
+		ProgressTypeToolCall,
+		ProgressTypeToolResult,
+		ProgressTypeStateChanged,
+	}, types)
+
+	s.Equal(ProgressLevelDebug, notifications[0].Level)
+	finished := notifications[1].LLMCall
+	s.Require().NotNil(finished)
+	s.Equal(1, finished.Call)
+	s.Equal(&s.mockReportResponse.Usage, finished.Usage)
+	s.Equal(1, finished.ToolCalls)
+
+	s.Require().NotNil(notifications[2].ToolCall)
+	s.Equal("report", notifications[2].ToolCall.Name)
+	s.Equal(s.mockReportResponse.ToolCalls[0].Args, notifications[2].ToolCall.Arguments)
+	s.Require().NotNil(notifications[3].ToolCall)
+	s.Equal(s.mockReportResponseContent, notifications[3].ToolCall.Result)
+
+	s.Equal(&StateChangeProgress{From: StatusRunning, To: StatusTerminated, Reason: "final response reported"}, notifications[4].StateChange)
+}
+
+func (s *WorkerTestSuite) TestChatBudgetExhausted() {
+	s.mockProvider.EXPECT().
+		Chat(gomock.Any()).
+		Return(providers.ChatResponse{}, nil)
+
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
+
+	s.worker.SetMetadata(TaskMetadata{Owner: "test-owner", Budget: 1})
+	actualResponse, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.ErrorIs(s.T(), err, ErrBudgetExhausted)
+	assert.Empty(s.T(), actualResponse)
+	assert.Equal(s.T(), StatusTerminated, s.worker.GetStatus())
+	assert.Equal(s.T(), 1, s.worker.LLMCalls)
+}
+
+func (s *WorkerTestSuite) TestChatRetriesProviderErrors() {
+	rateLimited := &providers.ProviderError{Kind: providers.ErrorKindRateLimit, Err: errors.New("429")}
+	gomock.InOrder(
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(providers.ChatResponse{}, rateLimited),
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil),
+	)
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
+
+	s.worker.SetRetryPolicy(RetryPolicy{MaxConsecutiveFailures: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	actualResponse, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, actualResponse)
+	assert.Empty(s.T(), s.worker.LastError)
+}
+
+func (s *WorkerTestSuite) TestChatFailsAfterConsecutiveFailures() {
+	timeout := &providers.ProviderError{Kind: providers.ErrorKindTimeout, Err: errors.New("deadline exceeded")}
+	s.mockProvider.EXPECT().Chat(gomock.Any()).Return(providers.ChatResponse{}, timeout).Times(2)
+	s.expectStateSaves()
+	s.mockStorage.EXPECT().SetAgentError(s.id, timeout.Error()).Return(nil)
+	s.expectBackgroundPubSub()
+
+	s.worker.SetRetryPolicy(RetryPolicy{MaxConsecutiveFailures: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	_, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.ErrorIs(s.T(), err, ErrTaskFailed)
+	assert.Equal(s.T(), StatusFailed, s.worker.GetStatus())
+	assert.Equal(s.T(), timeout.Error(), s.worker.LastError)
+}
+
+func (s *WorkerTestSuite) TestChatFailsOnAuthError() {
+	unauthorized := &providers.ProviderError{Kind: providers.ErrorKindAuth, Err: errors.New("401")}
+	s.mockProvider.EXPECT().Chat(gomock.Any()).Return(providers.ChatResponse{}, unauthorized).Times(1)
+	s.expectStateSaves()
+	s.mockStorage.EXPECT().SetAgentError(s.id, unauthorized.Error()).Return(nil)
+	s.expectBackgroundPubSub()
+
+	_, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.ErrorIs(s.T(), err, ErrTaskFailed)
+	assert.Equal(s.T(), StatusFailed, s.worker.GetStatus())
+}
+
+func (s *WorkerTestSuite) TestChatAwaitsUserInput() {
+	question := "Which city should I look for?"
+	askUserResponse := providers.ChatResponse{
+		ToolCalls: []providers.ToolCall{
+			{
+				ID:           "test-ask-user-id",
+				FunctionName: "ask_user",
+				Args:         `{"question": "Which city should I look for?"}`,
+			},
+		},
+	}
+	gomock.InOrder(
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(askUserResponse, nil),
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil),
+	)
+	s.expectFinalResponse()
+	s.mockStorage.EXPECT().
+		SaveAgentState(gomock.Any(), gomock.Any(), StatusRunning, gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	s.mockStorage.EXPECT().
+		SaveAgentState(gomock.Any(), gomock.Any(), StatusAwaitingInput, gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+	s.mockStorage.EXPECT().
+		SaveAgentState(gomock.Any(), gomock.Any(), StatusTerminated, gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		AnyTimes()
+	s.expectBackgroundPubSub()
+
+	awaited := false
+	callbacks := WorkerCallbacks{
+		OnAwaitInput: func(agentID string, status string) {
+			awaited = true
+			assert.Equal(s.T(), StatusAwaitingInput, status)
+		},
+	}
+	response, err := s.worker.Chat(context.Background(), "test prompt", callbacks)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), question, response)
+	assert.True(s.T(), awaited)
+	assert.Equal(s.T(), StatusAwaitingInput, s.worker.GetStatus())
+	assert.Equal(s.T(), question, s.worker.PendingQuestion)
+
+	answer := "Taipei"
+	s.mockStorage.EXPECT().ListUnreadAgentMessages(s.id).Return(nil, nil)
+	response, err = s.worker.ResumeChat(context.Background(), &answer, WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, response)
+	assert.Empty(s.T(), s.worker.PendingQuestion)
+	assert.Equal(s.T(), StatusTerminated, s.worker.GetStatus())
+}
+
+func (s *WorkerTestSuite) TestChatAskUserWithoutQuestion() {
+	askUserResponse := providers.ChatResponse{
+		ToolCalls: []providers.ToolCall{
+			{
+				ID:           "test-ask-user-id",
+				FunctionName: "ask_user",
+				Args:         `{"question": 42}`,
+			},
+		},
+	}
+	gomock.InOrder(
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(askUserResponse, nil),
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil),
+	)
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
+
+	// The model is told the question is missing and goes on instead of waiting for the user
+	response, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, response)
+	assert.Empty(s.T(), s.worker.PendingQuestion)
+	assert.Contains(s.T(), *s.worker.Messages[3].Content, "question is required")
+}
+
+func (s *WorkerTestSuite) TestChatAwaitsToolApproval() {
+	s.worker.SetApprovalPolicy(ToolApprovalPolicy{Tools: map[string]string{"save_content": ApprovalRequired}})
+	saveContentResponse := providers.ChatResponse{
+		ToolCalls: []providers.ToolCall{
+			{
+				ID:           "test-save-content-id",
+				FunctionName: "save_content",
+				Args:         `{"content": "draft"}`,
+			},
+		},
+	}
+	var approvalID string
+	gomock.InOrder(
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(saveContentResponse, nil),
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil),
+	)
+	s.mockStorage.EXPECT().CreateToolApproval(gomock.Any()).DoAndReturn(func(approval storage.ToolApproval) error {
+		assert.Equal(s.T(), "save_content", approval.ToolName)
+		assert.Equal(s.T(), `{"content": "draft"}`, approval.Arguments)
+		assert.Equal(s.T(), storage.ToolApprovalPending, approval.Status)
+		approvalID = approval.ApprovalID
+		return nil
+	})
+	s.expectFinalResponse()
+	// The approval request is written to the outbox with the awaiting approval state
+	s.mockStorage.EXPECT().
+		SaveAgentStateWithEvents(gomock.Any(), gomock.Any(), StatusAwaitingApproval, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(agentID string, state []byte, status string, role string, awakenedAt *time.Time, asleepAt *time.Time, events []storage.OutboxEvent) error {
+			if assert.Len(s.T(), events, 1) {
+				assert.Equal(s.T(), GetAgentApprovalTopic(), events[0].Topic)
+				_, payload, err := pubsub.DecodeEvent[WorkerApprovalNotification](events[0].Message, AgentApprovalEvent)
+				assert.NoError(s.T(), err)
+				assert.Equal(s.T(), approvalID, payload.ApprovalID)
+			}
+			return nil
+		})
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
+
+	response, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), response)
+	assert.Equal(s.T(), StatusAwaitingApproval, s.worker.GetStatus())
+	assert.Equal(s.T(), approvalID, s.worker.PendingApproval.ApprovalID)
+
+	// The user approves the call with edited content
+	s.mockStorage.EXPECT().GetToolApproval(approvalID).Return(&storage.ToolApproval{
+		ApprovalID:      approvalID,
+		Status:          storage.ToolApprovalApproved,
+		EditedArguments: `{"content": "edited"}`,
+	}, nil)
+	s.mockStorage.EXPECT().SavePost("edited").Return(nil)
+	s.mockStorage.EXPECT().MatchAgentInterests("edited", gomock.Any()).Return(nil, nil)
+	s.mockStorage.EXPECT().ListUnreadAgentMessages(s.id).Return(nil, nil)
+
+	response, err = s.worker.ResumeChat(context.Background(), nil, WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, response)
+	assert.Nil(s.T(), s.worker.PendingApproval)
+
+	// The decision is recorded as the result of the parked call
+	var toolMessage *providers.ChatMessage
+	for i, msg := range s.worker.Messages {
+		if msg.Role == "tool" && msg.ToolCall.ID == "test-save-content-id" {
+			toolMessage = &s.worker.Messages[i]
+		}
+	}
+	if assert.NotNil(s.T(), toolMessage) {
+		assert.Contains(s.T(), *toolMessage.Content, "approved")
+		assert.Contains(s.T(), *toolMessage.Content, `{"content": "edited"}`)
+	}
+}
+
+func (s *WorkerTestSuite) TestChatDeniesToolCall() {
+	s.worker.SetApprovalPolicy(ToolApprovalPolicy{Tools: map[string]string{"save_content": ApprovalDeny}})
+	saveContentResponse := providers.ChatResponse{
+		ToolCalls: []providers.ToolCall{
+			{
+				ID:           "test-save-content-id",
+				FunctionName: "save_content",
+				Args:         `{"content": "draft"}`,
+			},
+		},
+	}
+	gomock.InOrder(
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(saveContentResponse, nil),
+		s.mockProvider.EXPECT().Chat(gomock.Any()).Return(s.mockReportResponse, nil),
+	)
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	s.expectBackgroundPubSub()
+
+	// SavePost is not expected, the denied call must not run
+	response, err := s.worker.Chat(context.Background(), "test prompt", WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, response)
+	assert.Contains(s.T(), *s.worker.Messages[3].Content, "not allowed")
+}
+
+func (s *WorkerTestSuite) TestSendMessage() {
+	s.mockStorage.EXPECT().
+		GetAgentInfo("other-agent").
+		Return(&storage.AgentInfo{AgentID: "other-agent", Status: StatusAsleep}, nil)
+	s.mockStorage.EXPECT().SaveAgentMessage(gomock.Any()).DoAndReturn(func(message storage.AgentMessage) error {
+		assert.Equal(s.T(), s.id, message.FromAgentID)
+		assert.Equal(s.T(), "other-agent", message.ToAgentID)
+		assert.Equal(s.T(), "hello", message.Content)
+		assert.NotEmpty(s.T(), message.MessageID)
+		return nil
+	})
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentMessageTopic("other-agent"), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	result := s.worker.SendMessage(providers.ToolCall{Args: `{"to_agent_id": "other-agent", "content": "hello"}`})
+	assert.Equal(s.T(), "Message sent to agent other-agent.", result)
+
+	// Messages to itself or unknown agents are not sent
+	result = s.worker.SendMessage(providers.ToolCall{Args: `{"to_agent_id": "test-id", "content": "hello"}`})
+	assert.Contains(s.T(), result, "Error")
+	s.mockStorage.EXPECT().GetAgentInfo("unknown-agent").Return(nil, errors.New("no rows"))
+	result = s.worker.SendMessage(providers.ToolCall{Args: `{"to_agent_id": "unknown-agent", "content": "hello"}`})
+	assert.Equal(s.T(), "Error: agent unknown-agent not found", result)
+}
+
+func (s *WorkerTestSuite) TestOpenThread() {
+	s.mockStorage.EXPECT().
+		GetAgentInfo("other-agent").
+		Return(&storage.AgentInfo{AgentID: "other-agent", Status: StatusRunning}, nil)
+	s.mockStorage.EXPECT().CreateAgentThread(gomock.Any(), gomock.Any()).DoAndReturn(func(thread storage.AgentThread, message storage.AgentMessage) error {
+		assert.Equal(s.T(), s.id, thread.InitiatorAgentID)
+		assert.Equal(s.T(), "other-agent", thread.ResponderAgentID)
+		assert.Equal(s.T(), ThreadStatusRequested, thread.Status)
+		assert.Equal(s.T(), s.id, thread.LastSenderAgentID)
+		assert.Equal(s.T(), thread.ThreadID, message.ThreadID)
+		assert.Equal(s.T(), string(MessageKindRequest), message.Kind)
+		assert.Equal(s.T(), "other-agent", message.ToAgentID)
+		return nil
+	})
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentMessageTopic("other-agent"), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	result := s.worker.OpenThread(providers.ToolCall{Args: `{"to_agent_id": "other-agent", "subject": "news", "content": "do you have news about Go?"}`})
+	assert.Contains(s.T(), result, "opened with agent other-agent")
+
+	result = s.worker.OpenThread(providers.ToolCall{Args: `{"to_agent_id": "test-id", "subject": "news", "content": "hi"}`})
+	assert.Equal(s.T(), "Error: cannot open a thread with yourself", result)
+}
+
+func (s *WorkerTestSuite) TestReplyThread() {
+	thread := &storage.AgentThread{
+		ThreadID:          "thread-id",
+		InitiatorAgentID:  "other-agent",
+		ResponderAgentID:  s.id,
+		Status:            ThreadStatusRequested,
+		LastSenderAgentID: "other-agent",
+	}
+	s.mockStorage.EXPECT().GetAgentThread("thread-id").Return(thread, nil).Times(3)
+	s.mockStorage.EXPECT().AdvanceAgentThread(gomock.Any()).DoAndReturn(func(advance storage.AgentThreadAdvance) error {
+		assert.Equal(s.T(), ThreadStatusRequested, advance.ExpectedStatus)
+		assert.Equal(s.T(), "other-agent", advance.ExpectedLastSenderAgentID)
+		assert.Equal(s.T(), ThreadStatusOffered, advance.Status)
+		assert.Equal(s.T(), string(MessageKindOffer), advance.Message.Kind)
+		assert.Equal(s.T(), "other-agent", advance.Message.ToAgentID)
+		return nil
+	})
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentMessageTopic("other-agent"), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	result := s.worker.ReplyThread(providers.ToolCall{Args: `{"thread_id": "thread-id", "kind": "offer", "content": "I have three posts"}`})
+	assert.Equal(s.T(), "Sent offer to agent other-agent in thread thread-id, the thread is now offered.", result)
+
+	// Messages the protocol does not allow are not sent
+	result = s.worker.ReplyThread(providers.ToolCall{Args: `{"thread_id": "thread-id", "kind": "deliver", "content": "here you go"}`})
+	assert.Contains(s.T(), result, "Error")
+	result = s.worker.ReplyThread(providers.ToolCall{Args: `{"thread_id": "thread-id", "kind": "close", "content": "bye"}`})
+	assert.Contains(s.T(), result, "Error: kind must be one of")
+
+	// The other agent replied in the meantime
+	s.mockStorage.EXPECT().AdvanceAgentThread(gomock.Any()).Return(storage.ErrAgentThreadConflict)
+	result = s.worker.ReplyThread(providers.ToolCall{Args: `{"thread_id": "thread-id", "kind": "reject", "content": "no"}`})
+	assert.Contains(s.T(), result, "replied in the meantime")
+}
+
+func (s *WorkerTestSuite) TestCheckInbox() {
+	messages := []storage.AgentMessage{
+		{MessageID: "message-1", FromAgentID: "other-agent", ToAgentID: s.id, Content: "hello", CreatedAt: time.Now()},
+	}
+	gomock.InOrder(
+		s.mockStorage.EXPECT().ListUnreadAgentMessages(s.id).Return(messages, nil),
+		s.mockStorage.EXPECT().ListUnreadAgentMessages(s.id).Return(nil, nil),
+	)
+	s.mockStorage.EXPECT().MarkAgentMessagesRead(s.id, []string{"message-1"}).Return(nil)
+
+	result := s.worker.CheckInbox(providers.ToolCall{})
+	assert.Contains(s.T(), result, "From agent other-agent")
+	assert.Contains(s.T(), result, "hello")
+
+	result = s.worker.CheckInbox(providers.ToolCall{})
+	assert.Equal(s.T(), "No new messages.", result)
+}
+
+func (s *WorkerTestSuite) TestResumeChatInjectsInbox() {
+	messages := []storage.AgentMessage{
+		{MessageID: "message-1", FromAgentID: "other-agent", ToAgentID: s.id, Content: "the draft is ready", CreatedAt: time.Now()},
+	}
+	s.mockStorage.EXPECT().ListUnreadAgentMessages(s.id).Return(messages, nil)
+	s.mockStorage.EXPECT().MarkAgentMessagesRead(s.id, []string{"message-1"}).Return(nil)
+	s.mockProvider.EXPECT().
+		Chat(gomock.Any()).
+		DoAndReturn(func(messages []providers.ChatMessage) (providers.ChatResponse, error) {
+			// The inbox replaces the empty prompt of a wake-up
+			last := messages[len(messages)-1]
+			assert.Equal(s.T(), "user", last.Role)
+			if assert.NotNil(s.T(), last.Content) {
+				assert.Contains(s.T(), *last.Content, "## New Messages")
+				assert.Contains(s.T(), *last.Content, "the draft is ready")
+			}
+			return s.mockReportResponse, nil
+		})
+	s.expectFinalResponse()
+	s.expectStateSaves()
+	s.mockPubSub.EXPECT().
+		Subscribe(GetAgentMessageTopic(s.id), gomock.Any()).
+		Return(s.mockSubscription, nil)
+	s.mockSubscription.EXPECT().
+		Unsubscribe()
+	s.expectBackgroundPubSub()
+
+	response, err := s.worker.ResumeChat(context.Background(), nil, WorkerCallbacks{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), s.mockReportResponseContent, response)
+}
+
+func (s *WorkerTestSuite) TestSubscribeInterest() {
+	s.mockStorage.EXPECT().CreateAgentInterest(gomock.Any()).DoAndReturn(func(interest storage.AgentInterest) error {
+		assert.Equal(s.T(), s.id, interest.AgentID)
+		assert.Equal(s.T(), "golang", interest.Query)
+		assert.Equal(s.T(), []string{"generics"}, interest.Keywords)
+		assert.True(s.T(), interest.Semantic)
+		assert.NotEmpty(s.T(), interest.InterestID)
+		return nil
+	})
+
+	result := s.worker.SubscribeInterest(providers.ToolCall{Args: `{"query": "golang", "keywords": ["generics"], "semantic": true}`})
+	assert.Contains(s.T(), result, "Subscribed to new posts matching")
+
+	s.mockStorage.EXPECT().CancelAgentInterest(s.id, "unknown-interest").Return(storage.ErrAgentInterestNotFound)
+	result = s.worker.UnsubscribeInterest(providers.ToolCall{Args: `{"interest_id": "unknown-interest"}`})
+	assert.Equal(s.T(), "Error: you have no active interest with ID unknown-interest", result)
+}
+
+func (s *WorkerTestSuite) TestSaveContentNotifiesInterests() {
+	interests := []storage.AgentInterest{
+		{InterestID: "interest-1", AgentID: "consumer", Query: "golang"},
+		{InterestID: "interest-2", AgentID: "consumer", Query: "generics"},
+		{InterestID: "interest-3", AgentID: s.id, Query: "golang"},
+	}
+	s.mockStorage.EXPECT().SavePost("golang generics").Return(nil)
+	s.mockStorage.EXPECT().
+		MatchAgentInterests("golang generics", []string{StatusTerminated, StatusFailed}).
+		Return(interests, nil)
+	// The consumer is notified once, and the publisher is not notified of its own post
+	s.mockStorage.EXPECT().SaveAgentMessage(gomock.Any()).DoAndReturn(func(message storage.AgentMessage) error {
+		assert.Equal(s.T(), "consumer", message.ToAgentID)
+		assert.Contains(s.T(), message.Content, "interest-1")
+		assert.Contains(s.T(), message.Content, "golang generics")
+		return nil
+	})
+	s.mockPubSub.EXPECT().
+		Publish(gomock.Any(), GetAgentMessageTopic("consumer"), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	result := s.worker.saveContent(providers.ToolCall{Args: `{"content": "golang generics"}`})
+	assert.Contains(s.T(), result, "Content saved successfully")
+}
+
+func (s *WorkerTestSuite) TestFindAgents() {
+	profiles := []storage.AgentProfile{
+		{AgentID: "publisher-id", Role: "publisher", Description: "daily tech news", Tags: []string{"tech", "news"}},
+	}
+	s.mockStorage.EXPECT().
+		SearchAgentProfiles(storage.AgentProfileQuery{
+			Query:            "tech news",
+			Role:             "publisher",
+			Tags:             []string{"tech"},
+			ExcludedAgentID:  s.id,
+			ExcludedStatuses: []string{StatusTerminated, StatusFailed},
+			Limit:            MaxFindAgentsLimit,
+		}).
+		Return(profiles, nil)
+
+	result := s.worker.FindAgents(providers.ToolCall{Args: `{"query": "tech news", "role": "publisher", "tags": ["tech"], "limit": 500}`})
+	assert.Contains(s.T(), result, "Agent ID: publisher-id, role: publisher, description: daily tech news, tags: tech, news")
+}
+
+func (s *WorkerTestSuite) TestUpdateProfile() {
+	// A storage without the catch-all profile expectation of SetupTest
+	mockStorage := mock_storage.NewMockStorage(s.ctrl)
+	s.worker = NewWorker(&s.id, s.role, mockStorage, s.mockProvider, s.mockPubSub)
+	s.worker.SetMetadata(TaskMetadata{Owner: "test-owner"})
+	mockStorage.EXPECT().SaveAgentProfile(storage.AgentProfile{
+		AgentID:     s.id,
+		Owner:       "test-owner",
+		Role:        s.role,
+		Description: "looking for tech news",
+		Tags:        []string{"tech"},
+	}).Return(nil)
+
+	result := s.worker.UpdateProfile(providers.ToolCall{Args: `{"description": "looking for tech news", "tags": ["tech"]}`})
+	assert.Equal(s.T(), "Profile updated, other agents can now find you by it.", result)
+	assert.Equal(s.T(), "looking for tech news", s.worker.GetMetadata().Description)
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/agents/providers"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+// OpenThread is the open_thread tool, it starts a thread with another agent by requesting something from it.
+func (w *WorkerImpl) OpenThread(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: OpenThread", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	toAgentID, _ := args["to_agent_id"].(string)
+	subject, _ := args["subject"].(string)
+	content, _ := args["content"].(string)
+	if toAgentID == "" || subject == "" || content == "" {
+		return "Error: to_agent_id, subject and content are required"
+	}
+	if toAgentID == *w.ID {
+		return "Error: cannot open a thread with yourself"
+	}
+	if _, err := w.storage.GetAgentInfo(toAgentID); err != nil {
+		slog.Error("Worker: OpenThread failed to get recipient", "to", toAgentID, "error", err)
+		return fmt.Sprintf("Error: agent %s not found", toAgentID)
+	}
+
+	thread := storage.AgentThread{
+		ThreadID:          uuid.New().String(),
+		InitiatorAgentID:  *w.ID,
+		ResponderAgentID:  toAgentID,
+		Subject:           subject,
+		Status:            ThreadStatusRequested,
+		LastSenderAgentID: *w.ID,
+	}
+	message := w.newThreadMessage(thread, MessageKindRequest, fmt.Sprintf("Subject: %s\n%s", subject, content))
+	if err := w.storage.CreateAgentThread(thread, message.toAgentMessage()); err != nil {
+		slog.Error("Worker: OpenThread failed to create thread", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	w.publishThreadMessage(message)
+	slog.Info("Worker: OpenThread", "agentID", *w.ID, "threadID", thread.ThreadID, "to", toAgentID)
+	return fmt.Sprintf("Thread %s opened with agent %s, wait for its offer or rejection.", thread.ThreadID, toAgentID)
+}
+
+// ReplyThread is the reply_thread tool, it sends the next message of the protocol to the other agent of the thread.
+func (w *WorkerImpl) ReplyThread(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: ReplyThread", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	threadID, _ := args["thread_id"].(string)
+	kind, _ := args["kind"].(string)
+	content, _ := args["content"].(string)
+	switch MessageKind(kind) {
+	case MessageKindOffer, MessageKindAccept, MessageKindReject, MessageKindDeliver:
+	default:
+		return fmt.Sprintf("Error: kind must be one of %s, %s, %s or %s", MessageKindOffer, MessageKindAccept, MessageKindReject, MessageKindDeliver)
+	}
+	return w.advanceThread(threadID, MessageKind(kind), content)
+}
+
+// CloseThread is the close_thread tool, it ends a thread whatever its status.
+func (w *WorkerImpl) CloseThread(toolCall providers.ToolCall) string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(toolCall.Args), &args); err != nil {
+		slog.Error("Worker: CloseThread", "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	threadID, _ := args["thread_id"].(string)
+	content, _ := args["content"].(string)
+	if content == "" {
+		content = "The thread is closed."
+	}
+	return w.advanceThread(threadID, MessageKindClose, content)
+}
+
+// advanceThread validates the message against the thread's protocol, then saves it with the thread's new status.
+func (w *WorkerImpl) advanceThread(threadID string, kind MessageKind, content string) string {
+	thread, err := w.storage.GetAgentThread(threadID)
+	if errors.Is(err, storage.ErrAgentThreadNotFound) {
+		return fmt.Sprintf("Error: thread %s not found", threadID)
+	}
+	if err != nil {
+		slog.Error("Worker: Failed to get thread", "threadID", threadID, "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	status, err := NextThreadStatus(*thread, *w.ID, kind)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	message := w.newThreadMessage(*thread, kind, content)
+	advance := storage.AgentThreadAdvance{
+		ThreadID:                  thread.ThreadID,
+		ExpectedStatus:            thread.Status,
+		ExpectedLastSenderAgentID: thread.LastSenderAgentID,
+		Status:                    status,
+		Message:                   message.toAgentMessage(),
+	}
+	err = w.storage.AdvanceAgentThread(advance)
+	if errors.Is(err, storage.ErrAgentThreadConflict) {
+		return "Error: the other agent replied in the meantime, check your inbox before replying"
+	}
+	if err != nil {
+		slog.Error("Worker: Failed to advance thread", "threadID", threadID, "error", err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	w.publishThreadMessage(message)
+	slog.Info("Worker: Advanced thread", "agentID", *w.ID, "threadID", threadID, "kind", kind, "status", status)
+	return fmt.Sprintf("Sent %s to agent %s in thread %s, the thread is now %s.", kind, message.ToAgentID, threadID, status)
+}
+
+func (w *WorkerImpl) newThreadMessage(thread storage.AgentThread, kind MessageKind, content string) WorkerMessage {
+	return WorkerMessage{
+		MessageID:   uuid.New().String(),
+		FromAgentID: *w.ID,
+		ToAgentID:   threadRecipient(thread, *w.ID),
+		ThreadID:    thread.ThreadID,
+		Kind:        kind,
+		Content:     content,
+		CreatedAt:   time.Now(),
+	}
+}
+
+func (w *WorkerImpl) publishThreadMessage(message WorkerMessage) {
+	if err := w.publishMessage(context.Background(), message); err != nil {
+		// The message is in the recipient's inbox, it is read when the recipient is resumed
+		slog.Error("Worker: Failed to publish thread message", "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package control_plane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+)
+
+// AgentCommandNotification is broadcast to all nodes when the target agent is not live locally.
+type AgentCommandNotification struct {
+	AgentID string `json:"agent_id"`
+	Command string `json:"command"`
+}
+
+// AgentCommandEvent is the schema of the agent command events, their payload is an AgentCommandNotification.
+var AgentCommandEvent = pubsub.EventSchema{Type: "agent.command", Version: 1}
+
+// GetAgentCommandTopic returns the topic for agent commands routed between nodes
+func GetAgentCommandTopic() string {
+	return "agent_command"
+}
+
+// ErrInvalidAgentCommand is returned when sending an agent a command that does not exist.
+var ErrInvalidAgentCommand = errors.New("invalid agent command")
+
+// ErrAgentTerminated is returned when sending a command to a terminated agent.
+var ErrAgentTerminated = errors.New("agent is terminated")
+
+// ErrAgentNotFailed is returned when retrying an agent that did not fail.
+var ErrAgentNotFailed = errors.New("only failed agents can be retried")
+
+// ErrAgentCommandNotAllowed is returned when the command does not apply to the agent's status, e.g. pausing an asleep agent.
+var ErrAgentCommandNotAllowed = errors.New("command not allowed in the agent's status")
+
+var agentCommands = []string{worker.CmdPause, worker.CmdResume, worker.CmdSleep, worker.CmdTerminate, worker.CmdRetry}
+
+// SendAgentCommand sends a worker command to a single agent.
+// If the agent is live on this node the command goes straight to it,
+// if it is live elsewhere the command is broadcast to the owning node,
+// and if it is asleep its stored status is updated (resume wakes it up here, terminate ends it without resuming).
+// Retry only applies to failed agents, which are not live on any node.
+// Agents awaiting input or approval are not live either, they can only be terminated until the user responds.
+func (c *ControlPlaneImpl) SendAgentCommand(ctx context.Context, agentID string, command string) error {
+	slog.Info("ControlPlane: Sending agent command", "agent", agentID, "command", command)
+	if !slices.Contains(agentCommands, command) {
+		return fmt.Errorf("%w: %s", ErrInvalidAgentCommand, command)
+	}
+	if command != worker.CmdRetry {
+		err := c.controller.SendAgentCommand(ctx, agentID, command)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrAgentNotTracked) {
+			slog.Error("ControlPlane: Failed to send agent command", "agent", agentID, "error", err)
+			return err
+		}
+	}
+
+	info, err := c.storage.GetAgentInfo(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent info", "agent", agentID, "error", err)
+		return err
+	}
+	switch {
+	case info.Status == worker.StatusFailed:
+		return c.sendFailedAgentCommand(ctx, info, command)
+	case command == worker.CmdRetry:
+		return fmt.Errorf("%w: agent %s is %s", ErrAgentNotFailed, agentID, info.Status)
+	case info.Status == worker.StatusAsleep:
+		return c.sendAsleepAgentCommand(ctx, info, command)
+	case info.Status == worker.StatusAwaitingInput || info.Status == worker.StatusAwaitingApproval:
+		return c.sendAwaitingUserAgentCommand(info, command)
+	case info.Status == worker.StatusTerminated:
+		return fmt.Errorf("%w: %s", ErrAgentTerminated, agentID)
+	default:
+		return c.broadcastAgentCommand(ctx, agentID, command)
+	}
+}
+
+func (c *ControlPlaneImpl) sendFailedAgentCommand(ctx context.Context, info *storage.AgentInfo, command string) error {
+	switch command {
+	case worker.CmdRetry:
+		slog.Info("ControlPlane: Retrying failed agent", "agent", info.AgentID, "last_error", info.LastError)
+		state, err := c.storage.GetAgentState(info.AgentID)
+		if err != nil {
+			slog.Error("ControlPlane: Failed to get agent state", "agent", info.AgentID, "error", err)
+			return err
+		}
+		if err := c.storage.SetAgentError(info.AgentID, ""); err != nil {
+			slog.Error("ControlPlane: Failed to clear agent error", "agent", info.AgentID, "error", err)
+			return err
+		}
+		_, err = c.resumeAgent(ctx, info.AgentID, info.Status, info.Role, decodeTaskMetadata(state), nil)
+		return err
+	case worker.CmdTerminate:
+		slog.Info("ControlPlane: Terminating failed agent", "agent", info.AgentID)
+		return c.storage.UpdateAgentStatus(info.AgentID, worker.StatusTerminated)
+	default:
+		return fmt.Errorf("%w: cannot %s agent %s, retry it first", ErrAgentFailed, command, info.AgentID)
+	}
+}
+
+func (c *ControlPlaneImpl) sendAsleepAgentCommand(ctx context.Context, info *storage.AgentInfo, command string) error {
+	switch command {
+	case worker.CmdResume:
+		slog.Info("ControlPlane: Waking up asleep agent", "agent", info.AgentID)
+		state, err := c.storage.GetAgentState(info.AgentID)
+		if err != nil {
+			slog.Error("ControlPlane: Failed to get agent state", "agent", info.AgentID, "error", err)
+			return err
+		}
+		_, err = c.resumeAgent(ctx, info.AgentID, info.Status, info.Role, decodeTaskMetadata(state), nil)
+		return err
+	case worker.CmdTerminate:
+		slog.Info("ControlPlane: Terminating asleep agent", "agent", info.AgentID)
+		return c.storage.UpdateAgentStatus(info.AgentID, worker.StatusTerminated)
+	case worker.CmdSleep:
+		return nil
+	default:
+		return fmt.Errorf("%w: cannot %s asleep agent %s", ErrAgentCommandNotAllowed, command, info.AgentID)
+	}
+}
+
+func (c *ControlPlaneImpl) sendAwaitingUserAgentCommand(info *storage.AgentInfo, command string) error {
+	switch {
+	case command == worker.CmdTerminate:
+		slog.Info("ControlPlane: Terminating agent awaiting the user", "agent", info.AgentID, "status", info.Status)
+		return c.storage.UpdateAgentStatus(info.AgentID, worker.StatusTerminated)
+	case info.Status == worker.StatusAwaitingApproval:
+		return fmt.Errorf("%w: cannot %s agent %s, decide on its tool call first", ErrAgentAwaitingApproval, command, info.AgentID)
+	default:
+		return fmt.Errorf("%w: cannot %s agent %s, answer it first", ErrAgentAwaitingInput, command, info.AgentID)
+	}
+}
+
+func (c *ControlPlaneImpl) broadcastAgentCommand(ctx context.Context, agentID string, command string) error {
+	slog.Info("ControlPlane: Broadcasting agent command", "agent", agentID, "command", command)
+	payload := AgentCommandNotification{
+		AgentID: agentID,
+		Command: command,
+	}
+	// Keyed by the agent, so the commands of an agent are handled in the order they were sent
+	_, err := pubsub.PublishEvent(ctx, c.pubSub, GetAgentCommandTopic(), AgentCommandEvent, "", payload, worker.PublishTimeout, pubsub.WithKey(agentID))
+	if err != nil {
+		slog.Error("ControlPlane: Failed to publish agent command", "error", err)
+		return err
+	}
+	return nil
+}
+
+// onAgentCommandMessage handles agent commands broadcast by other nodes,
+// commands for agents not live on this node are ignored.
+func (c *ControlPlaneImpl) onAgentCommandMessage(ctx context.Context) pubsub.OnMessageCallback {
+	return func(message string, headers pubsub.Headers) error {
+		_, notification, err := pubsub.DecodeEvent[AgentCommandNotification](message, AgentCommandEvent)
+		if err != nil {
+			slog.Error("ControlPlane: Failed to decode agent command", "error", err)
+			return err
+		}
+		err = c.controller.SendAgentCommand(ctx, notification.AgentID, notification.Command)
+		if errors.Is(err, ErrAgentNotTracked) {
+			return nil
+		}
+		return err
+	}
+}
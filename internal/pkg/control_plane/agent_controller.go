@@ -2,6 +2,7 @@ package control_plane
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -16,6 +17,9 @@ const (
 	AgentControllerControlChSize = 10
 )
 
+// ErrAgentNotTracked is returned when an agent is not running under this controller.
+var ErrAgentNotTracked = errors.New("agent not tracked by this controller")
+
 type AgentControllerConfig struct {
 	ScanInterval  time.Duration
 	AgentLifeTime time.Duration
@@ -70,6 +74,22 @@ func (c *AgentControllerImpl) SendCommand(ctx context.Context, command string) e
 	}
 }
 
+// SendAgentCommand forwards a worker command to a single agent tracked by this controller.
+// It returns ErrAgentNotTracked if the agent is not live on this node.
+func (c *AgentControllerImpl) SendAgentCommand(ctx context.Context, agentID string, command string) error {
+	tracking, ok := c.tracker.GetTracking(agentID)
+	if !ok {
+		return ErrAgentNotTracked
+	}
+	slog.Info("AgentController sending agent command", "agent_id", agentID, "command", command)
+	err := tracking.Agent.SendCommand(ctx, command)
+	if err != nil {
+		slog.Error("AgentController error sending agent command", "agent_id", agentID, "command", command, "error", err)
+		return err
+	}
+	return nil
+}
+
 func (c *AgentControllerImpl) Start(ctx context.Context) error {
 	slog.Info("AgentController started")
 	ticker := time.NewTicker(c.cfg.ScanInterval)
@@ -118,17 +138,29 @@ func (c *AgentControllerImpl) scanAgents(ctx context.Context) (bool, error) {
 		if status == worker.StatusRunning {
 			allAgentsAsleep = false
 			agentAwakeDuration := time.Since(tracking.CreatedAt)
-			// If we're terminating or agent lifetime exceeded, put agent to sleep
-			if c.terminate || agentAwakeDuration > c.cfg.AgentLifeTime {
-				slog.Info("AgentController agent lifetime exceeded", "agent_id", tracking.AgentID, "created_at", tracking.CreatedAt, "agent_awake_duration", agentAwakeDuration.String())
+			// If the lease was not renewed, another node may reclaim the agent
+			leaseExpired := !tracking.LeaseExpiresAt.IsZero() && time.Now().After(tracking.LeaseExpiresAt)
+			// If we're terminating, agent lifetime exceeded or the lease expired, put agent to sleep
+			if c.terminate || leaseExpired || agentAwakeDuration > c.cfg.AgentLifeTime {
+				slog.Info("AgentController agent lifetime exceeded", "agent_id", tracking.AgentID, "created_at", tracking.CreatedAt, "agent_awake_duration", agentAwakeDuration.String(), "lease_expired", leaseExpired)
 				err := c.putAgentToSleep(ctx, tracking)
 				if err != nil {
 					slog.Error("AgentController error putting agent to sleep", "agent_id", tracking.AgentID, "error", err)
 					return false, err
 				}
 			}
-		} else if status == worker.StatusAsleep || status == worker.StatusTerminated {
-			slog.Info("AgentController agent is asleep or terminated, removing tracking", "agent_id", tracking.AgentID)
+		} else if status == worker.StatusPaused && c.terminate {
+			// Paused agents would block the shutdown, once asleep any node can wake them up
+			allAgentsAsleep = false
+			slog.Info("AgentController putting paused agent to sleep", "agent_id", tracking.AgentID)
+			err := c.putAgentToSleep(ctx, tracking)
+			if err != nil {
+				slog.Error("AgentController error putting agent to sleep", "agent_id", tracking.AgentID, "error", err)
+				return false, err
+			}
+		} else if status == worker.StatusAsleep || status == worker.StatusTerminated || status == worker.StatusFailed ||
+			status == worker.StatusAwaitingInput || status == worker.StatusAwaitingApproval {
+			slog.Info("AgentController agent is no longer running, removing tracking", "agent_id", tracking.AgentID, "status", status)
 			c.tracker.RemoveTracking(tracking.AgentID)
 		}
 	}
@@ -146,8 +178,9 @@ func (c *AgentControllerImpl) putAgentToSleep(ctx context.Context, tracking Agen
 		AgentID: tracking.AgentID,
 		Agent:   tracking.Agent,
 		// IMPORTANT: Do not call GetStatus() here. This would cause a deadlock cuz SendCommand is changing the status as well.
-		Status:    worker.StatusAsleep,
-		CreatedAt: tracking.CreatedAt,
+		Status:         worker.StatusAsleep,
+		CreatedAt:      tracking.CreatedAt,
+		LeaseExpiresAt: tracking.LeaseExpiresAt,
 	})
 	slog.Info("AgentController updated tracking", "agent_id", tracking.AgentID)
 	return nil
@@ -117,3 +117,55 @@ func (suite *AgentControllerTestSuite) TestStart() {
 
 	<-doneCh
 }
+
+func (suite *AgentControllerTestSuite) TestSendAgentCommand() {
+	agentController := control_plane.NewAgentController(suite.config, suite.mockStorage, suite.mockAgentTracker)
+
+	suite.mockAgentTracker.EXPECT().GetTracking("test-agent-id").Return(control_plane.AgentTracking{
+		AgentID: "test-agent-id",
+		Agent:   suite.mockAgent,
+		Status:  worker.StatusRunning,
+	}, true)
+	suite.mockAgent.EXPECT().SendCommand(gomock.Any(), worker.CmdPause).Return(nil)
+	err := agentController.SendAgentCommand(context.Background(), "test-agent-id", worker.CmdPause)
+	suite.NoError(err)
+
+	suite.mockAgentTracker.EXPECT().GetTracking("other-agent-id").Return(control_plane.AgentTracking{}, false)
+	err = agentController.SendAgentCommand(context.Background(), "other-agent-id", worker.CmdPause)
+	suite.ErrorIs(err, control_plane.ErrAgentNotTracked)
+}
+
+func (suite *AgentControllerTestSuite) TestStartPutsAgentWithExpiredLeaseToSleep() {
+	suite.config.AgentLifeTime = time.Hour
+	suite.mockAgent.EXPECT().GetID().Return("test-agent-id").AnyTimes()
+	agentController := control_plane.NewAgentController(suite.config, suite.mockStorage, suite.mockAgentTracker)
+
+	leaseExpiresAt := time.Now().Add(-time.Second)
+	suite.mockAgent.EXPECT().GetStatus().Return(worker.StatusRunning)
+	suite.mockAgentTracker.EXPECT().GetAllTrackings().Return([]control_plane.AgentTracking{
+		{
+			AgentID:        "test-agent-id",
+			Agent:          suite.mockAgent,
+			Status:         worker.StatusRunning,
+			CreatedAt:      time.Now(),
+			LeaseExpiresAt: leaseExpiresAt,
+		},
+	})
+	suite.mockAgent.EXPECT().SendCommand(gomock.Any(), worker.CmdSleep).Return(nil)
+	suite.mockAgentTracker.EXPECT().UpdateTracking("test-agent-id", gomock.Any()).Do(func(agentID string, tracking control_plane.AgentTracking) {
+		suite.Equal(worker.StatusAsleep, tracking.Status)
+		suite.Equal(leaseExpiresAt, tracking.LeaseExpiresAt)
+	})
+	suite.mockAgentTracker.EXPECT().GetAllTrackings().Return([]control_plane.AgentTracking{}).AnyTimes()
+
+	doneCh := make(chan struct{})
+	go func() {
+		err := agentController.Start(context.Background())
+		suite.NoError(err)
+		doneCh <- struct{}{}
+	}()
+
+	time.Sleep(2 * AgentScanInterval)
+	agentController.SendCommand(context.Background(), "stop")
+	<-doneCh
+}
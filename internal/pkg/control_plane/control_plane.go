@@ -2,9 +2,11 @@ package control_plane
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/roackb2/lucid/internal/pkg/agents/agent"
@@ -28,13 +30,14 @@ type ControlPlaneImpl struct {
 	chatProvider    providers.ChatProvider
 	controller      AgentController
 	scheduler       Scheduler
+	runQueue        *RunQueue
 	pubSub          pubsub.PubSub
 	callbacks       ControlPlaneCallbacks
 	workerCallbacks worker.WorkerCallbacks
 
 	controlCh   chan string
 	newAgentCh  chan agent.Agent
-	stopCmdSent bool // We should only handle the stop command once
+	stopCmdSent atomic.Bool // We should only handle the stop command once
 }
 
 func NewControlPlane(
@@ -43,6 +46,7 @@ func NewControlPlane(
 	chatProvider providers.ChatProvider,
 	controller AgentController,
 	scheduler Scheduler,
+	runQueue *RunQueue,
 	pubSub pubsub.PubSub,
 	callbacks ControlPlaneCallbacks,
 	workerCallbacks worker.WorkerCallbacks,
@@ -53,13 +57,13 @@ func NewControlPlane(
 		chatProvider:    chatProvider,
 		controller:      controller,
 		scheduler:       scheduler,
+		runQueue:        runQueue,
 		pubSub:          pubSub,
 		callbacks:       callbacks,
 		workerCallbacks: workerCallbacks,
 
-		controlCh:   make(chan string, ControlChannelSize),
-		newAgentCh:  make(chan agent.Agent, NewAgentChannelSize),
-		stopCmdSent: false,
+		controlCh:  make(chan string, ControlChannelSize),
+		newAgentCh: make(chan agent.Agent, NewAgentChannelSize),
 	}
 }
 
@@ -69,13 +73,25 @@ func (c *ControlPlaneImpl) Start(ctx context.Context) error {
 	// The resumeAgent function will register the agent with the controller
 	onAgentFound := func(agentID string, agentState dbaccess.AgentState) {
 		slog.Info("ControlPlane: Received new agent", "agent", agentID)
-		err := c.resumeAgent(ctx, agentState.AgentID, agentState.Role, nil)
+		metadata := decodeTaskMetadata(agentState.State)
+		// The scheduler claimed the agent for this node
+		_, err := c.resumeClaimedAgent(ctx, agentState.AgentID, agentState.Role, metadata, nil)
 		if err != nil {
 			slog.Error("ControlPlane: Failed to resume agent", "error", err)
+			return
 		}
+		c.publishAgentProgress(ctx, agentState.AgentID, metadata.Owner, worker.ProgressTypeAgentWoken, "Woken by the scheduler")
 	}
 	c.scheduler.SetCallback(onAgentFound)
 
+	// Receive commands for agents that live on this node but were sent from another node
+	subscription, err := c.pubSub.Subscribe(GetAgentCommandTopic(), pubsub.Idempotent(pubsub.NewDeduplicator(pubsub.DefaultDeduplicatorCapacity), c.onAgentCommandMessage(ctx)))
+	if err != nil {
+		slog.Error("ControlPlane: Failed to subscribe to agent commands", "error", err)
+		return err
+	}
+	defer subscription.Unsubscribe()
+
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 	// Start controller and scheduler in separate goroutines
@@ -96,19 +112,24 @@ func (c *ControlPlaneImpl) Start(ctx context.Context) error {
 		slog.Info("ControlPlane: Scheduler started")
 	}()
 
-	select {
-	case <-ctx.Done():
-		slog.Info("ControlPlane: Stopping")
-		// Controller and scheduler should stop themselves when context is canceled
-		// Same for all workers
-		return ctx.Err()
-	case cmd := <-c.controlCh:
-		slog.Info("ControlPlane: Received command", "command", cmd)
-		switch cmd {
-		case "stop":
-			c.stopCmdSent = true
-			c.controller.SendCommand(ctx, "stop") // controller is responsible for stopping agents
-			c.scheduler.SendCommand(ctx, "stop")
+	stopped := false
+	for !stopped {
+		select {
+		case <-ctx.Done():
+			slog.Info("ControlPlane: Stopping")
+			// Controller and scheduler should stop themselves when context is canceled
+			// Same for all workers
+			return ctx.Err()
+		case cmd := <-c.controlCh:
+			slog.Info("ControlPlane: Received command", "command", cmd)
+			switch cmd {
+			case "stop":
+				stopped = true
+				c.controller.SendCommand(ctx, "stop") // controller is responsible for stopping agents
+				c.scheduler.SendCommand(ctx, "stop")
+			default:
+				slog.Warn("ControlPlane: Unknown command", "command", cmd)
+			}
 		}
 	}
 
@@ -126,7 +147,12 @@ func (c *ControlPlaneImpl) SendCommand(ctx context.Context, command string) erro
 		slog.Error("ControlPlane: Control channel not initialized")
 		return fmt.Errorf("ControlPlane: Control channel not initialized")
 	}
-	if c.stopCmdSent {
+	// Shutdown, the HTTP handlers and Start send commands concurrently, the stop is sent exactly once
+	stopCmdSent := c.stopCmdSent.Load()
+	if command == "stop" {
+		stopCmdSent = !c.stopCmdSent.CompareAndSwap(false, true)
+	}
+	if stopCmdSent {
 		slog.Warn("ControlPlane: Stop command already sent, ignoring command", "command", command)
 		return nil
 	}
@@ -141,8 +167,9 @@ func (c *ControlPlaneImpl) SendCommand(ctx context.Context, command string) erro
 	}
 }
 
-// newAgent creates a new agent and registers it with the controller
-func (c *ControlPlaneImpl) newAgent(ctx context.Context, task string, role string) (agent.Agent, error) {
+// newAgent creates a new agent.
+// If agentID is set, the agent adopts the ID of the persisted agent it is going to resume.
+func (c *ControlPlaneImpl) newAgent(task string, role string, agentID *string) (agent.Agent, error) {
 	var agent agent.Agent
 	switch role {
 	case "publisher":
@@ -152,73 +179,260 @@ func (c *ControlPlaneImpl) newAgent(ctx context.Context, task string, role strin
 	default:
 		return nil, fmt.Errorf("ControlPlane: Invalid role: %s", role)
 	}
-	slog.Info("ControlPlane: Creating new agent", "agent", agent)
-	agentID, err := c.controller.RegisterAgent(ctx, agent)
-	if err != nil {
-		slog.Error("ControlPlane: Failed to register agent", "error", err)
-		return nil, err
+	if agentID != nil {
+		agent.SetID(*agentID)
 	}
-	slog.Info("ControlPlane: Registered agent", "agent", agentID)
+	slog.Info("ControlPlane: Creating new agent", "agent", agent)
 	return agent, nil
 }
 
-// startAgent starts the agent in a new goroutine and handles the final response
-func (c *ControlPlaneImpl) startAgent(ctx context.Context, agent agent.Agent) error {
-	slog.Info("ControlPlane: Starting agent", "agent", agent.GetID())
-	go func() {
-		resp, err := agent.StartTask(ctx, c.workerCallbacks)
-		if err != nil {
-			slog.Error("ControlPlane: Failed to start new agent", "error", err)
-			return
-		}
-		slog.Info("ControlPlane: agent final response", "agent", resp.Id, "response", resp.Message)
-		finalResponseCallback, ok := c.callbacks[ControlPlaneEventAgentFinalResponse]
-		if !ok {
-			slog.Error("ControlPlane: No final response callback set")
-			return
-		}
-		finalResponseCallback(resp.Id, resp.Message)
-	}()
-	return nil
+// startAgent queues the agent to start its task
+func (c *ControlPlaneImpl) startAgent(ctx context.Context, a agent.Agent, metadata worker.TaskMetadata) (*TaskHandle, error) {
+	slog.Info("ControlPlane: Starting agent", "agent", a.GetID())
+	return c.enqueueAgent(ctx, a, metadata, func() (*agent.AgentResponse, error) {
+		return a.StartTask(ctx, c.workerCallbacks)
+	})
 }
 
-// resumeAgent resumes an agent and handles the final response
-func (c *ControlPlaneImpl) resumeAgent(ctx context.Context, agentID string, role string, newPrompt *string) error {
+// resumeAgent claims the agent for this node and queues it to resume its task, like the scheduler does with the agents it finds.
+// It returns ErrAgentBusy if the agent left the status it was read in, or another node claimed it meanwhile.
+func (c *ControlPlaneImpl) resumeAgent(ctx context.Context, agentID string, status string, role string, metadata worker.TaskMetadata, newPrompt *string) (*TaskHandle, error) {
+	claimed, err := c.storage.ClaimAgent(agentID, c.scheduler.GetNodeID(), status, AgentClaimDuration)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to claim agent", "agent", agentID, "error", err)
+		return nil, err
+	}
+	if !claimed {
+		slog.Warn("ControlPlane: Agent was claimed or changed by another request, not resuming", "agent", agentID, "status", status)
+		return nil, ErrAgentBusy
+	}
+	return c.resumeClaimedAgent(ctx, agentID, role, metadata, newPrompt)
+}
+
+// resumeClaimedAgent queues an agent claimed by this node to resume its task.
+// If it cannot be queued, the claim on the agent is released so the scheduler of any node can retry it.
+func (c *ControlPlaneImpl) resumeClaimedAgent(ctx context.Context, agentID string, role string, metadata worker.TaskMetadata, newPrompt *string) (*TaskHandle, error) {
 	slog.Info("ControlPlane: Resuming agent", "agent", agentID)
-	agent, err := c.newAgent(ctx, "", role)
+	a, err := c.newAgent("", role, &agentID)
 	if err != nil {
 		slog.Error("ControlPlane: Failed to resume agent", "error", err)
-		return err
+		c.releaseAgentClaim(agentID)
+		return nil, err
 	}
-	go func() {
-		resp, err := agent.ResumeTask(ctx, agentID, newPrompt, c.workerCallbacks)
-		if err != nil {
-			slog.Error("ControlPlane: Failed to resume agent", "error", err)
-			return
-		}
-		slog.Info("ControlPlane: agent final response", "agent", resp.Id, "response", resp.Message)
-		finalResponseCallback, ok := c.callbacks[ControlPlaneEventAgentFinalResponse]
-		if !ok {
-			slog.Error("ControlPlane: No final response callback set")
-			return
+	handle, err := c.enqueueAgent(ctx, a, metadata, func() (*agent.AgentResponse, error) {
+		return a.ResumeTask(ctx, agentID, newPrompt, c.workerCallbacks)
+	})
+	if err != nil {
+		c.releaseAgentClaim(agentID)
+		return nil, err
+	}
+	return handle, nil
+}
+
+// releaseAgentClaim releases this node's claim on the agent, so the scheduler of any node can claim it again.
+func (c *ControlPlaneImpl) releaseAgentClaim(agentID string) {
+	if err := c.storage.ReleaseAgentClaim(agentID, c.scheduler.GetNodeID()); err != nil {
+		slog.Error("ControlPlane: Failed to release agent claim", "agent", agentID, "error", err)
+	}
+}
+
+// enqueueAgent submits the agent to the run queue.
+// Once admitted the agent is registered with the controller and its task runs,
+// the returned handle is resolved and the final response callback is called when the task returns.
+func (c *ControlPlaneImpl) enqueueAgent(ctx context.Context, a agent.Agent, metadata worker.TaskMetadata, run func() (*agent.AgentResponse, error)) (*TaskHandle, error) {
+	handle := newTaskHandle(a.GetID())
+	req := RunRequest{
+		AgentID:  a.GetID(),
+		Role:     a.GetRole(),
+		Owner:    metadata.Owner,
+		Priority: metadata.Priority,
+		// The node shut down before the agent started, let the scheduler of another node claim it
+		OnDiscard: func() {
+			slog.Warn("ControlPlane: Queued agent discarded", "agent", a.GetID())
+			c.releaseAgentClaim(a.GetID())
+			handle.resolve(nil, ErrRunQueueClosed)
+		},
+	}
+	err := c.runQueue.Submit(req, func() {
+		c.runAgent(ctx, a, handle, run)
+	})
+	if err != nil {
+		slog.Error("ControlPlane: Failed to queue agent", "agent", a.GetID(), "error", err)
+		return nil, err
+	}
+	return handle, nil
+}
+
+func (c *ControlPlaneImpl) runAgent(ctx context.Context, a agent.Agent, handle *TaskHandle, run func() (*agent.AgentResponse, error)) {
+	// The agent is asleep, terminated or failed, let the scheduler claim it again
+	defer c.releaseAgentClaim(a.GetID())
+
+	registeredID, err := c.controller.RegisterAgent(ctx, a)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to register agent", "agent", a.GetID(), "error", err)
+		handle.resolve(nil, err)
+		return
+	}
+	slog.Info("ControlPlane: Registered agent", "agent", registeredID)
+
+	resp, err := run()
+	if err != nil {
+		slog.Error("ControlPlane: Agent task failed", "agent", a.GetID(), "error", err)
+		handle.resolve(nil, err)
+		return
+	}
+	status := a.GetStatus()
+	if status == worker.StatusAwaitingInput {
+		// The task is not finished, it continues once the user answers
+		slog.Info("ControlPlane: Agent is awaiting input", "agent", a.GetID(), "question", resp.Message)
+		handle.resolve(resp, ErrAgentAwaitingInput)
+		return
+	}
+	if status == worker.StatusAwaitingApproval {
+		// The task is not finished, it continues once the user decides on the tool call
+		slog.Info("ControlPlane: Agent is awaiting approval", "agent", a.GetID())
+		handle.resolve(resp, ErrAgentAwaitingApproval)
+		return
+	}
+	if status == worker.StatusAsleep {
+		metadata := a.GetMetadata()
+		if err := c.scheduler.ScheduleWake(ctx, a.GetID(), metadata); err != nil {
+			slog.Error("ControlPlane: Failed to schedule agent wake", "agent", a.GetID(), "error", err)
+		} else {
+			c.publishAgentProgress(ctx, a.GetID(), metadata.Owner, worker.ProgressTypeAgentAsleep, "Asleep until woken by the scheduler")
 		}
-		finalResponseCallback(resp.Id, resp.Message)
-	}()
-	return nil
+		handle.resolve(resp, ErrAgentAsleep)
+	} else {
+		handle.resolve(resp, nil)
+	}
+	slog.Info("ControlPlane: agent final response", "agent", resp.Id, "response", resp.Message)
+	finalResponseCallback, ok := c.callbacks[ControlPlaneEventAgentFinalResponse]
+	if !ok {
+		slog.Error("ControlPlane: No final response callback set")
+		return
+	}
+	finalResponseCallback(resp.Id, resp.Message)
 }
 
-func (c *ControlPlaneImpl) KickoffTask(ctx context.Context, task string, role string) error {
-	slog.Info("ControlPlane: Kickoff task", "task", task, "role", role)
-	agent, err := c.newAgent(ctx, task, role)
+// publishAgentProgress publishes a phase of the agent's lifecycle handled by the control plane rather than the worker.
+func (c *ControlPlaneImpl) publishAgentProgress(ctx context.Context, agentID string, owner string, progressType worker.ProgressType, progress string) {
+	// Failures are logged by PublishProgress
+	_ = worker.PublishProgress(ctx, c.pubSub, worker.WorkerProgressNotification{
+		AgentID:  agentID,
+		Owner:    owner,
+		Type:     progressType,
+		Level:    worker.ProgressLevelInfo,
+		Progress: progress,
+	})
+}
+
+// KickoffTask creates a new agent for the task and queues it to start.
+// It returns the ID of the new agent and a handle to await the agent's result,
+// or ErrRunQueueFull if the node cannot take more tasks.
+func (c *ControlPlaneImpl) KickoffTask(ctx context.Context, task string, role string, metadata worker.TaskMetadata) (string, *TaskHandle, error) {
+	slog.Info("ControlPlane: Kickoff task", "task", task, "role", role, "owner", metadata.Owner)
+	agent, err := c.newAgent(task, role, nil)
 	if err != nil {
 		slog.Error("ControlPlane: Failed to start new agent", "error", err)
-		return err
+		return "", nil, err
 	}
-	err = c.startAgent(ctx, agent)
+	agent.SetMetadata(metadata)
+	handle, err := c.startAgent(ctx, agent, metadata)
 	if err != nil {
-		slog.Error("ControlPlane: Failed to start new agent", "error", err)
+		return "", nil, err
+	}
+	slog.Info("ControlPlane: Queued new agent", "agent", agent.GetID())
+	return agent.GetID(), handle, nil
+}
+
+// ResumeTask queues an asleep or terminated agent to continue its task with a new prompt.
+// It returns ErrAgentBusy if the agent is running or paused, ErrAgentFailed if it failed,
+// ErrAgentAwaitingInput or ErrAgentAwaitingApproval if it waits for the user, or ErrRunQueueFull if the node cannot take more tasks.
+func (c *ControlPlaneImpl) ResumeTask(ctx context.Context, agentID string, prompt string) (*TaskHandle, error) {
+	slog.Info("ControlPlane: Resume task", "agent", agentID)
+	info, err := c.storage.GetAgentInfo(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent info", "agent", agentID, "error", err)
+		return nil, err
+	}
+	if info.Status == worker.StatusRunning || info.Status == worker.StatusPaused {
+		slog.Warn("ControlPlane: Agent is busy, not resuming", "agent", agentID, "status", info.Status)
+		return nil, ErrAgentBusy
+	}
+	if info.Status == worker.StatusFailed {
+		slog.Warn("ControlPlane: Agent failed, not resuming", "agent", agentID, "last_error", info.LastError)
+		return nil, ErrAgentFailed
+	}
+	if info.Status == worker.StatusAwaitingInput {
+		slog.Warn("ControlPlane: Agent is awaiting input, not resuming", "agent", agentID)
+		return nil, ErrAgentAwaitingInput
+	}
+	if info.Status == worker.StatusAwaitingApproval {
+		slog.Warn("ControlPlane: Agent is awaiting approval, not resuming", "agent", agentID)
+		return nil, ErrAgentAwaitingApproval
+	}
+	state, err := c.storage.GetAgentState(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent state", "agent", agentID, "error", err)
+		return nil, err
+	}
+	return c.resumeAgent(ctx, agentID, info.Status, info.Role, decodeTaskMetadata(state), &prompt)
+}
+
+// AnswerAgent queues an agent awaiting input to continue its task, with the answer as the next user message.
+// It returns ErrAgentNotAwaitingInput if the agent did not ask a question, or ErrRunQueueFull if the node cannot take more tasks.
+func (c *ControlPlaneImpl) AnswerAgent(ctx context.Context, agentID string, answer string) (*TaskHandle, error) {
+	slog.Info("ControlPlane: Answer agent", "agent", agentID)
+	info, err := c.storage.GetAgentInfo(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent info", "agent", agentID, "error", err)
+		return nil, err
+	}
+	if info.Status != worker.StatusAwaitingInput {
+		slog.Warn("ControlPlane: Agent is not awaiting input", "agent", agentID, "status", info.Status)
+		return nil, ErrAgentNotAwaitingInput
+	}
+	state, err := c.storage.GetAgentState(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent state", "agent", agentID, "error", err)
+		return nil, err
+	}
+	return c.resumeAgent(ctx, agentID, info.Status, info.Role, decodeTaskMetadata(state), &answer)
+}
+
+// Shutdown drains the node: it stops accepting tasks, discards the queued ones, and puts the running agents to sleep.
+// It returns once every running agent has persisted its state, or with the context's error if the deadline passes first.
+func (c *ControlPlaneImpl) Shutdown(ctx context.Context) error {
+	slog.Info("ControlPlane: Shutting down")
+	c.runQueue.Close()
+	if err := c.SendCommand(ctx, "stop"); err != nil {
+		slog.Error("ControlPlane: Failed to send stop command", "error", err)
 		return err
 	}
-	slog.Info("ControlPlane: Started new agent", "agent", agent.GetID())
-	return nil
+
+	select {
+	case <-c.runQueue.Drained():
+		slog.Info("ControlPlane: All agents persisted")
+		return nil
+	case <-ctx.Done():
+		slog.Error("ControlPlane: Shutdown deadline exceeded", "stats", c.runQueue.GetStats())
+		return ctx.Err()
+	}
+}
+
+// GetRunQueueStats returns the number of running and pending agents on this node
+func (c *ControlPlaneImpl) GetRunQueueStats() RunQueueStats {
+	return c.runQueue.GetStats()
+}
+
+// decodeTaskMetadata reads the task metadata from a persisted worker state,
+// so resumed agents keep their owner and priority in the run queue.
+func decodeTaskMetadata(state []byte) worker.TaskMetadata {
+	var persisted struct {
+		Metadata worker.TaskMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(state, &persisted); err != nil {
+		slog.Warn("ControlPlane: Failed to decode task metadata", "error", err)
+	}
+	return persisted.Metadata
 }
@@ -0,0 +1,484 @@
+package control_plane_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/agent"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	mock_agent "github.com/roackb2/lucid/test/_mocks/agent"
+	mock_control_plane "github.com/roackb2/lucid/test/_mocks/control_plane"
+	mock_providers "github.com/roackb2/lucid/test/_mocks/providers"
+	mock_pubsub "github.com/roackb2/lucid/test/_mocks/pubsub"
+	mock_storage "github.com/roackb2/lucid/test/_mocks/storage"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+type ControlPlaneTestSuite struct {
+	suite.Suite
+	mockCtrl         *gomock.Controller
+	mockStorage      *mock_storage.MockStorage
+	mockChatProvider *mock_providers.MockChatProvider
+	mockController   *mock_control_plane.MockAgentController
+	mockScheduler    *mock_control_plane.MockScheduler
+	mockAgentFactory *mock_control_plane.MockAgentFactory
+	mockPubSub       *mock_pubsub.MockPubSub
+	controlPlane     *control_plane.ControlPlaneImpl
+}
+
+func (suite *ControlPlaneTestSuite) SetupTest() {
+	suite.mockCtrl = gomock.NewController(suite.T())
+	suite.mockStorage = mock_storage.NewMockStorage(suite.mockCtrl)
+	suite.mockChatProvider = mock_providers.NewMockChatProvider(suite.mockCtrl)
+	suite.mockController = mock_control_plane.NewMockAgentController(suite.mockCtrl)
+	suite.mockScheduler = mock_control_plane.NewMockScheduler(suite.mockCtrl)
+	suite.mockAgentFactory = mock_control_plane.NewMockAgentFactory(suite.mockCtrl)
+	suite.mockPubSub = mock_pubsub.NewMockPubSub(suite.mockCtrl)
+	suite.controlPlane = control_plane.NewControlPlane(
+		suite.mockAgentFactory,
+		suite.mockStorage,
+		suite.mockChatProvider,
+		suite.mockController,
+		suite.mockScheduler,
+		control_plane.NewRunQueue(control_plane.RunQueueConfig{}),
+		suite.mockPubSub,
+		control_plane.ControlPlaneCallbacks{},
+		worker.WorkerCallbacks{},
+	)
+	suite.mockScheduler.EXPECT().GetNodeID().Return("node-a").AnyTimes()
+}
+
+func (suite *ControlPlaneTestSuite) TearDownTest() {
+	suite.mockCtrl.Finish()
+}
+
+func TestControlPlane(t *testing.T) {
+	suite.Run(t, new(ControlPlaneTestSuite))
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandToLocalAgent() {
+	suite.mockController.EXPECT().SendAgentCommand(gomock.Any(), "agent-id", worker.CmdPause).Return(nil)
+
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", worker.CmdPause)
+	suite.NoError(err)
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandToRemoteAgent() {
+	suite.mockController.EXPECT().SendAgentCommand(gomock.Any(), "agent-id", worker.CmdPause).Return(control_plane.ErrAgentNotTracked)
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusRunning,
+		Role:    worker.RoleConsumer,
+	}, nil)
+	suite.mockPubSub.EXPECT().Publish(gomock.Any(), control_plane.GetAgentCommandTopic(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, message string, _ time.Duration, opts ...pubsub.PublishOption) error {
+			envelope, notification, err := pubsub.DecodeEvent[control_plane.AgentCommandNotification](message, control_plane.AgentCommandEvent)
+			suite.NoError(err)
+			options := pubsub.NewPublishOptions(opts...)
+			suite.Equal(envelope.EventID, options.Headers[pubsub.HeaderEventID])
+			suite.Equal("agent-id", options.Key)
+			suite.Equal("agent-id", notification.AgentID)
+			suite.Equal(worker.CmdPause, notification.Command)
+			return nil
+		})
+
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", worker.CmdPause)
+	suite.NoError(err)
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandTerminatesAsleepAgent() {
+	suite.mockController.EXPECT().SendAgentCommand(gomock.Any(), "agent-id", worker.CmdTerminate).Return(control_plane.ErrAgentNotTracked)
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAsleep,
+		Role:    worker.RoleConsumer,
+	}, nil)
+	suite.mockStorage.EXPECT().UpdateAgentStatus("agent-id", worker.StatusTerminated).Return(nil)
+
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", worker.CmdTerminate)
+	suite.NoError(err)
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandRejectsInvalidCommand() {
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", "explode")
+	suite.ErrorIs(err, control_plane.ErrInvalidAgentCommand)
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandRejectsCommandsTheAgentCannotTake() {
+	cases := []struct {
+		status  string
+		command string
+		err     error
+	}{
+		{worker.StatusTerminated, worker.CmdPause, control_plane.ErrAgentTerminated},
+		{worker.StatusAsleep, worker.CmdPause, control_plane.ErrAgentCommandNotAllowed},
+		{worker.StatusFailed, worker.CmdResume, control_plane.ErrAgentFailed},
+		{worker.StatusAwaitingInput, worker.CmdResume, control_plane.ErrAgentAwaitingInput},
+		{worker.StatusAwaitingApproval, worker.CmdResume, control_plane.ErrAgentAwaitingApproval},
+	}
+	for _, tc := range cases {
+		suite.mockController.EXPECT().SendAgentCommand(gomock.Any(), "agent-id", tc.command).Return(control_plane.ErrAgentNotTracked)
+		suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{AgentID: "agent-id", Status: tc.status, Role: worker.RoleConsumer}, nil)
+
+		err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", tc.command)
+		suite.ErrorIs(err, tc.err, "%s agent", tc.status)
+	}
+}
+
+func (suite *ControlPlaneTestSuite) TestKickoffTask() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	metadata := worker.TaskMetadata{Owner: "test-owner", Budget: 10}
+	response := &agent.AgentResponse{Id: "agent-id", Role: worker.RoleConsumer, Message: "done"}
+
+	suite.mockAgentFactory.EXPECT().NewConsumerAgent(suite.mockStorage, "test task", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RoleConsumer).AnyTimes()
+	mockAgent.EXPECT().SetMetadata(metadata)
+	mockAgent.EXPECT().StartTask(gomock.Any(), gomock.Any()).Return(response, nil)
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusTerminated)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").Return(nil)
+
+	agentID, handle, err := suite.controlPlane.KickoffTask(context.Background(), "test task", worker.RoleConsumer, metadata)
+	suite.NoError(err)
+	suite.Equal("agent-id", agentID)
+	suite.Equal("agent-id", handle.AgentID())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := handle.Wait(ctx)
+	suite.NoError(err)
+	suite.Equal(response, resp)
+}
+
+func (suite *ControlPlaneTestSuite) TestKickoffTaskAgentAsleep() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	response := &agent.AgentResponse{Id: "agent-id", Role: worker.RolePublisher}
+
+	suite.mockAgentFactory.EXPECT().NewPublisherAgent(suite.mockStorage, "test task", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RolePublisher).AnyTimes()
+	mockAgent.EXPECT().SetMetadata(gomock.Any())
+	mockAgent.EXPECT().StartTask(gomock.Any(), gomock.Any()).Return(response, nil)
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusAsleep)
+	mockAgent.EXPECT().GetMetadata().Return(worker.TaskMetadata{WakePolicy: control_plane.WakePolicyBackoff})
+	suite.mockScheduler.EXPECT().ScheduleWake(gomock.Any(), "agent-id", worker.TaskMetadata{WakePolicy: control_plane.WakePolicyBackoff}).Return(nil)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").Return(nil)
+	var progress worker.WorkerProgressNotification
+	suite.mockPubSub.EXPECT().Publish(gomock.Any(), worker.GetAgentProgressTopic(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, message string, _ time.Duration, opts ...pubsub.PublishOption) error {
+			_, notification, err := pubsub.DecodeEvent[worker.WorkerProgressNotification](message, worker.AgentProgressEvent)
+			suite.NoError(err)
+			progress = notification
+			return nil
+		})
+
+	_, handle, err := suite.controlPlane.KickoffTask(context.Background(), "test task", worker.RolePublisher, worker.TaskMetadata{})
+	suite.NoError(err)
+
+	<-handle.Done()
+	suite.ErrorIs(handle.Result().Err, control_plane.ErrAgentAsleep)
+	// The sleep is reported as the agent's progress
+	suite.Equal("agent-id", progress.AgentID)
+	suite.Equal(worker.ProgressTypeAgentAsleep, progress.Type)
+}
+
+func (suite *ControlPlaneTestSuite) TestResumeTask() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	response := &agent.AgentResponse{Id: "agent-id", Role: worker.RoleConsumer, Message: "done"}
+	state := []byte(`{"metadata":{"owner":"test-owner"}}`)
+
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusTerminated,
+		Role:    worker.RoleConsumer,
+	}, nil)
+	suite.mockStorage.EXPECT().GetAgentState("agent-id").Return(state, nil)
+	suite.mockStorage.EXPECT().ClaimAgent("agent-id", "node-a", worker.StatusTerminated, control_plane.AgentClaimDuration).Return(true, nil)
+	suite.mockAgentFactory.EXPECT().NewConsumerAgent(suite.mockStorage, "", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().SetID("agent-id")
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RoleConsumer).AnyTimes()
+	mockAgent.EXPECT().ResumeTask(gomock.Any(), "agent-id", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, newPrompt *string, _ worker.WorkerCallbacks) (*agent.AgentResponse, error) {
+			suite.Equal("new prompt", *newPrompt)
+			return response, nil
+		})
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusTerminated)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").Return(nil)
+
+	handle, err := suite.controlPlane.ResumeTask(context.Background(), "agent-id", "new prompt")
+	suite.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := handle.Wait(ctx)
+	suite.NoError(err)
+	suite.Equal(response, resp)
+}
+
+func (suite *ControlPlaneTestSuite) TestResumeTaskRejectsAgentClaimedByAnotherNode() {
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAsleep,
+		Role:    worker.RoleConsumer,
+	}, nil)
+	suite.mockStorage.EXPECT().GetAgentState("agent-id").Return([]byte(`{}`), nil)
+	// The scheduler of another node claimed the agent after it was read
+	suite.mockStorage.EXPECT().ClaimAgent("agent-id", "node-a", worker.StatusAsleep, control_plane.AgentClaimDuration).Return(false, nil)
+
+	handle, err := suite.controlPlane.ResumeTask(context.Background(), "agent-id", "new prompt")
+	suite.ErrorIs(err, control_plane.ErrAgentBusy)
+	suite.Nil(handle)
+}
+
+func (suite *ControlPlaneTestSuite) TestResumeTaskRejectsRunningAgent() {
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusRunning,
+		Role:    worker.RoleConsumer,
+	}, nil)
+
+	_, err := suite.controlPlane.ResumeTask(context.Background(), "agent-id", "new prompt")
+	suite.ErrorIs(err, control_plane.ErrAgentBusy)
+}
+
+func (suite *ControlPlaneTestSuite) TestShutdownWaitsForRunningAgents() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	suite.mockAgentFactory.EXPECT().NewConsumerAgent(suite.mockStorage, "test task", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent).Times(2)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RoleConsumer).AnyTimes()
+	mockAgent.EXPECT().SetMetadata(gomock.Any()).Times(2)
+	mockAgent.EXPECT().StartTask(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, worker.WorkerCallbacks) (*agent.AgentResponse, error) {
+		close(started)
+		<-release
+		return &agent.AgentResponse{Id: "agent-id", Role: worker.RoleConsumer}, nil
+	})
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusAsleep)
+	mockAgent.EXPECT().GetMetadata().Return(worker.TaskMetadata{})
+	suite.mockScheduler.EXPECT().ScheduleWake(gomock.Any(), "agent-id", gomock.Any()).Return(nil)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").Return(nil)
+	suite.mockPubSub.EXPECT().Publish(gomock.Any(), worker.GetAgentProgressTopic(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+
+	_, _, err := suite.controlPlane.KickoffTask(context.Background(), "test task", worker.RoleConsumer, worker.TaskMetadata{})
+	suite.NoError(err)
+	<-started
+
+	// The agent has not persisted yet, so the shutdown times out
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	suite.ErrorIs(suite.controlPlane.Shutdown(ctx), context.DeadlineExceeded)
+
+	// No new tasks are accepted while draining
+	_, _, err = suite.controlPlane.KickoffTask(context.Background(), "test task", worker.RoleConsumer, worker.TaskMetadata{})
+	suite.ErrorIs(err, control_plane.ErrRunQueueClosed)
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	suite.NoError(suite.controlPlane.Shutdown(ctx))
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandRetriesFailedAgent() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	done := make(chan struct{})
+
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID:   "agent-id",
+		Status:    worker.StatusFailed,
+		Role:      worker.RoleConsumer,
+		LastError: "rate_limit: 429",
+	}, nil)
+	suite.mockStorage.EXPECT().GetAgentState("agent-id").Return([]byte(`{}`), nil)
+	suite.mockStorage.EXPECT().SetAgentError("agent-id", "").Return(nil)
+	suite.mockStorage.EXPECT().ClaimAgent("agent-id", "node-a", worker.StatusFailed, control_plane.AgentClaimDuration).Return(true, nil)
+	suite.mockAgentFactory.EXPECT().NewConsumerAgent(suite.mockStorage, "", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().SetID("agent-id")
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RoleConsumer).AnyTimes()
+	mockAgent.EXPECT().ResumeTask(gomock.Any(), "agent-id", nil, gomock.Any()).Return(&agent.AgentResponse{Id: "agent-id"}, nil)
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusTerminated)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").DoAndReturn(func(string, string) error {
+		close(done)
+		return nil
+	})
+
+	// Failed agents are not live on any node, so the controller is not asked
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", worker.CmdRetry)
+	suite.NoError(err)
+	<-done
+}
+
+func (suite *ControlPlaneTestSuite) TestSendAgentCommandRejectsRetryOfHealthyAgent() {
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAsleep,
+		Role:    worker.RoleConsumer,
+	}, nil)
+
+	err := suite.controlPlane.SendAgentCommand(context.Background(), "agent-id", worker.CmdRetry)
+	suite.ErrorIs(err, control_plane.ErrAgentNotFailed)
+}
+
+func (suite *ControlPlaneTestSuite) TestAnswerAgent() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	response := &agent.AgentResponse{Id: "agent-id", Role: worker.RoleConsumer, Message: "Which year?"}
+
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAwaitingInput,
+		Role:    worker.RoleConsumer,
+	}, nil)
+	suite.mockStorage.EXPECT().GetAgentState("agent-id").Return([]byte(`{}`), nil)
+	suite.mockStorage.EXPECT().ClaimAgent("agent-id", "node-a", worker.StatusAwaitingInput, control_plane.AgentClaimDuration).Return(true, nil)
+	suite.mockAgentFactory.EXPECT().NewConsumerAgent(suite.mockStorage, "", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().SetID("agent-id")
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RoleConsumer).AnyTimes()
+	mockAgent.EXPECT().ResumeTask(gomock.Any(), "agent-id", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, newPrompt *string, _ worker.WorkerCallbacks) (*agent.AgentResponse, error) {
+			suite.Equal("Taipei", *newPrompt)
+			return response, nil
+		})
+	// The agent asks another question
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusAwaitingInput)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").Return(nil)
+
+	handle, err := suite.controlPlane.AnswerAgent(context.Background(), "agent-id", "Taipei")
+	suite.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := handle.Wait(ctx)
+	suite.ErrorIs(err, control_plane.ErrAgentAwaitingInput)
+	suite.Equal(response, resp)
+}
+
+func (suite *ControlPlaneTestSuite) TestAnswerAgentRejectsAgentNotAwaitingInput() {
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAsleep,
+		Role:    worker.RoleConsumer,
+	}, nil)
+
+	_, err := suite.controlPlane.AnswerAgent(context.Background(), "agent-id", "Taipei")
+	suite.ErrorIs(err, control_plane.ErrAgentNotAwaitingInput)
+}
+
+func (suite *ControlPlaneTestSuite) TestResumeTaskRejectsAgentAwaitingInput() {
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAwaitingInput,
+		Role:    worker.RoleConsumer,
+	}, nil)
+
+	_, err := suite.controlPlane.ResumeTask(context.Background(), "agent-id", "new prompt")
+	suite.ErrorIs(err, control_plane.ErrAgentAwaitingInput)
+}
+
+func (suite *ControlPlaneTestSuite) TestDecideToolApproval() {
+	mockAgent := mock_agent.NewMockAgent(suite.mockCtrl)
+	done := make(chan struct{})
+
+	suite.mockStorage.EXPECT().GetToolApproval("approval-id").Return(&storage.ToolApproval{
+		ApprovalID: "approval-id",
+		AgentID:    "agent-id",
+		ToolName:   "save_content",
+		Status:     storage.ToolApprovalPending,
+	}, nil)
+	suite.mockStorage.EXPECT().GetAgentInfo("agent-id").Return(&storage.AgentInfo{
+		AgentID: "agent-id",
+		Status:  worker.StatusAwaitingApproval,
+		Role:    worker.RolePublisher,
+	}, nil)
+	suite.mockStorage.EXPECT().
+		DecideToolApproval("approval-id", storage.ToolApprovalApproved, `{"content": "edited"}`, "").
+		Return(&storage.ToolApproval{ApprovalID: "approval-id", Status: storage.ToolApprovalApproved}, nil)
+	suite.mockStorage.EXPECT().GetAgentState("agent-id").Return([]byte(`{}`), nil)
+	suite.mockStorage.EXPECT().ClaimAgent("agent-id", "node-a", worker.StatusAwaitingApproval, control_plane.AgentClaimDuration).Return(true, nil)
+	suite.mockAgentFactory.EXPECT().NewPublisherAgent(suite.mockStorage, "", suite.mockChatProvider, suite.mockPubSub).Return(mockAgent)
+	suite.mockController.EXPECT().RegisterAgent(gomock.Any(), mockAgent).Return("agent-id", nil)
+	mockAgent.EXPECT().SetID("agent-id")
+	mockAgent.EXPECT().GetID().Return("agent-id").AnyTimes()
+	mockAgent.EXPECT().GetRole().Return(worker.RolePublisher).AnyTimes()
+	// The decision is read by the agent from storage, so it resumes without a new prompt
+	mockAgent.EXPECT().ResumeTask(gomock.Any(), "agent-id", nil, gomock.Any()).Return(&agent.AgentResponse{Id: "agent-id"}, nil)
+	mockAgent.EXPECT().GetStatus().Return(worker.StatusTerminated)
+	suite.mockStorage.EXPECT().ReleaseAgentClaim("agent-id", "node-a").DoAndReturn(func(string, string) error {
+		close(done)
+		return nil
+	})
+
+	decision := control_plane.ToolApprovalDecision{Approved: true, EditedArguments: `{"content": "edited"}`}
+	handle, err := suite.controlPlane.DecideToolApproval(context.Background(), "approval-id", decision)
+	suite.NoError(err)
+	suite.Equal("agent-id", handle.AgentID())
+	<-done
+}
+
+func (suite *ControlPlaneTestSuite) TestDecideToolApprovalRejectsDecidedApproval() {
+	suite.mockStorage.EXPECT().GetToolApproval("approval-id").Return(&storage.ToolApproval{
+		ApprovalID: "approval-id",
+		AgentID:    "agent-id",
+		Status:     storage.ToolApprovalRejected,
+	}, nil)
+
+	_, err := suite.controlPlane.DecideToolApproval(context.Background(), "approval-id", control_plane.ToolApprovalDecision{Approved: true})
+	suite.ErrorIs(err, storage.ErrToolApprovalNotPending)
+}
+
+func (suite *ControlPlaneTestSuite) TestDecideToolApprovalRejectsInvalidArguments() {
+	decision := control_plane.ToolApprovalDecision{Approved: true, EditedArguments: "not json"}
+	_, err := suite.controlPlane.DecideToolApproval(context.Background(), "approval-id", decision)
+	suite.ErrorIs(err, control_plane.ErrInvalidApprovalDecision)
+}
+
+func (suite *ControlPlaneTestSuite) TestSearchAgentProfilesClampsLimit() {
+	profiles := []storage.AgentProfile{{AgentID: "agent-id", Role: "publisher"}}
+	gomock.InOrder(
+		suite.mockStorage.EXPECT().
+			SearchAgentProfiles(storage.AgentProfileQuery{Query: "news", Limit: control_plane.DefaultDirectoryLimit}).
+			Return(profiles, nil),
+		suite.mockStorage.EXPECT().
+			SearchAgentProfiles(storage.AgentProfileQuery{Query: "news", Limit: control_plane.MaxDirectoryLimit}).
+			Return(profiles, nil),
+	)
+
+	result, err := suite.controlPlane.SearchAgentProfiles(context.Background(), storage.AgentProfileQuery{Query: "news"})
+	suite.NoError(err)
+	suite.Equal(profiles, result)
+	_, err = suite.controlPlane.SearchAgentProfiles(context.Background(), storage.AgentProfileQuery{Query: "news", Limit: 1000})
+	suite.NoError(err)
+}
+
+func (suite *ControlPlaneTestSuite) TestGetAgentThreadWithHistory() {
+	thread := &storage.AgentThread{ThreadID: "thread-id", InitiatorAgentID: "agent-1", ResponderAgentID: "agent-2", Status: worker.ThreadStatusOffered}
+	messages := []storage.AgentMessage{
+		{MessageID: "message-1", ThreadID: "thread-id", Kind: string(worker.MessageKindRequest)},
+		{MessageID: "message-2", ThreadID: "thread-id", Kind: string(worker.MessageKindOffer)},
+	}
+	suite.mockStorage.EXPECT().GetAgentThread("thread-id").Return(thread, nil)
+	suite.mockStorage.EXPECT().ListAgentThreadMessages("thread-id").Return(messages, nil)
+	suite.mockStorage.EXPECT().GetAgentThread("unknown").Return(nil, storage.ErrAgentThreadNotFound)
+
+	history, err := suite.controlPlane.GetAgentThread(context.Background(), "thread-id")
+	suite.NoError(err)
+	suite.Equal(*thread, history.Thread)
+	suite.Equal(messages, history.Messages)
+
+	_, err = suite.controlPlane.GetAgentThread(context.Background(), "unknown")
+	suite.ErrorIs(err, storage.ErrAgentThreadNotFound)
+}
@@ -0,0 +1,27 @@
+package control_plane
+
+import (
+	"context"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+const (
+	// DefaultDirectoryLimit is the number of profiles SearchAgentProfiles returns unless asked otherwise
+	DefaultDirectoryLimit = 20
+	// MaxDirectoryLimit bounds the number of profiles SearchAgentProfiles returns
+	MaxDirectoryLimit = 100
+)
+
+// SearchAgentProfiles browses the agent directory, the limit of the query is clamped to MaxDirectoryLimit.
+func (c *ControlPlaneImpl) SearchAgentProfiles(ctx context.Context, query storage.AgentProfileQuery) ([]storage.AgentProfile, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultDirectoryLimit
+	}
+	query.Limit = min(query.Limit, MaxDirectoryLimit)
+	return c.storage.SearchAgentProfiles(query)
+}
+
+func (c *ControlPlaneImpl) GetAgentProfile(ctx context.Context, agentID string) (*storage.AgentProfile, error) {
+	return c.storage.GetAgentProfile(agentID)
+}
@@ -3,6 +3,7 @@ package control_plane
 import (
 	"log/slog"
 	"sync"
+	"time"
 )
 
 type MemoryAgentTracker struct {
@@ -60,3 +61,15 @@ func (t *MemoryAgentTracker) GetAllTrackings() []AgentTracking {
 	slog.Debug("MemoryAgentTracker got all trackings", "count", len(trackings))
 	return trackings
 }
+
+// updateLease sets the lease expiry of a tracking without touching the other fields
+func (t *MemoryAgentTracker) updateLease(agentID string, leaseExpiresAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracking, ok := t.trackings[agentID]
+	if !ok {
+		return
+	}
+	tracking.LeaseExpiresAt = leaseExpiresAt
+	t.trackings[agentID] = tracking
+}
@@ -0,0 +1,148 @@
+package control_plane
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+const (
+	OutboxPollInterval    = 1 * time.Second
+	BatchProcessOutboxNum = 100
+	// OutboxClaimDuration hides the claimed events from other relays while they are published,
+	// events of a relay that stopped midway are published by another once the claim expires
+	OutboxClaimDuration   = 1 * time.Minute
+	OutboxRetryBackoff    = 1 * time.Second
+	OutboxMaxRetryBackoff = 5 * time.Minute
+	// OutboxRetention is how long delivered events are kept before they are deleted
+	OutboxRetention       = 24 * time.Hour
+	OutboxCleanupInterval = 10 * time.Minute
+	OutboxPublishTimeout  = 5 * time.Second
+)
+
+type OutboxRelayConfig struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	ClaimDuration   time.Duration
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	Retention       time.Duration
+}
+
+// OutboxRelayImpl publishes the events the agents wrote to the outbox with their state.
+// Several nodes can run a relay, each event is claimed by one of them at a time.
+// An event that fails to publish is retried with a backoff, doubling with each attempt, until it is published,
+// the following events with the same key are not claimed before it is delivered so they stay in order.
+// An event may be published more than once if its relay stops before marking it delivered,
+// consumers drop the duplicates by event ID.
+type OutboxRelayImpl struct {
+	cfg     OutboxRelayConfig
+	storage storage.Storage
+	pubSub  pubsub.PubSub
+}
+
+func NewOutboxRelay(cfg OutboxRelayConfig, storage storage.Storage, pubSub pubsub.PubSub) *OutboxRelayImpl {
+	mergedCfg := OutboxRelayConfig{
+		PollInterval:    utils.GetOrDefault(cfg.PollInterval, OutboxPollInterval),
+		BatchSize:       utils.GetOrDefault(cfg.BatchSize, BatchProcessOutboxNum),
+		ClaimDuration:   utils.GetOrDefault(cfg.ClaimDuration, OutboxClaimDuration),
+		RetryBackoff:    utils.GetOrDefault(cfg.RetryBackoff, OutboxRetryBackoff),
+		MaxRetryBackoff: utils.GetOrDefault(cfg.MaxRetryBackoff, OutboxMaxRetryBackoff),
+		Retention:       utils.GetOrDefault(cfg.Retention, OutboxRetention),
+	}
+	return &OutboxRelayImpl{
+		cfg:     mergedCfg,
+		storage: storage,
+		pubSub:  pubSub,
+	}
+}
+
+func (r *OutboxRelayImpl) Start(ctx context.Context) error {
+	slog.Info("OutboxRelay: Starting")
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(OutboxCleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("OutboxRelay: Stopping")
+			return ctx.Err()
+		case <-ticker.C:
+			if err := r.relayEvents(ctx); err != nil {
+				// The events are claimed again on a later tick
+				slog.Error("OutboxRelay: Failed to relay events", "error", err)
+			}
+		case <-cleanupTicker.C:
+			if err := r.storage.DeleteDeliveredOutboxEvents(r.cfg.Retention); err != nil {
+				slog.Error("OutboxRelay: Failed to delete delivered events", "error", err)
+			}
+		}
+	}
+}
+
+// relayEvents publishes the due events a batch at a time.
+// A batch holds at most the earliest undelivered event of each key, so it claims again
+// while a batch is full or delivered events whose successors are now due.
+func (r *OutboxRelayImpl) relayEvents(ctx context.Context) error {
+	for {
+		events, err := r.storage.ClaimOutboxEvents(r.cfg.BatchSize, r.cfg.ClaimDuration)
+		if err != nil {
+			return err
+		}
+		delivered := false
+		for _, event := range events {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if r.relayEvent(ctx, event) {
+				delivered = true
+			}
+		}
+		if len(events) < r.cfg.BatchSize && !delivered {
+			return nil
+		}
+	}
+}
+
+// relayEvent publishes the event and marks it delivered, or schedules its retry.
+// Returns whether the event was published.
+func (r *OutboxRelayImpl) relayEvent(ctx context.Context, event storage.OutboxEvent) bool {
+	opts := []pubsub.PublishOption{pubsub.WithHeaders(event.Headers)}
+	if event.Key != "" {
+		opts = append(opts, pubsub.WithKey(event.Key))
+	}
+	err := r.pubSub.Publish(ctx, event.Topic, event.Message, OutboxPublishTimeout, opts...)
+	if err != nil {
+		backoff := r.retryBackoff(event.Attempts)
+		slog.Warn("OutboxRelay: Failed to publish event, retrying", "id", event.ID, "topic", event.Topic, "attempts", event.Attempts+1, "backoff", backoff, "error", err)
+		r.retryEvent(event, err.Error(), backoff)
+		return false
+	}
+	if err := r.storage.MarkOutboxEventDelivered(event.ID); err != nil {
+		// The event is published again once the claim expires
+		slog.Error("OutboxRelay: Failed to mark event delivered", "id", event.ID, "error", err)
+	}
+	return true
+}
+
+func (r *OutboxRelayImpl) retryEvent(event storage.OutboxEvent, lastError string, backoff time.Duration) {
+	if err := r.storage.RetryOutboxEvent(event.ID, lastError, backoff); err != nil {
+		// The event is retried anyway once the claim expires
+		slog.Error("OutboxRelay: Failed to schedule event retry", "id", event.ID, "error", err)
+	}
+}
+
+// retryBackoff doubles the backoff for every failed attempt, up to the maximum.
+func (r *OutboxRelayImpl) retryBackoff(attempts int) time.Duration {
+	backoff := r.cfg.RetryBackoff
+	for i := 0; i < attempts && backoff < r.cfg.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.cfg.MaxRetryBackoff)
+}
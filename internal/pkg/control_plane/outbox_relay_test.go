@@ -0,0 +1,76 @@
+package control_plane_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	mock_pubsub "github.com/roackb2/lucid/test/_mocks/pubsub"
+	mock_storage "github.com/roackb2/lucid/test/_mocks/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStorage := mock_storage.NewMockStorage(ctrl)
+	mockPubSub := mock_pubsub.NewMockPubSub(ctrl)
+
+	headers := map[string]string{pubsub.HeaderEventID: "event-1"}
+	first := storage.OutboxEvent{ID: 1, Topic: "agent-1_response", Key: "agent-1", Message: "first", Headers: headers}
+	second := storage.OutboxEvent{ID: 2, Topic: "agent-2_response", Key: "agent-2", Message: "second", Attempts: 2}
+	third := storage.OutboxEvent{ID: 3, Topic: "agent_response", Key: "agent-2", Message: "third"}
+	retried := second
+	retried.Attempts = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The third event is claimed once the second, with the same key, is delivered on a later tick
+	gomock.InOrder(
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).Return([]storage.OutboxEvent{first, second}, nil),
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).Return(nil, nil),
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).Return([]storage.OutboxEvent{retried}, nil),
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).Return([]storage.OutboxEvent{third}, nil),
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).DoAndReturn(func(limit int, claimDuration time.Duration) ([]storage.OutboxEvent, error) {
+			cancel()
+			return nil, nil
+		}),
+		mockStorage.EXPECT().ClaimOutboxEvents(10, control_plane.OutboxClaimDuration).Return(nil, nil).AnyTimes(),
+	)
+
+	mockPubSub.EXPECT().
+		Publish(gomock.Any(), "agent-1_response", "first", control_plane.OutboxPublishTimeout, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, topic string, message string, timeout time.Duration, opts ...pubsub.PublishOption) error {
+			options := pubsub.NewPublishOptions(opts...)
+			assert.Equal(t, pubsub.Headers(headers), options.Headers)
+			assert.Equal(t, "agent-1", options.Key)
+			return nil
+		})
+	mockStorage.EXPECT().MarkOutboxEventDelivered(int64(1)).Return(nil)
+	// The backoff doubles with the attempts
+	gomock.InOrder(
+		mockPubSub.EXPECT().
+			Publish(gomock.Any(), "agent-2_response", "second", control_plane.OutboxPublishTimeout, gomock.Any()).
+			Return(errors.New("broker unavailable")),
+		mockStorage.EXPECT().RetryOutboxEvent(int64(2), "broker unavailable", 4*time.Second).Return(nil),
+		mockPubSub.EXPECT().
+			Publish(gomock.Any(), "agent-2_response", "second", control_plane.OutboxPublishTimeout, gomock.Any()).
+			Return(nil),
+		mockStorage.EXPECT().MarkOutboxEventDelivered(int64(2)).Return(nil),
+		mockPubSub.EXPECT().
+			Publish(gomock.Any(), "agent_response", "third", control_plane.OutboxPublishTimeout, gomock.Any()).
+			Return(nil),
+		mockStorage.EXPECT().MarkOutboxEventDelivered(int64(3)).Return(nil),
+	)
+
+	relay := control_plane.NewOutboxRelay(control_plane.OutboxRelayConfig{
+		PollInterval: time.Millisecond,
+		BatchSize:    10,
+	}, mockStorage, mockPubSub)
+	err := relay.Start(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,167 @@
+package control_plane
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+type PostgresAgentTrackerConfig struct {
+	NodeID            string
+	LeaseDuration     time.Duration
+	HeartbeatInterval time.Duration
+}
+
+// PostgresAgentTracker keeps the agents live on this node in memory,
+// and records a lease for each of them in Postgres so every node knows who owns an agent.
+// Leases are renewed by Start, agents whose node stops renewing them can be reclaimed by the scheduler of another node.
+type PostgresAgentTracker struct {
+	cfg   PostgresAgentTrackerConfig
+	local *MemoryAgentTracker
+}
+
+func NewPostgresAgentTracker(cfg PostgresAgentTrackerConfig) *PostgresAgentTracker {
+	leaseDuration := utils.GetOrDefault(cfg.LeaseDuration, 30*time.Second)
+	heartbeatInterval := utils.GetOrDefault(cfg.HeartbeatInterval, leaseDuration/3)
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID = DefaultNodeID()
+	}
+
+	mergedCfg := PostgresAgentTrackerConfig{
+		NodeID:            nodeID,
+		LeaseDuration:     leaseDuration,
+		HeartbeatInterval: heartbeatInterval,
+	}
+	return &PostgresAgentTracker{
+		cfg:   mergedCfg,
+		local: NewMemoryAgentTracker(),
+	}
+}
+
+// DefaultNodeID returns the hostname with a random suffix, so a restarted node never renews leases of its previous run
+func DefaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "node"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8])
+}
+
+func (t *PostgresAgentTracker) GetNodeID() string {
+	return t.cfg.NodeID
+}
+
+// Start renews the leases of all agents tracked by this node until the context is done
+func (t *PostgresAgentTracker) Start(ctx context.Context) error {
+	slog.Info("PostgresAgentTracker started", "node_id", t.cfg.NodeID)
+	ticker := time.NewTicker(t.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("PostgresAgentTracker stopping", "node_id", t.cfg.NodeID)
+			return ctx.Err()
+		case <-ticker.C:
+			if err := t.renewLeases(ctx); err != nil {
+				// Leases will expire on their own, and the controller puts the agents to sleep
+				slog.Error("PostgresAgentTracker failed to renew leases", "node_id", t.cfg.NodeID, "error", err)
+			}
+		}
+	}
+}
+
+func (t *PostgresAgentTracker) renewLeases(ctx context.Context) error {
+	trackings := t.local.GetAllTrackings()
+	if len(trackings) == 0 {
+		return nil
+	}
+	agentIDs := make([]string, 0, len(trackings))
+	for _, tracking := range trackings {
+		agentIDs = append(agentIDs, tracking.AgentID)
+	}
+
+	leaseExpiresAt := time.Now().Add(t.cfg.LeaseDuration)
+	renewedIDs, err := dbaccess.Querier.RenewAgentLeases(ctx, dbaccess.RenewAgentLeasesParams{
+		LeaseDuration: utils.ConvertToPgInterval(t.cfg.LeaseDuration),
+		NodeID:        t.cfg.NodeID,
+		AgentIds:      agentIDs,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Debug("PostgresAgentTracker renewed leases", "node_id", t.cfg.NodeID, "count", len(renewedIDs))
+
+	for _, agentID := range agentIDs {
+		if slices.Contains(renewedIDs, agentID) {
+			t.local.updateLease(agentID, leaseExpiresAt)
+			continue
+		}
+		// The lease row is gone or owned by another node, expire the lease so the controller stops the agent
+		slog.Warn("PostgresAgentTracker lost lease", "node_id", t.cfg.NodeID, "agent_id", agentID)
+		t.local.updateLease(agentID, time.Now())
+	}
+	return nil
+}
+
+func (t *PostgresAgentTracker) AddTracking(agentID string, tracking AgentTracking) {
+	slog.Debug("PostgresAgentTracker adding tracking", "agent_id", agentID, "node_id", t.cfg.NodeID)
+	tracking.LeaseExpiresAt = time.Now().Add(t.cfg.LeaseDuration)
+	t.local.AddTracking(agentID, tracking)
+
+	err := dbaccess.Querier.UpsertAgentTracking(context.Background(), dbaccess.UpsertAgentTrackingParams{
+		AgentID:       agentID,
+		NodeID:        t.cfg.NodeID,
+		Status:        tracking.Status,
+		LeaseDuration: utils.ConvertToPgInterval(t.cfg.LeaseDuration),
+	})
+	if err != nil {
+		slog.Error("PostgresAgentTracker failed to add tracking", "agent_id", agentID, "error", err)
+	}
+}
+
+func (t *PostgresAgentTracker) GetTracking(agentID string) (AgentTracking, bool) {
+	return t.local.GetTracking(agentID)
+}
+
+func (t *PostgresAgentTracker) UpdateTracking(agentID string, tracking AgentTracking) {
+	slog.Debug("PostgresAgentTracker updating tracking", "agent_id", agentID, "node_id", t.cfg.NodeID)
+	if existing, ok := t.local.GetTracking(agentID); ok && tracking.LeaseExpiresAt.IsZero() {
+		tracking.LeaseExpiresAt = existing.LeaseExpiresAt
+	}
+	t.local.UpdateTracking(agentID, tracking)
+
+	err := dbaccess.Querier.UpdateAgentTrackingStatus(context.Background(), dbaccess.UpdateAgentTrackingStatusParams{
+		AgentID: agentID,
+		NodeID:  t.cfg.NodeID,
+		Status:  tracking.Status,
+	})
+	if err != nil {
+		slog.Error("PostgresAgentTracker failed to update tracking", "agent_id", agentID, "error", err)
+	}
+}
+
+func (t *PostgresAgentTracker) RemoveTracking(agentID string) {
+	slog.Debug("PostgresAgentTracker removing tracking", "agent_id", agentID, "node_id", t.cfg.NodeID)
+	t.local.RemoveTracking(agentID)
+
+	err := dbaccess.Querier.DeleteAgentTracking(context.Background(), dbaccess.DeleteAgentTrackingParams{
+		AgentID: agentID,
+		NodeID:  t.cfg.NodeID,
+	})
+	if err != nil {
+		slog.Error("PostgresAgentTracker failed to remove tracking", "agent_id", agentID, "error", err)
+	}
+}
+
+func (t *PostgresAgentTracker) GetAllTrackings() []AgentTracking {
+	return t.local.GetAllTrackings()
+}
@@ -0,0 +1,255 @@
+package control_plane
+
+import (
+	"container/heap"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+var (
+	// ErrRunQueueFull is returned when an agent cannot be queued because too many runs are pending.
+	ErrRunQueueFull = errors.New("run queue is full")
+	// ErrRunQueueClosed is returned when an agent cannot be queued because the node is shutting down.
+	ErrRunQueueClosed = errors.New("run queue is closed")
+)
+
+type RunQueueConfig struct {
+	// MaxRunning caps the number of agents running on this node at the same time.
+	MaxRunning int
+	// MaxRunningPerRole caps the running agents of a role, roles not listed are only capped by MaxRunning.
+	MaxRunningPerRole map[string]int
+	// MaxRunningPerOwner caps the running agents of each owner, 0 means unlimited.
+	MaxRunningPerOwner int
+	// MaxPending caps the number of runs waiting for a slot.
+	MaxPending int
+}
+
+// RunRequest describes an agent run waiting for admission.
+type RunRequest struct {
+	AgentID  string
+	Role     string
+	Owner    string
+	Priority int
+	// OnDiscard is called instead of the run if the queue is closed before the request is admitted.
+	OnDiscard func()
+}
+
+// RunQueueStats is a snapshot of the run queue.
+type RunQueueStats struct {
+	Pending        int            `json:"pending"`
+	Running        int            `json:"running"`
+	MaxRunning     int            `json:"max_running"`
+	RunningByRole  map[string]int `json:"running_by_role"`
+	RunningByOwner map[string]int `json:"running_by_owner"`
+}
+
+// RunQueue admits agent runs on this node.
+// Runs wait in a priority queue and start once the running count, and the count of their role and owner, are under the limits.
+// A run blocked by its role or owner limit does not hold back runs of other roles and owners.
+type RunQueue struct {
+	cfg RunQueueConfig
+
+	mu             sync.Mutex
+	pending        pendingRuns
+	seq            uint64
+	running        int
+	runningByRole  map[string]int
+	runningByOwner map[string]int
+	closed         bool
+	drained        chan struct{}
+}
+
+func NewRunQueue(cfg RunQueueConfig) *RunQueue {
+	mergedCfg := RunQueueConfig{
+		MaxRunning:         utils.GetOrDefault(cfg.MaxRunning, 10),
+		MaxRunningPerRole:  cfg.MaxRunningPerRole,
+		MaxRunningPerOwner: cfg.MaxRunningPerOwner,
+		MaxPending:         utils.GetOrDefault(cfg.MaxPending, 1000),
+	}
+	return &RunQueue{
+		cfg:            mergedCfg,
+		runningByRole:  make(map[string]int),
+		runningByOwner: make(map[string]int),
+		drained:        make(chan struct{}),
+	}
+}
+
+// Submit queues the run, run is called in a new goroutine once the request is admitted.
+func (q *RunQueue) Submit(req RunRequest, run func()) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		slog.Warn("RunQueue: Queue is closed, rejecting run", "agent_id", req.AgentID)
+		return ErrRunQueueClosed
+	}
+	if q.pending.Len() >= q.cfg.MaxPending {
+		slog.Warn("RunQueue: Queue is full, rejecting run", "agent_id", req.AgentID, "pending", q.pending.Len())
+		return ErrRunQueueFull
+	}
+	q.seq++
+	heap.Push(&q.pending, &pendingRun{req: req, run: run, seq: q.seq})
+	slog.Info("RunQueue: Queued run", "agent_id", req.AgentID, "priority", req.Priority, "pending", q.pending.Len())
+	q.dispatch()
+	return nil
+}
+
+// Close stops admitting runs, new submissions are rejected with ErrRunQueueClosed
+// and the pending runs are discarded. Runs already started keep running, see Drained.
+func (q *RunQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	discarded := make([]*pendingRun, 0, q.pending.Len())
+	for q.pending.Len() > 0 {
+		discarded = append(discarded, heap.Pop(&q.pending).(*pendingRun))
+	}
+	slog.Info("RunQueue: Closed", "running", q.running, "discarded", len(discarded))
+	q.checkDrained()
+	q.mu.Unlock()
+
+	// Discard callbacks may call back into the queue, so they run without the lock
+	for _, p := range discarded {
+		if p.req.OnDiscard != nil {
+			p.req.OnDiscard()
+		}
+	}
+}
+
+// Drained returns a channel that is closed once the queue is closed and all started runs have finished.
+func (q *RunQueue) Drained() <-chan struct{} {
+	return q.drained
+}
+
+func (q *RunQueue) GetStats() RunQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := RunQueueStats{
+		Pending:        q.pending.Len(),
+		Running:        q.running,
+		MaxRunning:     q.cfg.MaxRunning,
+		RunningByRole:  make(map[string]int, len(q.runningByRole)),
+		RunningByOwner: make(map[string]int, len(q.runningByOwner)),
+	}
+	for role, count := range q.runningByRole {
+		stats.RunningByRole[role] = count
+	}
+	for owner, count := range q.runningByOwner {
+		stats.RunningByOwner[owner] = count
+	}
+	return stats
+}
+
+// dispatch starts the pending runs in priority order while there are free slots, the caller must hold the lock
+func (q *RunQueue) dispatch() {
+	var blocked []*pendingRun
+	for q.pending.Len() > 0 && q.running < q.cfg.MaxRunning {
+		p := heap.Pop(&q.pending).(*pendingRun)
+		if !q.admits(p.req) {
+			blocked = append(blocked, p)
+			continue
+		}
+		q.start(p)
+	}
+	for _, p := range blocked {
+		heap.Push(&q.pending, p)
+	}
+}
+
+func (q *RunQueue) admits(req RunRequest) bool {
+	if limit, ok := q.cfg.MaxRunningPerRole[req.Role]; ok && q.runningByRole[req.Role] >= limit {
+		return false
+	}
+	if req.Owner != "" && q.cfg.MaxRunningPerOwner > 0 && q.runningByOwner[req.Owner] >= q.cfg.MaxRunningPerOwner {
+		return false
+	}
+	return true
+}
+
+func (q *RunQueue) start(p *pendingRun) {
+	q.running++
+	q.runningByRole[p.req.Role]++
+	if p.req.Owner != "" {
+		q.runningByOwner[p.req.Owner]++
+	}
+	slog.Info("RunQueue: Starting run", "agent_id", p.req.AgentID, "running", q.running, "pending", q.pending.Len())
+	go func() {
+		defer q.finish(p.req)
+		p.run()
+	}()
+}
+
+func (q *RunQueue) finish(req RunRequest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.runningByRole[req.Role]--
+	if q.runningByRole[req.Role] == 0 {
+		delete(q.runningByRole, req.Role)
+	}
+	if req.Owner != "" {
+		q.runningByOwner[req.Owner]--
+		if q.runningByOwner[req.Owner] == 0 {
+			delete(q.runningByOwner, req.Owner)
+		}
+	}
+	slog.Info("RunQueue: Finished run", "agent_id", req.AgentID, "running", q.running, "pending", q.pending.Len())
+	if q.closed {
+		q.checkDrained()
+		return
+	}
+	q.dispatch()
+}
+
+// checkDrained signals Drained once nothing runs after the queue is closed, the caller must hold the lock
+func (q *RunQueue) checkDrained() {
+	if q.running > 0 {
+		return
+	}
+	select {
+	case <-q.drained:
+	default:
+		close(q.drained)
+	}
+}
+
+type pendingRun struct {
+	req RunRequest
+	run func()
+	seq uint64
+}
+
+// pendingRuns implements heap.Interface, higher priority first and FIFO within the same priority
+type pendingRuns []*pendingRun
+
+func (p pendingRuns) Len() int { return len(p) }
+
+func (p pendingRuns) Less(i, j int) bool {
+	if p[i].req.Priority != p[j].req.Priority {
+		return p[i].req.Priority > p[j].req.Priority
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p pendingRuns) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *pendingRuns) Push(x any) {
+	*p = append(*p, x.(*pendingRun))
+}
+
+func (p *pendingRuns) Pop() any {
+	old := *p
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*p = old[:n-1]
+	return item
+}
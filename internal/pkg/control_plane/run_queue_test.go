@@ -0,0 +1,173 @@
+package control_plane_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/stretchr/testify/suite"
+)
+
+type RunQueueTestSuite struct {
+	suite.Suite
+}
+
+func TestRunQueue(t *testing.T) {
+	suite.Run(t, new(RunQueueTestSuite))
+}
+
+// blockingRun returns a run that signals when it starts and returns when release is closed
+func blockingRun(started chan<- string, agentID string, release <-chan struct{}) func() {
+	return func() {
+		started <- agentID
+		<-release
+	}
+}
+
+func (suite *RunQueueTestSuite) receive(started <-chan string) string {
+	select {
+	case agentID := <-started:
+		return agentID
+	case <-time.After(time.Second):
+		suite.FailNow("run did not start in time")
+		return ""
+	}
+}
+
+func (suite *RunQueueTestSuite) assertNotStarted(started <-chan string) {
+	select {
+	case agentID := <-started:
+		suite.FailNow("run started over the limit", agentID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (suite *RunQueueTestSuite) TestMaxRunning() {
+	queue := control_plane.NewRunQueue(control_plane.RunQueueConfig{MaxRunning: 2})
+	started := make(chan string, 3)
+	release := make(chan struct{})
+
+	for _, agentID := range []string{"agent-1", "agent-2", "agent-3"} {
+		req := control_plane.RunRequest{AgentID: agentID, Role: "consumer"}
+		suite.NoError(queue.Submit(req, blockingRun(started, agentID, release)))
+	}
+	suite.receive(started)
+	suite.receive(started)
+	suite.assertNotStarted(started)
+
+	stats := queue.GetStats()
+	suite.Equal(2, stats.Running)
+	suite.Equal(1, stats.Pending)
+	suite.Equal(2, stats.RunningByRole["consumer"])
+
+	close(release)
+	suite.Equal("agent-3", suite.receive(started))
+	suite.Eventually(func() bool {
+		return queue.GetStats().Running == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *RunQueueTestSuite) TestPriority() {
+	queue := control_plane.NewRunQueue(control_plane.RunQueueConfig{MaxRunning: 1})
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	defer close(release)
+
+	suite.NoError(queue.Submit(control_plane.RunRequest{AgentID: "running"}, func() {
+		started <- "running"
+		<-release
+	}))
+	suite.receive(started)
+
+	for _, req := range []control_plane.RunRequest{
+		{AgentID: "low", Priority: 0},
+		{AgentID: "high", Priority: 10},
+		{AgentID: "low-2", Priority: 0},
+	} {
+		agentID := req.AgentID
+		suite.NoError(queue.Submit(req, func() {
+			started <- agentID
+		}))
+	}
+
+	release <- struct{}{}
+	suite.Equal("high", suite.receive(started))
+	suite.Equal("low", suite.receive(started))
+	suite.Equal("low-2", suite.receive(started))
+}
+
+func (suite *RunQueueTestSuite) TestPerRoleAndOwnerLimits() {
+	queue := control_plane.NewRunQueue(control_plane.RunQueueConfig{
+		MaxRunning:         10,
+		MaxRunningPerRole:  map[string]int{"publisher": 1},
+		MaxRunningPerOwner: 1,
+	})
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	defer close(release)
+
+	requests := []control_plane.RunRequest{
+		{AgentID: "publisher-1", Role: "publisher"},
+		{AgentID: "publisher-2", Role: "publisher"},
+		{AgentID: "alice-1", Role: "consumer", Owner: "alice"},
+		{AgentID: "alice-2", Role: "consumer", Owner: "alice"},
+	}
+	for _, req := range requests {
+		suite.NoError(queue.Submit(req, blockingRun(started, req.AgentID, release)))
+	}
+
+	// The blocked publisher does not hold back the consumer of another owner
+	startedIDs := []string{suite.receive(started), suite.receive(started)}
+	suite.ElementsMatch([]string{"publisher-1", "alice-1"}, startedIDs)
+	suite.assertNotStarted(started)
+	suite.Equal(2, queue.GetStats().Pending)
+	suite.Equal(1, queue.GetStats().RunningByOwner["alice"])
+}
+
+func (suite *RunQueueTestSuite) TestMaxPending() {
+	queue := control_plane.NewRunQueue(control_plane.RunQueueConfig{MaxRunning: 1, MaxPending: 1})
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	defer close(release)
+
+	suite.NoError(queue.Submit(control_plane.RunRequest{AgentID: "agent-1"}, blockingRun(started, "agent-1", release)))
+	suite.receive(started)
+	suite.NoError(queue.Submit(control_plane.RunRequest{AgentID: "agent-2"}, blockingRun(started, "agent-2", release)))
+
+	err := queue.Submit(control_plane.RunRequest{AgentID: "agent-3"}, blockingRun(started, "agent-3", release))
+	suite.ErrorIs(err, control_plane.ErrRunQueueFull)
+}
+
+func (suite *RunQueueTestSuite) TestClose() {
+	queue := control_plane.NewRunQueue(control_plane.RunQueueConfig{MaxRunning: 1})
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	discarded := make(chan string, 1)
+
+	suite.NoError(queue.Submit(control_plane.RunRequest{AgentID: "agent-1"}, blockingRun(started, "agent-1", release)))
+	suite.receive(started)
+	pending := control_plane.RunRequest{
+		AgentID:   "agent-2",
+		OnDiscard: func() { discarded <- "agent-2" },
+	}
+	suite.NoError(queue.Submit(pending, blockingRun(started, "agent-2", release)))
+
+	queue.Close()
+	suite.Equal("agent-2", <-discarded)
+	err := queue.Submit(control_plane.RunRequest{AgentID: "agent-3"}, blockingRun(started, "agent-3", release))
+	suite.ErrorIs(err, control_plane.ErrRunQueueClosed)
+
+	// The started run keeps running until it returns
+	select {
+	case <-queue.Drained():
+		suite.FailNow("queue drained with a run in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-queue.Drained():
+	case <-time.After(time.Second):
+		suite.FailNow("queue did not drain in time")
+	}
+	suite.assertNotStarted(started)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/roackb2/lucid/internal/pkg/agents/worker"
 	"github.com/roackb2/lucid/internal/pkg/dbaccess"
 	"github.com/roackb2/lucid/internal/pkg/utils"
@@ -17,15 +19,77 @@ const (
 	AgentSleepDuration     = 10 * time.Second
 	AgentAwakeDuration     = 5 * time.Minute
 	BatchProcessAgentNum   = 10
+	// AgentClaimDuration is how long a claimed agent is hidden from the schedulers of all nodes,
+	// the claim is released earlier when the resumed agent sleeps or terminates.
+	AgentClaimDuration = 1 * time.Minute
+	// AgentMaxBackoffDuration caps the sleep interval of the backoff wake policy
+	AgentMaxBackoffDuration = 10 * time.Minute
+	// AgentMaxEventWaitDuration is how long the event wake policy waits for a matching post before waking the agent anyway
+	AgentMaxEventWaitDuration = 1 * time.Hour
 )
 
+type SchedulerConfig struct {
+	// NodeID identifies this node in the claims it makes, defaults to DefaultNodeID()
+	NodeID        string
+	ScanInterval  time.Duration
+	BatchSize     int
+	AwakeDuration time.Duration
+	// DefaultWakePolicy is used for tasks that do not choose a wake policy
+	DefaultWakePolicy string
+	// Intervals of the built-in wake policies
+	SleepDuration        time.Duration
+	MaxBackoffDuration   time.Duration
+	MaxEventWaitDuration time.Duration
+	// WakePolicies are registered alongside the built-in policies, replacing a built-in policy of the same name
+	WakePolicies []WakePolicy
+}
+
 type SchedulerImpl struct {
+	cfg          SchedulerConfig
+	wakePolicies map[string]WakePolicy
 	controlCh    chan string
 	onAgentFound OnAgentFoundCallback
 }
 
-func NewScheduler(ctx context.Context, onAgentFound OnAgentFoundCallback) *SchedulerImpl {
+func NewScheduler(ctx context.Context, cfg SchedulerConfig, onAgentFound OnAgentFoundCallback) *SchedulerImpl {
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID = DefaultNodeID()
+	}
+	defaultWakePolicy := cfg.DefaultWakePolicy
+	if defaultWakePolicy == "" {
+		defaultWakePolicy = WakePolicyFixed
+	}
+	mergedCfg := SchedulerConfig{
+		NodeID:               nodeID,
+		ScanInterval:         utils.GetOrDefault(cfg.ScanInterval, ScanInterval),
+		BatchSize:            utils.GetOrDefault(cfg.BatchSize, BatchProcessAgentNum),
+		AwakeDuration:        utils.GetOrDefault(cfg.AwakeDuration, AgentAwakeDuration),
+		DefaultWakePolicy:    defaultWakePolicy,
+		SleepDuration:        utils.GetOrDefault(cfg.SleepDuration, AgentSleepDuration),
+		MaxBackoffDuration:   utils.GetOrDefault(cfg.MaxBackoffDuration, AgentMaxBackoffDuration),
+		MaxEventWaitDuration: utils.GetOrDefault(cfg.MaxEventWaitDuration, AgentMaxEventWaitDuration),
+		WakePolicies:         cfg.WakePolicies,
+	}
+
+	wakePolicies := map[string]WakePolicy{}
+	builtinPolicies := []WakePolicy{
+		&FixedIntervalPolicy{Interval: mergedCfg.SleepDuration},
+		&BackoffPolicy{BaseInterval: mergedCfg.SleepDuration, MaxInterval: mergedCfg.MaxBackoffDuration},
+		&EventPolicy{MaxInterval: mergedCfg.MaxEventWaitDuration},
+		&RoundRobinPolicy{Interval: mergedCfg.SleepDuration},
+	}
+	for _, policy := range append(builtinPolicies, cfg.WakePolicies...) {
+		wakePolicies[policy.Name()] = policy
+	}
+	if _, ok := wakePolicies[mergedCfg.DefaultWakePolicy]; !ok {
+		slog.Warn("Scheduler: Unknown default wake policy, using fixed", "wake_policy", mergedCfg.DefaultWakePolicy)
+		mergedCfg.DefaultWakePolicy = WakePolicyFixed
+	}
+
 	return &SchedulerImpl{
+		cfg:          mergedCfg,
+		wakePolicies: wakePolicies,
 		controlCh:    make(chan string, SchedulerControlChSize),
 		onAgentFound: onAgentFound,
 	}
@@ -38,6 +102,10 @@ func (s *SchedulerImpl) SetCallback(callback OnAgentFoundCallback) {
 	s.onAgentFound = callback
 }
 
+func (s *SchedulerImpl) GetNodeID() string {
+	return s.cfg.NodeID
+}
+
 func (s *SchedulerImpl) SendCommand(ctx context.Context, cmd string) error {
 	if s.controlCh == nil {
 		slog.Error("Scheduler: Control channel not initialized")
@@ -56,7 +124,7 @@ func (s *SchedulerImpl) SendCommand(ctx context.Context, cmd string) error {
 
 func (s *SchedulerImpl) Start(ctx context.Context) error {
 	slog.Info("Scheduler started")
-	ticker := time.NewTicker(ScanInterval)
+	ticker := time.NewTicker(s.cfg.ScanInterval)
 	defer ticker.Stop()
 
 	for {
@@ -89,33 +157,14 @@ func (s *SchedulerImpl) Start(ctx context.Context) error {
 }
 
 func (s *SchedulerImpl) searchAgents(ctx context.Context) error {
-	asleepParams := dbaccess.SearchAgentByAsleepDurationAndStatusParams{
-		Duration:  utils.ConvertToPgInterval(AgentSleepDuration),
-		Statuses:  []string{worker.StatusAsleep},
-		MaxAgents: BatchProcessAgentNum,
-	}
-	asleepAgents, err := dbaccess.Querier.SearchAgentByAsleepDurationAndStatus(ctx, asleepParams)
+	agents, err := s.claimAgents(ctx)
 	if err != nil {
-		slog.Error("Scheduler failed to search agents", "error", err)
+		slog.Error("Scheduler failed to claim agents", "error", err)
 		return err
 	}
-	slog.Info("Scheduler found asleep agents", "num_agents", len(asleepAgents))
 
-	// Search for agents that is running but has been awake for a while,
-	// probably means they're orphans with no controller
-	awakenParams := dbaccess.SearchAgentByAwakeDurationAndStatusParams{
-		Duration:  utils.ConvertToPgInterval(AgentAwakeDuration),
-		Statuses:  []string{worker.StatusRunning},
-		MaxAgents: BatchProcessAgentNum,
-	}
-	awakenedAgents, err := dbaccess.Querier.SearchAgentByAwakeDurationAndStatus(ctx, awakenParams)
-	if err != nil {
-		slog.Error("Scheduler failed to search agents", "error", err)
-		return err
-	}
-	slog.Info("Scheduler found awakened agents", "num_agents", len(awakenedAgents))
-
-	for _, agent := range append(asleepAgents, awakenedAgents...) {
+	// Callbacks run after the claim is committed, so no other scheduler can pick the same agents
+	for _, agent := range agents {
 		slog.Info("Scheduler handling agent", "agent_id", agent.AgentID)
 		if s.onAgentFound == nil {
 			slog.Warn("Scheduler: No callback set, skipping agent", "agent_id", agent.AgentID)
@@ -126,3 +175,121 @@ func (s *SchedulerImpl) searchAgents(ctx context.Context) error {
 
 	return nil
 }
+
+// claimAgents locks the agents that should be resumed and marks them as claimed by this node in one transaction.
+// Rows locked or claimed by another scheduler are skipped.
+// Failed agents are never claimed, they wait for the retry command, and agents awaiting input or approval wait for the user.
+func (s *SchedulerImpl) claimAgents(ctx context.Context) ([]dbaccess.AgentState, error) {
+	var agents []dbaccess.AgentState
+	err := dbaccess.WithTx(ctx, func(q *dbaccess.Queries) error {
+		// Agents that fell asleep before wake policies were recorded have no wake_at, and wake after the sleep duration
+		asleepParams := dbaccess.SearchClaimableAsleepAgentsParams{
+			Statuses:  []string{worker.StatusAsleep},
+			Duration:  utils.ConvertToPgInterval(s.cfg.SleepDuration),
+			MaxAgents: int32(s.cfg.BatchSize),
+		}
+		asleepAgents, err := q.SearchClaimableAsleepAgents(ctx, asleepParams)
+		if err != nil {
+			return err
+		}
+		slog.Info("Scheduler found asleep agents", "num_agents", len(asleepAgents))
+
+		// Search for agents that are running but whose node stopped renewing their lease,
+		// or that have no lease and have been awake for a while, probably means they're orphans with no controller
+		orphanedParams := dbaccess.SearchClaimableOrphanedAgentsParams{
+			Statuses:  []string{worker.StatusRunning},
+			Duration:  utils.ConvertToPgInterval(s.cfg.AwakeDuration),
+			MaxAgents: int32(s.cfg.BatchSize),
+		}
+		awakenedAgents, err := q.SearchClaimableOrphanedAgents(ctx, orphanedParams)
+		if err != nil {
+			return err
+		}
+		slog.Info("Scheduler found awakened agents", "num_agents", len(awakenedAgents))
+
+		found := append(asleepAgents, awakenedAgents...)
+		if len(found) == 0 {
+			return nil
+		}
+		agentIDs := make([]string, 0, len(found))
+		for _, agent := range found {
+			agentIDs = append(agentIDs, agent.AgentID)
+		}
+		claimedIDs, err := q.ClaimAgents(ctx, dbaccess.ClaimAgentsParams{
+			NodeID:        pgtype.Text{String: s.cfg.NodeID, Valid: true},
+			ClaimDuration: utils.ConvertToPgInterval(AgentClaimDuration),
+			AgentIds:      agentIDs,
+			Statuses:      []string{worker.StatusAsleep, worker.StatusRunning},
+		})
+		if err != nil {
+			return err
+		}
+		// Only the agents this node claimed are resumed, another node resumes the others
+		for _, agent := range found {
+			if slices.Contains(claimedIDs, agent.AgentID) {
+				agents = append(agents, agent)
+			}
+		}
+		if len(claimedIDs) < len(found) {
+			slog.Warn("Scheduler skipped agents claimed by another node", "num_found", len(found), "num_claimed", len(claimedIDs))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+// ScheduleWake records the wake policy of the agent and the next time it is due to wake.
+// A round is idle when no posts were created since the agent's previous round ended.
+func (s *SchedulerImpl) ScheduleWake(ctx context.Context, agentID string, metadata worker.TaskMetadata) error {
+	policy := s.getWakePolicy(metadata.WakePolicy)
+	agentState, err := dbaccess.Querier.GetAgentState(ctx, agentID)
+	if err != nil {
+		slog.Error("Scheduler: Failed to get agent state", "agent_id", agentID, "error", err)
+		return err
+	}
+
+	since := agentState.CreatedAt
+	if agentState.WakeScheduledAt.Valid {
+		since = agentState.WakeScheduledAt
+	}
+	newPosts, err := dbaccess.Querier.CountPostsSince(ctx, since)
+	if err != nil {
+		slog.Error("Scheduler: Failed to count new posts", "agent_id", agentID, "error", err)
+		return err
+	}
+	idleRounds := 0
+	if newPosts == 0 {
+		idleRounds = int(agentState.IdleRounds) + 1
+	}
+
+	asleepAt := time.Now()
+	if agentState.AsleepAt.Valid {
+		asleepAt = agentState.AsleepAt.Time
+	}
+	wakeAt := policy.NextWakeAt(WakeState{
+		AgentID:    agentID,
+		Owner:      metadata.Owner,
+		AsleepAt:   asleepAt,
+		IdleRounds: idleRounds,
+	})
+	slog.Info("Scheduler: Scheduled agent wake", "agent_id", agentID, "wake_policy", policy.Name(), "wake_at", wakeAt, "idle_rounds", idleRounds)
+	return dbaccess.Querier.UpdateAgentWake(ctx, dbaccess.UpdateAgentWakeParams{
+		AgentID:    agentID,
+		WakePolicy: policy.Name(),
+		WakeAt:     utils.ConvertToPgTimestamp(wakeAt),
+		IdleRounds: int32(idleRounds),
+	})
+}
+
+func (s *SchedulerImpl) getWakePolicy(name string) WakePolicy {
+	if policy, ok := s.wakePolicies[name]; ok {
+		return policy
+	}
+	if name != "" {
+		slog.Warn("Scheduler: Unknown wake policy, using default", "wake_policy", name, "default", s.cfg.DefaultWakePolicy)
+	}
+	return s.wakePolicies[s.cfg.DefaultWakePolicy]
+}
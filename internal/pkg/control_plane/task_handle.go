@@ -0,0 +1,83 @@
+package control_plane
+
+import (
+	"context"
+	"errors"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/agent"
+)
+
+// ErrAgentAsleep is the result of a task whose agent was put to sleep before reporting.
+// The task continues when the scheduler wakes the agent up, possibly on another node.
+var ErrAgentAsleep = errors.New("agent was put to sleep before finishing the task")
+
+// ErrAgentBusy is returned when resuming an agent that is running or paused.
+var ErrAgentBusy = errors.New("agent is running or paused")
+
+// ErrAgentFailed is returned when resuming an agent that failed, it must be retried with the retry command first.
+var ErrAgentFailed = errors.New("agent failed")
+
+// ErrAgentAwaitingInput is the result of a task whose agent asked the user a question, the response holds the question.
+// It is also returned when resuming such an agent with a new task, it must be answered with AnswerAgent first.
+var ErrAgentAwaitingInput = errors.New("agent is awaiting the user's answer")
+
+// ErrAgentNotAwaitingInput is returned when answering an agent that did not ask the user a question.
+var ErrAgentNotAwaitingInput = errors.New("agent is not awaiting input")
+
+// ErrAgentAwaitingApproval is the result of a task whose agent parked a tool call for the user's approval.
+// It is also returned when resuming such an agent with a new task, the user must decide on the call first.
+var ErrAgentAwaitingApproval = errors.New("agent is awaiting the user's approval of a tool call")
+
+// TaskResult is the outcome of an agent task.
+type TaskResult struct {
+	Response *agent.AgentResponse
+	Err      error
+}
+
+// TaskHandle lets the caller of KickoffTask await the result of the agent it started.
+// The result is set once, and can be read by any number of callers.
+type TaskHandle struct {
+	agentID string
+	done    chan struct{}
+	result  TaskResult
+}
+
+func newTaskHandle(agentID string) *TaskHandle {
+	return &TaskHandle{
+		agentID: agentID,
+		done:    make(chan struct{}),
+	}
+}
+
+// AgentID returns the ID of the agent running the task.
+func (h *TaskHandle) AgentID() string {
+	return h.agentID
+}
+
+// Done returns a channel that is closed once the result is available.
+func (h *TaskHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Result returns the task result, it is only valid after Done is closed.
+func (h *TaskHandle) Result() TaskResult {
+	return h.result
+}
+
+// Wait blocks until the task result is available or the context is done.
+func (h *TaskHandle) Wait(ctx context.Context) (*agent.AgentResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-h.done:
+		return h.result.Response, h.result.Err
+	}
+}
+
+func (h *TaskHandle) resolve(response *agent.AgentResponse, err error) {
+	h.result = TaskResult{
+		Response: response,
+		Err:      err,
+	}
+	close(h.done)
+}
@@ -0,0 +1,497 @@
+package control_plane
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/cron"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+const (
+	TaskScanInterval        = 10 * time.Second
+	BatchProcessScheduleNum = 10
+	// ScheduleMissedRunGrace is how late a run may fire before it counts as missed, e.g. after downtime
+	ScheduleMissedRunGrace = 1 * time.Minute
+	// ScheduleResultTimeout bounds how long a firing waits for its agent's result
+	ScheduleResultTimeout = 1 * time.Hour
+	// ScheduleLabel is the task label holding the ID of the schedule that started the agent
+	ScheduleLabel = "schedule_id"
+	// skippedRunNote starts the line of the last result recording a skipped run
+	skippedRunNote = "Skipped the run due at "
+)
+
+const (
+	ScheduleStatusActive = "active"
+	ScheduleStatusPaused = "paused"
+)
+
+// Missed run policies decide what happens to runs that were due while no node was scanning schedules.
+const (
+	// MissedRunPolicyRunOnce fires once for all the missed runs, then continues with the schedule
+	MissedRunPolicyRunOnce = "run_once"
+	// MissedRunPolicySkip drops the missed runs and waits for the next scheduled run
+	MissedRunPolicySkip = "skip"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// ScheduleDefinition describes a recurring task.
+// Exactly one of CronExpression and Interval must be set.
+type ScheduleDefinition struct {
+	Owner string
+	Role  string
+	// TaskTemplate is a text/template rendered on each run, see ScheduleTaskData for the available fields
+	TaskTemplate   string
+	CronExpression string
+	Interval       time.Duration
+	// TimeZone is the IANA location the cron expression is evaluated in, defaults to UTC
+	TimeZone string
+	// MissedRunPolicy defaults to MissedRunPolicyRunOnce
+	MissedRunPolicy string
+}
+
+// ScheduleTaskData is the data the task template of a schedule is rendered with.
+type ScheduleTaskData struct {
+	ScheduleID string
+	Owner      string
+	RunAt      time.Time
+	LastRunAt  *time.Time
+}
+
+// AgentSchedule is a stored schedule and the state of its runs.
+type AgentSchedule struct {
+	ScheduleDefinition
+	ScheduleID string
+	Status     string
+	// AgentID is the agent created by the first run, later runs resume it
+	AgentID    string
+	LastResult string
+	NextRunAt  time.Time
+	LastRunAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// SkippedRunResult returns the last result recording that the run due at the time was skipped.
+// The result of the previous run is kept after the note, and replaces any older note, so the next run still has it.
+func (s *AgentSchedule) SkippedRunResult(dueAt time.Time, reason string) string {
+	lines := strings.Split(s.LastResult, "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], skippedRunNote) {
+		lines = lines[1:]
+	}
+	note := fmt.Sprintf("%s%s: %s", skippedRunNote, dueAt.UTC().Format(time.RFC3339), reason)
+	previous := strings.TrimSpace(strings.Join(lines, "\n"))
+	if previous == "" {
+		return note
+	}
+	return note + "\n\n" + previous
+}
+
+// Validate checks the definition and fills in its defaults.
+func (d *ScheduleDefinition) Validate() error {
+	if d.Role == "" {
+		return fmt.Errorf("%w: role is required", ErrInvalidSchedule)
+	}
+	if _, err := template.New("task").Parse(d.TaskTemplate); err != nil || strings.TrimSpace(d.TaskTemplate) == "" {
+		return fmt.Errorf("%w: task template is empty or malformed", ErrInvalidSchedule)
+	}
+	if (d.CronExpression == "") == (d.Interval == 0) {
+		return fmt.Errorf("%w: exactly one of cron expression and interval is required", ErrInvalidSchedule)
+	}
+	if d.Interval < 0 {
+		return fmt.Errorf("%w: interval must be positive", ErrInvalidSchedule)
+	}
+	if d.CronExpression != "" {
+		if _, err := cron.Parse(d.CronExpression); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+	}
+	if d.TimeZone == "" {
+		d.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(d.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, d.TimeZone)
+	}
+	switch d.MissedRunPolicy {
+	case "":
+		d.MissedRunPolicy = MissedRunPolicyRunOnce
+	case MissedRunPolicyRunOnce, MissedRunPolicySkip:
+	default:
+		return fmt.Errorf("%w: unknown missed run policy %q", ErrInvalidSchedule, d.MissedRunPolicy)
+	}
+	return nil
+}
+
+// NextRun returns the first run of the schedule after the given time, in UTC.
+func (d *ScheduleDefinition) NextRun(after time.Time) (time.Time, error) {
+	if d.Interval > 0 {
+		return after.Add(d.Interval).UTC(), nil
+	}
+	schedule, err := cron.Parse(d.CronExpression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never matches", d.CronExpression)
+	}
+	return next.UTC(), nil
+}
+
+type TaskSchedulerConfig struct {
+	ScanInterval   time.Duration
+	BatchSize      int
+	MissedRunGrace time.Duration
+	ResultTimeout  time.Duration
+}
+
+type TaskSchedulerImpl struct {
+	cfg          TaskSchedulerConfig
+	controlPlane ControlPlane
+}
+
+func NewTaskScheduler(cfg TaskSchedulerConfig, controlPlane ControlPlane) *TaskSchedulerImpl {
+	mergedCfg := TaskSchedulerConfig{
+		ScanInterval:   utils.GetOrDefault(cfg.ScanInterval, TaskScanInterval),
+		BatchSize:      utils.GetOrDefault(cfg.BatchSize, BatchProcessScheduleNum),
+		MissedRunGrace: utils.GetOrDefault(cfg.MissedRunGrace, ScheduleMissedRunGrace),
+		ResultTimeout:  utils.GetOrDefault(cfg.ResultTimeout, ScheduleResultTimeout),
+	}
+	return &TaskSchedulerImpl{
+		cfg:          mergedCfg,
+		controlPlane: controlPlane,
+	}
+}
+
+func (s *TaskSchedulerImpl) Start(ctx context.Context) error {
+	slog.Info("TaskScheduler: Starting")
+	ticker := time.NewTicker(s.cfg.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("TaskScheduler: Stopping")
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.runDueSchedules(ctx); err != nil {
+				// A failed scan is retried on the next tick
+				slog.Error("TaskScheduler: Failed to run due schedules", "error", err)
+			}
+		}
+	}
+}
+
+func (s *TaskSchedulerImpl) CreateSchedule(ctx context.Context, definition ScheduleDefinition) (*AgentSchedule, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	nextRunAt, err := definition.NextRun(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	var interval pgtype.Interval
+	if definition.Interval > 0 {
+		interval = utils.ConvertToPgInterval(definition.Interval)
+	}
+	schedule, err := dbaccess.Querier.CreateAgentSchedule(ctx, dbaccess.CreateAgentScheduleParams{
+		ScheduleID:      uuid.New().String(),
+		Owner:           definition.Owner,
+		Role:            definition.Role,
+		TaskTemplate:    definition.TaskTemplate,
+		CronExpression:  definition.CronExpression,
+		RunInterval:     interval,
+		TimeZone:        definition.TimeZone,
+		MissedRunPolicy: definition.MissedRunPolicy,
+		Status:          ScheduleStatusActive,
+		NextRunAt:       utils.ConvertToPgTimestamp(&nextRunAt),
+	})
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to create schedule", "error", err)
+		return nil, err
+	}
+	slog.Info("TaskScheduler: Created schedule", "schedule_id", schedule.ScheduleID, "next_run_at", nextRunAt)
+	return toAgentSchedule(schedule), nil
+}
+
+// ListSchedules returns the schedules of the owner, or of all owners if owner is empty.
+func (s *TaskSchedulerImpl) ListSchedules(ctx context.Context, owner string) ([]AgentSchedule, error) {
+	rows, err := dbaccess.Querier.ListAgentSchedules(ctx, owner)
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to list schedules", "error", err)
+		return nil, err
+	}
+	schedules := make([]AgentSchedule, 0, len(rows))
+	for _, row := range rows {
+		schedules = append(schedules, *toAgentSchedule(row))
+	}
+	return schedules, nil
+}
+
+func (s *TaskSchedulerImpl) PauseSchedule(ctx context.Context, scheduleID string) (*AgentSchedule, error) {
+	schedule, err := s.getSchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateStatus(ctx, scheduleID, ScheduleStatusPaused, schedule.NextRunAt)
+}
+
+// ResumeSchedule reactivates a paused schedule from its next run after now,
+// runs missed while the schedule was paused are skipped.
+func (s *TaskSchedulerImpl) ResumeSchedule(ctx context.Context, scheduleID string) (*AgentSchedule, error) {
+	schedule, err := s.getSchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	nextRunAt, err := schedule.NextRun(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.updateStatus(ctx, scheduleID, ScheduleStatusActive, nextRunAt)
+}
+
+func (s *TaskSchedulerImpl) getSchedule(ctx context.Context, scheduleID string) (*AgentSchedule, error) {
+	schedule, err := dbaccess.Querier.GetAgentSchedule(ctx, scheduleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to get schedule", "schedule_id", scheduleID, "error", err)
+		return nil, err
+	}
+	return toAgentSchedule(schedule), nil
+}
+
+func (s *TaskSchedulerImpl) updateStatus(ctx context.Context, scheduleID string, status string, nextRunAt time.Time) (*AgentSchedule, error) {
+	schedule, err := dbaccess.Querier.UpdateAgentScheduleStatus(ctx, dbaccess.UpdateAgentScheduleStatusParams{
+		Status:     status,
+		NextRunAt:  utils.ConvertToPgTimestamp(&nextRunAt),
+		ScheduleID: scheduleID,
+	})
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to update schedule status", "schedule_id", scheduleID, "status", status, "error", err)
+		return nil, err
+	}
+	slog.Info("TaskScheduler: Updated schedule status", "schedule_id", scheduleID, "status", status)
+	return toAgentSchedule(schedule), nil
+}
+
+// runDueSchedules advances the due schedules to their next run in one transaction, so each run fires on one node only,
+// then fires the runs after the transaction commits.
+func (s *TaskSchedulerImpl) runDueSchedules(ctx context.Context) error {
+	now := time.Now().UTC()
+	var due []*AgentSchedule
+	err := dbaccess.WithTx(ctx, func(q *dbaccess.Queries) error {
+		rows, err := q.SearchDueAgentSchedules(ctx, dbaccess.SearchDueAgentSchedulesParams{
+			Status:       ScheduleStatusActive,
+			Now:          utils.ConvertToPgTimestamp(&now),
+			MaxSchedules: int32(s.cfg.BatchSize),
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			schedule := toAgentSchedule(row)
+			nextRunAt, err := schedule.NextRun(now)
+			if err != nil {
+				slog.Error("TaskScheduler: Failed to compute next run, pausing schedule", "schedule_id", schedule.ScheduleID, "error", err)
+				_, err = q.UpdateAgentScheduleStatus(ctx, dbaccess.UpdateAgentScheduleStatusParams{
+					Status:     ScheduleStatusPaused,
+					NextRunAt:  row.NextRunAt,
+					ScheduleID: schedule.ScheduleID,
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			// Runs missed during downtime, or postponed while the agent was busy, collapse into this one,
+			// unless the schedule skips them
+			shouldRun := true
+			lastRunAt := &now
+			missed := now.Sub(schedule.NextRunAt) > s.cfg.MissedRunGrace
+			if missed && schedule.MissedRunPolicy == MissedRunPolicySkip {
+				slog.Info("TaskScheduler: Skipping missed run", "schedule_id", schedule.ScheduleID, "missed_run_at", schedule.NextRunAt)
+				shouldRun = false
+				lastRunAt = schedule.LastRunAt
+				reason := fmt.Sprintf("it could not fire within %s of its due time", s.cfg.MissedRunGrace)
+				err = q.UpdateAgentScheduleResult(ctx, dbaccess.UpdateAgentScheduleResultParams{
+					LastResult: pgtype.Text{String: schedule.SkippedRunResult(schedule.NextRunAt, reason), Valid: true},
+					ScheduleID: schedule.ScheduleID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			err = q.UpdateAgentScheduleRun(ctx, dbaccess.UpdateAgentScheduleRunParams{
+				NextRunAt:  utils.ConvertToPgTimestamp(&nextRunAt),
+				LastRunAt:  utils.ConvertToPgTimestamp(lastRunAt),
+				ScheduleID: schedule.ScheduleID,
+			})
+			if err != nil {
+				return err
+			}
+			if shouldRun {
+				due = append(due, schedule)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range due {
+		s.fire(ctx, schedule, now)
+	}
+	return nil
+}
+
+// fire resumes the agent of the schedule with the rendered task and its previous result,
+// or starts a new agent on the first run.
+// A run the agent or the node cannot take yet is postponed rather than lost.
+func (s *TaskSchedulerImpl) fire(ctx context.Context, schedule *AgentSchedule, runAt time.Time) {
+	slog.Info("TaskScheduler: Firing schedule", "schedule_id", schedule.ScheduleID, "agent_id", schedule.AgentID)
+	prompt, err := renderScheduleTask(schedule, runAt)
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to render task", "schedule_id", schedule.ScheduleID, "error", err)
+		return
+	}
+
+	if schedule.AgentID != "" {
+		handle, err := s.controlPlane.ResumeTask(ctx, schedule.AgentID, prompt)
+		if errors.Is(err, ErrAgentBusy) || errors.Is(err, ErrRunQueueFull) {
+			s.postponeRun(ctx, schedule, err)
+			return
+		}
+		if err != nil {
+			slog.Error("TaskScheduler: Failed to resume agent", "schedule_id", schedule.ScheduleID, "agent_id", schedule.AgentID, "error", err)
+			return
+		}
+		go s.saveResult(ctx, schedule.ScheduleID, handle)
+		return
+	}
+
+	metadata := worker.TaskMetadata{
+		Owner:  schedule.Owner,
+		Labels: map[string]string{ScheduleLabel: schedule.ScheduleID},
+	}
+	agentID, handle, err := s.controlPlane.KickoffTask(ctx, prompt, schedule.Role, metadata)
+	if errors.Is(err, ErrRunQueueFull) {
+		s.postponeRun(ctx, schedule, err)
+		return
+	}
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to kickoff task", "schedule_id", schedule.ScheduleID, "error", err)
+		return
+	}
+	err = dbaccess.Querier.UpdateAgentScheduleAgent(ctx, dbaccess.UpdateAgentScheduleAgentParams{
+		AgentID:    pgtype.Text{String: agentID, Valid: true},
+		ScheduleID: schedule.ScheduleID,
+	})
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to save schedule agent", "schedule_id", schedule.ScheduleID, "agent_id", agentID, "error", err)
+	}
+	go s.saveResult(ctx, schedule.ScheduleID, handle)
+}
+
+// postponeRun moves the run back to its due time, so the next scan fires it again.
+// Once it is later than the missed run grace, the missed run policy of the schedule applies to it.
+func (s *TaskSchedulerImpl) postponeRun(ctx context.Context, schedule *AgentSchedule, reason error) {
+	slog.Warn("TaskScheduler: Postponing run", "schedule_id", schedule.ScheduleID, "agent_id", schedule.AgentID, "due_at", schedule.NextRunAt, "reason", reason)
+	err := dbaccess.Querier.UpdateAgentScheduleRun(ctx, dbaccess.UpdateAgentScheduleRunParams{
+		NextRunAt:  utils.ConvertToPgTimestamp(&schedule.NextRunAt),
+		LastRunAt:  utils.ConvertToPgTimestamp(schedule.LastRunAt),
+		ScheduleID: schedule.ScheduleID,
+	})
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to postpone run", "schedule_id", schedule.ScheduleID, "error", err)
+	}
+}
+
+// saveResult stores the final response of a run, so the next run has it in context
+func (s *TaskSchedulerImpl) saveResult(ctx context.Context, scheduleID string, handle *TaskHandle) {
+	waitCtx, cancel := context.WithTimeout(ctx, s.cfg.ResultTimeout)
+	defer cancel()
+	resp, err := handle.Wait(waitCtx)
+	if err != nil {
+		slog.Warn("TaskScheduler: Run finished without a result", "schedule_id", scheduleID, "agent_id", handle.AgentID(), "error", err)
+		return
+	}
+	err = dbaccess.Querier.UpdateAgentScheduleResult(ctx, dbaccess.UpdateAgentScheduleResultParams{
+		LastResult: pgtype.Text{String: resp.Message, Valid: true},
+		ScheduleID: scheduleID,
+	})
+	if err != nil {
+		slog.Error("TaskScheduler: Failed to save run result", "schedule_id", scheduleID, "error", err)
+	}
+}
+
+func renderScheduleTask(schedule *AgentSchedule, runAt time.Time) (string, error) {
+	tmpl, err := template.New("task").Parse(schedule.TaskTemplate)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	data := ScheduleTaskData{
+		ScheduleID: schedule.ScheduleID,
+		Owner:      schedule.Owner,
+		RunAt:      runAt.In(loc),
+		LastRunAt:  schedule.LastRunAt,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	if schedule.LastResult != "" {
+		fmt.Fprintf(&buf, "\n\n## Previous Result\n\n%s\n", schedule.LastResult)
+	}
+	return buf.String(), nil
+}
+
+func toAgentSchedule(row dbaccess.AgentSchedule) *AgentSchedule {
+	schedule := &AgentSchedule{
+		ScheduleDefinition: ScheduleDefinition{
+			Owner:           row.Owner,
+			Role:            row.Role,
+			TaskTemplate:    row.TaskTemplate,
+			CronExpression:  row.CronExpression,
+			Interval:        utils.ConvertFromPgInterval(row.RunInterval),
+			TimeZone:        row.TimeZone,
+			MissedRunPolicy: row.MissedRunPolicy,
+		},
+		ScheduleID: row.ScheduleID,
+		Status:     row.Status,
+		AgentID:    row.AgentID.String,
+		LastResult: row.LastResult.String,
+		NextRunAt:  row.NextRunAt.Time,
+		CreatedAt:  row.CreatedAt.Time,
+	}
+	if row.LastRunAt.Valid {
+		lastRunAt := row.LastRunAt.Time
+		schedule.LastRunAt = &lastRunAt
+	}
+	return schedule
+}
@@ -0,0 +1,67 @@
+package control_plane_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleDefinitionValidate(t *testing.T) {
+	definition := control_plane.ScheduleDefinition{
+		Role:           "consumer",
+		TaskTemplate:   "Summarize new posts about {{.Owner}}",
+		CronExpression: "0 9 * * *",
+	}
+	require.NoError(t, definition.Validate())
+	assert.Equal(t, "UTC", definition.TimeZone)
+	assert.Equal(t, control_plane.MissedRunPolicyRunOnce, definition.MissedRunPolicy)
+
+	invalid := []control_plane.ScheduleDefinition{
+		{TaskTemplate: "task", Interval: time.Hour},
+		{Role: "consumer", Interval: time.Hour},
+		{Role: "consumer", TaskTemplate: "{{.Owner", Interval: time.Hour},
+		{Role: "consumer", TaskTemplate: "task"},
+		{Role: "consumer", TaskTemplate: "task", Interval: time.Hour, CronExpression: "@daily"},
+		{Role: "consumer", TaskTemplate: "task", CronExpression: "0 25 * * *"},
+		{Role: "consumer", TaskTemplate: "task", Interval: time.Hour, TimeZone: "Mars/Olympus"},
+		{Role: "consumer", TaskTemplate: "task", Interval: time.Hour, MissedRunPolicy: "catch_up"},
+	}
+	for _, definition := range invalid {
+		assert.ErrorIs(t, definition.Validate(), control_plane.ErrInvalidSchedule, "%+v", definition)
+	}
+}
+
+func TestScheduleDefinitionNextRun(t *testing.T) {
+	after := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	definition := control_plane.ScheduleDefinition{Interval: 6 * time.Hour}
+	next, err := definition.NextRun(after)
+	require.NoError(t, err)
+	assert.Equal(t, after.Add(6*time.Hour), next)
+
+	// 09:00 in Taipei is 01:00 UTC
+	definition = control_plane.ScheduleDefinition{CronExpression: "0 9 * * *", TimeZone: "Asia/Taipei"}
+	next, err = definition.NextRun(after)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 16, 1, 0, 0, 0, time.UTC), next)
+
+	definition = control_plane.ScheduleDefinition{CronExpression: "0 0 30 2 *", TimeZone: "UTC"}
+	_, err = definition.NextRun(after)
+	assert.Error(t, err)
+}
+
+func TestAgentScheduleSkippedRunResult(t *testing.T) {
+	dueAt := time.Date(2024, time.March, 15, 9, 0, 0, 0, time.UTC)
+	schedule := control_plane.AgentSchedule{}
+	assert.Equal(t, "Skipped the run due at 2024-03-15T09:00:00Z: agent busy", schedule.SkippedRunResult(dueAt, "agent busy"))
+
+	// The previous result is kept for the next run, and a later skip replaces the older note
+	schedule.LastResult = "Found 3 posts"
+	schedule.LastResult = schedule.SkippedRunResult(dueAt, "agent busy")
+	assert.Equal(t, "Skipped the run due at 2024-03-15T09:00:00Z: agent busy\n\nFound 3 posts", schedule.LastResult)
+	schedule.LastResult = schedule.SkippedRunResult(dueAt.Add(time.Hour), "agent busy")
+	assert.Equal(t, "Skipped the run due at 2024-03-15T10:00:00Z: agent busy\n\nFound 3 posts", schedule.LastResult)
+}
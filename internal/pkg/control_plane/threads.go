@@ -0,0 +1,33 @@
+package control_plane
+
+import (
+	"context"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+)
+
+// AgentThreadHistory is a thread between two agents with all of its messages, oldest first.
+type AgentThreadHistory struct {
+	Thread   storage.AgentThread
+	Messages []storage.AgentMessage
+}
+
+func (c *ControlPlaneImpl) ListAgentThreads(ctx context.Context, agentID string) ([]storage.AgentThread, error) {
+	return c.storage.ListAgentThreads(agentID)
+}
+
+// GetAgentThread returns storage.ErrAgentThreadNotFound if there is no thread with the ID.
+func (c *ControlPlaneImpl) GetAgentThread(ctx context.Context, threadID string) (*AgentThreadHistory, error) {
+	thread, err := c.storage.GetAgentThread(threadID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := c.storage.ListAgentThreadMessages(threadID)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentThreadHistory{
+		Thread:   *thread,
+		Messages: messages,
+	}, nil
+}
@@ -0,0 +1,92 @@
+package control_plane
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+)
+
+// ErrInvalidApprovalDecision is returned when the edited arguments of an approved tool call are not a JSON object.
+var ErrInvalidApprovalDecision = errors.New("invalid approval decision")
+
+// ErrAgentNotAwaitingApproval is returned when deciding on a tool call whose agent no longer waits for the decision,
+// e.g. because it was terminated.
+var ErrAgentNotAwaitingApproval = errors.New("agent is not awaiting approval")
+
+// ToolApprovalDecision is the user's decision on a parked tool call.
+type ToolApprovalDecision struct {
+	Approved bool
+	// EditedArguments replace the arguments of an approved call, empty keeps the agent's arguments.
+	EditedArguments string
+	// Reason is shown to the agent, it helps the agent change course after a rejection.
+	Reason string
+}
+
+func (c *ControlPlaneImpl) ListToolApprovals(ctx context.Context, owner string, status string) ([]storage.ToolApproval, error) {
+	return c.storage.ListToolApprovals(owner, status)
+}
+
+// DecideToolApproval records the decision and queues the agent to resume, the agent then runs or skips the tool call.
+// It returns storage.ErrToolApprovalNotFound or storage.ErrToolApprovalNotPending for unknown or decided approvals,
+// ErrAgentNotAwaitingApproval if the agent stopped waiting, or ErrRunQueueFull if the node cannot take more tasks.
+func (c *ControlPlaneImpl) DecideToolApproval(ctx context.Context, approvalID string, decision ToolApprovalDecision) (*TaskHandle, error) {
+	slog.Info("ControlPlane: Deciding tool approval", "approval", approvalID, "approved", decision.Approved)
+	if decision.Approved && decision.EditedArguments != "" {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(decision.EditedArguments), &args); err != nil {
+			return nil, errors.Join(ErrInvalidApprovalDecision, err)
+		}
+	}
+	approval, err := c.storage.GetToolApproval(approvalID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get tool approval", "approval", approvalID, "error", err)
+		return nil, err
+	}
+	if approval.Status != storage.ToolApprovalPending {
+		return nil, storage.ErrToolApprovalNotPending
+	}
+	info, err := c.storage.GetAgentInfo(approval.AgentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent info", "agent", approval.AgentID, "error", err)
+		return nil, err
+	}
+	if info.Status != worker.StatusAwaitingApproval {
+		slog.Warn("ControlPlane: Agent is not awaiting approval", "agent", approval.AgentID, "status", info.Status)
+		return nil, ErrAgentNotAwaitingApproval
+	}
+
+	status := storage.ToolApprovalRejected
+	editedArguments := ""
+	if decision.Approved {
+		status = storage.ToolApprovalApproved
+		editedArguments = decision.EditedArguments
+	}
+	// Only one decision is recorded if several are made at once, the others get ErrToolApprovalNotPending
+	if _, err := c.storage.DecideToolApproval(approvalID, status, editedArguments, decision.Reason); err != nil {
+		slog.Error("ControlPlane: Failed to decide tool approval", "approval", approvalID, "error", err)
+		return nil, err
+	}
+
+	handle, err := c.resumeApprovedAgent(ctx, approval.AgentID, info.Role)
+	if err != nil {
+		// The agent still waits, let the user decide again once the node can take it
+		if reopenErr := c.storage.ReopenToolApproval(approvalID); reopenErr != nil {
+			slog.Error("ControlPlane: Failed to reopen tool approval", "approval", approvalID, "error", reopenErr)
+		}
+		return nil, err
+	}
+	return handle, nil
+}
+
+func (c *ControlPlaneImpl) resumeApprovedAgent(ctx context.Context, agentID string, role string) (*TaskHandle, error) {
+	state, err := c.storage.GetAgentState(agentID)
+	if err != nil {
+		slog.Error("ControlPlane: Failed to get agent state", "agent", agentID, "error", err)
+		return nil, err
+	}
+	return c.resumeAgent(ctx, agentID, worker.StatusAwaitingApproval, role, decodeTaskMetadata(state), nil)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/roackb2/lucid/internal/pkg/agents/agent"
 	"github.com/roackb2/lucid/internal/pkg/agents/providers"
 	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
 	"github.com/roackb2/lucid/internal/pkg/dbaccess"
 	"github.com/roackb2/lucid/internal/pkg/pubsub"
 )
@@ -16,6 +17,9 @@ type AgentTracking struct {
 	Agent     agent.Agent
 	Status    string
 	CreatedAt time.Time
+	// LeaseExpiresAt is when this node's ownership of the agent lapses unless renewed.
+	// Zero if the tracker does not use leases.
+	LeaseExpiresAt time.Time
 }
 
 // Tracker should guarantee thread safety
@@ -30,6 +34,7 @@ type AgentTracker interface {
 type AgentController interface {
 	Start(ctx context.Context) error
 	SendCommand(ctx context.Context, command string) error
+	SendAgentCommand(ctx context.Context, agentID string, command string) error
 	RegisterAgent(ctx context.Context, agent agent.Agent) (string, error)
 	GetAgentStatus(agentID string) (string, error)
 }
@@ -40,6 +45,27 @@ type Scheduler interface {
 	Start(ctx context.Context) error
 	SendCommand(ctx context.Context, command string) error
 	SetCallback(callback OnAgentFoundCallback)
+	// GetNodeID returns the node ID the scheduler claims agents with.
+	GetNodeID() string
+	// ScheduleWake records when an agent that just fell asleep should be woken, according to the wake policy of its task.
+	ScheduleWake(ctx context.Context, agentID string, metadata worker.TaskMetadata) error
+}
+
+// WakeState is what a WakePolicy knows about an agent that just fell asleep.
+type WakeState struct {
+	AgentID  string
+	Owner    string
+	AsleepAt time.Time
+	// IdleRounds is the number of consecutive rounds in which no new posts were created.
+	IdleRounds int
+}
+
+// WakePolicy decides when the scheduler wakes an asleep agent.
+type WakePolicy interface {
+	// Name is recorded on the agent and used by tasks to choose the policy.
+	Name() string
+	// NextWakeAt returns when the agent is due to wake, nil if only an event can wake it.
+	NextWakeAt(state WakeState) *time.Time
 }
 
 type AgentFactory interface {
@@ -59,6 +85,39 @@ type ControlPlaneCallbacks map[ControlPlaneEventKey]OnAgentFinalResponseCallback
 
 type ControlPlane interface {
 	Start(ctx context.Context) error
-	KickoffTask(ctx context.Context, task string, role string) error
+	KickoffTask(ctx context.Context, task string, role string, metadata worker.TaskMetadata) (string, *TaskHandle, error)
+	ResumeTask(ctx context.Context, agentID string, prompt string) (*TaskHandle, error)
+	// AnswerAgent resumes an agent awaiting input with the user's answer to its question.
+	AnswerAgent(ctx context.Context, agentID string, answer string) (*TaskHandle, error)
+	// ListToolApprovals lists the tool calls of an owner's agents by approval status, an empty owner or status matches all.
+	ListToolApprovals(ctx context.Context, owner string, status string) ([]storage.ToolApproval, error)
+	// DecideToolApproval records the user's decision on a parked tool call and resumes the agent that made it.
+	DecideToolApproval(ctx context.Context, approvalID string, decision ToolApprovalDecision) (*TaskHandle, error)
+	// SearchAgentProfiles browses the agent directory, best matches first.
+	SearchAgentProfiles(ctx context.Context, query storage.AgentProfileQuery) ([]storage.AgentProfile, error)
+	// GetAgentProfile returns storage.ErrAgentProfileNotFound if the agent has not published a profile.
+	GetAgentProfile(ctx context.Context, agentID string) (*storage.AgentProfile, error)
+	// ListAgentThreads lists the threads an agent takes part in, most recently active first.
+	ListAgentThreads(ctx context.Context, agentID string) ([]storage.AgentThread, error)
+	// GetAgentThread returns storage.ErrAgentThreadNotFound if there is no thread with the ID.
+	GetAgentThread(ctx context.Context, threadID string) (*AgentThreadHistory, error)
 	SendCommand(ctx context.Context, command string) error
+	SendAgentCommand(ctx context.Context, agentID string, command string) error
+	GetRunQueueStats() RunQueueStats
+	// Shutdown stops accepting tasks and waits for the running agents to sleep and persist their state.
+	Shutdown(ctx context.Context) error
+}
+
+// OutboxRelay publishes the events written to the outbox with the agents' state.
+type OutboxRelay interface {
+	Start(ctx context.Context) error
+}
+
+// TaskScheduler starts agents for recurring tasks according to their schedules.
+type TaskScheduler interface {
+	Start(ctx context.Context) error
+	CreateSchedule(ctx context.Context, definition ScheduleDefinition) (*AgentSchedule, error)
+	ListSchedules(ctx context.Context, owner string) ([]AgentSchedule, error)
+	PauseSchedule(ctx context.Context, scheduleID string) (*AgentSchedule, error)
+	ResumeSchedule(ctx context.Context, scheduleID string) (*AgentSchedule, error)
 }
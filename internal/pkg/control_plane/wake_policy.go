@@ -0,0 +1,82 @@
+package control_plane
+
+import (
+	"time"
+)
+
+// Names of the built-in wake policies, recorded on each agent in agent_states.wake_policy
+const (
+	WakePolicyFixed      = "fixed"
+	WakePolicyBackoff    = "backoff"
+	WakePolicyEvent      = "event"
+	WakePolicyRoundRobin = "round_robin"
+)
+
+// FixedIntervalPolicy wakes agents a fixed interval after they fall asleep.
+type FixedIntervalPolicy struct {
+	Interval time.Duration
+}
+
+func (p *FixedIntervalPolicy) Name() string {
+	return WakePolicyFixed
+}
+
+func (p *FixedIntervalPolicy) NextWakeAt(state WakeState) *time.Time {
+	wakeAt := state.AsleepAt.Add(p.Interval)
+	return &wakeAt
+}
+
+// BackoffPolicy doubles the sleep interval for every consecutive round that found no new posts,
+// up to MaxInterval, and goes back to BaseInterval once a round finds something new.
+type BackoffPolicy struct {
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+}
+
+func (p *BackoffPolicy) Name() string {
+	return WakePolicyBackoff
+}
+
+func (p *BackoffPolicy) NextWakeAt(state WakeState) *time.Time {
+	interval := p.BaseInterval
+	for i := 0; i < state.IdleRounds && interval < p.MaxInterval; i++ {
+		interval *= 2
+	}
+	interval = min(interval, p.MaxInterval)
+	wakeAt := state.AsleepAt.Add(interval)
+	return &wakeAt
+}
+
+// EventPolicy wakes agents when a post matching one of their interests is created,
+// the matching is done by the scheduler's search query.
+// Agents are still woken after MaxInterval so they are not stranded when nothing matches, 0 disables that.
+type EventPolicy struct {
+	MaxInterval time.Duration
+}
+
+func (p *EventPolicy) Name() string {
+	return WakePolicyEvent
+}
+
+func (p *EventPolicy) NextWakeAt(state WakeState) *time.Time {
+	if p.MaxInterval == 0 {
+		return nil
+	}
+	wakeAt := state.AsleepAt.Add(p.MaxInterval)
+	return &wakeAt
+}
+
+// RoundRobinPolicy wakes agents a fixed interval after they fall asleep like FixedIntervalPolicy,
+// but the scheduler interleaves due agents by owner, so a user with many agents cannot starve the others.
+type RoundRobinPolicy struct {
+	Interval time.Duration
+}
+
+func (p *RoundRobinPolicy) Name() string {
+	return WakePolicyRoundRobin
+}
+
+func (p *RoundRobinPolicy) NextWakeAt(state WakeState) *time.Time {
+	wakeAt := state.AsleepAt.Add(p.Interval)
+	return &wakeAt
+}
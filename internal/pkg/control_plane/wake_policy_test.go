@@ -0,0 +1,49 @@
+package control_plane_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedIntervalPolicy(t *testing.T) {
+	policy := &control_plane.FixedIntervalPolicy{Interval: 10 * time.Second}
+	asleepAt := time.Now()
+
+	wakeAt := policy.NextWakeAt(control_plane.WakeState{AsleepAt: asleepAt, IdleRounds: 3})
+	assert.Equal(t, asleepAt.Add(10*time.Second), *wakeAt)
+}
+
+func TestBackoffPolicy(t *testing.T) {
+	policy := &control_plane.BackoffPolicy{BaseInterval: 10 * time.Second, MaxInterval: time.Minute}
+	asleepAt := time.Now()
+
+	tests := []struct {
+		idleRounds int
+		interval   time.Duration
+	}{
+		{idleRounds: 0, interval: 10 * time.Second},
+		{idleRounds: 1, interval: 20 * time.Second},
+		{idleRounds: 2, interval: 40 * time.Second},
+		{idleRounds: 3, interval: time.Minute},
+		{idleRounds: 100, interval: time.Minute},
+	}
+	for _, test := range tests {
+		wakeAt := policy.NextWakeAt(control_plane.WakeState{AsleepAt: asleepAt, IdleRounds: test.idleRounds})
+		assert.Equal(t, asleepAt.Add(test.interval), *wakeAt, "idle rounds: %d", test.idleRounds)
+	}
+}
+
+func TestEventPolicy(t *testing.T) {
+	asleepAt := time.Now()
+
+	policy := &control_plane.EventPolicy{MaxInterval: time.Hour}
+	wakeAt := policy.NextWakeAt(control_plane.WakeState{AsleepAt: asleepAt})
+	assert.Equal(t, asleepAt.Add(time.Hour), *wakeAt)
+
+	// Without a max interval only matching posts wake the agent
+	policy = &control_plane.EventPolicy{}
+	assert.Nil(t, policy.NextWakeAt(control_plane.WakeState{AsleepAt: asleepAt}))
+}
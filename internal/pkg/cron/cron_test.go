@@ -0,0 +1,60 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{expr: "* * * * *", next: time.Date(2024, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{expr: "0 * * * *", next: time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{expr: "*/20 * * * *", next: time.Date(2024, time.March, 15, 10, 40, 0, 0, time.UTC)},
+		{expr: "0 9 * * *", next: time.Date(2024, time.March, 16, 9, 0, 0, 0, time.UTC)},
+		{expr: "@daily", next: time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 9 * * mon-fri", next: time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 */3 *", next: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "30 8 29 feb *", next: time.Date(2028, time.February, 29, 8, 30, 0, 0, time.UTC)},
+		{expr: "0 12 * * 7", next: time.Date(2024, time.March, 17, 12, 0, 0, 0, time.UTC)},
+		// Day of month and day of week are OR-ed when both are restricted
+		{expr: "0 0 20 * mon", next: time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := cron.Parse(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.next, schedule.Next(from), test.expr)
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Taipei")
+	require.NoError(t, err)
+	schedule, err := cron.Parse("0 8 * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC) // 08:00 in Taipei
+	next := schedule.Next(from.In(loc))
+	assert.Equal(t, time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	schedule, err := cron.Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		_, err := cron.Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
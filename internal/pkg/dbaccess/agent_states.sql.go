@@ -11,6 +11,49 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const claimAgents = `-- name: ClaimAgents :many
+UPDATE agent_states
+SET claimed_by = $1, claimed_until = now() + $2::interval
+WHERE agent_id = ANY($3::varchar[])
+  AND status = ANY($4::varchar[])
+  AND (claimed_until IS NULL OR claimed_until < now())
+RETURNING agent_id
+`
+
+type ClaimAgentsParams struct {
+	NodeID        pgtype.Text
+	ClaimDuration pgtype.Interval
+	AgentIds      []string
+	Statuses      []string
+}
+
+// Claims the agents that are still in one of the statuses and not claimed by any node, and returns their IDs.
+// An agent left out was claimed or changed by another node, it must not be resumed.
+func (q *Queries) ClaimAgents(ctx context.Context, arg ClaimAgentsParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, claimAgents,
+		arg.NodeID,
+		arg.ClaimDuration,
+		arg.AgentIds,
+		arg.Statuses,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var agent_id string
+		if err := rows.Scan(&agent_id); err != nil {
+			return nil, err
+		}
+		items = append(items, agent_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createAgentState = `-- name: CreateAgentState :exec
 INSERT INTO agent_states (agent_id, state, status, role, awakened_at, asleep_at)
 VALUES ($1, $2, $3, $4, $5, $6)
@@ -38,7 +81,7 @@ func (q *Queries) CreateAgentState(ctx context.Context, arg CreateAgentStatePara
 }
 
 const getAgentState = `-- name: GetAgentState :one
-SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at
+SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
 FROM agent_states
 WHERE agent_id = $1
 `
@@ -56,12 +99,38 @@ func (q *Queries) GetAgentState(ctx context.Context, agentID string) (AgentState
 		&i.UpdatedAt,
 		&i.AwakenedAt,
 		&i.AsleepAt,
+		&i.ClaimedBy,
+		&i.ClaimedUntil,
+		&i.WakePolicy,
+		&i.WakeAt,
+		&i.WakeScheduledAt,
+		&i.IdleRounds,
+		&i.LastError,
+		&i.FailedAt,
 	)
 	return i, err
 }
 
+const releaseAgentClaim = `-- name: ReleaseAgentClaim :exec
+UPDATE agent_states
+SET claimed_by = NULL, claimed_until = NULL
+WHERE agent_id = $1
+  AND claimed_by = $2
+`
+
+type ReleaseAgentClaimParams struct {
+	AgentID string
+	NodeID  pgtype.Text
+}
+
+// Releases the node's claim, a claim another node took after this one expired is kept.
+func (q *Queries) ReleaseAgentClaim(ctx context.Context, arg ReleaseAgentClaimParams) error {
+	_, err := q.db.Exec(ctx, releaseAgentClaim, arg.AgentID, arg.NodeID)
+	return err
+}
+
 const searchAgentByAsleepDurationAndStatus = `-- name: SearchAgentByAsleepDurationAndStatus :many
-SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at
+SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
 FROM agent_states
 WHERE asleep_at + $1::interval < now()
   AND status = ANY($2::varchar[])
@@ -94,6 +163,14 @@ func (q *Queries) SearchAgentByAsleepDurationAndStatus(ctx context.Context, arg
 			&i.UpdatedAt,
 			&i.AwakenedAt,
 			&i.AsleepAt,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.WakePolicy,
+			&i.WakeAt,
+			&i.WakeScheduledAt,
+			&i.IdleRounds,
+			&i.LastError,
+			&i.FailedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -106,7 +183,7 @@ func (q *Queries) SearchAgentByAsleepDurationAndStatus(ctx context.Context, arg
 }
 
 const searchAgentByAwakeDurationAndStatus = `-- name: SearchAgentByAwakeDurationAndStatus :many
-SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at
+SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
 FROM agent_states
 WHERE awakened_at + $1::interval < now()
   AND status = ANY($2::varchar[])
@@ -139,6 +216,14 @@ func (q *Queries) SearchAgentByAwakeDurationAndStatus(ctx context.Context, arg S
 			&i.UpdatedAt,
 			&i.AwakenedAt,
 			&i.AsleepAt,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.WakePolicy,
+			&i.WakeAt,
+			&i.WakeScheduledAt,
+			&i.IdleRounds,
+			&i.LastError,
+			&i.FailedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -151,7 +236,7 @@ func (q *Queries) SearchAgentByAwakeDurationAndStatus(ctx context.Context, arg S
 }
 
 const searchAgentByStatus = `-- name: SearchAgentByStatus :many
-SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at
+SELECT id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
 FROM agent_states
 WHERE status = $1
 `
@@ -175,6 +260,109 @@ func (q *Queries) SearchAgentByStatus(ctx context.Context, status string) ([]Age
 			&i.UpdatedAt,
 			&i.AwakenedAt,
 			&i.AsleepAt,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.WakePolicy,
+			&i.WakeAt,
+			&i.WakeScheduledAt,
+			&i.IdleRounds,
+			&i.LastError,
+			&i.FailedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchClaimableAsleepAgents = `-- name: SearchClaimableAsleepAgents :many
+SELECT agent_states.id, agent_id, status, role, state, created_at, updated_at, awakened_at, asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
+FROM agent_states
+JOIN (
+  SELECT candidates.id AS due_id,
+    ROW_NUMBER() OVER (
+      PARTITION BY CASE
+        WHEN candidates.wake_policy = 'round_robin' THEN COALESCE(candidates.state->'metadata'->>'owner', '')
+        ELSE candidates.agent_id
+      END
+      ORDER BY candidates.wake_at ASC NULLS FIRST, candidates.asleep_at ASC
+    ) AS owner_rank
+  FROM agent_states AS candidates
+  WHERE candidates.status = ANY($1::varchar[])
+    AND (candidates.claimed_until IS NULL OR candidates.claimed_until < now())
+    AND (
+      candidates.wake_at <= now()
+      OR (candidates.wake_at IS NULL AND candidates.wake_policy <> 'event' AND candidates.asleep_at + $2::interval < now())
+      OR (candidates.wake_policy = 'event' AND EXISTS (
+        SELECT 1
+        FROM posts
+        WHERE posts.created_at > candidates.asleep_at
+          AND posts.content ILIKE ANY(
+            SELECT '%' || interest || '%'
+            FROM jsonb_array_elements_text(COALESCE(candidates.state->'metadata'->'interests', '[]'::jsonb)) AS interest
+          )
+      ))
+      OR EXISTS (
+        SELECT 1
+        FROM agent_messages
+        WHERE agent_messages.to_agent_id = candidates.agent_id
+          AND agent_messages.read_at IS NULL
+          AND agent_messages.created_at > candidates.asleep_at
+      )
+    )
+) AS due ON due.due_id = agent_states.id
+WHERE agent_states.status = ANY($1::varchar[])
+  AND (agent_states.claimed_until IS NULL OR agent_states.claimed_until < now())
+ORDER BY due.owner_rank ASC, agent_states.wake_at ASC NULLS FIRST
+LIMIT $3
+FOR UPDATE OF agent_states SKIP LOCKED
+`
+
+type SearchClaimableAsleepAgentsParams struct {
+	Statuses  []string
+	Duration  pgtype.Interval
+	MaxAgents int32
+}
+
+// Locks the asleep agents that are due to wake and are not claimed by any node.
+// An agent is due when its wake_at has passed, when it has no wake_at and has been asleep longer than the given duration,
+// when it uses the event policy and a post matching one of its interests was created after it fell asleep,
+// or when another agent sent it a message after it fell asleep.
+// Agents of the round_robin policy are interleaved by owner, so no owner takes the whole batch.
+// The status and claim are checked again on the locked rows, as a row claimed by another node meanwhile is rechecked
+// on its latest version, and the limit applies after skipping locked rows, so a node waiting on another still gets due agents.
+// Must run in the same transaction as ClaimAgents.
+func (q *Queries) SearchClaimableAsleepAgents(ctx context.Context, arg SearchClaimableAsleepAgentsParams) ([]AgentState, error) {
+	rows, err := q.db.Query(ctx, searchClaimableAsleepAgents, arg.Statuses, arg.Duration, arg.MaxAgents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AgentState
+	for rows.Next() {
+		var i AgentState
+		if err := rows.Scan(
+			&i.ID,
+			&i.AgentID,
+			&i.Status,
+			&i.Role,
+			&i.State,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AwakenedAt,
+			&i.AsleepAt,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.WakePolicy,
+			&i.WakeAt,
+			&i.WakeScheduledAt,
+			&i.IdleRounds,
+			&i.LastError,
+			&i.FailedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -186,6 +374,86 @@ func (q *Queries) SearchAgentByStatus(ctx context.Context, status string) ([]Age
 	return items, nil
 }
 
+const searchClaimableOrphanedAgents = `-- name: SearchClaimableOrphanedAgents :many
+SELECT agent_states.id, agent_states.agent_id, agent_states.status, agent_states.role, agent_states.state, agent_states.created_at, agent_states.updated_at, agent_states.awakened_at, agent_states.asleep_at, claimed_by, claimed_until, wake_policy, wake_at, wake_scheduled_at, idle_rounds, last_error, failed_at
+FROM agent_states
+LEFT JOIN agent_trackings ON agent_trackings.agent_id = agent_states.agent_id
+WHERE agent_states.status = ANY($1::varchar[])
+  AND (
+    agent_trackings.lease_expires_at < now()
+    OR (agent_trackings.agent_id IS NULL AND agent_states.awakened_at + $2::interval < now())
+  )
+  AND (agent_states.claimed_until IS NULL OR agent_states.claimed_until < now())
+ORDER BY agent_states.awakened_at ASC
+LIMIT $3
+FOR UPDATE OF agent_states SKIP LOCKED
+`
+
+type SearchClaimableOrphanedAgentsParams struct {
+	Statuses  []string
+	Duration  pgtype.Interval
+	MaxAgents int32
+}
+
+// Locks the agents whose tracking lease has expired, or that were never tracked with a lease
+// and have been awake longer than the given duration, and are not claimed by any node.
+// Must run in the same transaction as ClaimAgents.
+func (q *Queries) SearchClaimableOrphanedAgents(ctx context.Context, arg SearchClaimableOrphanedAgentsParams) ([]AgentState, error) {
+	rows, err := q.db.Query(ctx, searchClaimableOrphanedAgents, arg.Statuses, arg.Duration, arg.MaxAgents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AgentState
+	for rows.Next() {
+		var i AgentState
+		if err := rows.Scan(
+			&i.ID,
+			&i.AgentID,
+			&i.Status,
+			&i.Role,
+			&i.State,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AwakenedAt,
+			&i.AsleepAt,
+			&i.ClaimedBy,
+			&i.ClaimedUntil,
+			&i.WakePolicy,
+			&i.WakeAt,
+			&i.WakeScheduledAt,
+			&i.IdleRounds,
+			&i.LastError,
+			&i.FailedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateAgentError = `-- name: UpdateAgentError :exec
+UPDATE agent_states
+SET last_error = $1, failed_at = $2
+WHERE agent_id = $3
+`
+
+type UpdateAgentErrorParams struct {
+	LastError pgtype.Text
+	FailedAt  pgtype.Timestamp
+	AgentID   string
+}
+
+// Records why the agent failed, a NULL error clears it when the agent is retried.
+func (q *Queries) UpdateAgentError(ctx context.Context, arg UpdateAgentErrorParams) error {
+	_, err := q.db.Exec(ctx, updateAgentError, arg.LastError, arg.FailedAt, arg.AgentID)
+	return err
+}
+
 const updateAgentState = `-- name: UpdateAgentState :exec
 UPDATE agent_states
 SET state = $1, status = $2, role = $3, awakened_at = $4, asleep_at = $5
@@ -212,3 +480,42 @@ func (q *Queries) UpdateAgentState(ctx context.Context, arg UpdateAgentStatePara
 	)
 	return err
 }
+
+const updateAgentStatus = `-- name: UpdateAgentStatus :exec
+UPDATE agent_states
+SET status = $1
+WHERE agent_id = $2
+`
+
+type UpdateAgentStatusParams struct {
+	Status  string
+	AgentID string
+}
+
+func (q *Queries) UpdateAgentStatus(ctx context.Context, arg UpdateAgentStatusParams) error {
+	_, err := q.db.Exec(ctx, updateAgentStatus, arg.Status, arg.AgentID)
+	return err
+}
+
+const updateAgentWake = `-- name: UpdateAgentWake :exec
+UPDATE agent_states
+SET wake_policy = $1, wake_at = $2, wake_scheduled_at = now(), idle_rounds = $3
+WHERE agent_id = $4
+`
+
+type UpdateAgentWakeParams struct {
+	WakePolicy string
+	WakeAt     pgtype.Timestamp
+	IdleRounds int32
+	AgentID    string
+}
+
+func (q *Queries) UpdateAgentWake(ctx context.Context, arg UpdateAgentWakeParams) error {
+	_, err := q.db.Exec(ctx, updateAgentWake,
+		arg.WakePolicy,
+		arg.WakeAt,
+		arg.IdleRounds,
+		arg.AgentID,
+	)
+	return err
+}
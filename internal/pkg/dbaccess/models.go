@@ -8,16 +8,124 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AgentInterest struct {
+	ID            int32
+	InterestID    string
+	AgentID       string
+	Query         string
+	Keywords      []string
+	Embedding     []float32
+	MinSimilarity float32
+	Active        bool
+	CreatedAt     pgtype.Timestamp
+}
+
+type AgentMessage struct {
+	ID          int32
+	MessageID   string
+	FromAgentID string
+	ToAgentID   string
+	Content     string
+	ReadAt      pgtype.Timestamp
+	CreatedAt   pgtype.Timestamp
+	ThreadID    pgtype.Text
+	Kind        string
+}
+
+type AgentProfile struct {
+	ID          int32
+	AgentID     string
+	Owner       string
+	Role        string
+	Description string
+	Tags        []string
+	Embedding   []float32
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type AgentSchedule struct {
+	ID              int32
+	ScheduleID      string
+	Owner           string
+	Role            string
+	TaskTemplate    string
+	CronExpression  string
+	RunInterval     pgtype.Interval
+	TimeZone        string
+	MissedRunPolicy string
+	Status          string
+	AgentID         pgtype.Text
+	LastResult      pgtype.Text
+	NextRunAt       pgtype.Timestamp
+	LastRunAt       pgtype.Timestamp
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+}
+
 type AgentState struct {
-	ID         int32
-	AgentID    string
-	Status     string
-	Role       string
-	State      []byte
-	CreatedAt  pgtype.Timestamp
-	UpdatedAt  pgtype.Timestamp
-	AwakenedAt pgtype.Timestamp
-	AsleepAt   pgtype.Timestamp
+	ID              int32
+	AgentID         string
+	Status          string
+	Role            string
+	State           []byte
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+	AwakenedAt      pgtype.Timestamp
+	AsleepAt        pgtype.Timestamp
+	ClaimedBy       pgtype.Text
+	ClaimedUntil    pgtype.Timestamp
+	WakePolicy      string
+	WakeAt          pgtype.Timestamp
+	WakeScheduledAt pgtype.Timestamp
+	IdleRounds      int32
+	LastError       pgtype.Text
+	FailedAt        pgtype.Timestamp
+}
+
+type AgentThread struct {
+	ID                int32
+	ThreadID          string
+	InitiatorAgentID  string
+	ResponderAgentID  string
+	Subject           string
+	Status            string
+	LastSenderAgentID string
+	CreatedAt         pgtype.Timestamp
+	UpdatedAt         pgtype.Timestamp
+}
+
+type AgentTracking struct {
+	ID             int32
+	AgentID        string
+	NodeID         string
+	Status         string
+	LeaseExpiresAt pgtype.Timestamp
+	CreatedAt      pgtype.Timestamp
+	UpdatedAt      pgtype.Timestamp
+}
+
+type ClientEvent struct {
+	ID        int64
+	EventID   string
+	AgentID   string
+	Owner     string
+	Event     string
+	Data      []byte
+	CreatedAt pgtype.Timestamp
+}
+
+type EventOutbox struct {
+	ID            int64
+	Topic         string
+	MessageKey    string
+	Message       string
+	Headers       []byte
+	Attempts      int32
+	LastError     string
+	NextAttemptAt pgtype.Timestamp
+	DeliveredAt   pgtype.Timestamp
+	CreatedAt     pgtype.Timestamp
 }
 
 type Post struct {
@@ -28,11 +136,35 @@ type Post struct {
 	UpdatedAt pgtype.Timestamp
 }
 
+type PubsubEvent struct {
+	ID        int64
+	Topic     string
+	Message   string
+	Headers   []byte
+	CreatedAt pgtype.Timestamp
+}
+
 type SchemaMigration struct {
 	Version int64
 	Dirty   bool
 }
 
+type ToolApproval struct {
+	ID              int32
+	ApprovalID      string
+	AgentID         string
+	Owner           string
+	Role            string
+	ToolCallID      string
+	ToolName        string
+	Arguments       string
+	Status          string
+	EditedArguments pgtype.Text
+	Reason          pgtype.Text
+	DecidedAt       pgtype.Timestamp
+	CreatedAt       pgtype.Timestamp
+}
+
 type User struct {
 	ID           int32
 	Username     string
@@ -7,8 +7,23 @@ package dbaccess
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countPostsSince = `-- name: CountPostsSince :one
+SELECT COUNT(*)
+FROM posts
+WHERE created_at > $1
+`
+
+func (q *Queries) CountPostsSince(ctx context.Context, since pgtype.Timestamp) (int64, error) {
+	row := q.db.QueryRow(ctx, countPostsSince, since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createPost = `-- name: CreatePost :exec
 INSERT INTO posts (user_id, content)
 VALUES ($1, $2)
@@ -30,10 +30,32 @@ func Close() {
 	dbPool.Close()
 }
 
+// Pool returns the connection pool behind Querier,
+// for callers that need a connection of their own, e.g. to LISTEN for notifications.
+func Pool() *pgxpool.Pool {
+	return dbPool
+}
+
 func InspectConn() {
 	slog.Info("Inspecting connection", "dbPool", dbPool)
 }
 
+// WithTx runs fn with queries bound to a new transaction,
+// the transaction is committed if fn succeeds and rolled back otherwise.
+func WithTx(ctx context.Context, fn func(q *Queries) error) error {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		slog.Error("Failed to begin transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(Querier.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 func getConnString() string {
 	return fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=disable",
 		config.Config.Database.User,
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"container/list"
+	"log/slog"
+	"sync"
+)
+
+// DefaultDeduplicatorCapacity is the number of event IDs a Deduplicator remembers unless configured otherwise
+const DefaultDeduplicatorCapacity = 10000
+
+// Deduplicator remembers the IDs of the latest handled events, so consumers can drop the events delivered twice,
+// as at-least-once delivery and publishing an event to several topics do.
+type Deduplicator struct {
+	capacity int
+	// order holds the event IDs, most recently handled first
+	order *list.List
+	seen  map[string]*list.Element
+	mutex sync.Mutex
+}
+
+func NewDeduplicator(capacity int) *Deduplicator {
+	if capacity <= 0 {
+		capacity = DefaultDeduplicatorCapacity
+	}
+	return &Deduplicator{
+		capacity: capacity,
+		order:    list.New(),
+		seen:     make(map[string]*list.Element),
+	}
+}
+
+// Seen reports whether the event was handled.
+func (d *Deduplicator) Seen(eventID string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, ok := d.seen[eventID]
+	return ok
+}
+
+// MarkSeen records that the event was handled, forgetting the oldest event once over capacity.
+func (d *Deduplicator) MarkSeen(eventID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if element, ok := d.seen[eventID]; ok {
+		d.order.MoveToFront(element)
+		return
+	}
+	d.seen[eventID] = d.order.PushFront(eventID)
+	if d.order.Len() > d.capacity {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.seen, oldest.Value.(string))
+	}
+}
+
+// Idempotent wraps the callback to drop the events it already handled.
+// An event counts as handled once the callback succeeded, so an event it failed on is handled again when redelivered.
+// The event ID is read from the headers, or from the envelope for messages published without headers,
+// and messages that are not events are always handled.
+func Idempotent(deduplicator *Deduplicator, callback OnMessageCallback) OnMessageCallback {
+	return func(message string, headers Headers) error {
+		eventID := headers[HeaderEventID]
+		if eventID == "" {
+			if envelope, err := DecodeEnvelope(message); err == nil {
+				eventID = envelope.EventID
+			}
+		}
+		if eventID == "" {
+			return callback(message, headers)
+		}
+		if deduplicator.Seen(eventID) {
+			slog.Info("PubSub: dropping duplicate event", "eventID", eventID)
+			return nil
+		}
+		if err := callback(message, headers); err != nil {
+			return err
+		}
+		deduplicator.MarkSeen(eventID)
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers set on messages carrying an Envelope, so consumers can route or drop them without decoding the message.
+const (
+	HeaderEventID       = "event_id"
+	HeaderEventType     = "event_type"
+	HeaderSchemaVersion = "schema_version"
+	HeaderTraceParent   = "traceparent"
+)
+
+var (
+	// ErrUnexpectedEventType is returned when decoding an event of another type than expected.
+	ErrUnexpectedEventType = errors.New("unexpected event type")
+	// ErrUnsupportedSchemaVersion is returned when decoding an event whose schema is newer than the consumer knows.
+	ErrUnsupportedSchemaVersion = errors.New("unsupported event schema version")
+)
+
+// EventSchema identifies the type of an event and the version of its payload's schema.
+// Versions only grow when the payload changes incompatibly, so a consumer decodes
+// the events of its own version and older ones, and rejects the newer ones.
+type EventSchema struct {
+	Type    string
+	Version int
+}
+
+// TraceContext carries the W3C trace context fields, traceparent and tracestate, across pubsub hops.
+type TraceContext map[string]string
+
+// Envelope wraps the payload of every event published on pubsub topics.
+type Envelope struct {
+	EventID       string `json:"event_id"`
+	Type          string `json:"type"`
+	SchemaVersion int    `json:"schema_version"`
+	// Source is the agent the event comes from, empty for events not emitted by an agent
+	Source       string          `json:"source,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+	TraceContext TraceContext    `json:"trace_context,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a context whose events continue the trace,
+// consumers use it to propagate the trace of the event they handle to the events they publish.
+func ContextWithTraceContext(ctx context.Context, traceContext TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext)
+}
+
+// TraceContextFromContext returns the trace context of the context, nil if it has none.
+func TraceContextFromContext(ctx context.Context) TraceContext {
+	traceContext, _ := ctx.Value(traceContextKey{}).(TraceContext)
+	return traceContext
+}
+
+// NewEnvelope wraps the payload in an envelope with a new event ID.
+// The event continues the trace of the context, or starts a new trace if the context has none.
+func NewEnvelope(ctx context.Context, schema EventSchema, source string, payload any) (*Envelope, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	traceContext := TraceContextFromContext(ctx)
+	if traceContext[HeaderTraceParent] == "" {
+		traceContext = TraceContext{HeaderTraceParent: newTraceParent()}
+	}
+	return &Envelope{
+		EventID:       uuid.New().String(),
+		Type:          schema.Type,
+		SchemaVersion: schema.Version,
+		Source:        source,
+		Timestamp:     time.Now().UTC(),
+		TraceContext:  traceContext,
+		Payload:       payloadBytes,
+	}, nil
+}
+
+// newTraceParent starts a new sampled trace in the W3C traceparent format.
+func newTraceParent() string {
+	traceID := strings.ReplaceAll(uuid.New().String(), "-", "")
+	spanID := strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	return fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+}
+
+// Headers returns the headers the envelope is published with.
+func (e *Envelope) Headers() Headers {
+	headers := Headers{
+		HeaderEventID:       e.EventID,
+		HeaderEventType:     e.Type,
+		HeaderSchemaVersion: strconv.Itoa(e.SchemaVersion),
+	}
+	if traceParent := e.TraceContext[HeaderTraceParent]; traceParent != "" {
+		headers[HeaderTraceParent] = traceParent
+	}
+	return headers
+}
+
+func (e *Envelope) Encode() (string, error) {
+	envelopeBytes, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(envelopeBytes), nil
+}
+
+// DecodePayload unmarshals the payload into v.
+func (e *Envelope) DecodePayload(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+func DecodeEnvelope(message string) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(message), &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.EventID == "" || envelope.Type == "" {
+		return nil, fmt.Errorf("message is not an event envelope")
+	}
+	return &envelope, nil
+}
+
+// PublishEvent wraps the payload in an envelope and publishes it, see PublishEnvelope.
+func PublishEvent(ctx context.Context, pubSub PubSub, topic string, schema EventSchema, source string, payload any, timeout time.Duration, opts ...PublishOption) (*Envelope, error) {
+	envelope, err := NewEnvelope(ctx, schema, source, payload)
+	if err != nil {
+		return nil, err
+	}
+	return envelope, PublishEnvelope(ctx, pubSub, topic, envelope, timeout, opts...)
+}
+
+// PublishEnvelope publishes an envelope with its headers, the same envelope may be published to several topics.
+// The envelope is keyed by its source, so the events of an agent stay in order, unless the options set another key.
+func PublishEnvelope(ctx context.Context, pubSub PubSub, topic string, envelope *Envelope, timeout time.Duration, opts ...PublishOption) error {
+	message, err := envelope.Encode()
+	if err != nil {
+		return err
+	}
+	opts = append([]PublishOption{WithHeaders(envelope.Headers()), WithKey(envelope.Source)}, opts...)
+	return pubSub.Publish(ctx, topic, message, timeout, opts...)
+}
+
+// DecodeEvent decodes an event of the schema's type and its payload,
+// it rejects events of other types and events of newer schema versions.
+func DecodeEvent[T any](message string, schema EventSchema) (*Envelope, T, error) {
+	var payload T
+	envelope, err := DecodeEnvelope(message)
+	if err != nil {
+		return nil, payload, err
+	}
+	if envelope.Type != schema.Type {
+		return envelope, payload, fmt.Errorf("%w: got %s, want %s", ErrUnexpectedEventType, envelope.Type, schema.Type)
+	}
+	if envelope.SchemaVersion > schema.Version {
+		return envelope, payload, fmt.Errorf("%w: %s version %d, supported up to %d", ErrUnsupportedSchemaVersion, envelope.Type, envelope.SchemaVersion, schema.Version)
+	}
+	if err := envelope.DecodePayload(&payload); err != nil {
+		return envelope, payload, err
+	}
+	return envelope, payload, nil
+}
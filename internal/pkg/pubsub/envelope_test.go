@@ -0,0 +1,144 @@
+package pubsub_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	mock_pubsub "github.com/roackb2/lucid/test/_mocks/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type testPayload struct {
+	Content string `json:"content"`
+}
+
+var testEvent = pubsub.EventSchema{Type: "test.event", Version: 2}
+
+func TestEnvelope_EncodeDecode(t *testing.T) {
+	envelope, err := pubsub.NewEnvelope(context.Background(), testEvent, "agent-id", testPayload{Content: "hello"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, envelope.EventID)
+	assert.Equal(t, "agent-id", envelope.Source)
+	assert.False(t, envelope.Timestamp.IsZero())
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, envelope.TraceContext[pubsub.HeaderTraceParent])
+
+	headers := envelope.Headers()
+	assert.Equal(t, envelope.EventID, headers[pubsub.HeaderEventID])
+	assert.Equal(t, testEvent.Type, headers[pubsub.HeaderEventType])
+	assert.Equal(t, strconv.Itoa(testEvent.Version), headers[pubsub.HeaderSchemaVersion])
+	assert.Equal(t, envelope.TraceContext[pubsub.HeaderTraceParent], headers[pubsub.HeaderTraceParent])
+
+	message, err := envelope.Encode()
+	require.NoError(t, err)
+	decoded, payload, err := pubsub.DecodeEvent[testPayload](message, testEvent)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", payload.Content)
+	assert.Equal(t, envelope.EventID, decoded.EventID)
+	assert.Equal(t, envelope.TraceContext, decoded.TraceContext)
+}
+
+func TestEnvelope_DecodeRejects(t *testing.T) {
+	envelope, err := pubsub.NewEnvelope(context.Background(), testEvent, "agent-id", testPayload{Content: "hello"})
+	require.NoError(t, err)
+	message, err := envelope.Encode()
+	require.NoError(t, err)
+
+	_, _, err = pubsub.DecodeEvent[testPayload](message, pubsub.EventSchema{Type: "other.event", Version: 2})
+	assert.ErrorIs(t, err, pubsub.ErrUnexpectedEventType)
+	// Consumers of an older schema cannot read newer events, consumers of a newer schema read older ones
+	_, _, err = pubsub.DecodeEvent[testPayload](message, pubsub.EventSchema{Type: testEvent.Type, Version: 1})
+	assert.ErrorIs(t, err, pubsub.ErrUnsupportedSchemaVersion)
+	_, _, err = pubsub.DecodeEvent[testPayload](message, pubsub.EventSchema{Type: testEvent.Type, Version: 3})
+	assert.NoError(t, err)
+
+	_, _, err = pubsub.DecodeEvent[testPayload](`{"content": "not an event"}`, testEvent)
+	assert.Error(t, err)
+}
+
+func TestEnvelope_ContinuesTrace(t *testing.T) {
+	traceContext := pubsub.TraceContext{pubsub.HeaderTraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+	ctx := pubsub.ContextWithTraceContext(context.Background(), traceContext)
+
+	envelope, err := pubsub.NewEnvelope(ctx, testEvent, "", testPayload{})
+	require.NoError(t, err)
+	assert.Equal(t, traceContext, envelope.TraceContext)
+}
+
+func TestMemoryPubSub_PublishEventWithHeaders(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	received := make(chan pubsub.Headers, 1)
+	_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error {
+		received <- headers
+		return nil
+	})
+	require.NoError(t, err)
+
+	envelope, err := pubsub.PublishEvent(context.Background(), ps, "topic", testEvent, "agent-id", testPayload{Content: "hello"}, testTimeout)
+	require.NoError(t, err)
+	select {
+	case headers := <-received:
+		assert.Equal(t, envelope.Headers(), headers)
+	case <-time.After(testTimeout):
+		require.Fail(t, "Did not receive message in time")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	var handled []string
+	fail := true
+	callback := pubsub.Idempotent(pubsub.NewDeduplicator(2), func(message string, headers pubsub.Headers) error {
+		if message == "flaky" && fail {
+			fail = false
+			return errors.New("callback failed")
+		}
+		handled = append(handled, message)
+		return nil
+	})
+
+	envelope, err := pubsub.NewEnvelope(context.Background(), testEvent, "agent-id", testPayload{})
+	require.NoError(t, err)
+	message, err := envelope.Encode()
+	require.NoError(t, err)
+
+	// Duplicates are dropped whether the event ID comes from the headers or the envelope
+	require.NoError(t, callback("first", pubsub.Headers{pubsub.HeaderEventID: "event-1"}))
+	require.NoError(t, callback("first", pubsub.Headers{pubsub.HeaderEventID: "event-1"}))
+	require.NoError(t, callback(message, nil))
+	require.NoError(t, callback(message, envelope.Headers()))
+	// A failed event is handled again when redelivered
+	require.Error(t, callback("flaky", pubsub.Headers{pubsub.HeaderEventID: "event-2"}))
+	require.NoError(t, callback("flaky", pubsub.Headers{pubsub.HeaderEventID: "event-2"}))
+	// Messages that are not events are always handled
+	require.NoError(t, callback("plain", nil))
+	require.NoError(t, callback("plain", nil))
+	// The oldest events are forgotten over capacity
+	require.NoError(t, callback("first", pubsub.Headers{pubsub.HeaderEventID: "event-1"}))
+
+	assert.Equal(t, []string{"first", message, "flaky", "plain", "plain", "first"}, handled)
+}
+
+func TestPublishEnvelope_KeyedBySource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPubSub := mock_pubsub.NewMockPubSub(ctrl)
+	var keys []string
+	mockPubSub.EXPECT().Publish(gomock.Any(), "topic", gomock.Any(), testTimeout, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, _ string, _ time.Duration, opts ...pubsub.PublishOption) error {
+			keys = append(keys, pubsub.NewPublishOptions(opts...).Key)
+			return nil
+		}).
+		Times(2)
+
+	_, err := pubsub.PublishEvent(context.Background(), mockPubSub, "topic", testEvent, "agent-id", testPayload{}, testTimeout)
+	require.NoError(t, err)
+	_, err = pubsub.PublishEvent(context.Background(), mockPubSub, "topic", testEvent, "", testPayload{}, testTimeout, pubsub.WithKey("other-agent"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent-id", "other-agent"}, keys)
+}
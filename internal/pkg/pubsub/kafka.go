@@ -15,30 +15,42 @@ import (
 const (
 	DefaultPartition      = 0
 	DefaultReaderMaxBytes = 10 * 1024 * 1024 // 10MB
+	// PartitionLookupTimeout bounds the lookup of a topic's partitions when subscribing
+	PartitionLookupTimeout = 10 * time.Second
 )
 
 type KafkaPubSub struct {
 	writer             *kafka.Writer
-	subscriptions      map[string]context.CancelFunc
+	subscriptions      map[*kafkaSubscription]struct{}
 	subscriptionsMutex sync.Mutex
 }
 
+// kafkaSubscription runs its own readers, so subscribers of a topic do not share offsets and each gets every message.
+// Outside of a group it runs a reader per partition of the topic, and its callbacks are serialized across them.
+type kafkaSubscription struct {
+	topic         string
+	cancel        context.CancelFunc
+	pubSub        *KafkaPubSub
+	callbackMutex sync.Mutex
+}
+
 func NewKafkaPubSub() *KafkaPubSub {
 	return &KafkaPubSub{
 		writer: &kafka.Writer{
 			Addr:                   kafka.TCP(config.Config.Kafka.Address),
-			Balancer:               &kafka.LeastBytes{},
+			Balancer:               &kafka.Hash{}, // Messages with the same key, e.g. an agent's events, stay on one partition and in order
 			AllowAutoTopicCreation: true,
 			MaxAttempts:            5, // Increase the number of attempts
 			ReadTimeout:            10 * time.Second,
 			WriteTimeout:           10 * time.Second,
 			RequiredAcks:           kafka.RequireAll,
 		},
-		subscriptions: make(map[string]context.CancelFunc),
+		subscriptions: make(map[*kafkaSubscription]struct{}),
 	}
 }
 
-func (k *KafkaPubSub) Publish(ctx context.Context, topic string, message string, timeout time.Duration) error {
+func (k *KafkaPubSub) Publish(ctx context.Context, topic string, message string, timeout time.Duration, opts ...PublishOption) error {
+	options := NewPublishOptions(opts...)
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -47,10 +59,15 @@ func (k *KafkaPubSub) Publish(ctx context.Context, topic string, message string,
 
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		err = k.writer.WriteMessages(ctx, kafka.Message{
-			Topic: topic,
-			Value: []byte(message),
-		})
+		kafkaMessage := kafka.Message{
+			Topic:   topic,
+			Value:   []byte(message),
+			Headers: toKafkaHeaders(options.Headers),
+		}
+		if options.Key != "" {
+			kafkaMessage.Key = []byte(options.Key)
+		}
+		err = k.writer.WriteMessages(ctx, kafkaMessage)
 		if err != nil {
 			if isUnknownTopicOrPartitionError(err) {
 				// Wait and retry
@@ -76,56 +93,153 @@ func isUnknownTopicOrPartitionError(err error) bool {
 	return false
 }
 
-func (k *KafkaPubSub) Subscribe(topic string, callback OnMessageCallback) error {
+func (k *KafkaPubSub) Subscribe(topic string, callback OnMessageCallback, opts ...SubscribeOption) (Subscription, error) {
+	options, err := NewSubscribeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
+	subscription := &kafkaSubscription{
+		topic:  topic,
+		cancel: cancel,
+		pubSub: k,
+	}
 
 	k.subscriptionsMutex.Lock()
-	k.subscriptions[topic] = cancel
+	k.subscriptions[subscription] = struct{}{}
 	k.subscriptionsMutex.Unlock()
 
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{config.Config.Kafka.Address},
-		Topic:    topic,
-		MaxBytes: DefaultReaderMaxBytes,
-	})
-	r.SetOffset(kafka.LastOffset)
-
-	go func() {
-		defer r.Close()
-		for {
-			m, err := r.ReadMessage(ctx)
-			if err != nil {
-				if errors.Is(err, context.Canceled) {
-					slog.Info("KafkaPubSub: subscription to topic canceled", "topic", topic)
-					return
-				}
-				slog.Error("KafkaPubSub: failed to read message", "error", err)
+	if options.GroupID != "" {
+		// The group balances the topic's partitions between its members
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:  []string{config.Config.Kafka.Address},
+			Topic:    topic,
+			MaxBytes: DefaultReaderMaxBytes,
+			GroupID:  options.GroupID,
+			// Only used when the group has no committed offset yet
+			StartOffset: kafkaStartOffset(options.StartPosition),
+		})
+		go k.consume(ctx, r, subscription, callback, options)
+		return subscription, nil
+	}
+
+	for _, partition := range k.topicPartitions(topic) {
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{config.Config.Kafka.Address},
+			Topic:     topic,
+			Partition: partition,
+			MaxBytes:  DefaultReaderMaxBytes,
+		})
+		r.SetOffset(kafkaStartOffset(options.StartPosition))
+		go k.consume(ctx, r, subscription, callback, options)
+	}
+	return subscription, nil
+}
+
+// topicPartitions returns the partitions of the topic, or the default partition if they cannot be looked up,
+// e.g. when the topic is created by the first message published to it.
+// Partitions added to the topic afterwards are not read by existing subscriptions.
+func (k *KafkaPubSub) topicPartitions(topic string) []int {
+	ctx, cancel := context.WithTimeout(context.Background(), PartitionLookupTimeout)
+	defer cancel()
+	conn, err := kafka.DialContext(ctx, "tcp", config.Config.Kafka.Address)
+	if err != nil {
+		slog.Warn("KafkaPubSub: failed to look up partitions, reading the default partition", "topic", topic, "error", err)
+		return []int{DefaultPartition}
+	}
+	defer conn.Close()
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil || len(partitions) == 0 {
+		slog.Warn("KafkaPubSub: failed to look up partitions, reading the default partition", "topic", topic, "error", err)
+		return []int{DefaultPartition}
+	}
+	ids := make([]int, len(partitions))
+	for i, partition := range partitions {
+		ids[i] = partition.ID
+	}
+	return ids
+}
+
+// consume reads messages until the subscription is canceled,
+// messages are handled in the order of their partition and committed once handled if the subscription commits manually.
+func (k *KafkaPubSub) consume(ctx context.Context, r *kafka.Reader, subscription *kafkaSubscription, callback OnMessageCallback, options SubscribeOptions) {
+	defer r.Close()
+	topic := subscription.topic
+	for {
+		var m kafka.Message
+		var err error
+		if options.ManualCommit {
+			m, err = r.FetchMessage(ctx)
+		} else {
+			m, err = r.ReadMessage(ctx)
+		}
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				slog.Info("KafkaPubSub: subscription to topic canceled", "topic", topic)
 				return
 			}
-			if err := callback(string(m.Value)); err != nil {
-				slog.Error("KafkaPubSub: callback error", "error", err)
+			slog.Error("KafkaPubSub: failed to read message", "error", err)
+			return
+		}
+		subscription.callbackMutex.Lock()
+		err = processMessage(ctx, k, topic, callback, string(m.Value), fromKafkaHeaders(m.Headers), options)
+		subscription.callbackMutex.Unlock()
+		if err != nil {
+			// Not committed, the group redelivers the message to its next member
+			slog.Info("KafkaPubSub: subscription to topic canceled while handling message", "topic", topic, "partition", m.Partition, "offset", m.Offset)
+			return
+		}
+		if options.ManualCommit {
+			if err := r.CommitMessages(ctx, m); err != nil {
+				slog.Error("KafkaPubSub: failed to commit message", "topic", topic, "partition", m.Partition, "offset", m.Offset, "error", err)
 			}
 		}
-	}()
-	return nil
+	}
 }
 
-func (k *KafkaPubSub) Unsubscribe(topic string) {
-	k.subscriptionsMutex.Lock()
-	if cancel, ok := k.subscriptions[topic]; ok {
-		cancel()
-		delete(k.subscriptions, topic)
+func toKafkaHeaders(headers Headers) []kafka.Header {
+	kafkaHeaders := make([]kafka.Header, 0, len(headers))
+	for key, value := range headers {
+		kafkaHeaders = append(kafkaHeaders, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return kafkaHeaders
+}
+
+func fromKafkaHeaders(kafkaHeaders []kafka.Header) Headers {
+	headers := make(Headers, len(kafkaHeaders))
+	for _, header := range kafkaHeaders {
+		headers[header.Key] = string(header.Value)
+	}
+	return headers
+}
+
+func kafkaStartOffset(position StartPosition) int64 {
+	switch position {
+	case StartEarliest, StartCommitted:
+		return kafka.FirstOffset
+	default:
+		return kafka.LastOffset
 	}
-	k.subscriptionsMutex.Unlock()
 }
 
 func (k *KafkaPubSub) Close() error {
 	k.subscriptionsMutex.Lock()
-	for _, cancel := range k.subscriptions {
-		cancel()
+	for subscription := range k.subscriptions {
+		subscription.cancel()
 	}
-	k.subscriptions = make(map[string]context.CancelFunc)
+	k.subscriptions = make(map[*kafkaSubscription]struct{})
 	k.subscriptionsMutex.Unlock()
 
 	return k.writer.Close()
 }
+
+func (s *kafkaSubscription) Topic() string {
+	return s.topic
+}
+
+func (s *kafkaSubscription) Unsubscribe() {
+	s.pubSub.subscriptionsMutex.Lock()
+	delete(s.pubSub.subscriptions, s)
+	s.pubSub.subscriptionsMutex.Unlock()
+	s.cancel()
+}
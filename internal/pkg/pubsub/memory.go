@@ -0,0 +1,295 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMemoryBufferSize is the number of messages buffered for each subscriber unless configured otherwise
+	DefaultMemoryBufferSize = 256
+)
+
+// Overflow policies of MemoryPubSub, applied when a subscriber's buffer is full.
+const (
+	// OverflowBlock makes Publish wait for room in the buffer until its timeout
+	OverflowBlock = "block"
+	// OverflowDropNewest drops the message being published
+	OverflowDropNewest = "drop_newest"
+	// OverflowDropOldest drops the oldest buffered message to make room for the message being published
+	OverflowDropOldest = "drop_oldest"
+)
+
+// ErrPubSubClosed is returned when publishing to or subscribing with a closed PubSub.
+var ErrPubSubClosed = errors.New("pubsub is closed")
+
+type MemoryPubSubConfig struct {
+	// BufferSize is the number of messages buffered for each subscriber, defaults to DefaultMemoryBufferSize
+	BufferSize int
+	// OverflowPolicy is OverflowBlock, OverflowDropNewest or OverflowDropOldest, defaults to OverflowBlock
+	OverflowPolicy string
+}
+
+// MemoryPubSub delivers messages between the components of a single process, without a broker.
+// Like KafkaPubSub, a message published to a topic is delivered to every subscriber of the topic,
+// and each subscriber runs its callbacks in its own goroutine, in the order the messages were published,
+// so messages stay in order whatever their key.
+// The members of a consumer group take turns receiving the messages delivered to the group.
+// Messages are not retained, so every start position only delivers messages published after the subscription,
+// and manual commit has no effect as messages are not redelivered.
+type MemoryPubSub struct {
+	config             MemoryPubSubConfig
+	subscriptions      map[string][]*memorySubscription
+	subscriptionsMutex sync.RWMutex
+	// groupCursors picks the next member of each consumer group, keyed by topic and group ID
+	groupCursors map[string]*atomic.Uint64
+	closed       bool
+	// deadLetters publishes the messages dead-lettered by the subscribers, the MemoryPubSub itself unless set
+	deadLetters PubSub
+}
+
+type memorySubscription struct {
+	topic    string
+	options  SubscribeOptions
+	messages chan memoryMessage
+	ctx      context.Context
+	cancel   context.CancelFunc
+	pubSub   *MemoryPubSub
+}
+
+type memoryMessage struct {
+	value   string
+	headers Headers
+}
+
+func NewMemoryPubSub(config MemoryPubSubConfig) *MemoryPubSub {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultMemoryBufferSize
+	}
+	switch config.OverflowPolicy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		if config.OverflowPolicy != "" {
+			slog.Warn("MemoryPubSub: unknown overflow policy, blocking", "policy", config.OverflowPolicy)
+		}
+		config.OverflowPolicy = OverflowBlock
+	}
+	return &MemoryPubSub{
+		config:        config,
+		subscriptions: make(map[string][]*memorySubscription),
+		groupCursors:  make(map[string]*atomic.Uint64),
+	}
+}
+
+// Publish delivers the message to the buffers of the topic's subscribers.
+// With the OverflowBlock policy, it returns an error if a subscriber's buffer stays full until the timeout,
+// the other subscribers still get the message.
+func (m *MemoryPubSub) Publish(ctx context.Context, topic string, message string, timeout time.Duration, opts ...PublishOption) error {
+	options := NewPublishOptions(opts...)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	m.subscriptionsMutex.RLock()
+	if m.closed {
+		m.subscriptionsMutex.RUnlock()
+		return ErrPubSubClosed
+	}
+	subscriptions := m.recipients(topic)
+	m.subscriptionsMutex.RUnlock()
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		// Each subscriber gets its own copy of the headers, so callbacks cannot change each other's
+		headers := make(Headers, len(options.Headers))
+		for key, value := range options.Headers {
+			headers[key] = value
+		}
+		if err := m.deliver(ctx, subscription, memoryMessage{value: message, headers: headers}); err != nil {
+			slog.Error("MemoryPubSub: failed to deliver message", "topic", topic, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recipients returns the subscriptions a message of the topic is delivered to:
+// every subscription outside of a group, and the next member of each group.
+// It must be called with the subscriptions mutex held.
+func (m *MemoryPubSub) recipients(topic string) []*memorySubscription {
+	var recipients []*memorySubscription
+	groups := map[string][]*memorySubscription{}
+	var groupIDs []string
+	for _, subscription := range m.subscriptions[topic] {
+		groupID := subscription.options.GroupID
+		if groupID == "" {
+			recipients = append(recipients, subscription)
+			continue
+		}
+		if _, ok := groups[groupID]; !ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+		groups[groupID] = append(groups[groupID], subscription)
+	}
+	for _, groupID := range groupIDs {
+		members := groups[groupID]
+		next := m.groupCursors[groupKey(topic, groupID)].Add(1)
+		recipients = append(recipients, members[next%uint64(len(members))])
+	}
+	return recipients
+}
+
+func groupKey(topic string, groupID string) string {
+	return topic + "\x00" + groupID
+}
+
+func (m *MemoryPubSub) deliver(ctx context.Context, subscription *memorySubscription, message memoryMessage) error {
+	switch m.config.OverflowPolicy {
+	case OverflowDropNewest:
+		select {
+		case subscription.messages <- message:
+		case <-subscription.ctx.Done():
+		default:
+			slog.Warn("MemoryPubSub: subscriber buffer full, dropping newest message", "topic", subscription.topic)
+		}
+		return nil
+	case OverflowDropOldest:
+		for {
+			select {
+			case subscription.messages <- message:
+				return nil
+			case <-subscription.ctx.Done():
+				return nil
+			default:
+			}
+			select {
+			case <-subscription.messages:
+				slog.Warn("MemoryPubSub: subscriber buffer full, dropping oldest message", "topic", subscription.topic)
+			default:
+			}
+		}
+	default:
+		select {
+		case subscription.messages <- message:
+			return nil
+		case <-subscription.ctx.Done():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *MemoryPubSub) Subscribe(topic string, callback OnMessageCallback, opts ...SubscribeOption) (Subscription, error) {
+	options, err := NewSubscribeOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription := &memorySubscription{
+		topic:    topic,
+		options:  options,
+		messages: make(chan memoryMessage, m.config.BufferSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		pubSub:   m,
+	}
+
+	m.subscriptionsMutex.Lock()
+	if m.closed {
+		m.subscriptionsMutex.Unlock()
+		cancel()
+		return nil, ErrPubSubClosed
+	}
+	m.subscriptions[topic] = append(m.subscriptions[topic], subscription)
+	if options.GroupID != "" {
+		if _, ok := m.groupCursors[groupKey(topic, options.GroupID)]; !ok {
+			m.groupCursors[groupKey(topic, options.GroupID)] = &atomic.Uint64{}
+		}
+	}
+	m.subscriptionsMutex.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("MemoryPubSub: subscription to topic canceled", "topic", topic)
+				return
+			case message := <-subscription.messages:
+				if err := processMessage(ctx, m.deadLetterPubSub(), topic, callback, message.value, message.headers, options); err != nil {
+					slog.Info("MemoryPubSub: subscription to topic canceled while handling message", "topic", topic)
+					return
+				}
+			}
+		}
+	}()
+	return subscription, nil
+}
+
+func (m *MemoryPubSub) deadLetterPubSub() PubSub {
+	if m.deadLetters != nil {
+		return m.deadLetters
+	}
+	return m
+}
+
+// topics returns the topics with at least one subscriber.
+func (m *MemoryPubSub) topics() []string {
+	m.subscriptionsMutex.RLock()
+	defer m.subscriptionsMutex.RUnlock()
+	topics := make([]string, 0, len(m.subscriptions))
+	for topic := range m.subscriptions {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+func (m *MemoryPubSub) subscribed(topic string) bool {
+	m.subscriptionsMutex.RLock()
+	defer m.subscriptionsMutex.RUnlock()
+	return len(m.subscriptions[topic]) > 0
+}
+
+func (m *MemoryPubSub) Close() error {
+	m.subscriptionsMutex.Lock()
+	m.closed = true
+	subscriptions := m.subscriptions
+	m.subscriptions = make(map[string][]*memorySubscription)
+	m.subscriptionsMutex.Unlock()
+
+	for _, topicSubscriptions := range subscriptions {
+		for _, subscription := range topicSubscriptions {
+			subscription.stop()
+		}
+	}
+	return nil
+}
+
+func (s *memorySubscription) Topic() string {
+	return s.topic
+}
+
+// Unsubscribe cancels the subscription, messages still buffered for it are dropped.
+func (s *memorySubscription) Unsubscribe() {
+	m := s.pubSub
+	m.subscriptionsMutex.Lock()
+	subscriptions := m.subscriptions[s.topic]
+	for i, subscription := range subscriptions {
+		if subscription == s {
+			m.subscriptions[s.topic] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+	if len(m.subscriptions[s.topic]) == 0 {
+		delete(m.subscriptions, s.topic)
+	}
+	m.subscriptionsMutex.Unlock()
+	s.stop()
+}
+
+func (s *memorySubscription) stop() {
+	s.cancel()
+}
@@ -0,0 +1,235 @@
+package pubsub_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTimeout = time.Second
+
+func receive(t *testing.T, messages chan string) string {
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(testTimeout):
+		require.Fail(t, "Did not receive message in time")
+		return ""
+	}
+}
+
+func subscribe(t *testing.T, ps pubsub.PubSub, topic string, messages chan string) pubsub.Subscription {
+	subscription, err := ps.Subscribe(topic, func(message string, headers pubsub.Headers) error {
+		messages <- message
+		return nil
+	})
+	require.NoError(t, err)
+	return subscription
+}
+
+func TestMemoryPubSub_FanOut(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	first := make(chan string, 10)
+	second := make(chan string, 10)
+	other := make(chan string, 10)
+	subscribe(t, ps, "topic", first)
+	subscribe(t, ps, "topic", second)
+	subscribe(t, ps, "other-topic", other)
+
+	require.NoError(t, ps.Publish(context.Background(), "topic", "message-1", testTimeout))
+	require.NoError(t, ps.Publish(context.Background(), "topic", "message-2", testTimeout))
+
+	// Every subscriber gets every message of the topic, in order
+	assert.Equal(t, "message-1", receive(t, first))
+	assert.Equal(t, "message-2", receive(t, first))
+	assert.Equal(t, "message-1", receive(t, second))
+	assert.Equal(t, "message-2", receive(t, second))
+	assert.Empty(t, other)
+}
+
+func TestMemoryPubSub_Unsubscribe(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	first := make(chan string, 10)
+	second := make(chan string, 10)
+	firstSubscription := subscribe(t, ps, "topic", first)
+	subscribe(t, ps, "topic", second)
+	assert.Equal(t, "topic", firstSubscription.Topic())
+	require.NoError(t, ps.Publish(context.Background(), "topic", "before", testTimeout))
+	assert.Equal(t, "before", receive(t, first))
+	assert.Equal(t, "before", receive(t, second))
+
+	// The other subscriber of the topic keeps receiving messages
+	firstSubscription.Unsubscribe()
+	require.NoError(t, ps.Publish(context.Background(), "topic", "after", testTimeout))
+	assert.Equal(t, "after", receive(t, second))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, first)
+
+	// Unsubscribing twice has no effect
+	firstSubscription.Unsubscribe()
+}
+
+func TestMemoryPubSub_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{policy: pubsub.OverflowDropNewest, expected: []string{"message-1", "message-2"}},
+		{policy: pubsub.OverflowDropOldest, expected: []string{"message-2", "message-3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{BufferSize: 2, OverflowPolicy: test.policy})
+			defer ps.Close()
+
+			// The callback holds the first message until released, so the others fill the buffer
+			release := make(chan struct{})
+			started := make(chan struct{})
+			messages := make(chan string, 10)
+			_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error {
+				if message == "blocker" {
+					close(started)
+					<-release
+					return nil
+				}
+				messages <- message
+				return nil
+			})
+			require.NoError(t, err)
+			require.NoError(t, ps.Publish(context.Background(), "topic", "blocker", testTimeout))
+			<-started
+			for _, message := range []string{"message-1", "message-2", "message-3"} {
+				require.NoError(t, ps.Publish(context.Background(), "topic", message, testTimeout))
+			}
+			close(release)
+
+			for _, expected := range test.expected {
+				assert.Equal(t, expected, receive(t, messages))
+			}
+			time.Sleep(50 * time.Millisecond)
+			assert.Empty(t, messages)
+		})
+	}
+}
+
+func TestMemoryPubSub_BlockTimesOut(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{BufferSize: 1, OverflowPolicy: pubsub.OverflowBlock})
+	defer ps.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error {
+		<-release
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, ps.Publish(context.Background(), "topic", "message-1", testTimeout))
+	// Either message-1 is still buffered or held by the callback, so the buffer fills up at the latest with message-3
+	_ = ps.Publish(context.Background(), "topic", "message-2", 50*time.Millisecond)
+	err = ps.Publish(context.Background(), "topic", "message-3", 50*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemoryPubSub_Close(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	subscription := subscribe(t, ps, "topic", make(chan string, 10))
+	require.NoError(t, ps.Close())
+	// Subscriptions canceled by Close can still be unsubscribed
+	subscription.Unsubscribe()
+
+	assert.ErrorIs(t, ps.Publish(context.Background(), "topic", "message", testTimeout), pubsub.ErrPubSubClosed)
+	_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error { return nil })
+	assert.ErrorIs(t, err, pubsub.ErrPubSubClosed)
+}
+
+func TestMemoryPubSub_ConsumerGroup(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	received := make(chan string, 10)
+	all := make(chan string, 10)
+	for i := 0; i < 2; i++ {
+		_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error {
+			received <- message
+			return nil
+		}, pubsub.WithGroupID("group"))
+		require.NoError(t, err)
+	}
+	subscribe(t, ps, "topic", all)
+
+	for _, message := range []string{"message-1", "message-2", "message-3", "message-4"} {
+		require.NoError(t, ps.Publish(context.Background(), "topic", message, testTimeout))
+	}
+
+	// The group gets each message once, while the subscriber outside of the group gets all of them
+	var groupMessages []string
+	for i := 0; i < 4; i++ {
+		groupMessages = append(groupMessages, receive(t, received))
+		assert.NotEmpty(t, receive(t, all))
+	}
+	assert.ElementsMatch(t, []string{"message-1", "message-2", "message-3", "message-4"}, groupMessages)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, received)
+}
+
+func TestMemoryPubSub_RetryAndDeadLetter(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	deadLetters := make(chan string, 10)
+	subscribe(t, ps, "topic.dlq", deadLetters)
+
+	attempts := map[string]int{}
+	handled := make(chan string, 10)
+	_, err := ps.Subscribe("topic", func(message string, headers pubsub.Headers) error {
+		attempts[message]++
+		// The flaky message succeeds on its second attempt, the poison message never does
+		if message == "poison" || attempts[message] < 2 {
+			return errors.New("callback failed")
+		}
+		handled <- message
+		return nil
+	},
+		pubsub.WithGroupID("group"),
+		pubsub.WithManualCommit(),
+		pubsub.WithRetry(3, time.Millisecond, 5*time.Millisecond),
+		pubsub.WithDeadLetterTopic("topic.dlq"),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, ps.Publish(context.Background(), "topic", "flaky", testTimeout))
+	require.NoError(t, ps.Publish(context.Background(), "topic", "poison", testTimeout))
+
+	assert.Equal(t, "flaky", receive(t, handled))
+	var deadLetter pubsub.DeadLetterMessage
+	require.NoError(t, json.Unmarshal([]byte(receive(t, deadLetters)), &deadLetter))
+	assert.Equal(t, "topic", deadLetter.Topic)
+	assert.Equal(t, "group", deadLetter.GroupID)
+	assert.Equal(t, "poison", deadLetter.Message)
+	assert.Equal(t, "callback failed", deadLetter.Error)
+	assert.Equal(t, 3, deadLetter.Attempts)
+	assert.Empty(t, deadLetters)
+}
+
+func TestMemoryPubSub_InvalidOptions(t *testing.T) {
+	ps := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer ps.Close()
+
+	callback := func(message string, headers pubsub.Headers) error { return nil }
+	_, err := ps.Subscribe("topic", callback, pubsub.WithManualCommit())
+	assert.ErrorIs(t, err, pubsub.ErrInvalidSubscribeOptions)
+	_, err = ps.Subscribe("topic", callback, pubsub.WithStartPosition(pubsub.StartCommitted))
+	assert.ErrorIs(t, err, pubsub.ErrInvalidSubscribeOptions)
+	_, err = ps.Subscribe("topic", callback, pubsub.WithStartPosition("middle"))
+	assert.ErrorIs(t, err, pubsub.ErrInvalidSubscribeOptions)
+}
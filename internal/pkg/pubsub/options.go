@@ -0,0 +1,255 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StartPosition is where a subscription starts reading a topic.
+type StartPosition string
+
+const (
+	// StartLatest only delivers messages published after the subscription, it is the default
+	StartLatest StartPosition = "latest"
+	// StartEarliest delivers the messages the topic still retains, then the new ones
+	StartEarliest StartPosition = "earliest"
+	// StartCommitted resumes after the last message the subscription's group committed,
+	// or from the earliest retained message if the group never committed, it requires a group ID
+	StartCommitted StartPosition = "committed"
+)
+
+const (
+	// DefaultRetryBackoff is the wait before the second attempt of a failed callback, doubled for each further attempt
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// DefaultMaxRetryBackoff bounds the wait between attempts of a failed callback
+	DefaultMaxRetryBackoff = 30 * time.Second
+	// DeadLetterPublishTimeout bounds each attempt to publish a failed message to the dead-letter topic
+	DeadLetterPublishTimeout = 10 * time.Second
+)
+
+// ErrInvalidSubscribeOptions is returned by Subscribe when the options contradict each other.
+var ErrInvalidSubscribeOptions = errors.New("invalid subscribe options")
+
+// SubscribeOptions configures how a subscription reads its topic and handles callback errors.
+type SubscribeOptions struct {
+	// GroupID makes the subscription a member of a consumer group,
+	// each message of the topic is delivered to a single member of the group instead of every subscriber.
+	GroupID string
+	// StartPosition defaults to StartCommitted for group members and StartLatest otherwise.
+	// Group members that already committed always resume after their committed message.
+	StartPosition StartPosition
+	// ManualCommit commits a message only once the callback succeeded or the message was dead-lettered,
+	// so messages in flight when the subscriber stops are redelivered to the group: at-least-once delivery.
+	// Without it, messages are committed as soon as they are read. It requires a group ID.
+	ManualCommit bool
+	// MaxAttempts is the number of times the callback is called for a message before giving up, at least once.
+	MaxAttempts int
+	// RetryBackoff is the wait before the second attempt, doubled for each further attempt up to MaxRetryBackoff.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// DeadLetterTopic receives the messages the callback kept failing on, wrapped in a DeadLetterMessage.
+	// Without it, such messages are logged and skipped.
+	DeadLetterTopic string
+}
+
+// SubscribeOption sets an option of a subscription.
+type SubscribeOption func(*SubscribeOptions)
+
+// WithGroupID makes the subscription a member of the consumer group.
+func WithGroupID(groupID string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.GroupID = groupID
+	}
+}
+
+// WithStartPosition sets where the subscription starts reading the topic.
+func WithStartPosition(position StartPosition) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.StartPosition = position
+	}
+}
+
+// WithManualCommit commits messages only after they are handled, see SubscribeOptions.ManualCommit.
+func WithManualCommit() SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.ManualCommit = true
+	}
+}
+
+// WithRetry calls the callback up to maxAttempts times for a message, with exponential backoff between attempts.
+func WithRetry(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.MaxAttempts = maxAttempts
+		o.RetryBackoff = backoff
+		o.MaxRetryBackoff = maxBackoff
+	}
+}
+
+// WithDeadLetterTopic routes the messages the callback kept failing on to the topic.
+func WithDeadLetterTopic(topic string) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.DeadLetterTopic = topic
+	}
+}
+
+// NewSubscribeOptions applies the options over the defaults and validates them.
+func NewSubscribeOptions(opts ...SubscribeOption) (SubscribeOptions, error) {
+	options := SubscribeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.StartPosition == "" {
+		options.StartPosition = StartLatest
+		if options.GroupID != "" {
+			options.StartPosition = StartCommitted
+		}
+	}
+	switch options.StartPosition {
+	case StartLatest, StartEarliest, StartCommitted:
+	default:
+		return options, fmt.Errorf("%w: unknown start position %q", ErrInvalidSubscribeOptions, options.StartPosition)
+	}
+	if options.GroupID == "" && options.StartPosition == StartCommitted {
+		return options, fmt.Errorf("%w: starting from the committed message requires a group ID", ErrInvalidSubscribeOptions)
+	}
+	if options.GroupID == "" && options.ManualCommit {
+		return options, fmt.Errorf("%w: manual commit requires a group ID", ErrInvalidSubscribeOptions)
+	}
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 1
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+	if options.MaxRetryBackoff <= 0 {
+		options.MaxRetryBackoff = DefaultMaxRetryBackoff
+	}
+	return options, nil
+}
+
+// PublishOptions configures a published message.
+type PublishOptions struct {
+	// Headers are delivered to the subscribers' callbacks along with the message.
+	Headers Headers
+	// Key routes the message, messages with the same key are delivered in the order they were published.
+	// Messages without a key may be delivered out of order when the topic is partitioned.
+	Key string
+}
+
+// PublishOption sets an option of a published message.
+type PublishOption func(*PublishOptions)
+
+// WithHeaders publishes the message with the headers, merged with the headers set by previous options.
+func WithHeaders(headers Headers) PublishOption {
+	return func(o *PublishOptions) {
+		if o.Headers == nil {
+			o.Headers = Headers{}
+		}
+		for key, value := range headers {
+			o.Headers[key] = value
+		}
+	}
+}
+
+// WithKey publishes the message with the key, such as the ID of the agent the message is about.
+func WithKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.Key = key
+	}
+}
+
+// NewPublishOptions applies the options of a published message.
+func NewPublishOptions(opts ...PublishOption) PublishOptions {
+	options := PublishOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// DeadLetterMessage is published to the dead-letter topic for a message the callback kept failing on.
+type DeadLetterMessage struct {
+	Topic    string    `json:"topic"`
+	GroupID  string    `json:"group_id,omitempty"`
+	Message  string    `json:"message"`
+	Headers  Headers   `json:"headers,omitempty"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// handleMessage calls the callback until it succeeds or the attempts run out, backing off between attempts.
+// It returns the last callback error, or the context's error if the subscription was canceled while retrying.
+func handleMessage(ctx context.Context, callback OnMessageCallback, message string, headers Headers, options SubscribeOptions) error {
+	backoff := options.RetryBackoff
+	var err error
+	for attempt := 1; attempt <= options.MaxAttempts; attempt++ {
+		if err = callback(message, headers); err == nil {
+			return nil
+		}
+		if attempt == options.MaxAttempts {
+			break
+		}
+		slog.Warn("PubSub: callback failed, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, options.MaxRetryBackoff)
+	}
+	return err
+}
+
+// deadLetter publishes a message the callback kept failing on to the dead-letter topic,
+// retrying until it succeeds or the subscription is canceled, so the message is not committed before it is kept.
+func deadLetter(ctx context.Context, pubSub PubSub, topic string, message string, headers Headers, cause error, options SubscribeOptions) error {
+	payload, err := json.Marshal(DeadLetterMessage{
+		Topic:    topic,
+		GroupID:  options.GroupID,
+		Message:  message,
+		Headers:  headers,
+		Error:    cause.Error(),
+		Attempts: options.MaxAttempts,
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	backoff := options.RetryBackoff
+	for {
+		err := pubSub.Publish(ctx, options.DeadLetterTopic, string(payload), DeadLetterPublishTimeout)
+		if err == nil {
+			slog.Warn("PubSub: message dead-lettered", "topic", topic, "deadLetterTopic", options.DeadLetterTopic, "error", cause)
+			return nil
+		}
+		slog.Error("PubSub: failed to publish to dead-letter topic", "deadLetterTopic", options.DeadLetterTopic, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, options.MaxRetryBackoff)
+	}
+}
+
+// processMessage handles a message with retries, then dead-letters it if the callback kept failing.
+// It returns an error only if the message must not be committed, because the subscription was canceled before it was handled.
+func processMessage(ctx context.Context, pubSub PubSub, topic string, callback OnMessageCallback, message string, headers Headers, options SubscribeOptions) error {
+	err := handleMessage(ctx, callback, message, headers, options)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if options.DeadLetterTopic == "" {
+		slog.Error("PubSub: callback error, skipping message", "topic", topic, "attempts", options.MaxAttempts, "error", err)
+		return nil
+	}
+	return deadLetter(ctx, pubSub, topic, message, headers, err, options)
+}
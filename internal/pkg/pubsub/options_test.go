@@ -0,0 +1,31 @@
+package pubsub_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSubscribeOptions(t *testing.T) {
+	options, err := pubsub.NewSubscribeOptions()
+	require.NoError(t, err)
+	assert.Equal(t, pubsub.StartLatest, options.StartPosition)
+	assert.Equal(t, 1, options.MaxAttempts)
+	assert.Equal(t, pubsub.DefaultRetryBackoff, options.RetryBackoff)
+	assert.Equal(t, pubsub.DefaultMaxRetryBackoff, options.MaxRetryBackoff)
+
+	// Group members resume from their committed message unless told otherwise
+	options, err = pubsub.NewSubscribeOptions(pubsub.WithGroupID("group"), pubsub.WithRetry(5, time.Second, time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, pubsub.StartCommitted, options.StartPosition)
+	assert.Equal(t, 5, options.MaxAttempts)
+	assert.Equal(t, time.Second, options.RetryBackoff)
+	assert.Equal(t, time.Minute, options.MaxRetryBackoff)
+
+	options, err = pubsub.NewSubscribeOptions(pubsub.WithGroupID("group"), pubsub.WithStartPosition(pubsub.StartEarliest))
+	require.NoError(t, err)
+	assert.Equal(t, pubsub.StartEarliest, options.StartPosition)
+}
@@ -0,0 +1,340 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+const (
+	// DefaultPostgresChannel is the channel events are notified on unless configured otherwise
+	DefaultPostgresChannel = "lucid_pubsub"
+	// DefaultPostgresRetention is how long events are kept for catch-up unless configured otherwise
+	DefaultPostgresRetention = 24 * time.Hour
+	// PostgresInlineMessageLimit is the size up to which a message is sent within its notification,
+	// below the 8000 bytes NOTIFY accepts; larger messages are read back from the events table
+	PostgresInlineMessageLimit = 7000
+	// PostgresCatchUpBatchSize is the number of events read at once when catching up after a reconnect
+	PostgresCatchUpBatchSize = 500
+	// PostgresCatchUpOverlap is the number of events before the last delivered one read again when catching up,
+	// so events committed out of ID order while disconnected are not missed; duplicates are skipped
+	PostgresCatchUpOverlap = 100
+	// PostgresDispatchTimeout bounds the delivery of a notified event to the local subscribers
+	PostgresDispatchTimeout = 10 * time.Second
+	// PostgresCleanupInterval is how often events older than the retention are deleted
+	PostgresCleanupInterval     = 10 * time.Minute
+	PostgresReconnectBackoff    = time.Second
+	PostgresMaxReconnectBackoff = 30 * time.Second
+)
+
+// ErrDatabaseNotInitialized is reported while PostgresPubSub waits for dbaccess to be initialized.
+var ErrDatabaseNotInitialized = errors.New("dbaccess is not initialized")
+
+type PostgresPubSubConfig struct {
+	// Channel is the channel events of every topic are notified on, defaults to DefaultPostgresChannel
+	Channel string
+	// Retention is how long events are kept for catch-up, defaults to DefaultPostgresRetention
+	Retention time.Duration
+	// Local configures the buffers of the subscribers of this process
+	Local MemoryPubSubConfig
+}
+
+// PostgresPubSub shares messages between nodes through the Postgres database used by dbaccess,
+// which must be initialized first, so deployments already running Postgres need no broker.
+// Publish stores the message in the pubsub_events table and notifies its ID on a single channel,
+// inlining the message when it fits in the notification.
+// Each PostgresPubSub listens on a connection of its own and hands the events of the topics it has subscribers for
+// to a MemoryPubSub, which runs the callbacks, so buffering, consumer groups and retries behave as with MemoryPubSub:
+// members of a consumer group only take turns within a process.
+// Messages are delivered in the order they were committed, whatever their key.
+// After losing its connection, it catches up from the table on the events published while disconnected.
+// Start positions only deliver messages published after the subscription, as with MemoryPubSub.
+type PostgresPubSub struct {
+	config PostgresPubSubConfig
+	local  *MemoryPubSub
+	// delivered skips the events notified again while catching up
+	delivered *Deduplicator
+	// listened, firstEventID and lastEventID record whether it listened before,
+	// the latest event when it first listened and the latest event notified, only used by the listener goroutine
+	listened     bool
+	firstEventID int64
+	lastEventID  int64
+	closed       atomic.Bool
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// postgresNotification is the payload notified for a published event,
+// the message and headers are only included when Inline is set.
+type postgresNotification struct {
+	ID      int64   `json:"id"`
+	Topic   string  `json:"topic"`
+	Inline  bool    `json:"inline,omitempty"`
+	Message string  `json:"message,omitempty"`
+	Headers Headers `json:"headers,omitempty"`
+}
+
+func NewPostgresPubSub(config PostgresPubSubConfig) *PostgresPubSub {
+	if config.Channel == "" {
+		config.Channel = DefaultPostgresChannel
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultPostgresRetention
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PostgresPubSub{
+		config:    config,
+		local:     NewMemoryPubSub(config.Local),
+		delivered: NewDeduplicator(DefaultDeduplicatorCapacity),
+		cancel:    cancel,
+	}
+	// Dead letters go through Postgres, so they are shared with the other nodes like any message
+	p.local.deadLetters = p
+
+	p.wg.Add(2)
+	go func() {
+		defer p.wg.Done()
+		p.listen(ctx)
+	}()
+	go func() {
+		defer p.wg.Done()
+		p.cleanUp(ctx)
+	}()
+	return p
+}
+
+// Publish stores the message and notifies it in one transaction, so it is only notified once stored.
+func (p *PostgresPubSub) Publish(ctx context.Context, topic string, message string, timeout time.Duration, opts ...PublishOption) error {
+	if p.closed.Load() {
+		return ErrPubSubClosed
+	}
+	options := NewPublishOptions(opts...)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	headers := options.Headers
+	if headers == nil {
+		headers = Headers{}
+	}
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	err = dbaccess.WithTx(ctx, func(q *dbaccess.Queries) error {
+		event, err := q.CreatePubSubEvent(ctx, dbaccess.CreatePubSubEventParams{
+			Topic:   topic,
+			Message: message,
+			Headers: encodedHeaders,
+		})
+		if err != nil {
+			return err
+		}
+		payload, err := encodeNotification(event.ID, topic, message, headers)
+		if err != nil {
+			return err
+		}
+		return q.NotifyPubSubEvent(ctx, dbaccess.NotifyPubSubEventParams{
+			Channel: p.config.Channel,
+			Payload: payload,
+		})
+	})
+	if err != nil {
+		slog.Error("PostgresPubSub: failed to publish message", "topic", topic, "error", err)
+		return err
+	}
+	return nil
+}
+
+func encodeNotification(id int64, topic string, message string, headers Headers) (string, error) {
+	notification := postgresNotification{ID: id, Topic: topic, Inline: true, Message: message, Headers: headers}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) <= PostgresInlineMessageLimit {
+		return string(payload), nil
+	}
+	payload, err = json.Marshal(postgresNotification{ID: id, Topic: topic})
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+func (p *PostgresPubSub) Subscribe(topic string, callback OnMessageCallback, opts ...SubscribeOption) (Subscription, error) {
+	if p.closed.Load() {
+		return nil, ErrPubSubClosed
+	}
+	return p.local.Subscribe(topic, callback, opts...)
+}
+
+// Close stops listening and cancels the subscriptions, then waits for the listening connection to be closed.
+func (p *PostgresPubSub) Close() error {
+	if p.closed.Swap(true) {
+		return nil
+	}
+	p.cancel()
+	p.wg.Wait()
+	return p.local.Close()
+}
+
+// listen keeps a connection listening for notifications, reconnecting with a backoff when it is lost.
+func (p *PostgresPubSub) listen(ctx context.Context) {
+	backoff := PostgresReconnectBackoff
+	for {
+		listening, err := p.listenOnce(ctx)
+		if ctx.Err() != nil {
+			slog.Info("PostgresPubSub: stopped listening", "channel", p.config.Channel)
+			return
+		}
+		if listening {
+			backoff = PostgresReconnectBackoff
+		}
+		slog.Error("PostgresPubSub: lost listening connection, reconnecting", "channel", p.config.Channel, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, PostgresMaxReconnectBackoff)
+	}
+}
+
+// listenOnce listens on a connection taken out of the pool until it fails,
+// reporting whether it got to listen so the reconnect backoff can be reset.
+func (p *PostgresPubSub) listenOnce(ctx context.Context) (bool, error) {
+	pool := dbaccess.Pool()
+	if pool == nil {
+		return false, ErrDatabaseNotInitialized
+	}
+	pooledConn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	// A listening connection must not be handed to other queries, so it is closed rather than released
+	conn := pooledConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.config.Channel}.Sanitize()); err != nil {
+		return false, err
+	}
+	slog.Info("PostgresPubSub: listening for notifications", "channel", p.config.Channel)
+	if err := p.catchUp(ctx); err != nil {
+		return true, err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		p.handleNotification(ctx, notification.Payload)
+	}
+}
+
+// catchUp delivers the events of the subscribed topics published while not listening.
+// When first listening, it only records the latest event, as earlier events predate the subscriptions.
+func (p *PostgresPubSub) catchUp(ctx context.Context) error {
+	if !p.listened {
+		latestID, err := dbaccess.Querier.GetLatestPubSubEventID(ctx)
+		if err != nil {
+			return err
+		}
+		p.listened = true
+		p.firstEventID = latestID
+		p.lastEventID = latestID
+		return nil
+	}
+
+	topics := p.local.topics()
+	if len(topics) == 0 {
+		return nil
+	}
+	afterID := max(p.lastEventID-PostgresCatchUpOverlap, p.firstEventID)
+	for {
+		events, err := dbaccess.Querier.ListPubSubEventsAfter(ctx, dbaccess.ListPubSubEventsAfterParams{
+			AfterID:   afterID,
+			Topics:    topics,
+			MaxEvents: PostgresCatchUpBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			p.dispatch(ctx, event.ID, event.Topic, event.Message, decodeHeaders(event.Headers))
+			afterID = event.ID
+		}
+		if len(events) < PostgresCatchUpBatchSize {
+			slog.Info("PostgresPubSub: caught up", "channel", p.config.Channel, "last_event_id", p.lastEventID)
+			return nil
+		}
+	}
+}
+
+func (p *PostgresPubSub) handleNotification(ctx context.Context, payload string) {
+	var notification postgresNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		slog.Error("PostgresPubSub: failed to decode notification", "payload", payload, "error", err)
+		return
+	}
+	if !p.local.subscribed(notification.Topic) {
+		p.lastEventID = max(p.lastEventID, notification.ID)
+		return
+	}
+	if !notification.Inline {
+		event, err := dbaccess.Querier.GetPubSubEvent(ctx, notification.ID)
+		if err != nil {
+			slog.Error("PostgresPubSub: failed to read notified event", "id", notification.ID, "topic", notification.Topic, "error", err)
+			return
+		}
+		notification.Message = event.Message
+		notification.Headers = decodeHeaders(event.Headers)
+	}
+	p.dispatch(ctx, notification.ID, notification.Topic, notification.Message, notification.Headers)
+}
+
+// dispatch hands an event to the local subscribers of its topic, unless it was already delivered.
+func (p *PostgresPubSub) dispatch(ctx context.Context, id int64, topic string, message string, headers Headers) {
+	p.lastEventID = max(p.lastEventID, id)
+	eventID := strconv.FormatInt(id, 10)
+	if p.delivered.Seen(eventID) {
+		return
+	}
+	p.delivered.MarkSeen(eventID)
+	if err := p.local.Publish(ctx, topic, message, PostgresDispatchTimeout, WithHeaders(headers)); err != nil {
+		slog.Error("PostgresPubSub: failed to dispatch event", "id", id, "topic", topic, "error", err)
+	}
+}
+
+func decodeHeaders(encoded []byte) Headers {
+	var headers Headers
+	if err := json.Unmarshal(encoded, &headers); err != nil {
+		slog.Error("PostgresPubSub: failed to decode headers", "error", err)
+	}
+	return headers
+}
+
+// cleanUp periodically deletes the events older than the retention.
+func (p *PostgresPubSub) cleanUp(ctx context.Context) {
+	ticker := time.NewTicker(PostgresCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dbaccess.Querier.DeleteExpiredPubSubEvents(ctx, utils.ConvertToPgInterval(p.config.Retention)); err != nil {
+				slog.Error("PostgresPubSub: failed to delete expired events", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeNotification(t *testing.T) {
+	headers := Headers{HeaderEventType: "agent.response"}
+
+	payload, err := encodeNotification(1, "topic", "message", headers)
+	require.NoError(t, err)
+	var notification postgresNotification
+	require.NoError(t, json.Unmarshal([]byte(payload), &notification))
+	assert.Equal(t, postgresNotification{ID: 1, Topic: "topic", Inline: true, Message: "message", Headers: headers}, notification)
+
+	// Too large for NOTIFY, the subscribers read the message from the events table
+	payload, err = encodeNotification(2, "topic", strings.Repeat("x", PostgresInlineMessageLimit), headers)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(payload), PostgresInlineMessageLimit)
+	notification = postgresNotification{}
+	require.NoError(t, json.Unmarshal([]byte(payload), &notification))
+	assert.Equal(t, postgresNotification{ID: 2, Topic: "topic"}, notification)
+}
+
+// PostgresPubSub runs callbacks through a MemoryPubSub, whose dead letters must reach the other nodes.
+func TestMemoryPubSub_DeadLettersThroughConfiguredPubSub(t *testing.T) {
+	shared := NewMemoryPubSub(MemoryPubSubConfig{})
+	defer shared.Close()
+	local := NewMemoryPubSub(MemoryPubSubConfig{})
+	defer local.Close()
+	local.deadLetters = shared
+
+	deadLetters := make(chan string, 1)
+	_, err := shared.Subscribe("topic.dlq", func(message string, headers Headers) error {
+		deadLetters <- message
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = local.Subscribe("topic", func(message string, headers Headers) error {
+		return errors.New("callback failed")
+	}, WithDeadLetterTopic("topic.dlq"))
+	require.NoError(t, err)
+
+	require.NoError(t, local.Publish(context.Background(), "topic", "poison", time.Second))
+	select {
+	case message := <-deadLetters:
+		var deadLetter DeadLetterMessage
+		require.NoError(t, json.Unmarshal([]byte(message), &deadLetter))
+		assert.Equal(t, "poison", deadLetter.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "Did not receive dead letter in time")
+	}
+}
@@ -0,0 +1,43 @@
+package pubsub
+
+import (
+	"log/slog"
+
+	"github.com/roackb2/lucid/config"
+)
+
+// Drivers of the PubSub created by NewPubSub.
+const (
+	// DriverKafka shares messages between nodes through the Kafka broker
+	DriverKafka = "kafka"
+	// DriverPostgres shares messages between nodes through the database used by dbaccess
+	DriverPostgres = "postgres"
+	// DriverMemory keeps messages within the process, for tests and single-node mode
+	DriverMemory = "memory"
+)
+
+// NewPubSub creates the PubSub of the configured driver, Kafka unless configured otherwise.
+// The postgres driver needs dbaccess to be initialized, e.g. by the relational storage.
+func NewPubSub() PubSub {
+	memoryConfig := MemoryPubSubConfig{
+		BufferSize:     config.Config.PubSub.Memory.BufferSize,
+		OverflowPolicy: config.Config.PubSub.Memory.OverflowPolicy,
+	}
+	switch config.Config.PubSub.Driver {
+	case DriverMemory:
+		slog.Info("PubSub: using in-memory pubsub")
+		return NewMemoryPubSub(memoryConfig)
+	case DriverPostgres:
+		slog.Info("PubSub: using postgres pubsub")
+		return NewPostgresPubSub(PostgresPubSubConfig{
+			Channel:   config.Config.PubSub.Postgres.Channel,
+			Retention: config.Config.PubSub.Postgres.Retention,
+			Local:     memoryConfig,
+		})
+	case DriverKafka, "":
+		return NewKafkaPubSub()
+	default:
+		slog.Warn("PubSub: unknown driver, using kafka", "driver", config.Config.PubSub.Driver)
+		return NewKafkaPubSub()
+	}
+}
@@ -11,15 +11,16 @@ import (
 	"time"
 )
 
+// Headers are key-value metadata carried alongside a message, such as the event ID and type of an Envelope.
+type Headers map[string]string
+
 // OnMessageCallback is a function type that defines the signature for
 // callback functions used in subscriptions.
 //
-// The callback function receives a context and the message as a string.
+// The callback function receives the message as a string and the headers it was published with,
+// which are empty if it was published without headers.
 // It returns an error if processing the message fails.
-//
-// The context can be used to manage cancellation and deadlines for the
-// callback execution.
-type OnMessageCallback func(message string) error
+type OnMessageCallback func(message string, headers Headers) error
 
 // PubSub defines the interface for a publish-subscribe messaging system.
 //
@@ -34,31 +35,29 @@ type PubSub interface {
 	// - topic: The topic to which the message will be published.
 	// - message: The message content to be published.
 	// - timeout: The maximum duration to wait for the publish operation to complete.
+	// - opts: Options of the message, such as its headers.
 	//
 	// Returns:
 	// - error: An error if the publish operation fails; otherwise, nil.
-	Publish(ctx context.Context, topic string, message string, timeout time.Duration) error
+	Publish(ctx context.Context, topic string, message string, timeout time.Duration, opts ...PublishOption) error
 
 	// Subscribe registers a callback function to receive messages from the specified topic.
 	//
 	// Parameters:
 	// - topic: The topic to subscribe to.
 	// - callback: The function to be called when a message is received.
+	// - opts: Options of the subscription, such as its consumer group, start position, retries and dead-letter topic.
 	//
 	// Returns:
-	// - error: An error if the subscription fails; otherwise, nil.
+	// - Subscription: The handle to cancel this subscription, independently of the other subscriptions to the topic.
+	// - error: An error if the subscription fails or the options are invalid; otherwise, nil.
 	//
-	// The subscription will remain active until the context is canceled.
+	// Every subscriber of a topic receives every message published to it,
+	// except that the members of a consumer group share the messages delivered to the group.
+	// The subscription remains active until it is unsubscribed or the PubSub is closed,
+	// callers must unsubscribe once they no longer need the messages.
 	// Note: each call to Subscribe runs a new goroutine.
-	Subscribe(topic string, callback OnMessageCallback) error
-
-	// Unsubscribe removes the subscription from the specified topic.
-	//
-	// Parameters:
-	// - topic: The topic to unsubscribe from.
-	//
-	// If there is no active subscription to the topic, this method has no effect.
-	Unsubscribe(topic string)
+	Subscribe(topic string, callback OnMessageCallback, opts ...SubscribeOption) (Subscription, error)
 
 	// Close gracefully shuts down the PubSub system, releasing any allocated resources.
 	//
@@ -68,3 +67,15 @@ type PubSub interface {
 	// After calling Close, the PubSub instance should not be used.
 	Close() error
 }
+
+// Subscription is a handle to a single subscription returned by PubSub.Subscribe.
+type Subscription interface {
+	// Topic returns the topic of the subscription.
+	Topic() string
+
+	// Unsubscribe cancels the subscription, the callback is not called for messages received afterwards.
+	// Other subscriptions to the same topic are not affected.
+	//
+	// Calling Unsubscribe more than once has no effect.
+	Unsubscribe()
+}
@@ -16,3 +16,12 @@ func ConvertToPgTimestamp(t *time.Time) pgtype.Timestamp {
 func ConvertToPgInterval(d time.Duration) pgtype.Interval {
 	return pgtype.Interval{Microseconds: d.Microseconds(), Valid: true}
 }
+
+// ConvertFromPgInterval returns the duration of an interval, counting days as 24 hours and months as 30 days.
+func ConvertFromPgInterval(i pgtype.Interval) time.Duration {
+	if !i.Valid {
+		return 0
+	}
+	days := int64(i.Days) + int64(i.Months)*30
+	return time.Duration(i.Microseconds)*time.Microsecond + time.Duration(days)*24*time.Hour
+}
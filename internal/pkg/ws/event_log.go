@@ -0,0 +1,244 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+)
+
+const (
+	// DefaultEventRetention is how long events are kept for replay unless configured otherwise
+	DefaultEventRetention = 24 * time.Hour
+	// EventCleanupInterval is how often events older than the retention are deleted
+	EventCleanupInterval = 10 * time.Minute
+	// DefaultEventPollInterval is how often the events recorded by other nodes are read unless configured otherwise
+	DefaultEventPollInterval = time.Second
+	// ReplayBatchSize is the number of events read at once when replaying
+	ReplayBatchSize = 500
+)
+
+type EventLogConfig struct {
+	// Retention is how long events are kept for replay, defaults to DefaultEventRetention
+	Retention time.Duration
+	// PollInterval is how often the events recorded by other nodes are read, defaults to DefaultEventPollInterval
+	PollInterval time.Duration
+}
+
+// EventLogImpl records the agent events sent to clients, so a client reconnecting with a cursor gets the events it missed.
+// Each node runs one, it subscribes to the agents' topics once and records every event, once whichever nodes record it.
+// Listeners do not get the events as they are recorded, the event log reads them back in cursor order and hands them
+// to the connections of the node, so every node sends the events in the same order and a cursor is never followed by a smaller one.
+type EventLogImpl struct {
+	cfg          EventLogConfig
+	storage      storage.Storage
+	pubSub       pubsub.PubSub
+	deduplicator *pubsub.Deduplicator
+	// unrecorded holds the IDs of the events sent without a cursor, so they are not sent again if another node recorded them
+	unrecorded *pubsub.Deduplicator
+	// recorded wakes up the reader when this node recorded an event
+	recorded chan struct{}
+	// lastCursor is the cursor of the last event handed to the listeners, only the reader uses it
+	lastCursor int64
+
+	listenersMux   sync.RWMutex
+	listeners      map[int]func(ClientEvent)
+	nextListenerID int
+}
+
+func NewEventLog(cfg EventLogConfig, storage storage.Storage, pubSub pubsub.PubSub) *EventLogImpl {
+	mergedCfg := EventLogConfig{
+		Retention:    utils.GetOrDefault(cfg.Retention, DefaultEventRetention),
+		PollInterval: utils.GetOrDefault(cfg.PollInterval, DefaultEventPollInterval),
+	}
+	return &EventLogImpl{
+		cfg:          mergedCfg,
+		storage:      storage,
+		pubSub:       pubSub,
+		deduplicator: pubsub.NewDeduplicator(WsEventDeduplicatorCapacity),
+		unrecorded:   pubsub.NewDeduplicator(WsEventDeduplicatorCapacity),
+		recorded:     make(chan struct{}, 1),
+		listeners:    make(map[int]func(ClientEvent)),
+	}
+}
+
+// Start records and dispatches the agents' events until the context is done.
+func (l *EventLogImpl) Start(ctx context.Context) error {
+	slog.Info("EventLog: Starting")
+	// Listeners get the events recorded from now on
+	lastCursor, err := l.storage.GetLatestClientEventID()
+	if err != nil {
+		slog.Error("EventLog: Failed to get the latest cursor", "error", err)
+		return err
+	}
+	l.lastCursor = lastCursor
+	topics := map[string]pubsub.OnMessageCallback{
+		worker.GetAgentProgressTopic():        l.onAgentProgress,
+		worker.GetAgentResponseGeneralTopic(): l.onAgentResponse,
+		worker.GetAgentApprovalTopic():        l.onAgentApprovalRequest,
+	}
+	for topic, callback := range topics {
+		subscription, err := l.pubSub.Subscribe(topic, pubsub.Idempotent(l.deduplicator, callback))
+		if err != nil {
+			slog.Error("EventLog: Failed to subscribe", "topic", topic, "error", err)
+			return err
+		}
+		defer subscription.Unsubscribe()
+	}
+
+	cleanupTicker := time.NewTicker(EventCleanupInterval)
+	defer cleanupTicker.Stop()
+	pollTicker := time.NewTicker(l.cfg.PollInterval)
+	defer pollTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("EventLog: Stopping")
+			return ctx.Err()
+		case <-cleanupTicker.C:
+			if err := l.storage.DeleteExpiredClientEvents(l.cfg.Retention); err != nil {
+				slog.Error("EventLog: Failed to delete expired events", "error", err)
+			}
+		case <-l.recorded:
+			l.readRecorded()
+		case <-pollTicker.C:
+			l.readRecorded()
+		}
+	}
+}
+
+// Listen calls the listener with every event recorded by any node from now on, in cursor order,
+// until the returned function is called.
+func (l *EventLogImpl) Listen(listener func(ClientEvent)) func() {
+	l.listenersMux.Lock()
+	defer l.listenersMux.Unlock()
+	id := l.nextListenerID
+	l.nextListenerID++
+	l.listeners[id] = listener
+	return func() {
+		l.listenersMux.Lock()
+		defer l.listenersMux.Unlock()
+		delete(l.listeners, id)
+	}
+}
+
+// Replay calls the callback with the recorded events after the cursor, oldest first,
+// of the agents by ID or of the owner's agents when the owner is not empty.
+// Events older than the retention are no longer replayed.
+func (l *EventLogImpl) Replay(afterCursor int64, agentIDs []string, owner string, callback func(ClientEvent) error) error {
+	for {
+		events, err := l.storage.ListClientEventsAfter(afterCursor, agentIDs, owner, ReplayBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := callback(toClientEvent(event)); err != nil {
+				return err
+			}
+			afterCursor = event.ID
+		}
+		if len(events) < ReplayBatchSize {
+			return nil
+		}
+	}
+}
+
+func (l *EventLogImpl) onAgentProgress(message string, headers pubsub.Headers) error {
+	envelope, notification, err := pubsub.DecodeEvent[worker.WorkerProgressNotification](message, worker.AgentProgressEvent)
+	if err != nil {
+		slog.Error("EventLog: Failed to decode agent progress", "error", err)
+		return err
+	}
+	return l.record(envelope.EventID, notification.AgentID, notification.Owner, WsEventTypeAgentProgress, notification)
+}
+
+func (l *EventLogImpl) onAgentResponse(message string, headers pubsub.Headers) error {
+	envelope, notification, err := pubsub.DecodeEvent[worker.WorkerResponseNotification](message, worker.AgentResponseEvent)
+	if err != nil {
+		slog.Error("EventLog: Failed to decode agent response", "error", err)
+		return err
+	}
+	return l.record(envelope.EventID, notification.AgentID, notification.Owner, WsEventTypeAgentResponse, notification)
+}
+
+func (l *EventLogImpl) onAgentApprovalRequest(message string, headers pubsub.Headers) error {
+	envelope, notification, err := pubsub.DecodeEvent[worker.WorkerApprovalNotification](message, worker.AgentApprovalEvent)
+	if err != nil {
+		slog.Error("EventLog: Failed to decode agent approval request", "error", err)
+		return err
+	}
+	return l.record(envelope.EventID, notification.AgentID, notification.Owner, WsEventTypeAgentApprovalRequest, notification)
+}
+
+// record stores the event, the reader dispatches it with its cursor.
+// If it cannot be stored, it is dispatched at once without a cursor, as it cannot be replayed.
+func (l *EventLogImpl) record(eventID string, agentID string, owner string, event WsEventType, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = l.storage.RecordClientEvent(storage.ClientEvent{
+		EventID: eventID,
+		AgentID: agentID,
+		Owner:   owner,
+		Event:   string(event),
+		Data:    data,
+	})
+	if err != nil {
+		slog.Error("EventLog: Failed to record event, sending it without a cursor", "event_id", eventID, "error", err)
+		l.unrecorded.MarkSeen(eventID)
+		l.dispatch(ClientEvent{AgentID: agentID, Owner: owner, Event: event, Data: data})
+		return nil
+	}
+	select {
+	case l.recorded <- struct{}{}:
+	default:
+		// The reader is already woken up
+	}
+	return nil
+}
+
+// readRecorded dispatches the events recorded by any node after the last cursor, in cursor order.
+func (l *EventLogImpl) readRecorded() {
+	for {
+		events, err := l.storage.ListAllClientEventsAfter(l.lastCursor, ReplayBatchSize)
+		if err != nil {
+			slog.Error("EventLog: Failed to read recorded events", "after_cursor", l.lastCursor, "error", err)
+			return
+		}
+		for _, event := range events {
+			l.lastCursor = event.ID
+			if l.unrecorded.Seen(event.EventID) {
+				continue
+			}
+			l.dispatch(toClientEvent(event))
+		}
+		if len(events) < ReplayBatchSize {
+			return
+		}
+	}
+}
+
+func (l *EventLogImpl) dispatch(event ClientEvent) {
+	l.listenersMux.RLock()
+	defer l.listenersMux.RUnlock()
+	for _, listener := range l.listeners {
+		listener(event)
+	}
+}
+
+func toClientEvent(event storage.ClientEvent) ClientEvent {
+	return ClientEvent{
+		Cursor:  event.ID,
+		AgentID: event.AgentID,
+		Owner:   event.Owner,
+		Event:   WsEventType(event.Event),
+		Data:    json.RawMessage(event.Data),
+	}
+}
@@ -0,0 +1,290 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	mock_storage "github.com/roackb2/lucid/test/_mocks/storage"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+// probeAgentID is the agent of the events published to tell the event log is running, they are not recorded
+const probeAgentID = "probe"
+
+// clientEventStore records the client events in memory in place of the database.
+type clientEventStore struct {
+	mu     sync.Mutex
+	events []storage.ClientEvent
+	probed bool
+}
+
+// expectClientEvents makes the storage record and list the client events in the store.
+func expectClientEvents(mockStorage *mock_storage.MockStorage, store *clientEventStore) {
+	mockStorage.EXPECT().RecordClientEvent(gomock.Any()).DoAndReturn(func(event storage.ClientEvent) (int64, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if event.AgentID == probeAgentID {
+			store.probed = true
+			return 0, nil
+		}
+		for _, recorded := range store.events {
+			if recorded.EventID == event.EventID {
+				return recorded.ID, nil
+			}
+		}
+		event.ID = int64(len(store.events) + 1)
+		store.events = append(store.events, event)
+		return event.ID, nil
+	}).AnyTimes()
+	mockStorage.EXPECT().ListClientEventsAfter(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(afterID int64, agentIDs []string, owner string, limit int) ([]storage.ClientEvent, error) {
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			events := []storage.ClientEvent{}
+			for _, event := range store.events {
+				if event.ID <= afterID || (!slices.Contains(agentIDs, event.AgentID) && (owner == "" || event.Owner != owner)) {
+					continue
+				}
+				if len(events) == limit {
+					break
+				}
+				events = append(events, event)
+			}
+			return events, nil
+		}).AnyTimes()
+	mockStorage.EXPECT().ListAllClientEventsAfter(gomock.Any(), gomock.Any()).DoAndReturn(func(afterID int64, limit int) ([]storage.ClientEvent, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		events := []storage.ClientEvent{}
+		for _, event := range store.events {
+			if event.ID > afterID && len(events) < limit {
+				events = append(events, event)
+			}
+		}
+		return events, nil
+	}).AnyTimes()
+	mockStorage.EXPECT().GetLatestClientEventID().DoAndReturn(func() (int64, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return int64(len(store.events)), nil
+	}).AnyTimes()
+}
+
+func (store *clientEventStore) count() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return len(store.events)
+}
+
+func (store *clientEventStore) isProbed() bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.probed
+}
+
+// startEventLog runs the event log until the context is done and waits until it records events.
+func startEventLog(ctx context.Context, s *suite.Suite, eventLog *EventLogImpl, ps pubsub.PubSub, store *clientEventStore) {
+	go eventLog.Start(ctx)
+	s.Require().Eventually(func() bool {
+		notification := worker.WorkerProgressNotification{AgentID: probeAgentID, Progress: "probe"}
+		_, err := pubsub.PublishEvent(ctx, ps, worker.GetAgentProgressTopic(), worker.AgentProgressEvent, probeAgentID, notification, time.Second)
+		return err == nil && store.isProbed()
+	}, time.Second, 10*time.Millisecond)
+}
+
+type EventLogTestSuite struct {
+	suite.Suite
+	mockCtrl    *gomock.Controller
+	mockStorage *mock_storage.MockStorage
+	pubSub      *pubsub.MemoryPubSub
+	eventLog    *EventLogImpl
+	cancel      context.CancelFunc
+}
+
+func (s *EventLogTestSuite) SetupTest() {
+	s.mockCtrl = gomock.NewController(s.T())
+	s.mockStorage = mock_storage.NewMockStorage(s.mockCtrl)
+	s.pubSub = pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	s.eventLog = NewEventLog(EventLogConfig{}, s.mockStorage, s.pubSub)
+}
+
+func (s *EventLogTestSuite) TearDownTest() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.pubSub.Close()
+	s.mockCtrl.Finish()
+}
+
+func TestEventLogSuite(t *testing.T) {
+	suite.Run(t, new(EventLogTestSuite))
+}
+
+func (s *EventLogTestSuite) start(store *clientEventStore) {
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	startEventLog(ctx, &s.Suite, s.eventLog, s.pubSub, store)
+}
+
+func (s *EventLogTestSuite) listen() chan ClientEvent {
+	events := make(chan ClientEvent, 10)
+	stop := s.eventLog.Listen(func(event ClientEvent) {
+		if event.AgentID != probeAgentID {
+			events <- event
+		}
+	})
+	s.T().Cleanup(stop)
+	return events
+}
+
+func (s *EventLogTestSuite) receive(events chan ClientEvent) ClientEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(receiveTimeout):
+		s.FailNow("no event received")
+		return ClientEvent{}
+	}
+}
+
+func (s *EventLogTestSuite) TestDispatchesRecordedEventsWithTheirCursor() {
+	store := &clientEventStore{}
+	expectClientEvents(s.mockStorage, store)
+	s.start(store)
+	events := s.listen()
+
+	approval := worker.WorkerApprovalNotification{AgentID: "agent-1", Owner: "alice", ApprovalID: "approval-1"}
+	_, err := pubsub.PublishEvent(context.Background(), s.pubSub, worker.GetAgentApprovalTopic(), worker.AgentApprovalEvent, "agent-1", approval, time.Second)
+	s.Require().NoError(err)
+
+	event := s.receive(events)
+	s.Equal(int64(1), event.Cursor)
+	s.Equal("agent-1", event.AgentID)
+	s.Equal("alice", event.Owner)
+	s.Equal(WsEventTypeAgentApprovalRequest, event.Event)
+	var received worker.WorkerApprovalNotification
+	s.Require().NoError(decodeData(event.Data, &received))
+	s.Equal(approval, received)
+}
+
+func (s *EventLogTestSuite) TestDispatchesWithoutCursorWhenRecordingFails() {
+	store := &clientEventStore{}
+	s.mockStorage.EXPECT().RecordClientEvent(gomock.Any()).DoAndReturn(func(event storage.ClientEvent) (int64, error) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if event.AgentID == probeAgentID {
+			store.probed = true
+		}
+		return 0, errors.New("database unavailable")
+	}).AnyTimes()
+	s.mockStorage.EXPECT().GetLatestClientEventID().Return(int64(0), nil).AnyTimes()
+	s.mockStorage.EXPECT().ListAllClientEventsAfter(gomock.Any(), gomock.Any()).Return([]storage.ClientEvent{}, nil).AnyTimes()
+	s.start(store)
+	events := s.listen()
+
+	notification := worker.WorkerResponseNotification{AgentID: "agent-1", Response: "done"}
+	_, err := pubsub.PublishEvent(context.Background(), s.pubSub, worker.GetAgentResponseGeneralTopic(), worker.AgentResponseEvent, "agent-1", notification, time.Second)
+	s.Require().NoError(err)
+
+	event := s.receive(events)
+	s.Equal(int64(0), event.Cursor)
+	s.Equal(WsEventTypeAgentResponse, event.Event)
+}
+
+func (s *EventLogTestSuite) TestReplayReadsEveryBatch() {
+	store := &clientEventStore{}
+	for i := 1; i <= ReplayBatchSize+2; i++ {
+		store.events = append(store.events, storage.ClientEvent{ID: int64(i), AgentID: "agent-1", Event: string(WsEventTypeAgentProgress), Data: []byte(`{}`)})
+	}
+	expectClientEvents(s.mockStorage, store)
+
+	var cursors []int64
+	err := s.eventLog.Replay(1, []string{"agent-1"}, "", func(event ClientEvent) error {
+		cursors = append(cursors, event.Cursor)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Len(cursors, ReplayBatchSize+1)
+	s.Equal(int64(2), cursors[0])
+	s.Equal(int64(ReplayBatchSize+2), cursors[len(cursors)-1])
+}
+
+func (s *EventLogTestSuite) TestReplayStopsOnCallbackError() {
+	store := &clientEventStore{events: []storage.ClientEvent{
+		{ID: 1, AgentID: "agent-1", Data: []byte(`{}`)},
+		{ID: 2, AgentID: "agent-1", Data: []byte(`{}`)},
+	}}
+	expectClientEvents(s.mockStorage, store)
+
+	calls := 0
+	err := s.eventLog.Replay(0, []string{"agent-1"}, "", func(event ClientEvent) error {
+		calls++
+		return errors.New("connection closed")
+	})
+	s.Error(err)
+	s.Equal(1, calls)
+}
+
+func (s *EventLogTestSuite) TestDispatchesEventsOfEveryNodeInCursorOrder() {
+	store := &clientEventStore{}
+	expectClientEvents(s.mockStorage, store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Each node receives the agents' events from its own subscription, in its own order
+	nodeA := NewEventLog(EventLogConfig{PollInterval: 50 * time.Millisecond}, s.mockStorage, s.pubSub)
+	startEventLog(ctx, &s.Suite, nodeA, s.pubSub, store)
+	pubSubB := pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	defer pubSubB.Close()
+	nodeB := NewEventLog(EventLogConfig{PollInterval: 50 * time.Millisecond}, s.mockStorage, pubSubB)
+	startEventLog(ctx, &s.Suite, nodeB, pubSubB, store)
+
+	listen := func(eventLog *EventLogImpl) chan ClientEvent {
+		events := make(chan ClientEvent, 10)
+		stop := eventLog.Listen(func(event ClientEvent) {
+			events <- event
+		})
+		s.T().Cleanup(stop)
+		return events
+	}
+	eventsA := listen(nodeA)
+	eventsB := listen(nodeB)
+
+	newEvent := func(agentID string) *pubsub.Envelope {
+		notification := worker.WorkerResponseNotification{AgentID: agentID, Response: "done"}
+		envelope, err := pubsub.NewEnvelope(ctx, worker.AgentResponseEvent, agentID, notification)
+		s.Require().NoError(err)
+		return envelope
+	}
+	publish := func(ps pubsub.PubSub, envelope *pubsub.Envelope) {
+		s.Require().NoError(pubsub.PublishEnvelope(ctx, ps, worker.GetAgentResponseGeneralTopic(), envelope, time.Second))
+	}
+	eventX := newEvent("agent-x")
+	eventY := newEvent("agent-y")
+	// Node B records X first, node A records Y before it receives X
+	publish(pubSubB, eventX)
+	s.Require().Eventually(func() bool { return store.count() == 1 }, time.Second, 10*time.Millisecond)
+	publish(s.pubSub, eventY)
+	s.Require().Eventually(func() bool { return store.count() == 2 }, time.Second, 10*time.Millisecond)
+	publish(s.pubSub, eventX)
+
+	for name, events := range map[string]chan ClientEvent{"node A": eventsA, "node B": eventsB} {
+		first := s.receive(events)
+		second := s.receive(events)
+		s.Equal(int64(1), first.Cursor, name)
+		s.Equal("agent-x", first.AgentID, name)
+		s.Equal(int64(2), second.Cursor, name)
+		s.Equal("agent-y", second.AgentID, name)
+	}
+	time.Sleep(200 * time.Millisecond)
+	s.Empty(eventsA, "node A sent an event twice")
+	s.Empty(eventsB, "node B sent an event twice")
+}
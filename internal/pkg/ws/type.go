@@ -1,5 +1,14 @@
 package ws
 
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+)
+
 type WsEventType string
 
 const (
@@ -7,11 +16,166 @@ const (
 	WsEventTypePong          WsEventType = "pong"
 	WsEventTypeAgentResponse WsEventType = "agent_response"
 	WsEventTypeAgentProgress WsEventType = "agent_progress"
+	// WsEventTypeAgentApprovalRequest asks the user to decide on a tool call through the approvals API
+	WsEventTypeAgentApprovalRequest WsEventType = "agent_approval_request"
+	// WsEventTypeAck answers a client request that succeeded, its data is a WsAck
+	WsEventTypeAck WsEventType = "ack"
+	// WsEventTypeError answers a client request that failed, its data is a WsError
+	WsEventTypeError WsEventType = "error"
+)
+
+// Requests sent by the client, each is answered with an ack or an error carrying the request ID.
+const (
+	// WsEventTypeSetProgressFilter chooses the progress the client receives, its data is a ProgressFilter
+	WsEventTypeSetProgressFilter WsEventType = "set_progress_filter"
+	// WsEventTypeSubscribe adds agents to the ones the client receives events of, its data is a SubscribeRequest
+	WsEventTypeSubscribe WsEventType = "subscribe"
+	// WsEventTypeUnsubscribe removes agents from the ones the client receives events of, its data is a SubscribeRequest
+	WsEventTypeUnsubscribe WsEventType = "unsubscribe"
+	// WsEventTypeKickoffTask starts an agent owned by the connection's owner, its data is a KickoffTaskRequest
+	WsEventTypeKickoffTask WsEventType = "kickoff_task"
+	// WsEventTypeSendCommand sends a worker command to an agent, its data is a SendCommandRequest
+	WsEventTypeSendCommand WsEventType = "send_command"
+	// WsEventTypeAnswerAgent answers the question of an agent awaiting input, its data is an AnswerAgentRequest
+	WsEventTypeAnswerAgent WsEventType = "answer_agent"
+	// WsEventTypeResume replays the events of the subscribed agents after a cursor, its data is a ResumeRequest
+	WsEventTypeResume WsEventType = "resume"
 )
 
+// WsMessage is a message in either direction.
+// RequestID is chosen by the client for its requests and copied to their ack or error.
+// Cursor is set on agent events, the client keeps the last one received to resume after it when reconnecting.
 type WsMessage struct {
-	Event WsEventType `json:"event"`
-	Data  any         `json:"data"`
+	Event     WsEventType `json:"event"`
+	RequestID string      `json:"request_id,omitempty"`
+	Cursor    int64       `json:"cursor,omitempty"`
+	Data      any         `json:"data"`
+}
+
+// WsAck acknowledges a request, Result is specific to the request:
+// a SubscriptionResult for subscribe and unsubscribe, a ProgressFilter for set_progress_filter,
+// a TaskResult for kickoff_task and answer_agent, a SendCommandRequest for send_command and a ResumeResult for resume.
+type WsAck struct {
+	Request WsEventType `json:"request"`
+	Result  any         `json:"result,omitempty"`
+}
+
+// WsErrorCode classifies why a request failed.
+type WsErrorCode string
+
+const (
+	// WsErrorCodeBadRequest is returned for unknown events and malformed or incomplete requests
+	WsErrorCodeBadRequest WsErrorCode = "bad_request"
+	// WsErrorCodeNotFound is returned when the agent does not exist
+	WsErrorCodeNotFound WsErrorCode = "not_found"
+	// WsErrorCodeConflict is returned when the agent is not in a state that accepts the request
+	WsErrorCodeConflict WsErrorCode = "conflict"
+	// WsErrorCodeTooManyRequests is returned when the node cannot take more tasks
+	WsErrorCodeTooManyRequests WsErrorCode = "too_many_requests"
+	// WsErrorCodeUnavailable is returned when the node is shutting down
+	WsErrorCodeUnavailable WsErrorCode = "unavailable"
+	WsErrorCodeInternal    WsErrorCode = "internal"
+)
+
+type WsError struct {
+	Request WsEventType `json:"request,omitempty"`
+	Code    WsErrorCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+// SubscribeRequest selects agents by ID, or all the agents of the connection's owner, including those started later.
+type SubscribeRequest struct {
+	AgentIDs  []string `json:"agent_ids,omitempty"`
+	OwnAgents bool     `json:"own_agents,omitempty"`
+}
+
+// SubscriptionResult is the selection of agents the client receives events of after the request.
+type SubscriptionResult struct {
+	AgentIDs  []string `json:"agent_ids"`
+	OwnAgents bool     `json:"own_agents"`
+}
+
+// KickoffTaskRequest mirrors the agent creation API, the owner is the connection's owner.
+type KickoffTaskRequest struct {
+	Role         string            `json:"role"`
+	Task         string            `json:"task"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Budget       int               `json:"budget,omitempty"`
+	CustomPrompt string            `json:"custom_prompt,omitempty"`
+	Priority     int               `json:"priority,omitempty"`
+	WakePolicy   string            `json:"wake_policy,omitempty"`
+	Interests    []string          `json:"interests,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+}
+
+type SendCommandRequest struct {
+	AgentID string `json:"agent_id"`
+	Command string `json:"command"`
+}
+
+type AnswerAgentRequest struct {
+	AgentID string `json:"agent_id"`
+	Answer  string `json:"answer"`
+}
+
+// TaskResult tells which agent took the task, its result is sent later as an agent_response event.
+type TaskResult struct {
+	AgentID string `json:"agent_id"`
+	Status  string `json:"status"`
+}
+
+// ResumeRequest asks for the events after the cursor, 0 replays every event still retained.
+type ResumeRequest struct {
+	Cursor int64 `json:"cursor"`
+}
+
+// ResumeResult is sent once the missed events were replayed, before the live ones.
+// Cursor is the last replayed cursor, or the requested one when nothing was missed.
+type ResumeResult struct {
+	Cursor   int64 `json:"cursor"`
+	Replayed int   `json:"replayed"`
+}
+
+// ClientEvent is an agent event as sent to clients, Data is the notification as JSON.
+// Cursor is 0 when the event could not be recorded, it is then sent live but cannot be replayed.
+type ClientEvent struct {
+	Cursor  int64
+	AgentID string
+	Owner   string
+	Event   WsEventType
+	Data    json.RawMessage
+}
+
+// EventLog hands the agent events to the connections of the node and replays them to resuming clients.
+type EventLog interface {
+	Start(ctx context.Context) error
+	Listen(listener func(ClientEvent)) (stop func())
+	Replay(afterCursor int64, agentIDs []string, owner string, callback func(ClientEvent) error) error
+}
+
+// ProgressFilter selects the agent progress sent to a client.
+// Types lists the progress types to send, all of them when empty,
+// Level is the verbosity, progress more verbose than it is not sent.
+type ProgressFilter struct {
+	Types []worker.ProgressType `json:"types,omitempty"`
+	Level worker.ProgressLevel  `json:"level"`
+}
+
+// DefaultProgressFilter sends every progress type up to the info level.
+var DefaultProgressFilter = ProgressFilter{Level: worker.ProgressLevelInfo}
+
+// Matches returns whether the progress passes the filter.
+// Progress without a level, published before progress was structured, is treated as info.
+func (f ProgressFilter) Matches(notification worker.WorkerProgressNotification) bool {
+	level := notification.Level
+	if level == "" {
+		level = worker.ProgressLevelInfo
+	}
+	if !f.Level.Includes(level) {
+		return false
+	}
+	return len(f.Types) == 0 || slices.Contains(f.Types, notification.Type)
 }
 
 type WsConnection interface {
@@ -19,6 +183,7 @@ type WsConnection interface {
 	ReadJSON(v interface{}) error
 	WriteMessage(mt int, message []byte) error
 	WriteJSON(message interface{}) error
+	SetWriteDeadline(t time.Time) error
 	Close() error
 }
 
@@ -0,0 +1,25 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressFilterMatches(t *testing.T) {
+	toolCall := worker.WorkerProgressNotification{Type: worker.ProgressTypeToolCall, Level: worker.ProgressLevelInfo}
+	toolResult := worker.WorkerProgressNotification{Type: worker.ProgressTypeToolResult, Level: worker.ProgressLevelDebug}
+	legacy := worker.WorkerProgressNotification{Progress: "Calling tool: report"}
+
+	assert.True(t, DefaultProgressFilter.Matches(toolCall))
+	assert.False(t, DefaultProgressFilter.Matches(toolResult))
+	assert.True(t, DefaultProgressFilter.Matches(legacy))
+
+	debug := ProgressFilter{Level: worker.ProgressLevelDebug}
+	assert.True(t, debug.Matches(toolResult))
+
+	toolResultsOnly := ProgressFilter{Types: []worker.ProgressType{worker.ProgressTypeToolResult}, Level: worker.ProgressLevelDebug}
+	assert.True(t, toolResultsOnly.Matches(toolResult))
+	assert.False(t, toolResultsOnly.Matches(toolCall))
+}
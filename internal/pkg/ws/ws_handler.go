@@ -3,39 +3,150 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
 	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
 	"github.com/roackb2/lucid/internal/pkg/pubsub"
 )
 
+const (
+	// WsEventDeduplicatorCapacity is the number of events remembered to drop duplicates
+	WsEventDeduplicatorCapacity = 1000
+	// WsSendQueueSize is the number of messages waiting to be written to a client, a client further behind is disconnected.
+	// It holds more than the events the event log reads at once, so a client keeping up is not disconnected by a burst.
+	WsSendQueueSize = 2 * ReplayBatchSize
+	// WsWriteTimeout is how long writing a message to a client may take before the client is disconnected
+	WsWriteTimeout = 10 * time.Second
+)
+
+var (
+	// errBadRequest marks the requests rejected before reaching the control plane
+	errBadRequest = errors.New("bad request")
+	// errConnectionClosed is returned when writing to a connection that is no longer written to
+	errConnectionClosed = errors.New("connection closed")
+	// errClientTooSlow is returned when the client's send queue is full
+	errClientTooSlow = errors.New("client too slow")
+)
+
+// WsHandlerImpl serves a client connection.
+// The client only receives the events of the agents it subscribed to, by ID or as the agents of the connection's owner,
+// and can start, command and answer agents through the control plane.
+// Events carry the cursor of the event log, a client reconnecting with its last cursor first gets the events it missed.
+// Messages are queued and written by a goroutine of the connection, so a slow client never holds up the events of the others,
+// a client falling too far behind is disconnected and can resume from its last cursor.
 type WsHandlerImpl struct {
-	conn   WsConnection
-	pubsub pubsub.PubSub
+	conn         WsConnection
+	eventLog     EventLog
+	controlPlane control_plane.ControlPlane
+	owner        string
+	resumeCursor *int64
+	// deduplicator remembers the cursors written, as a replayed event may also be received live
+	deduplicator *pubsub.Deduplicator
+
+	// sendQueue holds the messages for the writer, as connections do not support concurrent writers
+	sendQueue chan WsMessage
+	// closed stops the writer and the messages queued after it
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// mux guards the client's choice of events
+	mux            sync.RWMutex
+	progressFilter ProgressFilter
+	agentIDs       map[string]struct{}
+	ownAgents      bool
+
+	// resumeMux runs one replay at a time, deliveryMux holds the live events while replaying
+	resumeMux   sync.Mutex
+	deliveryMux sync.Mutex
+	replaying   bool
+	pending     []ClientEvent
+}
+
+// NewWsHandler creates the handler of a connection opened by the owner, which may be empty for anonymous clients.
+// A connection with an owner starts subscribed to the owner's agents.
+func NewWsHandler(conn WsConnection, eventLog EventLog, controlPlane control_plane.ControlPlane, owner string) *WsHandlerImpl {
+	return &WsHandlerImpl{
+		conn:           conn,
+		eventLog:       eventLog,
+		controlPlane:   controlPlane,
+		owner:          owner,
+		deduplicator:   pubsub.NewDeduplicator(WsEventDeduplicatorCapacity),
+		sendQueue:      make(chan WsMessage, WsSendQueueSize),
+		closed:         make(chan struct{}),
+		progressFilter: DefaultProgressFilter,
+		agentIDs:       make(map[string]struct{}),
+		ownAgents:      owner != "",
+	}
+}
+
+// SetProgressFilter replaces the filter of the progress sent to the client.
+// A filter without a level keeps the default verbosity.
+func (h *WsHandlerImpl) SetProgressFilter(filter ProgressFilter) error {
+	if filter.Level == "" {
+		filter.Level = DefaultProgressFilter.Level
+	}
+	if !filter.Level.Valid() {
+		return fmt.Errorf("unknown progress level: %s", filter.Level)
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.progressFilter = filter
+	return nil
 }
 
-func NewWsHandler(conn WsConnection, pubsub pubsub.PubSub) *WsHandlerImpl {
-	return &WsHandlerImpl{conn: conn, pubsub: pubsub}
+// SetResumeCursor makes the connection replay the events after the cursor before the live ones.
+func (h *WsHandlerImpl) SetResumeCursor(cursor int64) {
+	h.resumeCursor = &cursor
+}
+
+func (h *WsHandlerImpl) getProgressFilter() ProgressFilter {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.progressFilter
+}
+
+// isSubscribed returns whether the client receives the events of the agent.
+// Events published before they carried the owner only match subscriptions by ID.
+func (h *WsHandlerImpl) isSubscribed(agentID string, owner string) bool {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if _, ok := h.agentIDs[agentID]; ok {
+		return true
+	}
+	return h.ownAgents && owner != "" && owner == h.owner
 }
 
 func (h *WsHandlerImpl) HandleConnection(ctx context.Context) error {
 	var wg sync.WaitGroup
-	ctx, cancel := context.WithCancel(ctx)
+	// Agents outlive the connection, so requests are served with the caller's context
+	connCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	go h.writeLoop()
+	defer h.stopWriting()
 
-	err := h.subscribeToEvents()
-	if err != nil {
-		slog.Error("subscribeToEvents:", "error", err)
-		return err
+	// Listening stops when the connection ends, so messages stop being written to the closed connection
+	stopListening := h.eventLog.Listen(h.onEvent)
+	defer stopListening()
+	if h.resumeCursor != nil {
+		if _, _, err := h.resume(*h.resumeCursor); err != nil {
+			slog.Error("resume:", "error", err)
+			return err
+		}
 	}
 
 	for {
 		select {
-		case <-ctx.Done():
+		case <-connCtx.Done():
 			wg.Wait()
-			return ctx.Err()
+			return connCtx.Err()
 		default:
 			var msg WsMessage
 			err := h.conn.ReadJSON(&msg)
@@ -44,12 +155,12 @@ func (h *WsHandlerImpl) HandleConnection(ctx context.Context) error {
 				wg.Wait()
 				return err
 			}
-			slog.Info("Received message", "event", msg.Event)
+			slog.Info("Received message", "event", msg.Event, "request_id", msg.RequestID)
 
 			wg.Add(1)
 			go func(msg WsMessage) {
 				defer wg.Done()
-				err := h.handleMessage(msg)
+				err := h.handleMessage(ctx, msg)
 				if err != nil {
 					slog.Error("handleMessage:", "error", err)
 				}
@@ -58,12 +169,126 @@ func (h *WsHandlerImpl) HandleConnection(ctx context.Context) error {
 	}
 }
 
-func (w *WsHandlerImpl) handleMessage(msg WsMessage) error {
+// writeMessage queues the message, waiting while the client's queue is full.
+func (w *WsHandlerImpl) writeMessage(msg WsMessage) error {
+	select {
+	case w.sendQueue <- msg:
+		return nil
+	case <-w.closed:
+		return errConnectionClosed
+	}
+}
+
+// sendLiveMessage queues the message without waiting, as live events are dispatched to every client at once.
+// A client whose queue is full is disconnected.
+func (w *WsHandlerImpl) sendLiveMessage(msg WsMessage) error {
+	select {
+	case w.sendQueue <- msg:
+		return nil
+	case <-w.closed:
+		return errConnectionClosed
+	default:
+		slog.Warn("Client too slow, disconnecting", "owner", w.owner, "queued", len(w.sendQueue))
+		w.disconnect()
+		return errClientTooSlow
+	}
+}
+
+// writeLoop writes the queued messages until the connection ends, a client failing to take a message in time is disconnected.
+func (w *WsHandlerImpl) writeLoop() {
+	for {
+		select {
+		case <-w.closed:
+			return
+		case msg := <-w.sendQueue:
+			err := w.conn.SetWriteDeadline(time.Now().Add(WsWriteTimeout))
+			if err == nil {
+				err = w.conn.WriteJSON(msg)
+			}
+			if err != nil {
+				slog.Error("write:", "error", err)
+				w.disconnect()
+				return
+			}
+		}
+	}
+}
+
+func (w *WsHandlerImpl) stopWriting() {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+	})
+}
+
+// disconnect closes the connection, which ends the read loop of HandleConnection.
+func (w *WsHandlerImpl) disconnect() {
+	w.stopWriting()
+	if err := w.conn.Close(); err != nil {
+		slog.Warn("Failed to close connection", "error", err)
+	}
+}
+
+// handleMessage serves a client message, answering requests with an ack or an error.
+func (w *WsHandlerImpl) handleMessage(ctx context.Context, msg WsMessage) error {
+	var result any
+	var err error
 	switch msg.Event {
 	case WsEventTypePing:
 		return w.handlePing(msg)
+	case WsEventTypeSetProgressFilter:
+		result, err = w.handleSetProgressFilter(msg)
+	case WsEventTypeSubscribe:
+		result, err = w.handleSubscribe(msg)
+	case WsEventTypeUnsubscribe:
+		result, err = w.handleUnsubscribe(msg)
+	case WsEventTypeKickoffTask:
+		result, err = w.handleKickoffTask(ctx, msg)
+	case WsEventTypeSendCommand:
+		result, err = w.handleSendCommand(ctx, msg)
+	case WsEventTypeAnswerAgent:
+		result, err = w.handleAnswerAgent(ctx, msg)
+	case WsEventTypeResume:
+		result, err = w.handleResume(msg)
 	default:
-		return fmt.Errorf("unknown event: %s", msg.Event)
+		err = fmt.Errorf("%w: unknown event: %s", errBadRequest, msg.Event)
+	}
+	if err != nil {
+		slog.Warn("Request failed", "event", msg.Event, "request_id", msg.RequestID, "error", err)
+		return w.writeMessage(WsMessage{
+			Event:     WsEventTypeError,
+			RequestID: msg.RequestID,
+			Data:      WsError{Request: msg.Event, Code: errorCode(err), Message: err.Error()},
+		})
+	}
+	return w.writeMessage(WsMessage{
+		Event:     WsEventTypeAck,
+		RequestID: msg.RequestID,
+		Data:      WsAck{Request: msg.Event, Result: result},
+	})
+}
+
+// errorCode classifies the error like the HTTP API maps it to a status code.
+func errorCode(err error) WsErrorCode {
+	switch {
+	case errors.Is(err, errBadRequest), errors.Is(err, control_plane.ErrInvalidAgentCommand):
+		return WsErrorCodeBadRequest
+	case errors.Is(err, storage.ErrAgentNotFound):
+		return WsErrorCodeNotFound
+	case errors.Is(err, control_plane.ErrAgentNotAwaitingInput),
+		errors.Is(err, control_plane.ErrAgentTerminated),
+		errors.Is(err, control_plane.ErrAgentNotFailed),
+		errors.Is(err, control_plane.ErrAgentCommandNotAllowed),
+		errors.Is(err, control_plane.ErrAgentBusy),
+		errors.Is(err, control_plane.ErrAgentFailed),
+		errors.Is(err, control_plane.ErrAgentAwaitingInput),
+		errors.Is(err, control_plane.ErrAgentAwaitingApproval):
+		return WsErrorCodeConflict
+	case errors.Is(err, control_plane.ErrRunQueueFull):
+		return WsErrorCodeTooManyRequests
+	case errors.Is(err, control_plane.ErrRunQueueClosed):
+		return WsErrorCodeUnavailable
+	default:
+		return WsErrorCodeInternal
 	}
 }
 
@@ -71,63 +296,264 @@ func (w *WsHandlerImpl) handlePing(msg WsMessage) error {
 	slog.Info("Received ping message")
 
 	respMsg := WsMessage{
-		Event: WsEventTypePong,
-		Data:  fmt.Sprintf("pong: %s", msg.Data),
+		Event:     WsEventTypePong,
+		RequestID: msg.RequestID,
+		Data:      fmt.Sprintf("pong: %s", msg.Data),
 	}
 
-	w.conn.WriteJSON(respMsg)
-	return nil
+	return w.writeMessage(respMsg)
 }
 
-func (w *WsHandlerImpl) subscribeToEvents() error {
-	err := w.pubsub.Subscribe(worker.GetAgentProgressTopic(), w.handleAgentProgress)
-	if err != nil {
-		slog.Error("Failed to subscribe to agent progress topic", "error", err)
-		return err
+func (w *WsHandlerImpl) handleSetProgressFilter(msg WsMessage) (any, error) {
+	var filter ProgressFilter
+	if err := decodeData(msg.Data, &filter); err != nil {
+		return nil, err
+	}
+	if err := w.SetProgressFilter(filter); err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	slog.Info("Set progress filter", "types", filter.Types, "level", filter.Level)
+	return w.getProgressFilter(), nil
+}
+
+func (w *WsHandlerImpl) handleSubscribe(msg WsMessage) (any, error) {
+	var req SubscribeRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if len(req.AgentIDs) == 0 && !req.OwnAgents {
+		return nil, fmt.Errorf("%w: agent_ids or own_agents is required", errBadRequest)
 	}
-	err = w.pubsub.Subscribe(worker.GetAgentResponseGeneralTopic(), w.handleAgentResponse)
+	if req.OwnAgents && w.owner == "" {
+		return nil, fmt.Errorf("%w: connect with an owner to subscribe to own agents", errBadRequest)
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for _, agentID := range req.AgentIDs {
+		w.agentIDs[agentID] = struct{}{}
+	}
+	w.ownAgents = w.ownAgents || req.OwnAgents
+	slog.Info("Subscribed to agents", "agent_ids", req.AgentIDs, "own_agents", req.OwnAgents, "owner", w.owner)
+	return w.subscriptionResult(), nil
+}
+
+func (w *WsHandlerImpl) handleUnsubscribe(msg WsMessage) (any, error) {
+	var req SubscribeRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if len(req.AgentIDs) == 0 && !req.OwnAgents {
+		return nil, fmt.Errorf("%w: agent_ids or own_agents is required", errBadRequest)
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for _, agentID := range req.AgentIDs {
+		delete(w.agentIDs, agentID)
+	}
+	w.ownAgents = w.ownAgents && !req.OwnAgents
+	slog.Info("Unsubscribed from agents", "agent_ids", req.AgentIDs, "own_agents", req.OwnAgents, "owner", w.owner)
+	return w.subscriptionResult(), nil
+}
+
+// subscriptionResult must be called with mux held.
+func (w *WsHandlerImpl) subscriptionResult() SubscriptionResult {
+	agentIDs := make([]string, 0, len(w.agentIDs))
+	for agentID := range w.agentIDs {
+		agentIDs = append(agentIDs, agentID)
+	}
+	slices.Sort(agentIDs)
+	return SubscriptionResult{AgentIDs: agentIDs, OwnAgents: w.ownAgents}
+}
+
+// handleKickoffTask starts the agent and subscribes the client to it, so it receives its events whatever its owner.
+func (w *WsHandlerImpl) handleKickoffTask(ctx context.Context, msg WsMessage) (any, error) {
+	var req KickoffTaskRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if req.Role == "" || req.Task == "" {
+		return nil, fmt.Errorf("%w: role and task are required", errBadRequest)
+	}
+	metadata := worker.TaskMetadata{
+		Owner:        w.owner,
+		Labels:       req.Labels,
+		Budget:       req.Budget,
+		CustomPrompt: req.CustomPrompt,
+		Priority:     req.Priority,
+		WakePolicy:   req.WakePolicy,
+		Interests:    req.Interests,
+		Description:  req.Description,
+		Tags:         req.Tags,
+	}
+	agentID, _, err := w.controlPlane.KickoffTask(ctx, req.Task, req.Role, metadata)
 	if err != nil {
-		slog.Error("Failed to subscribe to agent response topic", "error", err)
-		return err
+		return nil, err
 	}
-	return nil
+	w.mux.Lock()
+	w.agentIDs[agentID] = struct{}{}
+	w.mux.Unlock()
+	slog.Info("Started agent", "agent_id", agentID, "role", req.Role, "owner", w.owner)
+	return TaskResult{AgentID: agentID, Status: worker.StatusRunning}, nil
+}
+
+func (w *WsHandlerImpl) handleSendCommand(ctx context.Context, msg WsMessage) (any, error) {
+	var req SendCommandRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if req.AgentID == "" || req.Command == "" {
+		return nil, fmt.Errorf("%w: agent_id and command are required", errBadRequest)
+	}
+	if err := w.controlPlane.SendAgentCommand(ctx, req.AgentID, req.Command); err != nil {
+		return nil, err
+	}
+	slog.Info("Sent agent command", "agent_id", req.AgentID, "command", req.Command)
+	return req, nil
+}
+
+func (w *WsHandlerImpl) handleAnswerAgent(ctx context.Context, msg WsMessage) (any, error) {
+	var req AnswerAgentRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if req.AgentID == "" || req.Answer == "" {
+		return nil, fmt.Errorf("%w: agent_id and answer are required", errBadRequest)
+	}
+	if _, err := w.controlPlane.AnswerAgent(ctx, req.AgentID, req.Answer); err != nil {
+		return nil, err
+	}
+	slog.Info("Answered agent", "agent_id", req.AgentID)
+	return TaskResult{AgentID: req.AgentID, Status: worker.StatusRunning}, nil
 }
 
-func (w *WsHandlerImpl) handleAgentProgress(message string) error {
-	slog.Info("Received agent progress", "message", message)
-	notification := worker.WorkerProgressNotification{}
-	err := json.Unmarshal([]byte(message), &notification)
+func (w *WsHandlerImpl) handleResume(msg WsMessage) (any, error) {
+	var req ResumeRequest
+	if err := decodeData(msg.Data, &req); err != nil {
+		return nil, err
+	}
+	if req.Cursor < 0 {
+		return nil, fmt.Errorf("%w: cursor must not be negative", errBadRequest)
+	}
+	cursor, replayed, err := w.resume(req.Cursor)
 	if err != nil {
-		slog.Error("Failed to unmarshal agent progress notification", "error", err)
-		return err
+		return nil, err
 	}
-	// TODO: Filter messages with client specified agent id
-	err = w.conn.WriteJSON(WsMessage{
-		Event: WsEventTypeAgentProgress,
-		Data:  notification.Progress,
-	})
+	return ResumeResult{Cursor: cursor, Replayed: replayed}, nil
+}
+
+// decodeData decodes the data of a client message, which was decoded as generic JSON, into v.
+func decodeData(data any, v any) error {
+	encoded, err := json.Marshal(data)
 	if err != nil {
-		slog.Error("Failed to write agent progress message", "error", err)
-		return err
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	if err := json.Unmarshal(encoded, v); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
 	}
 	return nil
 }
 
-func (w *WsHandlerImpl) handleAgentResponse(message string) error {
-	slog.Info("Received agent response", "message", message)
-	notification := worker.WorkerResponseNotification{}
-	err := json.Unmarshal([]byte(message), &notification)
-	if err != nil {
-		return err
+// onEvent sends the agent event to the client if it is subscribed to the agent,
+// or holds it while missed events are replayed so it is sent after them.
+func (w *WsHandlerImpl) onEvent(event ClientEvent) {
+	w.deliveryMux.Lock()
+	defer w.deliveryMux.Unlock()
+	if w.replaying {
+		if len(w.pending) == WsSendQueueSize {
+			slog.Warn("Client too slow to replay, disconnecting", "owner", w.owner)
+			w.disconnect()
+			return
+		}
+		w.pending = append(w.pending, event)
+		return
+	}
+	if _, err := w.deliver(event, w.sendLiveMessage); err != nil && !errors.Is(err, errConnectionClosed) {
+		slog.Error("Failed to write agent event", "event", event.Event, "cursor", event.Cursor, "error", err)
+	}
+}
+
+// deliver sends the event if the client selected it, returning whether it was sent.
+// An event is sent once even when it is both replayed and received live.
+func (w *WsHandlerImpl) deliver(event ClientEvent, send func(WsMessage) error) (bool, error) {
+	if !w.isSubscribed(event.AgentID, event.Owner) {
+		return false, nil
+	}
+	if event.Event == WsEventTypeAgentProgress {
+		var notification worker.WorkerProgressNotification
+		if err := json.Unmarshal(event.Data, &notification); err != nil {
+			return false, err
+		}
+		if !w.getProgressFilter().Matches(notification) {
+			return false, nil
+		}
+	}
+	if event.Cursor != 0 {
+		cursorKey := strconv.FormatInt(event.Cursor, 10)
+		if w.deduplicator.Seen(cursorKey) {
+			return false, nil
+		}
+		w.deduplicator.MarkSeen(cursorKey)
 	}
-	// TODO: Filter messages with client specified agent id
-	err = w.conn.WriteJSON(WsMessage{
-		Event: WsEventTypeAgentResponse,
-		Data:  notification.Response,
+	err := send(WsMessage{
+		Event:  event.Event,
+		Cursor: event.Cursor,
+		Data:   event.Data,
+	})
+	return err == nil, err
+}
+
+// resume replays the events of the subscribed agents after the cursor, then sends the live events received meanwhile.
+// It returns the last replayed cursor and the number of events replayed.
+func (w *WsHandlerImpl) resume(afterCursor int64) (int64, int, error) {
+	w.resumeMux.Lock()
+	defer w.resumeMux.Unlock()
+
+	w.deliveryMux.Lock()
+	w.replaying = true
+	w.deliveryMux.Unlock()
+	defer func() {
+		w.deliveryMux.Lock()
+		defer w.deliveryMux.Unlock()
+		// Live events are held while the pending ones are sent, so they must not wait for the client either
+		for _, event := range w.pending {
+			if _, err := w.deliver(event, w.sendLiveMessage); err != nil && !errors.Is(err, errConnectionClosed) {
+				slog.Error("Failed to write agent event", "event", event.Event, "cursor", event.Cursor, "error", err)
+			}
+		}
+		w.pending = nil
+		w.replaying = false
+	}()
+
+	w.mux.RLock()
+	agentIDs := make([]string, 0, len(w.agentIDs))
+	for agentID := range w.agentIDs {
+		agentIDs = append(agentIDs, agentID)
+	}
+	owner := ""
+	if w.ownAgents {
+		owner = w.owner
+	}
+	w.mux.RUnlock()
+
+	lastCursor := afterCursor
+	replayed := 0
+	if len(agentIDs) == 0 && owner == "" {
+		return lastCursor, replayed, nil
+	}
+	err := w.eventLog.Replay(afterCursor, agentIDs, owner, func(event ClientEvent) error {
+		written, err := w.deliver(event, w.writeMessage)
+		if err != nil {
+			return err
+		}
+		lastCursor = event.Cursor
+		if written {
+			replayed++
+		}
+		return nil
 	})
 	if err != nil {
-		slog.Error("Failed to write agent response message", "error", err)
-		return err
+		return lastCursor, replayed, err
 	}
-	return nil
+	slog.Info("Replayed missed events", "after_cursor", afterCursor, "cursor", lastCursor, "replayed", replayed, "owner", w.owner)
+	return lastCursor, replayed, nil
 }
@@ -0,0 +1,422 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	mock_control_plane "github.com/roackb2/lucid/test/_mocks/control_plane"
+	mock_storage "github.com/roackb2/lucid/test/_mocks/storage"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+)
+
+const receiveTimeout = time.Second
+
+// fakeConn passes messages through JSON like a websocket connection.
+// Writes wait until the test reads them or the connection is closed.
+type fakeConn struct {
+	in        chan []byte
+	out       chan WsMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeConn(outSize int) *fakeConn {
+	return &fakeConn{in: make(chan []byte, 10), out: make(chan WsMessage, outSize), closed: make(chan struct{})}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, errors.New("not supported")
+}
+
+func (c *fakeConn) ReadJSON(v interface{}) error {
+	select {
+	case message, ok := <-c.in:
+		if !ok {
+			return io.EOF
+		}
+		return json.Unmarshal(message, v)
+	case <-c.closed:
+		return net.ErrClosed
+	}
+}
+
+func (c *fakeConn) WriteMessage(mt int, message []byte) error {
+	return errors.New("not supported")
+}
+
+func (c *fakeConn) WriteJSON(message interface{}) error {
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	var msg WsMessage
+	if err := json.Unmarshal(encoded, &msg); err != nil {
+		return err
+	}
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.closed:
+		return net.ErrClosed
+	}
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+type WsHandlerTestSuite struct {
+	suite.Suite
+	mockCtrl         *gomock.Controller
+	mockControlPlane *mock_control_plane.MockControlPlane
+	mockStorage      *mock_storage.MockStorage
+	pubSub           *pubsub.MemoryPubSub
+	store            *clientEventStore
+	eventLog         *EventLogImpl
+	cancel           context.CancelFunc
+	conn             *fakeConn
+	done             chan struct{}
+}
+
+func (s *WsHandlerTestSuite) SetupTest() {
+	s.mockCtrl = gomock.NewController(s.T())
+	s.mockControlPlane = mock_control_plane.NewMockControlPlane(s.mockCtrl)
+	s.mockStorage = mock_storage.NewMockStorage(s.mockCtrl)
+	s.pubSub = pubsub.NewMemoryPubSub(pubsub.MemoryPubSubConfig{})
+	s.store = &clientEventStore{}
+	expectClientEvents(s.mockStorage, s.store)
+	s.eventLog = NewEventLog(EventLogConfig{}, s.mockStorage, s.pubSub)
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	startEventLog(ctx, &s.Suite, s.eventLog, s.pubSub, s.store)
+	s.conn = newFakeConn(10)
+}
+
+func (s *WsHandlerTestSuite) TearDownTest() {
+	if s.done != nil {
+		close(s.conn.in)
+		<-s.done
+		s.done = nil
+	}
+	s.cancel()
+	s.pubSub.Close()
+	s.mockCtrl.Finish()
+}
+
+func TestWsHandlerSuite(t *testing.T) {
+	suite.Run(t, new(WsHandlerTestSuite))
+}
+
+// connect serves the connection of the owner and waits until it is subscribed to the agents' events.
+func (s *WsHandlerTestSuite) connect(owner string) {
+	s.serve(NewWsHandler(s.conn, s.eventLog, s.mockControlPlane, owner))
+	s.ping()
+}
+
+func (s *WsHandlerTestSuite) serve(handler *WsHandlerImpl) {
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		handler.HandleConnection(context.Background())
+	}()
+}
+
+// ping waits until the connection reads messages, which it only does once listening and done replaying.
+func (s *WsHandlerTestSuite) ping() {
+	s.send(WsEventTypePing, "ping", "hello")
+	s.Equal(WsEventTypePong, s.receive().Event)
+}
+
+func (s *WsHandlerTestSuite) send(event WsEventType, requestID string, data any) {
+	message, err := json.Marshal(WsMessage{Event: event, RequestID: requestID, Data: data})
+	s.Require().NoError(err)
+	s.conn.in <- message
+}
+
+func (s *WsHandlerTestSuite) receive() WsMessage {
+	select {
+	case msg := <-s.conn.out:
+		return msg
+	case <-time.After(receiveTimeout):
+		s.FailNow("no message received")
+		return WsMessage{}
+	}
+}
+
+// receiveAck waits for the ack of the request and decodes its result.
+func (s *WsHandlerTestSuite) receiveAck(requestID string, result any) {
+	msg := s.receive()
+	s.Require().Equal(WsEventTypeAck, msg.Event, "unexpected message: %+v", msg)
+	s.Equal(requestID, msg.RequestID)
+	var ack WsAck
+	s.Require().NoError(decodeData(msg.Data, &ack))
+	if result != nil {
+		s.Require().NoError(decodeData(ack.Result, result))
+	}
+}
+
+func (s *WsHandlerTestSuite) receiveError(requestID string) WsError {
+	msg := s.receive()
+	s.Require().Equal(WsEventTypeError, msg.Event, "unexpected message: %+v", msg)
+	s.Equal(requestID, msg.RequestID)
+	var wsErr WsError
+	s.Require().NoError(decodeData(msg.Data, &wsErr))
+	return wsErr
+}
+
+func (s *WsHandlerTestSuite) publishProgress(agentID string, owner string, progress string) {
+	notification := worker.WorkerProgressNotification{AgentID: agentID, Owner: owner, Progress: progress, Level: worker.ProgressLevelInfo}
+	_, err := pubsub.PublishEvent(context.Background(), s.pubSub, worker.GetAgentProgressTopic(), worker.AgentProgressEvent, agentID, notification, time.Second)
+	s.Require().NoError(err)
+}
+
+func (s *WsHandlerTestSuite) receiveProgress() worker.WorkerProgressNotification {
+	_, notification := s.receiveProgressWithCursor()
+	return notification
+}
+
+func (s *WsHandlerTestSuite) receiveProgressWithCursor() (int64, worker.WorkerProgressNotification) {
+	msg := s.receive()
+	s.Require().Equal(WsEventTypeAgentProgress, msg.Event, "unexpected message: %+v", msg)
+	var notification worker.WorkerProgressNotification
+	s.Require().NoError(decodeData(msg.Data, &notification))
+	return msg.Cursor, notification
+}
+
+// waitRecorded waits until the event log recorded the number of events published by the test.
+func (s *WsHandlerTestSuite) waitRecorded(count int) {
+	s.Require().Eventually(func() bool { return s.store.count() == count }, time.Second, 10*time.Millisecond)
+}
+
+func (s *WsHandlerTestSuite) TestReceivesEventsOfOwnAgents() {
+	s.connect("alice")
+
+	s.publishProgress("bob-agent", "bob", "not for alice")
+	s.publishProgress("alice-agent", "alice", "for alice")
+	s.Equal("for alice", s.receiveProgress().Progress)
+
+	response := worker.WorkerResponseNotification{AgentID: "alice-agent", Owner: "alice", Response: "done"}
+	_, err := pubsub.PublishEvent(context.Background(), s.pubSub, worker.GetAgentResponseGeneralTopic(), worker.AgentResponseEvent, "alice-agent", response, time.Second)
+	s.Require().NoError(err)
+	msg := s.receive()
+	s.Equal(WsEventTypeAgentResponse, msg.Event)
+	var received worker.WorkerResponseNotification
+	s.Require().NoError(decodeData(msg.Data, &received))
+	s.Equal(response, received)
+}
+
+func (s *WsHandlerTestSuite) TestSubscribeByAgentID() {
+	s.connect("")
+
+	s.send(WsEventTypeSubscribe, "own", SubscribeRequest{OwnAgents: true})
+	s.Equal(WsErrorCodeBadRequest, s.receiveError("own").Code)
+
+	var result SubscriptionResult
+	s.send(WsEventTypeSubscribe, "sub", SubscribeRequest{AgentIDs: []string{"agent-1", "agent-2"}})
+	s.receiveAck("sub", &result)
+	s.Equal(SubscriptionResult{AgentIDs: []string{"agent-1", "agent-2"}}, result)
+
+	s.publishProgress("agent-1", "bob", "first")
+	s.Equal("first", s.receiveProgress().Progress)
+
+	s.send(WsEventTypeUnsubscribe, "unsub", SubscribeRequest{AgentIDs: []string{"agent-1"}})
+	s.receiveAck("unsub", &result)
+	s.Equal(SubscriptionResult{AgentIDs: []string{"agent-2"}}, result)
+
+	s.publishProgress("agent-1", "bob", "unsubscribed")
+	s.publishProgress("agent-2", "bob", "second")
+	s.Equal("second", s.receiveProgress().Progress)
+}
+
+func (s *WsHandlerTestSuite) TestKickoffTaskSubscribesToTheAgent() {
+	s.connect("alice")
+	s.mockControlPlane.EXPECT().
+		KickoffTask(gomock.Any(), "find posts", worker.RoleConsumer, worker.TaskMetadata{Owner: "alice", Budget: 3}).
+		Return("agent-1", nil, nil)
+
+	var result TaskResult
+	s.send(WsEventTypeKickoffTask, "kickoff", KickoffTaskRequest{Role: worker.RoleConsumer, Task: "find posts", Budget: 3})
+	s.receiveAck("kickoff", &result)
+	s.Equal(TaskResult{AgentID: "agent-1", Status: worker.StatusRunning}, result)
+
+	// Progress published before the owner was carried only matches by ID
+	s.publishProgress("agent-1", "", "started")
+	s.Equal("started", s.receiveProgress().Progress)
+
+	s.send(WsEventTypeKickoffTask, "invalid", KickoffTaskRequest{Role: worker.RoleConsumer})
+	s.Equal(WsErrorCodeBadRequest, s.receiveError("invalid").Code)
+}
+
+func (s *WsHandlerTestSuite) TestSendCommand() {
+	s.connect("alice")
+	s.mockControlPlane.EXPECT().SendAgentCommand(gomock.Any(), "agent-1", worker.CmdPause).Return(nil)
+
+	var result SendCommandRequest
+	s.send(WsEventTypeSendCommand, "pause", SendCommandRequest{AgentID: "agent-1", Command: worker.CmdPause})
+	s.receiveAck("pause", &result)
+	s.Equal(SendCommandRequest{AgentID: "agent-1", Command: worker.CmdPause}, result)
+}
+
+func (s *WsHandlerTestSuite) TestAnswerAgent() {
+	s.connect("alice")
+	gomock.InOrder(
+		s.mockControlPlane.EXPECT().AnswerAgent(gomock.Any(), "agent-1", "Taipei").Return(nil, nil),
+		s.mockControlPlane.EXPECT().AnswerAgent(gomock.Any(), "agent-1", "Taipei").Return(nil, control_plane.ErrAgentNotAwaitingInput),
+	)
+
+	var result TaskResult
+	s.send(WsEventTypeAnswerAgent, "answer", AnswerAgentRequest{AgentID: "agent-1", Answer: "Taipei"})
+	s.receiveAck("answer", &result)
+	s.Equal(TaskResult{AgentID: "agent-1", Status: worker.StatusRunning}, result)
+
+	s.send(WsEventTypeAnswerAgent, "again", AnswerAgentRequest{AgentID: "agent-1", Answer: "Taipei"})
+	wsErr := s.receiveError("again")
+	s.Equal(WsErrorCodeConflict, wsErr.Code)
+	s.Equal(WsEventTypeAnswerAgent, wsErr.Request)
+}
+
+func (s *WsHandlerTestSuite) TestRejectsUnknownEvent() {
+	s.connect("alice")
+
+	s.send("dance", "unknown", nil)
+	s.Equal(WsErrorCodeBadRequest, s.receiveError("unknown").Code)
+}
+
+func (s *WsHandlerTestSuite) TestEventsCarryIncreasingCursors() {
+	s.connect("alice")
+
+	s.publishProgress("alice-agent", "alice", "first")
+	s.publishProgress("alice-agent", "alice", "second")
+	firstCursor, first := s.receiveProgressWithCursor()
+	secondCursor, second := s.receiveProgressWithCursor()
+	s.Equal("first", first.Progress)
+	s.Equal("second", second.Progress)
+	s.Positive(firstCursor)
+	s.Greater(secondCursor, firstCursor)
+}
+
+func (s *WsHandlerTestSuite) TestResumesFromCursorOnConnect() {
+	// Events sent while the client was away
+	s.publishProgress("alice-agent", "alice", "received before disconnecting")
+	s.publishProgress("bob-agent", "bob", "not for alice")
+	s.publishProgress("alice-agent", "alice", "missed")
+	s.waitRecorded(3)
+
+	handler := NewWsHandler(s.conn, s.eventLog, s.mockControlPlane, "alice")
+	handler.SetResumeCursor(1)
+	s.serve(handler)
+
+	cursor, missed := s.receiveProgressWithCursor()
+	s.Equal(int64(3), cursor)
+	s.Equal("missed", missed.Progress)
+	s.ping()
+
+	s.publishProgress("alice-agent", "alice", "live")
+	cursor, live := s.receiveProgressWithCursor()
+	s.Equal(int64(4), cursor)
+	s.Equal("live", live.Progress)
+}
+
+func (s *WsHandlerTestSuite) TestResumeReplaysSubscribedAgentsAfterCursor() {
+	s.publishProgress("agent-1", "bob", "started")
+	s.publishProgress("agent-2", "bob", "other agent")
+	s.publishProgress("agent-1", "bob", "still running")
+	s.waitRecorded(3)
+	s.connect("")
+
+	var subscription SubscriptionResult
+	s.send(WsEventTypeSubscribe, "sub", SubscribeRequest{AgentIDs: []string{"agent-1"}})
+	s.receiveAck("sub", &subscription)
+
+	s.send(WsEventTypeResume, "resume", ResumeRequest{Cursor: 0})
+	s.Equal("started", s.receiveProgress().Progress)
+	s.Equal("still running", s.receiveProgress().Progress)
+	var result ResumeResult
+	s.receiveAck("resume", &result)
+	s.Equal(ResumeResult{Cursor: 3, Replayed: 2}, result)
+
+	// Events already sent are not sent again
+	s.send(WsEventTypeResume, "again", ResumeRequest{Cursor: 1})
+	s.receiveAck("again", &result)
+	s.Equal(ResumeResult{Cursor: 3, Replayed: 0}, result)
+
+	s.send(WsEventTypeResume, "negative", ResumeRequest{Cursor: -1})
+	s.Equal(WsErrorCodeBadRequest, s.receiveError("negative").Code)
+}
+
+func (s *WsHandlerTestSuite) TestResumeAppliesProgressFilter() {
+	debug := worker.WorkerProgressNotification{AgentID: "alice-agent", Owner: "alice", Progress: "debug", Level: worker.ProgressLevelDebug}
+	_, err := pubsub.PublishEvent(context.Background(), s.pubSub, worker.GetAgentProgressTopic(), worker.AgentProgressEvent, "alice-agent", debug, time.Second)
+	s.Require().NoError(err)
+	s.publishProgress("alice-agent", "alice", "info")
+	s.waitRecorded(2)
+	s.connect("alice")
+
+	s.send(WsEventTypeResume, "resume", ResumeRequest{Cursor: 0})
+	s.Equal("info", s.receiveProgress().Progress)
+	var result ResumeResult
+	s.receiveAck("resume", &result)
+	s.Equal(ResumeResult{Cursor: 2, Replayed: 1}, result)
+}
+
+func (s *WsHandlerTestSuite) TestDisconnectsSlowClientWithoutHoldingUpOthers() {
+	// The slow client reads the pong of its ping and nothing after
+	slowConn := newFakeConn(0)
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		NewWsHandler(slowConn, s.eventLog, s.mockControlPlane, "alice").HandleConnection(context.Background())
+	}()
+	message, err := json.Marshal(WsMessage{Event: WsEventTypePing, RequestID: "ping", Data: "hello"})
+	s.Require().NoError(err)
+	slowConn.in <- message
+	select {
+	case msg := <-slowConn.out:
+		s.Equal(WsEventTypePong, msg.Event)
+	case <-time.After(receiveTimeout):
+		s.FailNow("no pong received")
+	}
+	s.conn = newFakeConn(2 * WsSendQueueSize)
+	s.connect("alice")
+
+	// The fast client reads the events as they come, while the slow client's queue fills up
+	count := WsSendQueueSize + 10
+	lastCursor := int64(0)
+	for sent := 0; sent < count; {
+		chunk := min(32, count-sent)
+		for i := 0; i < chunk; i++ {
+			s.publishProgress("alice-agent", "alice", fmt.Sprintf("progress %d", sent+i))
+		}
+		for i := 0; i < chunk; i++ {
+			cursor, _ := s.receiveProgressWithCursor()
+			s.Greater(cursor, lastCursor)
+			lastCursor = cursor
+		}
+		sent += chunk
+	}
+
+	select {
+	case <-slowDone:
+	case <-time.After(receiveTimeout):
+		s.FailNow("slow client not disconnected")
+	}
+	s.ping()
+}
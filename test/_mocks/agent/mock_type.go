@@ -68,6 +68,20 @@ func (mr *MockAgentMockRecorder) GetID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetID", reflect.TypeOf((*MockAgent)(nil).GetID))
 }
 
+// GetMetadata mocks base method.
+func (m *MockAgent) GetMetadata() worker.TaskMetadata {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetadata")
+	ret0, _ := ret[0].(worker.TaskMetadata)
+	return ret0
+}
+
+// GetMetadata indicates an expected call of GetMetadata.
+func (mr *MockAgentMockRecorder) GetMetadata() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockAgent)(nil).GetMetadata))
+}
+
 // GetRole mocks base method.
 func (m *MockAgent) GetRole() string {
 	m.ctrl.T.Helper()
@@ -139,6 +153,30 @@ func (mr *MockAgentMockRecorder) SendCommand(ctx, command any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCommand", reflect.TypeOf((*MockAgent)(nil).SendCommand), ctx, command)
 }
 
+// SetID mocks base method.
+func (m *MockAgent) SetID(agentID string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetID", agentID)
+}
+
+// SetID indicates an expected call of SetID.
+func (mr *MockAgentMockRecorder) SetID(agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetID", reflect.TypeOf((*MockAgent)(nil).SetID), agentID)
+}
+
+// SetMetadata mocks base method.
+func (m *MockAgent) SetMetadata(metadata worker.TaskMetadata) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMetadata", metadata)
+}
+
+// SetMetadata indicates an expected call of SetMetadata.
+func (mr *MockAgentMockRecorder) SetMetadata(metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMetadata", reflect.TypeOf((*MockAgent)(nil).SetMetadata), metadata)
+}
+
 // StartTask mocks base method.
 func (m *MockAgent) StartTask(ctx context.Context, callbacks worker.WorkerCallbacks) (*agent.AgentResponse, error) {
 	m.ctrl.T.Helper()
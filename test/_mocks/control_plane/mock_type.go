@@ -12,10 +12,12 @@ package mock_control_plane
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	agent "github.com/roackb2/lucid/internal/pkg/agents/agent"
 	providers "github.com/roackb2/lucid/internal/pkg/agents/providers"
 	storage "github.com/roackb2/lucid/internal/pkg/agents/storage"
+	worker "github.com/roackb2/lucid/internal/pkg/agents/worker"
 	control_plane "github.com/roackb2/lucid/internal/pkg/control_plane"
 	pubsub "github.com/roackb2/lucid/internal/pkg/pubsub"
 	gomock "go.uber.org/mock/gomock"
@@ -164,6 +166,20 @@ func (mr *MockAgentControllerMockRecorder) RegisterAgent(ctx, agent any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterAgent", reflect.TypeOf((*MockAgentController)(nil).RegisterAgent), ctx, agent)
 }
 
+// SendAgentCommand mocks base method.
+func (m *MockAgentController) SendAgentCommand(ctx context.Context, agentID, command string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendAgentCommand", ctx, agentID, command)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendAgentCommand indicates an expected call of SendAgentCommand.
+func (mr *MockAgentControllerMockRecorder) SendAgentCommand(ctx, agentID, command any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAgentCommand", reflect.TypeOf((*MockAgentController)(nil).SendAgentCommand), ctx, agentID, command)
+}
+
 // SendCommand mocks base method.
 func (m *MockAgentController) SendCommand(ctx context.Context, command string) error {
 	m.ctrl.T.Helper()
@@ -216,6 +232,34 @@ func (m *MockScheduler) EXPECT() *MockSchedulerMockRecorder {
 	return m.recorder
 }
 
+// GetNodeID mocks base method.
+func (m *MockScheduler) GetNodeID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNodeID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetNodeID indicates an expected call of GetNodeID.
+func (mr *MockSchedulerMockRecorder) GetNodeID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeID", reflect.TypeOf((*MockScheduler)(nil).GetNodeID))
+}
+
+// ScheduleWake mocks base method.
+func (m *MockScheduler) ScheduleWake(ctx context.Context, agentID string, metadata worker.TaskMetadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleWake", ctx, agentID, metadata)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleWake indicates an expected call of ScheduleWake.
+func (mr *MockSchedulerMockRecorder) ScheduleWake(ctx, agentID, metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleWake", reflect.TypeOf((*MockScheduler)(nil).ScheduleWake), ctx, agentID, metadata)
+}
+
 // SendCommand mocks base method.
 func (m *MockScheduler) SendCommand(ctx context.Context, command string) error {
 	m.ctrl.T.Helper()
@@ -256,6 +300,58 @@ func (mr *MockSchedulerMockRecorder) Start(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockScheduler)(nil).Start), ctx)
 }
 
+// MockWakePolicy is a mock of WakePolicy interface.
+type MockWakePolicy struct {
+	ctrl     *gomock.Controller
+	recorder *MockWakePolicyMockRecorder
+	isgomock struct{}
+}
+
+// MockWakePolicyMockRecorder is the mock recorder for MockWakePolicy.
+type MockWakePolicyMockRecorder struct {
+	mock *MockWakePolicy
+}
+
+// NewMockWakePolicy creates a new mock instance.
+func NewMockWakePolicy(ctrl *gomock.Controller) *MockWakePolicy {
+	mock := &MockWakePolicy{ctrl: ctrl}
+	mock.recorder = &MockWakePolicyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWakePolicy) EXPECT() *MockWakePolicyMockRecorder {
+	return m.recorder
+}
+
+// Name mocks base method.
+func (m *MockWakePolicy) Name() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Name")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Name indicates an expected call of Name.
+func (mr *MockWakePolicyMockRecorder) Name() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockWakePolicy)(nil).Name))
+}
+
+// NextWakeAt mocks base method.
+func (m *MockWakePolicy) NextWakeAt(state control_plane.WakeState) *time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextWakeAt", state)
+	ret0, _ := ret[0].(*time.Time)
+	return ret0
+}
+
+// NextWakeAt indicates an expected call of NextWakeAt.
+func (mr *MockWakePolicyMockRecorder) NextWakeAt(state any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextWakeAt", reflect.TypeOf((*MockWakePolicy)(nil).NextWakeAt), state)
+}
+
 // MockAgentFactory is a mock of AgentFactory interface.
 type MockAgentFactory struct {
 	ctrl     *gomock.Controller
@@ -332,18 +428,168 @@ func (m *MockControlPlane) EXPECT() *MockControlPlaneMockRecorder {
 	return m.recorder
 }
 
+// AnswerAgent mocks base method.
+func (m *MockControlPlane) AnswerAgent(ctx context.Context, agentID, answer string) (*control_plane.TaskHandle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnswerAgent", ctx, agentID, answer)
+	ret0, _ := ret[0].(*control_plane.TaskHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnswerAgent indicates an expected call of AnswerAgent.
+func (mr *MockControlPlaneMockRecorder) AnswerAgent(ctx, agentID, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnswerAgent", reflect.TypeOf((*MockControlPlane)(nil).AnswerAgent), ctx, agentID, answer)
+}
+
+// DecideToolApproval mocks base method.
+func (m *MockControlPlane) DecideToolApproval(ctx context.Context, approvalID string, decision control_plane.ToolApprovalDecision) (*control_plane.TaskHandle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideToolApproval", ctx, approvalID, decision)
+	ret0, _ := ret[0].(*control_plane.TaskHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecideToolApproval indicates an expected call of DecideToolApproval.
+func (mr *MockControlPlaneMockRecorder) DecideToolApproval(ctx, approvalID, decision any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideToolApproval", reflect.TypeOf((*MockControlPlane)(nil).DecideToolApproval), ctx, approvalID, decision)
+}
+
+// GetAgentProfile mocks base method.
+func (m *MockControlPlane) GetAgentProfile(ctx context.Context, agentID string) (*storage.AgentProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentProfile", ctx, agentID)
+	ret0, _ := ret[0].(*storage.AgentProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentProfile indicates an expected call of GetAgentProfile.
+func (mr *MockControlPlaneMockRecorder) GetAgentProfile(ctx, agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentProfile", reflect.TypeOf((*MockControlPlane)(nil).GetAgentProfile), ctx, agentID)
+}
+
+// GetAgentThread mocks base method.
+func (m *MockControlPlane) GetAgentThread(ctx context.Context, threadID string) (*control_plane.AgentThreadHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentThread", ctx, threadID)
+	ret0, _ := ret[0].(*control_plane.AgentThreadHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentThread indicates an expected call of GetAgentThread.
+func (mr *MockControlPlaneMockRecorder) GetAgentThread(ctx, threadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentThread", reflect.TypeOf((*MockControlPlane)(nil).GetAgentThread), ctx, threadID)
+}
+
+// GetRunQueueStats mocks base method.
+func (m *MockControlPlane) GetRunQueueStats() control_plane.RunQueueStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRunQueueStats")
+	ret0, _ := ret[0].(control_plane.RunQueueStats)
+	return ret0
+}
+
+// GetRunQueueStats indicates an expected call of GetRunQueueStats.
+func (mr *MockControlPlaneMockRecorder) GetRunQueueStats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunQueueStats", reflect.TypeOf((*MockControlPlane)(nil).GetRunQueueStats))
+}
+
 // KickoffTask mocks base method.
-func (m *MockControlPlane) KickoffTask(ctx context.Context, task, role string) error {
+func (m *MockControlPlane) KickoffTask(ctx context.Context, task, role string, metadata worker.TaskMetadata) (string, *control_plane.TaskHandle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KickoffTask", ctx, task, role, metadata)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(*control_plane.TaskHandle)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// KickoffTask indicates an expected call of KickoffTask.
+func (mr *MockControlPlaneMockRecorder) KickoffTask(ctx, task, role, metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KickoffTask", reflect.TypeOf((*MockControlPlane)(nil).KickoffTask), ctx, task, role, metadata)
+}
+
+// ListAgentThreads mocks base method.
+func (m *MockControlPlane) ListAgentThreads(ctx context.Context, agentID string) ([]storage.AgentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAgentThreads", ctx, agentID)
+	ret0, _ := ret[0].([]storage.AgentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAgentThreads indicates an expected call of ListAgentThreads.
+func (mr *MockControlPlaneMockRecorder) ListAgentThreads(ctx, agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAgentThreads", reflect.TypeOf((*MockControlPlane)(nil).ListAgentThreads), ctx, agentID)
+}
+
+// ListToolApprovals mocks base method.
+func (m *MockControlPlane) ListToolApprovals(ctx context.Context, owner, status string) ([]storage.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListToolApprovals", ctx, owner, status)
+	ret0, _ := ret[0].([]storage.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListToolApprovals indicates an expected call of ListToolApprovals.
+func (mr *MockControlPlaneMockRecorder) ListToolApprovals(ctx, owner, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListToolApprovals", reflect.TypeOf((*MockControlPlane)(nil).ListToolApprovals), ctx, owner, status)
+}
+
+// ResumeTask mocks base method.
+func (m *MockControlPlane) ResumeTask(ctx context.Context, agentID, prompt string) (*control_plane.TaskHandle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeTask", ctx, agentID, prompt)
+	ret0, _ := ret[0].(*control_plane.TaskHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeTask indicates an expected call of ResumeTask.
+func (mr *MockControlPlaneMockRecorder) ResumeTask(ctx, agentID, prompt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeTask", reflect.TypeOf((*MockControlPlane)(nil).ResumeTask), ctx, agentID, prompt)
+}
+
+// SearchAgentProfiles mocks base method.
+func (m *MockControlPlane) SearchAgentProfiles(ctx context.Context, query storage.AgentProfileQuery) ([]storage.AgentProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchAgentProfiles", ctx, query)
+	ret0, _ := ret[0].([]storage.AgentProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchAgentProfiles indicates an expected call of SearchAgentProfiles.
+func (mr *MockControlPlaneMockRecorder) SearchAgentProfiles(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchAgentProfiles", reflect.TypeOf((*MockControlPlane)(nil).SearchAgentProfiles), ctx, query)
+}
+
+// SendAgentCommand mocks base method.
+func (m *MockControlPlane) SendAgentCommand(ctx context.Context, agentID, command string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "KickoffTask", ctx, task, role)
+	ret := m.ctrl.Call(m, "SendAgentCommand", ctx, agentID, command)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// KickoffTask indicates an expected call of KickoffTask.
-func (mr *MockControlPlaneMockRecorder) KickoffTask(ctx, task, role any) *gomock.Call {
+// SendAgentCommand indicates an expected call of SendAgentCommand.
+func (mr *MockControlPlaneMockRecorder) SendAgentCommand(ctx, agentID, command any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KickoffTask", reflect.TypeOf((*MockControlPlane)(nil).KickoffTask), ctx, task, role)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendAgentCommand", reflect.TypeOf((*MockControlPlane)(nil).SendAgentCommand), ctx, agentID, command)
 }
 
 // SendCommand mocks base method.
@@ -360,6 +606,20 @@ func (mr *MockControlPlaneMockRecorder) SendCommand(ctx, command any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCommand", reflect.TypeOf((*MockControlPlane)(nil).SendCommand), ctx, command)
 }
 
+// Shutdown mocks base method.
+func (m *MockControlPlane) Shutdown(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shutdown", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Shutdown indicates an expected call of Shutdown.
+func (mr *MockControlPlaneMockRecorder) Shutdown(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockControlPlane)(nil).Shutdown), ctx)
+}
+
 // Start mocks base method.
 func (m *MockControlPlane) Start(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -373,3 +633,139 @@ func (mr *MockControlPlaneMockRecorder) Start(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockControlPlane)(nil).Start), ctx)
 }
+
+// MockOutboxRelay is a mock of OutboxRelay interface.
+type MockOutboxRelay struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboxRelayMockRecorder
+	isgomock struct{}
+}
+
+// MockOutboxRelayMockRecorder is the mock recorder for MockOutboxRelay.
+type MockOutboxRelayMockRecorder struct {
+	mock *MockOutboxRelay
+}
+
+// NewMockOutboxRelay creates a new mock instance.
+func NewMockOutboxRelay(ctrl *gomock.Controller) *MockOutboxRelay {
+	mock := &MockOutboxRelay{ctrl: ctrl}
+	mock.recorder = &MockOutboxRelayMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOutboxRelay) EXPECT() *MockOutboxRelayMockRecorder {
+	return m.recorder
+}
+
+// Start mocks base method.
+func (m *MockOutboxRelay) Start(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockOutboxRelayMockRecorder) Start(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockOutboxRelay)(nil).Start), ctx)
+}
+
+// MockTaskScheduler is a mock of TaskScheduler interface.
+type MockTaskScheduler struct {
+	ctrl     *gomock.Controller
+	recorder *MockTaskSchedulerMockRecorder
+	isgomock struct{}
+}
+
+// MockTaskSchedulerMockRecorder is the mock recorder for MockTaskScheduler.
+type MockTaskSchedulerMockRecorder struct {
+	mock *MockTaskScheduler
+}
+
+// NewMockTaskScheduler creates a new mock instance.
+func NewMockTaskScheduler(ctrl *gomock.Controller) *MockTaskScheduler {
+	mock := &MockTaskScheduler{ctrl: ctrl}
+	mock.recorder = &MockTaskSchedulerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTaskScheduler) EXPECT() *MockTaskSchedulerMockRecorder {
+	return m.recorder
+}
+
+// CreateSchedule mocks base method.
+func (m *MockTaskScheduler) CreateSchedule(ctx context.Context, definition control_plane.ScheduleDefinition) (*control_plane.AgentSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSchedule", ctx, definition)
+	ret0, _ := ret[0].(*control_plane.AgentSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSchedule indicates an expected call of CreateSchedule.
+func (mr *MockTaskSchedulerMockRecorder) CreateSchedule(ctx, definition any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchedule", reflect.TypeOf((*MockTaskScheduler)(nil).CreateSchedule), ctx, definition)
+}
+
+// ListSchedules mocks base method.
+func (m *MockTaskScheduler) ListSchedules(ctx context.Context, owner string) ([]control_plane.AgentSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSchedules", ctx, owner)
+	ret0, _ := ret[0].([]control_plane.AgentSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSchedules indicates an expected call of ListSchedules.
+func (mr *MockTaskSchedulerMockRecorder) ListSchedules(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSchedules", reflect.TypeOf((*MockTaskScheduler)(nil).ListSchedules), ctx, owner)
+}
+
+// PauseSchedule mocks base method.
+func (m *MockTaskScheduler) PauseSchedule(ctx context.Context, scheduleID string) (*control_plane.AgentSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseSchedule", ctx, scheduleID)
+	ret0, _ := ret[0].(*control_plane.AgentSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PauseSchedule indicates an expected call of PauseSchedule.
+func (mr *MockTaskSchedulerMockRecorder) PauseSchedule(ctx, scheduleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSchedule", reflect.TypeOf((*MockTaskScheduler)(nil).PauseSchedule), ctx, scheduleID)
+}
+
+// ResumeSchedule mocks base method.
+func (m *MockTaskScheduler) ResumeSchedule(ctx context.Context, scheduleID string) (*control_plane.AgentSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeSchedule", ctx, scheduleID)
+	ret0, _ := ret[0].(*control_plane.AgentSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResumeSchedule indicates an expected call of ResumeSchedule.
+func (mr *MockTaskSchedulerMockRecorder) ResumeSchedule(ctx, scheduleID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSchedule", reflect.TypeOf((*MockTaskScheduler)(nil).ResumeSchedule), ctx, scheduleID)
+}
+
+// Start mocks base method.
+func (m *MockTaskScheduler) Start(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockTaskSchedulerMockRecorder) Start(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockTaskScheduler)(nil).Start), ctx)
+}
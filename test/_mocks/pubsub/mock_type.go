@@ -57,41 +57,90 @@ func (mr *MockPubSubMockRecorder) Close() *gomock.Call {
 }
 
 // Publish mocks base method.
-func (m *MockPubSub) Publish(ctx context.Context, topic, message string, timeout time.Duration) error {
+func (m *MockPubSub) Publish(ctx context.Context, topic, message string, timeout time.Duration, opts ...pubsub.PublishOption) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Publish", ctx, topic, message, timeout)
+	varargs := []any{ctx, topic, message, timeout}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Publish", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Publish indicates an expected call of Publish.
-func (mr *MockPubSubMockRecorder) Publish(ctx, topic, message, timeout any) *gomock.Call {
+func (mr *MockPubSubMockRecorder) Publish(ctx, topic, message, timeout any, opts ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPubSub)(nil).Publish), ctx, topic, message, timeout)
+	varargs := append([]any{ctx, topic, message, timeout}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPubSub)(nil).Publish), varargs...)
 }
 
 // Subscribe mocks base method.
-func (m *MockPubSub) Subscribe(topic string, callback pubsub.OnMessageCallback) error {
+func (m *MockPubSub) Subscribe(topic string, callback pubsub.OnMessageCallback, opts ...pubsub.SubscribeOption) (pubsub.Subscription, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Subscribe", topic, callback)
-	ret0, _ := ret[0].(error)
-	return ret0
+	varargs := []any{topic, callback}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Subscribe", varargs...)
+	ret0, _ := ret[0].(pubsub.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Subscribe indicates an expected call of Subscribe.
-func (mr *MockPubSubMockRecorder) Subscribe(topic, callback any) *gomock.Call {
+func (mr *MockPubSubMockRecorder) Subscribe(topic, callback any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{topic, callback}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockPubSub)(nil).Subscribe), varargs...)
+}
+
+// MockSubscription is a mock of Subscription interface.
+type MockSubscription struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubscriptionMockRecorder
+	isgomock struct{}
+}
+
+// MockSubscriptionMockRecorder is the mock recorder for MockSubscription.
+type MockSubscriptionMockRecorder struct {
+	mock *MockSubscription
+}
+
+// NewMockSubscription creates a new mock instance.
+func NewMockSubscription(ctrl *gomock.Controller) *MockSubscription {
+	mock := &MockSubscription{ctrl: ctrl}
+	mock.recorder = &MockSubscriptionMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubscription) EXPECT() *MockSubscriptionMockRecorder {
+	return m.recorder
+}
+
+// Topic mocks base method.
+func (m *MockSubscription) Topic() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Topic")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Topic indicates an expected call of Topic.
+func (mr *MockSubscriptionMockRecorder) Topic() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockPubSub)(nil).Subscribe), topic, callback)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Topic", reflect.TypeOf((*MockSubscription)(nil).Topic))
 }
 
 // Unsubscribe mocks base method.
-func (m *MockPubSub) Unsubscribe(topic string) {
+func (m *MockSubscription) Unsubscribe() {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Unsubscribe", topic)
+	m.ctrl.Call(m, "Unsubscribe")
 }
 
 // Unsubscribe indicates an expected call of Unsubscribe.
-func (mr *MockPubSubMockRecorder) Unsubscribe(topic any) *gomock.Call {
+func (mr *MockSubscriptionMockRecorder) Unsubscribe() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockPubSub)(nil).Unsubscribe), topic)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockSubscription)(nil).Unsubscribe))
 }
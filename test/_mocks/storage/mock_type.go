@@ -13,6 +13,7 @@ import (
 	reflect "reflect"
 	time "time"
 
+	storage "github.com/roackb2/lucid/internal/pkg/agents/storage"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,6 +41,64 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// AdvanceAgentThread mocks base method.
+func (m *MockStorage) AdvanceAgentThread(advance storage.AgentThreadAdvance) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdvanceAgentThread", advance)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AdvanceAgentThread indicates an expected call of AdvanceAgentThread.
+func (mr *MockStorageMockRecorder) AdvanceAgentThread(advance any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdvanceAgentThread", reflect.TypeOf((*MockStorage)(nil).AdvanceAgentThread), advance)
+}
+
+// CancelAgentInterest mocks base method.
+func (m *MockStorage) CancelAgentInterest(agentID, interestID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelAgentInterest", agentID, interestID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelAgentInterest indicates an expected call of CancelAgentInterest.
+func (mr *MockStorageMockRecorder) CancelAgentInterest(agentID, interestID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelAgentInterest", reflect.TypeOf((*MockStorage)(nil).CancelAgentInterest), agentID, interestID)
+}
+
+// ClaimAgent mocks base method.
+func (m *MockStorage) ClaimAgent(agentID, nodeID, status string, claimDuration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimAgent", agentID, nodeID, status, claimDuration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimAgent indicates an expected call of ClaimAgent.
+func (mr *MockStorageMockRecorder) ClaimAgent(agentID, nodeID, status, claimDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimAgent", reflect.TypeOf((*MockStorage)(nil).ClaimAgent), agentID, nodeID, status, claimDuration)
+}
+
+// ClaimOutboxEvents mocks base method.
+func (m *MockStorage) ClaimOutboxEvents(limit int, claimDuration time.Duration) ([]storage.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOutboxEvents", limit, claimDuration)
+	ret0, _ := ret[0].([]storage.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOutboxEvents indicates an expected call of ClaimOutboxEvents.
+func (mr *MockStorageMockRecorder) ClaimOutboxEvents(limit, claimDuration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOutboxEvents", reflect.TypeOf((*MockStorage)(nil).ClaimOutboxEvents), limit, claimDuration)
+}
+
 // Close mocks base method.
 func (m *MockStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -54,6 +113,121 @@ func (mr *MockStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStorage)(nil).Close))
 }
 
+// CreateAgentInterest mocks base method.
+func (m *MockStorage) CreateAgentInterest(interest storage.AgentInterest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAgentInterest", interest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAgentInterest indicates an expected call of CreateAgentInterest.
+func (mr *MockStorageMockRecorder) CreateAgentInterest(interest any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAgentInterest", reflect.TypeOf((*MockStorage)(nil).CreateAgentInterest), interest)
+}
+
+// CreateAgentThread mocks base method.
+func (m *MockStorage) CreateAgentThread(thread storage.AgentThread, message storage.AgentMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAgentThread", thread, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAgentThread indicates an expected call of CreateAgentThread.
+func (mr *MockStorageMockRecorder) CreateAgentThread(thread, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAgentThread", reflect.TypeOf((*MockStorage)(nil).CreateAgentThread), thread, message)
+}
+
+// CreateToolApproval mocks base method.
+func (m *MockStorage) CreateToolApproval(approval storage.ToolApproval) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateToolApproval", approval)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateToolApproval indicates an expected call of CreateToolApproval.
+func (mr *MockStorageMockRecorder) CreateToolApproval(approval any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToolApproval", reflect.TypeOf((*MockStorage)(nil).CreateToolApproval), approval)
+}
+
+// DecideToolApproval mocks base method.
+func (m *MockStorage) DecideToolApproval(approvalID, status, editedArguments, reason string) (*storage.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideToolApproval", approvalID, status, editedArguments, reason)
+	ret0, _ := ret[0].(*storage.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecideToolApproval indicates an expected call of DecideToolApproval.
+func (mr *MockStorageMockRecorder) DecideToolApproval(approvalID, status, editedArguments, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideToolApproval", reflect.TypeOf((*MockStorage)(nil).DecideToolApproval), approvalID, status, editedArguments, reason)
+}
+
+// DeleteDeliveredOutboxEvents mocks base method.
+func (m *MockStorage) DeleteDeliveredOutboxEvents(retention time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeliveredOutboxEvents", retention)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeliveredOutboxEvents indicates an expected call of DeleteDeliveredOutboxEvents.
+func (mr *MockStorageMockRecorder) DeleteDeliveredOutboxEvents(retention any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeliveredOutboxEvents", reflect.TypeOf((*MockStorage)(nil).DeleteDeliveredOutboxEvents), retention)
+}
+
+// DeleteExpiredClientEvents mocks base method.
+func (m *MockStorage) DeleteExpiredClientEvents(retention time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredClientEvents", retention)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredClientEvents indicates an expected call of DeleteExpiredClientEvents.
+func (mr *MockStorageMockRecorder) DeleteExpiredClientEvents(retention any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredClientEvents", reflect.TypeOf((*MockStorage)(nil).DeleteExpiredClientEvents), retention)
+}
+
+// GetAgentInfo mocks base method.
+func (m *MockStorage) GetAgentInfo(agentID string) (*storage.AgentInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentInfo", agentID)
+	ret0, _ := ret[0].(*storage.AgentInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentInfo indicates an expected call of GetAgentInfo.
+func (mr *MockStorageMockRecorder) GetAgentInfo(agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentInfo", reflect.TypeOf((*MockStorage)(nil).GetAgentInfo), agentID)
+}
+
+// GetAgentProfile mocks base method.
+func (m *MockStorage) GetAgentProfile(agentID string) (*storage.AgentProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentProfile", agentID)
+	ret0, _ := ret[0].(*storage.AgentProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentProfile indicates an expected call of GetAgentProfile.
+func (mr *MockStorageMockRecorder) GetAgentProfile(agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentProfile", reflect.TypeOf((*MockStorage)(nil).GetAgentProfile), agentID)
+}
+
 // GetAgentState mocks base method.
 func (m *MockStorage) GetAgentState(agentID string) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -69,6 +243,269 @@ func (mr *MockStorageMockRecorder) GetAgentState(agentID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentState", reflect.TypeOf((*MockStorage)(nil).GetAgentState), agentID)
 }
 
+// GetAgentThread mocks base method.
+func (m *MockStorage) GetAgentThread(threadID string) (*storage.AgentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAgentThread", threadID)
+	ret0, _ := ret[0].(*storage.AgentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAgentThread indicates an expected call of GetAgentThread.
+func (mr *MockStorageMockRecorder) GetAgentThread(threadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAgentThread", reflect.TypeOf((*MockStorage)(nil).GetAgentThread), threadID)
+}
+
+// GetLatestClientEventID mocks base method.
+func (m *MockStorage) GetLatestClientEventID() (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestClientEventID")
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestClientEventID indicates an expected call of GetLatestClientEventID.
+func (mr *MockStorageMockRecorder) GetLatestClientEventID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestClientEventID", reflect.TypeOf((*MockStorage)(nil).GetLatestClientEventID))
+}
+
+// GetToolApproval mocks base method.
+func (m *MockStorage) GetToolApproval(approvalID string) (*storage.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetToolApproval", approvalID)
+	ret0, _ := ret[0].(*storage.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetToolApproval indicates an expected call of GetToolApproval.
+func (mr *MockStorageMockRecorder) GetToolApproval(approvalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToolApproval", reflect.TypeOf((*MockStorage)(nil).GetToolApproval), approvalID)
+}
+
+// ListAgentThreadMessages mocks base method.
+func (m *MockStorage) ListAgentThreadMessages(threadID string) ([]storage.AgentMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAgentThreadMessages", threadID)
+	ret0, _ := ret[0].([]storage.AgentMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAgentThreadMessages indicates an expected call of ListAgentThreadMessages.
+func (mr *MockStorageMockRecorder) ListAgentThreadMessages(threadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAgentThreadMessages", reflect.TypeOf((*MockStorage)(nil).ListAgentThreadMessages), threadID)
+}
+
+// ListAgentThreads mocks base method.
+func (m *MockStorage) ListAgentThreads(agentID string) ([]storage.AgentThread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAgentThreads", agentID)
+	ret0, _ := ret[0].([]storage.AgentThread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAgentThreads indicates an expected call of ListAgentThreads.
+func (mr *MockStorageMockRecorder) ListAgentThreads(agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAgentThreads", reflect.TypeOf((*MockStorage)(nil).ListAgentThreads), agentID)
+}
+
+// ListAllClientEventsAfter mocks base method.
+func (m *MockStorage) ListAllClientEventsAfter(afterID int64, limit int) ([]storage.ClientEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllClientEventsAfter", afterID, limit)
+	ret0, _ := ret[0].([]storage.ClientEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAllClientEventsAfter indicates an expected call of ListAllClientEventsAfter.
+func (mr *MockStorageMockRecorder) ListAllClientEventsAfter(afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllClientEventsAfter", reflect.TypeOf((*MockStorage)(nil).ListAllClientEventsAfter), afterID, limit)
+}
+
+// ListClientEventsAfter mocks base method.
+func (m *MockStorage) ListClientEventsAfter(afterID int64, agentIDs []string, owner string, limit int) ([]storage.ClientEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListClientEventsAfter", afterID, agentIDs, owner, limit)
+	ret0, _ := ret[0].([]storage.ClientEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListClientEventsAfter indicates an expected call of ListClientEventsAfter.
+func (mr *MockStorageMockRecorder) ListClientEventsAfter(afterID, agentIDs, owner, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListClientEventsAfter", reflect.TypeOf((*MockStorage)(nil).ListClientEventsAfter), afterID, agentIDs, owner, limit)
+}
+
+// ListToolApprovals mocks base method.
+func (m *MockStorage) ListToolApprovals(owner, status string) ([]storage.ToolApproval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListToolApprovals", owner, status)
+	ret0, _ := ret[0].([]storage.ToolApproval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListToolApprovals indicates an expected call of ListToolApprovals.
+func (mr *MockStorageMockRecorder) ListToolApprovals(owner, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListToolApprovals", reflect.TypeOf((*MockStorage)(nil).ListToolApprovals), owner, status)
+}
+
+// ListUnreadAgentMessages mocks base method.
+func (m *MockStorage) ListUnreadAgentMessages(agentID string) ([]storage.AgentMessage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnreadAgentMessages", agentID)
+	ret0, _ := ret[0].([]storage.AgentMessage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnreadAgentMessages indicates an expected call of ListUnreadAgentMessages.
+func (mr *MockStorageMockRecorder) ListUnreadAgentMessages(agentID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnreadAgentMessages", reflect.TypeOf((*MockStorage)(nil).ListUnreadAgentMessages), agentID)
+}
+
+// MarkAgentMessagesRead mocks base method.
+func (m *MockStorage) MarkAgentMessagesRead(agentID string, messageIDs []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkAgentMessagesRead", agentID, messageIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkAgentMessagesRead indicates an expected call of MarkAgentMessagesRead.
+func (mr *MockStorageMockRecorder) MarkAgentMessagesRead(agentID, messageIDs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAgentMessagesRead", reflect.TypeOf((*MockStorage)(nil).MarkAgentMessagesRead), agentID, messageIDs)
+}
+
+// MarkOutboxEventDelivered mocks base method.
+func (m *MockStorage) MarkOutboxEventDelivered(id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventDelivered", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventDelivered indicates an expected call of MarkOutboxEventDelivered.
+func (mr *MockStorageMockRecorder) MarkOutboxEventDelivered(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventDelivered", reflect.TypeOf((*MockStorage)(nil).MarkOutboxEventDelivered), id)
+}
+
+// MatchAgentInterests mocks base method.
+func (m *MockStorage) MatchAgentInterests(content string, excludedStatuses []string) ([]storage.AgentInterest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MatchAgentInterests", content, excludedStatuses)
+	ret0, _ := ret[0].([]storage.AgentInterest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MatchAgentInterests indicates an expected call of MatchAgentInterests.
+func (mr *MockStorageMockRecorder) MatchAgentInterests(content, excludedStatuses any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MatchAgentInterests", reflect.TypeOf((*MockStorage)(nil).MatchAgentInterests), content, excludedStatuses)
+}
+
+// RecordClientEvent mocks base method.
+func (m *MockStorage) RecordClientEvent(event storage.ClientEvent) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordClientEvent", event)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordClientEvent indicates an expected call of RecordClientEvent.
+func (mr *MockStorageMockRecorder) RecordClientEvent(event any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordClientEvent", reflect.TypeOf((*MockStorage)(nil).RecordClientEvent), event)
+}
+
+// ReleaseAgentClaim mocks base method.
+func (m *MockStorage) ReleaseAgentClaim(agentID, nodeID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseAgentClaim", agentID, nodeID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseAgentClaim indicates an expected call of ReleaseAgentClaim.
+func (mr *MockStorageMockRecorder) ReleaseAgentClaim(agentID, nodeID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseAgentClaim", reflect.TypeOf((*MockStorage)(nil).ReleaseAgentClaim), agentID, nodeID)
+}
+
+// ReopenToolApproval mocks base method.
+func (m *MockStorage) ReopenToolApproval(approvalID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReopenToolApproval", approvalID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReopenToolApproval indicates an expected call of ReopenToolApproval.
+func (mr *MockStorageMockRecorder) ReopenToolApproval(approvalID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReopenToolApproval", reflect.TypeOf((*MockStorage)(nil).ReopenToolApproval), approvalID)
+}
+
+// RetryOutboxEvent mocks base method.
+func (m *MockStorage) RetryOutboxEvent(id int64, lastError string, backoff time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryOutboxEvent", id, lastError, backoff)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryOutboxEvent indicates an expected call of RetryOutboxEvent.
+func (mr *MockStorageMockRecorder) RetryOutboxEvent(id, lastError, backoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryOutboxEvent", reflect.TypeOf((*MockStorage)(nil).RetryOutboxEvent), id, lastError, backoff)
+}
+
+// SaveAgentMessage mocks base method.
+func (m *MockStorage) SaveAgentMessage(message storage.AgentMessage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAgentMessage", message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAgentMessage indicates an expected call of SaveAgentMessage.
+func (mr *MockStorageMockRecorder) SaveAgentMessage(message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAgentMessage", reflect.TypeOf((*MockStorage)(nil).SaveAgentMessage), message)
+}
+
+// SaveAgentProfile mocks base method.
+func (m *MockStorage) SaveAgentProfile(profile storage.AgentProfile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAgentProfile", profile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAgentProfile indicates an expected call of SaveAgentProfile.
+func (mr *MockStorageMockRecorder) SaveAgentProfile(profile any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAgentProfile", reflect.TypeOf((*MockStorage)(nil).SaveAgentProfile), profile)
+}
+
 // SaveAgentState mocks base method.
 func (m *MockStorage) SaveAgentState(agentID string, state []byte, status, role string, awakenedAt, asleepAt *time.Time) error {
 	m.ctrl.T.Helper()
@@ -83,6 +520,20 @@ func (mr *MockStorageMockRecorder) SaveAgentState(agentID, state, status, role,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAgentState", reflect.TypeOf((*MockStorage)(nil).SaveAgentState), agentID, state, status, role, awakenedAt, asleepAt)
 }
 
+// SaveAgentStateWithEvents mocks base method.
+func (m *MockStorage) SaveAgentStateWithEvents(agentID string, state []byte, status, role string, awakenedAt, asleepAt *time.Time, events []storage.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAgentStateWithEvents", agentID, state, status, role, awakenedAt, asleepAt, events)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAgentStateWithEvents indicates an expected call of SaveAgentStateWithEvents.
+func (mr *MockStorageMockRecorder) SaveAgentStateWithEvents(agentID, state, status, role, awakenedAt, asleepAt, events any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAgentStateWithEvents", reflect.TypeOf((*MockStorage)(nil).SaveAgentStateWithEvents), agentID, state, status, role, awakenedAt, asleepAt, events)
+}
+
 // SavePost mocks base method.
 func (m *MockStorage) SavePost(content string) error {
 	m.ctrl.T.Helper()
@@ -97,6 +548,21 @@ func (mr *MockStorageMockRecorder) SavePost(content any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePost", reflect.TypeOf((*MockStorage)(nil).SavePost), content)
 }
 
+// SearchAgentProfiles mocks base method.
+func (m *MockStorage) SearchAgentProfiles(query storage.AgentProfileQuery) ([]storage.AgentProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchAgentProfiles", query)
+	ret0, _ := ret[0].([]storage.AgentProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchAgentProfiles indicates an expected call of SearchAgentProfiles.
+func (mr *MockStorageMockRecorder) SearchAgentProfiles(query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchAgentProfiles", reflect.TypeOf((*MockStorage)(nil).SearchAgentProfiles), query)
+}
+
 // SearchPosts mocks base method.
 func (m *MockStorage) SearchPosts(query string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -111,3 +577,31 @@ func (mr *MockStorageMockRecorder) SearchPosts(query any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPosts", reflect.TypeOf((*MockStorage)(nil).SearchPosts), query)
 }
+
+// SetAgentError mocks base method.
+func (m *MockStorage) SetAgentError(agentID, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAgentError", agentID, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAgentError indicates an expected call of SetAgentError.
+func (mr *MockStorageMockRecorder) SetAgentError(agentID, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAgentError", reflect.TypeOf((*MockStorage)(nil).SetAgentError), agentID, message)
+}
+
+// UpdateAgentStatus mocks base method.
+func (m *MockStorage) UpdateAgentStatus(agentID, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAgentStatus", agentID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAgentStatus indicates an expected call of UpdateAgentStatus.
+func (mr *MockStorageMockRecorder) UpdateAgentStatus(agentID, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAgentStatus", reflect.TypeOf((*MockStorage)(nil).UpdateAgentStatus), agentID, status)
+}
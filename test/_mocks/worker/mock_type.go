@@ -82,6 +82,20 @@ func (mr *MockWorkerMockRecorder) Deserialize(state any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deserialize", reflect.TypeOf((*MockWorker)(nil).Deserialize), state)
 }
 
+// GetMetadata mocks base method.
+func (m *MockWorker) GetMetadata() worker.TaskMetadata {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetadata")
+	ret0, _ := ret[0].(worker.TaskMetadata)
+	return ret0
+}
+
+// GetMetadata indicates an expected call of GetMetadata.
+func (mr *MockWorkerMockRecorder) GetMetadata() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockWorker)(nil).GetMetadata))
+}
+
 // GetRole mocks base method.
 func (m *MockWorker) GetRole() string {
 	m.ctrl.T.Helper()
@@ -181,3 +195,27 @@ func (mr *MockWorkerMockRecorder) Serialize() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Serialize", reflect.TypeOf((*MockWorker)(nil).Serialize))
 }
+
+// SetApprovalPolicy mocks base method.
+func (m *MockWorker) SetApprovalPolicy(policy worker.ToolApprovalPolicy) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetApprovalPolicy", policy)
+}
+
+// SetApprovalPolicy indicates an expected call of SetApprovalPolicy.
+func (mr *MockWorkerMockRecorder) SetApprovalPolicy(policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetApprovalPolicy", reflect.TypeOf((*MockWorker)(nil).SetApprovalPolicy), policy)
+}
+
+// SetMetadata mocks base method.
+func (m *MockWorker) SetMetadata(metadata worker.TaskMetadata) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetMetadata", metadata)
+}
+
+// SetMetadata indicates an expected call of SetMetadata.
+func (mr *MockWorkerMockRecorder) SetMetadata(metadata any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMetadata", reflect.TypeOf((*MockWorker)(nil).SetMetadata), metadata)
+}
@@ -0,0 +1,132 @@
+package pubsub_integration_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testLeaseDuration     = 1 * time.Second
+	testHeartbeatInterval = 200 * time.Millisecond
+)
+
+func newTestTracker(nodeID string) *control_plane.PostgresAgentTracker {
+	return control_plane.NewPostgresAgentTracker(control_plane.PostgresAgentTrackerConfig{
+		NodeID:            nodeID,
+		LeaseDuration:     testLeaseDuration,
+		HeartbeatInterval: testHeartbeatInterval,
+	})
+}
+
+// createRunningAgent creates the state of an agent running on the tracker's node.
+func createRunningAgent(t *testing.T, ctx context.Context, tracker *control_plane.PostgresAgentTracker) string {
+	agentID := uuid.New().String()
+	err := dbaccess.Querier.CreateAgentState(ctx, dbaccess.CreateAgentStateParams{
+		AgentID:    agentID,
+		State:      []byte("{}"),
+		Status:     worker.StatusRunning,
+		Role:       "consumer",
+		AwakenedAt: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		dbaccess.Querier.UpdateAgentStatus(context.Background(), dbaccess.UpdateAgentStatusParams{
+			AgentID: agentID,
+			Status:  worker.StatusTerminated,
+		})
+		for _, nodeID := range []string{"node-a", "node-b"} {
+			dbaccess.Querier.DeleteAgentTracking(context.Background(), dbaccess.DeleteAgentTrackingParams{
+				AgentID: agentID,
+				NodeID:  nodeID,
+			})
+		}
+	})
+	tracker.AddTracking(agentID, control_plane.AgentTracking{AgentID: agentID, Status: worker.StatusRunning, CreatedAt: time.Now()})
+	return agentID
+}
+
+func TestPostgresAgentTracker_ExpiresLostLeases(t *testing.T) {
+	setupDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodeA := newTestTracker("node-a")
+	agentID := createRunningAgent(t, ctx, nodeA)
+	go nodeA.Start(ctx)
+
+	// Heartbeats keep the lease ahead of its initial expiry
+	tracking, ok := nodeA.GetTracking(agentID)
+	require.True(t, ok)
+	initialExpiry := tracking.LeaseExpiresAt
+	require.Eventually(t, func() bool {
+		tracking, _ := nodeA.GetTracking(agentID)
+		return tracking.LeaseExpiresAt.After(initialExpiry)
+	}, testLeaseDuration, 50*time.Millisecond)
+
+	// Another node takes the agent over, node A's next renewal does not return it
+	nodeB := newTestTracker("node-b")
+	nodeB.AddTracking(agentID, control_plane.AgentTracking{AgentID: agentID, Status: worker.StatusRunning, CreatedAt: time.Now()})
+	require.Eventually(t, func() bool {
+		tracking, _ := nodeA.GetTracking(agentID)
+		return !tracking.LeaseExpiresAt.After(time.Now())
+	}, 2*testHeartbeatInterval+time.Second, 50*time.Millisecond)
+
+	row, err := dbaccess.Querier.GetAgentTracking(ctx, agentID)
+	require.NoError(t, err)
+	require.Equal(t, "node-b", row.NodeID)
+}
+
+func TestPostgresAgentTracker_ReclaimsAgentsOfStoppedNodes(t *testing.T) {
+	setupDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodeA := newTestTracker("node-a")
+	agentID := createRunningAgent(t, ctx, nodeA)
+	nodeACtx, stopNodeA := context.WithCancel(ctx)
+	go nodeA.Start(nodeACtx)
+
+	mu := sync.Mutex{}
+	found := 0
+	nodeB := newTestTracker("node-b")
+	onAgentFound := func(foundID string, agent dbaccess.AgentState) {
+		if foundID != agentID {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		found++
+		nodeB.AddTracking(foundID, control_plane.AgentTracking{AgentID: foundID, Status: worker.StatusRunning, CreatedAt: time.Now()})
+	}
+	scheduler := control_plane.NewScheduler(ctx, control_plane.SchedulerConfig{NodeID: "node-b"}, onAgentFound)
+	go scheduler.Start(ctx)
+
+	// The agent is not claimable while node A renews its lease
+	time.Sleep(2 * testLeaseDuration)
+	mu.Lock()
+	require.Zero(t, found, "agent reclaimed while its lease was renewed")
+	mu.Unlock()
+
+	// Node A stops heartbeating, node B reclaims the agent once the lease expires
+	stopNodeA()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return found == 1
+	}, testLeaseDuration+3*control_plane.ScanInterval, 100*time.Millisecond)
+
+	row, err := dbaccess.Querier.GetAgentTracking(ctx, agentID)
+	require.NoError(t, err)
+	require.Equal(t, "node-b", row.NodeID)
+}
@@ -10,8 +10,8 @@ import (
 )
 
 func TestKafkaPubSub_Integration(t *testing.T) {
-	pubsub := pubsub.NewKafkaPubSub()
-	defer pubsub.Close()
+	ps := pubsub.NewKafkaPubSub()
+	defer ps.Close()
 
 	topic := "test-topic"
 	message := "test-message"
@@ -20,15 +20,15 @@ func TestKafkaPubSub_Integration(t *testing.T) {
 
 	// Start a subscriber
 	receivedMessages := make(chan string)
-	err := pubsub.Subscribe(topic, func(msg string) error {
+	subscription, err := ps.Subscribe(topic, func(msg string, headers pubsub.Headers) error {
 		receivedMessages <- msg
 		return nil
 	})
 	require.NoError(t, err)
-	defer pubsub.Unsubscribe(topic)
+	defer subscription.Unsubscribe()
 
 	// Publish a message
-	err = pubsub.Publish(ctx, topic, message, 5*time.Second)
+	err = ps.Publish(ctx, topic, message, 5*time.Second)
 	require.NoError(t, err)
 
 	// Wait for the message to be received
@@ -39,3 +39,34 @@ func TestKafkaPubSub_Integration(t *testing.T) {
 		require.Fail(t, "Did not receive message in time")
 	}
 }
+
+func TestKafkaPubSub_KeyedMessagesStayInOrder(t *testing.T) {
+	ps := pubsub.NewKafkaPubSub()
+	defer ps.Close()
+
+	topic := "test-keyed-topic"
+	ctx := context.Background()
+
+	receivedMessages := make(chan string, 10)
+	subscription, err := ps.Subscribe(topic, func(msg string, headers pubsub.Headers) error {
+		receivedMessages <- msg
+		return nil
+	})
+	require.NoError(t, err)
+	defer subscription.Unsubscribe()
+
+	expected := []string{"message-1", "message-2", "message-3", "message-4", "message-5"}
+	for _, message := range expected {
+		err = ps.Publish(ctx, topic, message, 5*time.Second, pubsub.WithKey("agent-id"))
+		require.NoError(t, err)
+	}
+
+	for _, message := range expected {
+		select {
+		case msg := <-receivedMessages:
+			require.Equal(t, message, msg)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "Did not receive message in time")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pubsub_integration_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/stretchr/testify/require"
+)
+
+// claimOutboxMessages claims the due outbox events and returns the messages of the ones with the key.
+// The claims expire at once, so other events in the database are left due.
+func claimOutboxMessages(t *testing.T, store *storage.RelationalStorage, key string) map[string]int64 {
+	events, err := store.ClaimOutboxEvents(1000, 0)
+	require.NoError(t, err)
+	messages := map[string]int64{}
+	for _, event := range events {
+		if event.Key == key {
+			messages[event.Message] = event.ID
+		}
+	}
+	return messages
+}
+
+func TestRelationalStorage_ClaimsOutboxEventsOfAKeyInOrder(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+	// dbaccess is initialized by setupDatabase
+	store := &storage.RelationalStorage{}
+	key := uuid.New().String()
+	for _, message := range []string{"first", "second"} {
+		err := dbaccess.Querier.CreateOutboxEvent(ctx, dbaccess.CreateOutboxEventParams{
+			Topic:      "outbox_test",
+			MessageKey: key,
+			Message:    message,
+			Headers:    []byte("{}"),
+		})
+		require.NoError(t, err)
+	}
+
+	messages := claimOutboxMessages(t, store, key)
+	require.Len(t, messages, 1)
+	first, ok := messages["first"]
+	require.True(t, ok)
+
+	// The second event stays held on the next tick while the first one is retried
+	require.NoError(t, store.RetryOutboxEvent(first, "broker unavailable", 0))
+	messages = claimOutboxMessages(t, store, key)
+	require.Len(t, messages, 1)
+	require.Contains(t, messages, "first")
+
+	require.NoError(t, store.MarkOutboxEventDelivered(first))
+	messages = claimOutboxMessages(t, store, key)
+	require.Len(t, messages, 1)
+	second, ok := messages["second"]
+	require.True(t, ok)
+	require.NoError(t, store.MarkOutboxEventDelivered(second))
+}
+
+// Events without a key are not held by earlier undelivered ones
+func TestRelationalStorage_ClaimsOutboxEventsWithoutKey(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+	store := &storage.RelationalStorage{}
+	topic := "outbox_test_" + uuid.New().String()
+	for _, message := range []string{"first", "second"} {
+		err := dbaccess.Querier.CreateOutboxEvent(ctx, dbaccess.CreateOutboxEventParams{
+			Topic:   topic,
+			Message: message,
+			Headers: []byte("{}"),
+		})
+		require.NoError(t, err)
+	}
+
+	events, err := store.ClaimOutboxEvents(1000, 0)
+	require.NoError(t, err)
+	claimed := 0
+	for _, event := range events {
+		if event.Topic == topic {
+			claimed++
+			require.NoError(t, store.MarkOutboxEventDelivered(event.ID))
+		}
+	}
+	require.Equal(t, 2, claimed)
+}
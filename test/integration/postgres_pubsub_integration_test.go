@@ -0,0 +1,71 @@
+package pubsub_integration_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roackb2/lucid/internal/pkg/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMessage struct {
+	message string
+	headers pubsub.Headers
+}
+
+func TestPostgresPubSub_DeliversAcrossInstances(t *testing.T) {
+	setupDatabase(t)
+
+	publisher := pubsub.NewPostgresPubSub(pubsub.PostgresPubSubConfig{})
+	defer publisher.Close()
+	subscriber := pubsub.NewPostgresPubSub(pubsub.PostgresPubSubConfig{})
+	defer subscriber.Close()
+
+	topic := "test-postgres-topic-" + uuid.New().String()
+	ctx := context.Background()
+
+	received := make(chan receivedMessage, 10)
+	subscription, err := subscriber.Subscribe(topic, func(message string, headers pubsub.Headers) error {
+		received <- receivedMessage{message: message, headers: headers}
+		return nil
+	})
+	require.NoError(t, err)
+	defer subscription.Unsubscribe()
+
+	// The subscriber only gets messages published once it listens
+	require.Eventually(t, func() bool {
+		if err := publisher.Publish(ctx, topic, "ready", 5*time.Second); err != nil {
+			return false
+		}
+		select {
+		case <-received:
+			return true
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 100*time.Millisecond)
+	// Drain the extra readiness messages
+	time.Sleep(500 * time.Millisecond)
+	for len(received) > 0 {
+		<-received
+	}
+
+	// The large message does not fit in a notification and is read back from the events table
+	messages := []string{"small", strings.Repeat("large", 2*pubsub.PostgresInlineMessageLimit)}
+	for _, message := range messages {
+		err := publisher.Publish(ctx, topic, message, 5*time.Second, pubsub.WithHeaders(pubsub.Headers{"size": "any"}))
+		require.NoError(t, err)
+	}
+	for _, message := range messages {
+		select {
+		case got := <-received:
+			require.Equal(t, message, got.message)
+			require.Equal(t, pubsub.Headers{"size": "any"}, got.headers)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "Did not receive message in time")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package pubsub_integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/roackb2/lucid/internal/pkg/agents/storage"
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/stretchr/testify/require"
+)
+
+// createDueAgent creates an asleep agent due to wake under the policy.
+// Event policy agents are interested in the keyword, a post containing it makes them due.
+func createDueAgent(t *testing.T, ctx context.Context, wakePolicy string, owner string, keyword string) string {
+	agentID := uuid.New().String()
+	state, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"owner": owner, "interests": []string{keyword}},
+	})
+	require.NoError(t, err)
+	err = dbaccess.Querier.CreateAgentState(ctx, dbaccess.CreateAgentStateParams{
+		AgentID:  agentID,
+		State:    state,
+		Status:   worker.StatusAsleep,
+		Role:     "consumer",
+		AsleepAt: pgtype.Timestamp{Time: time.Now().Add(-2 * control_plane.AgentSleepDuration), Valid: true},
+	})
+	require.NoError(t, err)
+	if wakePolicy == "" {
+		return agentID
+	}
+	wakeAt := pgtype.Timestamp{}
+	if wakePolicy != control_plane.WakePolicyEvent {
+		wakeAt = pgtype.Timestamp{Time: time.Now().Add(-time.Second), Valid: true}
+	}
+	err = dbaccess.Querier.UpdateAgentWake(ctx, dbaccess.UpdateAgentWakeParams{
+		WakePolicy: wakePolicy,
+		WakeAt:     wakeAt,
+		AgentID:    agentID,
+	})
+	require.NoError(t, err)
+	return agentID
+}
+
+func TestScheduler_ClaimsAgentsExclusively(t *testing.T) {
+	setupDatabase(t)
+
+	testCases := []struct {
+		name       string
+		wakePolicy string
+	}{
+		// Agents that fell asleep before wake policies were recorded wake after the sleep duration
+		{name: "sleep duration"},
+		{name: "round robin", wakePolicy: control_plane.WakePolicyRoundRobin},
+		{name: "event policy", wakePolicy: control_plane.WakePolicyEvent},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Enough asleep agents to take several scans of both schedulers, shared by a few owners
+			agentNum := 3 * control_plane.BatchProcessAgentNum
+			keyword := uuid.New().String()
+			agentIDs := make(map[string]bool, agentNum)
+			for i := 0; i < agentNum; i++ {
+				agentID := createDueAgent(t, ctx, tc.wakePolicy, fmt.Sprintf("owner-%d", i%3), keyword)
+				agentIDs[agentID] = true
+			}
+			defer func() {
+				for agentID := range agentIDs {
+					dbaccess.Querier.UpdateAgentStatus(context.Background(), dbaccess.UpdateAgentStatusParams{
+						AgentID: agentID,
+						Status:  worker.StatusTerminated,
+					})
+				}
+			}()
+			if tc.wakePolicy == control_plane.WakePolicyEvent {
+				err := dbaccess.Querier.CreatePost(ctx, dbaccess.CreatePostParams{UserID: 1, Content: "a post about " + keyword})
+				require.NoError(t, err)
+			}
+
+			// The callbacks never release the claims, so every agent must be found exactly once
+			mu := sync.Mutex{}
+			found := map[string]int{}
+			onAgentFound := func(agentID string, agent dbaccess.AgentState) {
+				if !agentIDs[agentID] {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				found[agentID]++
+			}
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < 2; i++ {
+				scheduler := control_plane.NewScheduler(ctx, control_plane.SchedulerConfig{}, onAgentFound)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					scheduler.Start(ctx)
+				}()
+			}
+
+			require.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(found) == agentNum
+			}, 10*time.Second, 100*time.Millisecond)
+
+			// Let both schedulers scan a few more times before checking for duplicates
+			time.Sleep(3 * control_plane.ScanInterval)
+			cancel()
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			for agentID, count := range found {
+				require.Equal(t, 1, count, "agent %s was resumed more than once", agentID)
+			}
+		})
+	}
+}
+
+func TestRelationalStorage_ClaimsAgentsForOneNode(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+	// dbaccess is initialized by setupDatabase
+	store := &storage.RelationalStorage{}
+	agentID := createDueAgent(t, ctx, "", "owner", "")
+	defer dbaccess.Querier.UpdateAgentStatus(context.Background(), dbaccess.UpdateAgentStatusParams{
+		AgentID: agentID,
+		Status:  worker.StatusTerminated,
+	})
+
+	claimed, err := store.ClaimAgent(agentID, "node-a", worker.StatusAsleep, time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	// A second claim loses while the first one holds
+	claimed, err = store.ClaimAgent(agentID, "node-b", worker.StatusAsleep, time.Minute)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	// A node only releases its own claim
+	require.NoError(t, store.ReleaseAgentClaim(agentID, "node-b"))
+	claimed, err = store.ClaimAgent(agentID, "node-b", worker.StatusAsleep, time.Minute)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	require.NoError(t, store.ReleaseAgentClaim(agentID, "node-a"))
+	// The claim fails if the agent left the status it was read in
+	claimed, err = store.ClaimAgent(agentID, "node-b", worker.StatusAwaitingInput, time.Minute)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	claimed, err = store.ClaimAgent(agentID, "node-b", worker.StatusAsleep, time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+}
@@ -0,0 +1,30 @@
+package pubsub_integration_test
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/roackb2/lucid/config"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	loadConfigOnce sync.Once
+	loadConfigErr  error
+)
+
+// setupDatabase loads the dev config once and initializes dbaccess for the test.
+func setupDatabase(t *testing.T) {
+	loadConfigOnce.Do(func() {
+		// Config is looked up relative to the repository root
+		if loadConfigErr = os.Chdir("../.."); loadConfigErr != nil {
+			return
+		}
+		loadConfigErr = config.LoadConfig("dev")
+	})
+	require.NoError(t, loadConfigErr)
+	require.NoError(t, dbaccess.Initialize())
+	t.Cleanup(dbaccess.Close)
+}
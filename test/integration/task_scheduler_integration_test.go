@@ -0,0 +1,74 @@
+package pubsub_integration_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roackb2/lucid/internal/pkg/agents/worker"
+	"github.com/roackb2/lucid/internal/pkg/control_plane"
+	"github.com/roackb2/lucid/internal/pkg/dbaccess"
+	"github.com/roackb2/lucid/internal/pkg/utils"
+	mock_control_plane "github.com/roackb2/lucid/test/_mocks/control_plane"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTaskScheduler_PostponesRunsTheAgentCannotTake(t *testing.T) {
+	setupDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The node never has room for the run
+	ctrl := gomock.NewController(t)
+	controlPlane := mock_control_plane.NewMockControlPlane(ctrl)
+	var kickoffs atomic.Int32
+	controlPlane.EXPECT().KickoffTask(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, task string, role string, metadata worker.TaskMetadata) (string, *control_plane.TaskHandle, error) {
+			kickoffs.Add(1)
+			return "", nil, control_plane.ErrRunQueueFull
+		}).
+		AnyTimes()
+
+	grace := 2 * time.Second
+	taskScheduler := control_plane.NewTaskScheduler(control_plane.TaskSchedulerConfig{
+		ScanInterval:   100 * time.Millisecond,
+		MissedRunGrace: grace,
+	}, controlPlane)
+	schedule, err := taskScheduler.CreateSchedule(ctx, control_plane.ScheduleDefinition{
+		Owner:           "alice",
+		Role:            "consumer",
+		TaskTemplate:    "Summarize new posts",
+		Interval:        time.Hour,
+		MissedRunPolicy: control_plane.MissedRunPolicySkip,
+	})
+	require.NoError(t, err)
+	defer taskScheduler.PauseSchedule(context.Background(), schedule.ScheduleID)
+
+	// Make the first run due now
+	dueAt := time.Now().UTC()
+	_, err = dbaccess.Querier.UpdateAgentScheduleStatus(ctx, dbaccess.UpdateAgentScheduleStatusParams{
+		Status:     control_plane.ScheduleStatusActive,
+		NextRunAt:  utils.ConvertToPgTimestamp(&dueAt),
+		ScheduleID: schedule.ScheduleID,
+	})
+	require.NoError(t, err)
+
+	go taskScheduler.Start(ctx)
+
+	// The run stays due and is fired again on the next scans
+	require.Eventually(t, func() bool { return kickoffs.Load() >= 2 }, grace, 50*time.Millisecond)
+	row, err := dbaccess.Querier.GetAgentSchedule(ctx, schedule.ScheduleID)
+	require.NoError(t, err)
+	require.WithinDuration(t, dueAt, row.NextRunAt.Time, time.Millisecond)
+
+	// Once later than the grace, the skip policy drops the run and records it
+	require.Eventually(t, func() bool {
+		row, err = dbaccess.Querier.GetAgentSchedule(ctx, schedule.ScheduleID)
+		return err == nil && strings.HasPrefix(row.LastResult.String, "Skipped the run due at")
+	}, 3*grace, 100*time.Millisecond)
+	require.True(t, row.NextRunAt.Time.After(time.Now().Add(30*time.Minute)), "next run at %s", row.NextRunAt.Time)
+}